@@ -1,15 +1,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/boolean-maybe/navidown/loaders"
 	"github.com/boolean-maybe/navidown/navidown"
+	"github.com/boolean-maybe/navidown/navidown/theme"
 	tviewAdapter "github.com/boolean-maybe/navidown/navidown/tview"
 	"github.com/boolean-maybe/navidown/util"
 	"github.com/gdamore/tcell/v2"
@@ -37,12 +42,59 @@ func main() {
 		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCache(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "cache: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
 	// parse flags
+	themeFile := flag.String("theme-file", "", "JSON or TOML theme file: a full style config, or inherit/colors/style layers over a built-in theme")
+	hyperlinks := flag.Bool("hyperlinks", false, "make links clickable in terminals that support OSC 8 hyperlinks")
+	hscrollFlag := flag.String("hscroll", "off", "horizontal scrolling of wide tables and code blocks: off (fit to width), document, or block (shift+left/right or h/l scroll)")
+	logicalOrder := flag.Bool("logical-order", false, "leave right-to-left text in logical order, for terminals that reorder bidirectional text themselves")
+	colorFlag := flag.String("color", "auto", "color profile: auto (from NO_COLOR, COLORTERM, TERM), truecolor, 256, 16, or none")
 	syntaxTheme := flag.String("syntax-theme", "", "chroma style name for code block syntax highlighting (e.g. dracula, monokai, catppuccin-macchiato)")
 	syntaxBg := flag.String("syntax-background", "", "background color for code blocks (e.g. #282a36, 236)")
 	syntaxBorder := flag.String("syntax-border", "", "border color for code blocks (e.g. #6272a4, 244)")
+	offline := flag.Bool("offline", false, "serve cached remote documents and images when the network is unavailable")
+	noCache := flag.Bool("no-cache", false, "disable the on-disk HTTP cache for remote documents and images")
+	useNetrc := flag.Bool("netrc", false, "authenticate remote fetches with credentials from ~/.netrc (or $NETRC)")
+	openWith := flag.String("open-with", "", "command for opening links externally, with {url} for the link (default: $BROWSER, then xdg-open/open)")
+	openHTML := flag.Bool("open-html", false, "open links to HTML pages in the external browser instead of converting them")
+	var forgeRules []navidown.ForgeRule
+	flag.Func("forge", "`kind=host`: treat host as a self-hosted forge (kind: github, gitlab, gitea) so blob/tree links fetch raw markdown (repeatable)", func(v string) error {
+		rule, err := parseForgeFlag(v)
+		if err != nil {
+			return err
+		}
+		forgeRules = append(forgeRules, rule)
+		return nil
+	})
+	var tokenRules navidown.StaticCredentials
+	flag.Func("token", "`host=ENV_VAR`: send the bearer token in ENV_VAR to host over https (repeatable; host may be *.domain, or a URL prefix such as http://intranet/ to allow http)", func(v string) error {
+		rule, err := parseTokenFlag(v)
+		if err != nil {
+			return err
+		}
+		tokenRules = append(tokenRules, rule)
+		return nil
+	})
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s [flags] <file-path-or-url>\n\nflags:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <file-dir-or-url>\n       %s export [flags] <file-or-dir>\n\nflags:\n", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -54,8 +106,50 @@ func main() {
 
 	arg := flag.Arg(0)
 
+	// shared on-disk HTTP cache for remote documents and images
+	var httpCache *navidown.HTTPCache
+	if !*noCache {
+		httpCache = navidown.NewHTTPCache(navidown.HTTPCacheOptions{Offline: *offline})
+		defer httpCache.Close()
+	}
+
+	// per-host credentials for private documents and images
+	var creds navidown.CredentialResolver
+	if len(tokenRules) > 0 || *useNetrc {
+		var netrc navidown.CredentialResolver
+		if *useNetrc {
+			netrc = &navidown.NetrcCredentials{}
+		}
+		creds = navidown.ChainCredentials(tokenRules, netrc)
+	}
+
+	// map forge blob/tree links to raw markdown
+	rewriter := navidown.NewForgeRewriter(forgeRules...)
+
+	// content fetcher for the initial document and link navigation
+	provider := &loaders.FileHTTP{SearchRoots: []string{"."}, Cache: httpCache, Credentials: creds, Rewriter: rewriter}
+
+	// route mailto:, PDFs, etc. (and optionally HTML pages) to an external opener
+	dispatcher, err := newLinkDispatcher(provider, *openWith, *openHTML)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -open-with: %v\n", err)
+		os.Exit(1)
+	}
+
+	colorProfile, err := navidown.ParseColorProfile(*colorFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -color: %v\n", err)
+		os.Exit(1)
+	}
+
+	hscroll, err := navidown.ParseHScrollMode(*hscrollFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -hscroll: %v\n", err)
+		os.Exit(1)
+	}
+
 	// load initial content
-	content, sourcePath, err := loadContent(arg)
+	content, sourcePath, err := loadContent(provider, arg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error loading content: %v\n", err)
 		os.Exit(1)
@@ -68,31 +162,45 @@ func main() {
 	mdViewer := tviewAdapter.NewTextView()
 
 	// set custom ANSI converter for proper background color support
-	mdViewer.SetAnsiConverter(util.NewAnsiConverter(true))
+	converter := util.NewAnsiConverter(true)
+	converter.SetColorLevel(util.ColorLevel(colorProfile))
+	mdViewer.SetAnsiConverter(converter)
 
 	// enable Kitty image protocol support
 	imgResolver := navidown.NewImageResolver([]string{"."})
 	imgResolver.SetDarkMode(navidown.IsDarkBackground())
+	imgResolver.SetHTTPCache(httpCache)
+	imgResolver.SetCredentials(creds)
+	imgResolver.SetURLRewriter(rewriter)
 	imgManager := tviewAdapter.NewImageManager(imgResolver, 8, 16)
 	// Allow images to take up to their natural size (0 = no limit)
 	imgManager.SetMaxRows(0)
 	imgManager.SetSupported(true)
 	mdViewer.SetImageManager(imgManager)
 
-	// apply syntax highlighting overrides if specified
-	if *syntaxTheme != "" || *syntaxBg != "" || *syntaxBorder != "" {
-		renderer := navidown.NewANSIRenderer()
-		if *syntaxTheme != "" {
-			renderer = renderer.WithCodeTheme(*syntaxTheme)
-		}
-		if *syntaxBg != "" {
-			renderer = renderer.WithCodeBackground(*syntaxBg)
-		}
-		if *syntaxBorder != "" {
-			renderer = renderer.WithCodeBorder(*syntaxBorder)
+	// apply the theme file, syntax highlighting overrides, color profile, and hyperlinks
+	renderer := navidown.NewANSIRenderer()
+	if *themeFile != "" {
+		renderer, err = navidown.NewANSIRendererFromThemeFile(*themeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -theme-file: %v\n", err)
+			os.Exit(1)
 		}
-		mdViewer.Core().SetRenderer(renderer)
 	}
+	if *syntaxTheme != "" {
+		renderer = renderer.WithCodeTheme(*syntaxTheme)
+	}
+	if *syntaxBg != "" {
+		renderer = renderer.WithCodeBackground(*syntaxBg)
+	}
+	if *syntaxBorder != "" {
+		renderer = renderer.WithCodeBorder(*syntaxBorder)
+	}
+	mdViewer.Core().SetRenderer(renderer.WithColorProfile(colorProfile).WithHyperlinks(*hyperlinks))
+
+	// render wide tables and code blocks at full width and scroll them sideways
+	mdViewer.Core().SetHorizontalScroll(hscroll)
+	mdViewer.Core().SetLogicalOrder(*logicalOrder)
 
 	// enable mermaid diagram rendering (requires mmdc in PATH)
 	mdViewer.Core().SetMermaidOptions(&navidown.MermaidOptions{})
@@ -100,55 +208,29 @@ func main() {
 	// enable graphviz diagram rendering (requires dot in PATH)
 	mdViewer.Core().SetGraphvizOptions(&navidown.GraphvizOptions{})
 
-	// set up content fetcher for link navigation
-	provider := &loaders.FileHTTP{SearchRoots: []string{"."}}
+	// enable plantuml diagram rendering (requires plantuml in PATH)
+	mdViewer.Core().Diagrams().Register("plantuml",
+		navidown.NewPlantUMLRenderer(navidown.PlantUMLOptions{}), navidown.PlantUMLLanguages...)
 
-	// wire up link activation handler - manually fetch and update through adapter
-	mdViewer.SetSelectHandler(func(v *tviewAdapter.TextViewViewer, elem navidown.NavElement) {
-		if elem.Type != navidown.NavElementURL {
-			return
-		}
-
-		// handle internal anchor links (same file)
-		if elem.IsInternalLink() {
-			v.ScrollToAnchor(elem.AnchorTarget(), true)
-			return
-		}
-
-		// parse fragment from URL
-		path, fragment := splitFragment(elem.URL)
+	// enable d2 diagram rendering (requires d2 in PATH)
+	mdViewer.Core().Diagrams().Register("d2", navidown.NewD2Renderer(navidown.D2Options{}), navidown.D2Languages...)
 
-		// create elem for fetching (without fragment)
-		elemForFetch := elem
-		elemForFetch.URL = path
+	// show diagrams that fail to render as an error box, and log why
+	mdViewer.Core().SetDiagramFailureMode(navidown.DiagramFailureError)
+	mdViewer.Core().SetDiagramErrorHandler(func(e navidown.DiagramError) {
+		slog.Debug("diagram failed", "language", e.Language, "line", e.Line, "error", e.Err)
+	})
 
-		// fetch content for external links
-		content, err := provider.FetchContent(elemForFetch)
-		if err != nil {
+	// follow links: same-file anchors scroll, mailto:, PDFs, etc. open
+	// externally, and everything else is fetched and shown
+	fetcher := navidown.NewContentFetcher(provider, []string{"."})
+	fetcher.SetURLRewriter(rewriter)
+	fetcher.SetLinkDispatcher(dispatcher)
+	mdViewer.SetSelectHandler(func(v *tviewAdapter.TextViewViewer, elem navidown.NavElement) {
+		err := v.FollowLink(fetcher, elem)
+		if err != nil && !errors.Is(err, navidown.ErrNotLink) && !errors.Is(err, navidown.ErrEmptyContent) {
 			errorContent := "# Error\n\nFailed to load `" + elem.URL + "`:\n\n```\n" + err.Error() + "\n```"
 			v.SetMarkdownWithSource(errorContent, elem.SourceFilePath, true)
-			return
-		}
-
-		if content == "" {
-			return
-		}
-
-		// resolve path (use path without fragment)
-		newSourcePath := path
-		if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") && elem.SourceFilePath != "" {
-			resolved, rerr := navidown.ResolveMarkdownPath(path, elem.SourceFilePath, []string{"."})
-			if rerr == nil && resolved != "" {
-				newSourcePath = resolved
-			}
-		}
-
-		// update through adapter (this will refresh display)
-		v.SetMarkdownWithSource(content, newSourcePath, true)
-
-		// scroll to anchor after load
-		if fragment != "" {
-			v.ScrollToAnchor(fragment, false)
 		}
 	})
 
@@ -180,7 +262,17 @@ func main() {
 			app.Stop()
 			return nil
 		case 'r':
-			refreshContent(app, mdViewer)
+			refreshContent(app, mdViewer, provider)
+			return nil
+		case 'e':
+			editContent(app, mdViewer, provider)
+			return nil
+		case 'o':
+			if sel := mdViewer.Core().Selected(); sel != nil && sel.Type == navidown.NavElementURL {
+				if err := dispatcher.OpenExternal(*sel); err != nil {
+					statusBar.SetText(fmt.Sprintf(" [red]failed to open %s: %s[-]", tview.Escape(sel.URL), tview.Escape(err.Error())))
+				}
+			}
 			return nil
 		}
 		return event
@@ -194,15 +286,14 @@ func main() {
 }
 
 // loadContent loads content from a file path or URL.
-func loadContent(arg string) (content string, sourcePath string, err error) {
+func loadContent(provider *loaders.FileHTTP, arg string) (content string, sourcePath string, err error) {
 	// check if it's an HTTP(S) URL
 	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
 		if isImageFile(arg) {
 			return fmt.Sprintf("![%s](%s)\n", filepath.Base(arg), arg), arg, nil
 		}
-		provider := &loaders.FileHTTP{}
 		content, err := provider.FetchContent(navidown.NavElement{URL: arg})
-		return content, arg, err
+		return content, navidown.RewriteSourceURL(provider.Rewriter, arg), err
 	}
 
 	// local file
@@ -215,11 +306,22 @@ func loadContent(arg string) (content string, sourcePath string, err error) {
 		return fmt.Sprintf("![%s](%s)\n", filepath.Base(absPath), absPath), absPath, nil
 	}
 
+	// directories show their README/index or a generated listing
+	if info, serr := os.Stat(absPath); serr == nil && info.IsDir() {
+		content, err := loaders.DirectoryIndex(absPath)
+		return content, navidown.DirectorySourcePath(absPath), err
+	}
+
 	data, err := os.ReadFile(absPath) // #nosec G703 -- path is user's own CLI argument
 	if err != nil {
 		return "", "", fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// source files are shown syntax highlighted
+	if md, ok := loaders.SourceAsMarkdown(absPath, data); ok {
+		return md, absPath, nil
+	}
+
 	return string(data), absPath, nil
 }
 
@@ -250,15 +352,15 @@ func updateStatusBar(statusBar *tview.TextView, v *tviewAdapter.TextViewViewer)
 	} else {
 		status += "[gray]▶[-]"
 	}
-	status += fmt.Sprintf(" | Scroll:[%s]j/k[-] Top/End:[%s]g/G[-] Refresh:[%s]r[-] Quit:[%s]q[-]", keyColor, keyColor, keyColor, keyColor)
+	status += fmt.Sprintf(" | Scroll:[%s]j/k[-] Top/End:[%s]g/G[-] Open:[%s]o[-] Edit:[%s]e[-] Refresh:[%s]r[-] Quit:[%s]q[-]", keyColor, keyColor, keyColor, keyColor, keyColor, keyColor)
 
 	statusBar.SetText(status)
 }
 
 // refreshContent clears all caches, re-reads the current file from disk, and re-renders.
-func refreshContent(app *tview.Application, v *tviewAdapter.TextViewViewer) {
+func refreshContent(app *tview.Application, v *tviewAdapter.TextViewViewer, provider *loaders.FileHTTP) {
 	srcPath := v.Core().SourceFilePath()
-	content, sourcePath, err := loadContent(srcPath)
+	content, sourcePath, err := loadContent(provider, srcPath)
 	if err != nil {
 		content = "# Error\n\nFailed to reload `" + srcPath + "`:\n\n```\n" + err.Error() + "\n```"
 		sourcePath = srcPath
@@ -273,8 +375,300 @@ func refreshContent(app *tview.Application, v *tviewAdapter.TextViewViewer) {
 	})
 }
 
-// splitFragment separates a URL into path and fragment components.
-func splitFragment(url string) (path, fragment string) {
-	path, fragment, _ = strings.Cut(url, "#")
-	return path, fragment
+// editContent opens the current file in the user's editor at the line being
+// read, suspending the application, and reloads it on return keeping the
+// reading position. Documents that are not local files are left alone.
+func editContent(app *tview.Application, v *tviewAdapter.TextViewViewer, provider *loaders.FileHTTP) {
+	path, line, ok := v.Core().EditPosition()
+	if !ok {
+		return
+	}
+	cmd, err := navidown.EditorCommand(path, line)
+	if err != nil {
+		return
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	var runErr error
+	app.Suspend(func() { runErr = cmd.Run() })
+	if runErr != nil {
+		slog.Debug("editor failed", "path", path, "error", runErr)
+	}
+
+	content, _, err := loadContent(provider, path)
+	if err != nil {
+		content = "# Error\n\nFailed to reload `" + path + "`:\n\n```\n" + err.Error() + "\n```"
+	}
+	if content == v.Core().Markdown() {
+		return
+	}
+
+	// use a one-shot before-draw to get the screen for Kitty image purge
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		v.InvalidateForDocument(screen)
+		if err := v.Reload(content); err != nil {
+			errorContent := "# Error\n\nFailed to reload `" + path + "`:\n\n```\n" + err.Error() + "\n```"
+			v.SetMarkdownWithSource(errorContent, path, false)
+		}
+		app.SetBeforeDrawFunc(nil)
+		return false
+	})
+}
+
+// newLinkDispatcher builds the dispatcher deciding which links open outside
+// the viewer. openWith overrides the system opener; openHTML sends text/html
+// pages to it instead of converting them.
+func newLinkDispatcher(provider *loaders.FileHTTP, openWith string, openHTML bool) (*navidown.LinkDispatcher, error) {
+	var rules []navidown.LinkRule
+	if openHTML {
+		rules = append(rules, navidown.LinkRule{
+			Schemes:      []string{"http", "https"},
+			ContentTypes: []string{"text/html", "application/xhtml+xml"},
+			Action:       navidown.LinkOpenExternal,
+		})
+	}
+	d := navidown.NewLinkDispatcher(rules...)
+	d.Probe = provider.ContentType
+	d.SearchRoots = provider.SearchRoots
+	d.Policy = provider.Policy
+	if openWith != "" {
+		opener, err := navidown.ParseCommandOpener(openWith)
+		if err != nil {
+			return nil, err
+		}
+		d.Opener = opener
+	}
+	return d, nil
+}
+
+// parseForgeFlag parses a -forge value of the form kind=host.
+func parseForgeFlag(v string) (navidown.ForgeRule, error) {
+	kind, host, ok := strings.Cut(v, "=")
+	host = strings.TrimSpace(host)
+	if !ok || host == "" {
+		return navidown.ForgeRule{}, fmt.Errorf("expected kind=host, got %q", v)
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "github":
+		return navidown.ForgeRule{Kind: navidown.ForgeGitHub, Host: host}, nil
+	case "gitlab":
+		return navidown.ForgeRule{Kind: navidown.ForgeGitLab, Host: host}, nil
+	case "gitea", "forgejo":
+		return navidown.ForgeRule{Kind: navidown.ForgeGitea, Host: host}, nil
+	}
+	return navidown.ForgeRule{}, fmt.Errorf("unknown forge kind %q (want github, gitlab, or gitea)", kind)
+}
+
+// parseTokenFlag parses a -token value of the form host=ENV_VAR.
+func parseTokenFlag(v string) (navidown.Credential, error) {
+	match, env, ok := strings.Cut(v, "=")
+	match, env = strings.TrimSpace(match), strings.TrimSpace(env)
+	if !ok || match == "" || env == "" {
+		return navidown.Credential{}, fmt.Errorf("expected host=ENV_VAR, got %q", v)
+	}
+	return navidown.Credential{Match: match, BearerTokenEnv: env}, nil
+}
+
+// runExport implements "export": it converts a markdown file (to -o, or
+// stdout) or a directory tree (to the -o directory) to HTML or plain text.
+func runExport(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "html", "output format: html or text")
+	out := fs.String("o", "", "output file, or output directory when exporting a directory (default: stdout for a file)")
+	themeFile := fs.String("theme-file", "", "JSON or TOML theme file for the HTML page colors (default: dark)")
+	width := fs.Int("width", 80, "word wrap for text output")
+	noDiagrams := fs.Bool("no-diagrams", false, "keep mermaid, graphviz, plantuml and d2 blocks as code instead of rendering them to images")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s export [flags] <file-or-dir>\n\nflags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one file or directory")
+	}
+	src := fs.Arg(0)
+
+	exportFormat, err := navidown.ParseExportFormat(*format)
+	if err != nil {
+		return err
+	}
+	opts := navidown.ExportOptions{Format: exportFormat, Width: *width}
+	// diagrams become images in HTML pages; plain text keeps their source
+	diagrams := !*noDiagrams && exportFormat == navidown.ExportHTML
+	if diagrams {
+		opts.Mermaid = &navidown.MermaidOptions{}
+		opts.Graphviz = &navidown.GraphvizOptions{}
+	}
+	if *themeFile != "" {
+		if opts.Theme, err = theme.Load(*themeFile); err != nil {
+			return fmt.Errorf("invalid -theme-file: %w", err)
+		}
+	}
+	exporter := navidown.NewExporter(opts)
+	defer exporter.Close()
+	if diagrams {
+		exporter.Diagrams().Register("plantuml",
+			navidown.NewPlantUMLRenderer(navidown.PlantUMLOptions{}), navidown.PlantUMLLanguages...)
+		exporter.Diagrams().Register("d2", navidown.NewD2Renderer(navidown.D2Options{}), navidown.D2Languages...)
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if *out == "" {
+			return errors.New("-o is required when exporting a directory")
+		}
+		written, err := exporter.ExportTree(src, *out)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stderr, "exported %d files to %s\n", len(written), *out)
+		return nil
+	}
+	if *out != "" {
+		return exporter.ExportFile(src, *out)
+	}
+
+	data, err := os.ReadFile(src) // #nosec G304 -- export source is chosen by the user
+	if err != nil {
+		return err
+	}
+	page, err := exporter.Export(string(data), src)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdout, page)
+	return err
+}
+
+// runCache implements "cache stats" and "cache prune": it reports on or
+// bounds the disk cache of rendered diagrams and images.
+func runCache(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("cache", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", "", "cache directory (default: the user cache dir's navidown directory)")
+	maxSize := fs.String("max-size", "", "prune to this total size, e.g. 200M or 1G (default: 512M)")
+	maxAge := fs.String("max-age", "", "prune files unused for this long, e.g. 7d or 12h (default: 30d)")
+	reset := fs.Bool("reset", false, "stats: set the hit, miss and eviction counts to zero")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s cache [flags] stats|prune\n\nflags:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "stats" && fs.Arg(0) != "prune") {
+		fs.Usage()
+		return errors.New("expected stats or prune")
+	}
+
+	var opts navidown.CacheOptions
+	var err error
+	if *maxSize != "" {
+		if opts.MaxSize, err = parseByteSize(*maxSize); err != nil {
+			return fmt.Errorf("invalid -max-size: %w", err)
+		}
+	}
+	if *maxAge != "" {
+		if opts.MaxAge, err = parseAge(*maxAge); err != nil {
+			return fmt.Errorf("invalid -max-age: %w", err)
+		}
+	}
+	manager := navidown.SharedCacheManager()
+	if *dir != "" {
+		manager = navidown.NewCacheManager(*dir, opts)
+	}
+	manager.SetOptions(opts)
+
+	if fs.Arg(0) == "prune" {
+		result, err := manager.Prune()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "removed %d files (%s), %d files (%s) remain\n",
+			result.Removed, formatByteSize(result.Freed), result.Remaining, formatByteSize(result.Bytes))
+		return nil
+	}
+
+	stats, err := manager.Stats()
+	if err != nil {
+		return err
+	}
+	for _, d := range stats.Dirs {
+		fmt.Fprintf(stdout, "%-8s %6d files %10s  %s\n", filepath.Base(d.Dir), d.Files, formatByteSize(d.Bytes), d.Dir)
+	}
+	fmt.Fprintf(stdout, "total    %6d files %10s\n", stats.Files, formatByteSize(stats.Bytes))
+	lookups := stats.Hits + stats.Misses
+	ratio := 0.0
+	if lookups > 0 {
+		ratio = float64(stats.Hits) / float64(lookups) * 100
+	}
+	fmt.Fprintf(stdout, "hits %d, misses %d (%.0f%% hit rate), evictions %d\n", stats.Hits, stats.Misses, ratio, stats.Evictions)
+	if stats.LastPrune.IsZero() {
+		fmt.Fprintln(stdout, "never pruned")
+	} else {
+		fmt.Fprintf(stdout, "last pruned %s\n", stats.LastPrune.Local().Format(time.DateTime))
+	}
+	if *reset {
+		return manager.ResetStats()
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as 1048576, 512K, 200M or 1G (powers of
+// 1024); a negative size means unbounded.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	unit := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a size: %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseAge parses a duration as time.ParseDuration does, also taking days
+// such as 7d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(strings.TrimSpace(s), "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("not a duration: %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatByteSize formats n bytes for display, such as 3.2M.
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
 }
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/boolean-maybe/navidown/loaders"
+	"github.com/boolean-maybe/navidown/navidown"
 )
 
 func TestIsImageFile(t *testing.T) {
@@ -53,7 +58,7 @@ func TestLoadContentImage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	content, sourcePath, err := loadContent(imgPath)
+	content, sourcePath, err := loadContent(&loaders.FileHTTP{}, imgPath)
 	if err != nil {
 		t.Fatalf("loadContent returned error: %v", err)
 	}
@@ -78,7 +83,7 @@ func TestLoadContentMarkdown(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	content, _, err := loadContent(mdPath)
+	content, _, err := loadContent(&loaders.FileHTTP{}, mdPath)
 	if err != nil {
 		t.Fatalf("loadContent returned error: %v", err)
 	}
@@ -87,3 +92,158 @@ func TestLoadContentMarkdown(t *testing.T) {
 		t.Errorf("expected raw markdown content %q, got %q", mdContent, content)
 	}
 }
+
+func TestParseTokenFlag(t *testing.T) {
+	rule, err := parseTokenFlag("docs.example.com=DOCS_TOKEN")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Match != "docs.example.com" || rule.BearerTokenEnv != "DOCS_TOKEN" {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+
+	for _, bad := range []string{"", "host", "=VAR", "host="} {
+		if _, err := parseTokenFlag(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestParseForgeFlag(t *testing.T) {
+	rule, err := parseForgeFlag("gitlab=git.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Kind != navidown.ForgeGitLab || rule.Host != "git.example.com" {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+
+	for _, bad := range []string{"", "gitlab", "svn=host", "github="} {
+		if _, err := parseForgeFlag(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestLoadContentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "guide.md"), []byte("# Guide\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content, sourcePath, err := loadContent(&loaders.FileHTTP{}, dir)
+	if err != nil {
+		t.Fatalf("loadContent returned error: %v", err)
+	}
+	if want := navidown.DirectorySourcePath(dir); sourcePath != want {
+		t.Errorf("sourcePath = %q, want %q", sourcePath, want)
+	}
+	if !strings.Contains(content, "[Guide](guide.md)") {
+		t.Errorf("expected generated listing, got: %q", content)
+	}
+}
+
+func TestNewLinkDispatcher(t *testing.T) {
+	provider := &loaders.FileHTTP{SearchRoots: []string{"."}}
+	if _, err := newLinkDispatcher(provider, "   ", false); err == nil {
+		t.Error("expected error for blank -open-with command")
+	}
+
+	d, err := newLinkDispatcher(provider, "firefox {url}", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Rules) != len(navidown.DefaultLinkRules)+1 || d.Probe == nil {
+		t.Errorf("-open-html should add a probed content-type rule, got %d rules", len(d.Rules))
+	}
+	if o, ok := d.Opener.(navidown.CommandOpener); !ok || o.Args[0] != "firefox" {
+		t.Errorf("opener = %#v", d.Opener)
+	}
+}
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(src, []byte("# Doc\n\nSee [next](next.md).\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := runExport([]string{"-format", "text", src}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stdout.String(), "# Doc") || strings.Contains(stdout.String(), "\x1b") {
+		t.Errorf("text export = %q", stdout.String())
+	}
+
+	out := filepath.Join(dir, "site")
+	if err := runExport([]string{"-o", out, dir}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filepath.Join(out, "doc.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `href="next.html"`) {
+		t.Errorf("exported page lacks the rewritten link:\n%s", page)
+	}
+
+	if err := runExport([]string{dir}, &stdout, &stderr); err == nil {
+		t.Error("expected error exporting a directory without -o")
+	}
+	if err := runExport([]string{"-format", "pdf", src}, &stdout, &stderr); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	dir := t.TempDir()
+	svg := filepath.Join(dir, "svg")
+	if err := os.MkdirAll(svg, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	png := filepath.Join(svg, strings.Repeat("a", 64)+".png")
+	if err := os.WriteFile(png, make([]byte, 2048), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := runCache([]string{"-dir", dir, "stats"}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "1 files       2.0K") {
+		t.Errorf("stats = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := runCache([]string{"-dir", dir, "-max-size", "1K", "prune"}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stdout.String(), "removed 1 files (2.0K)") {
+		t.Errorf("prune = %q", stdout.String())
+	}
+	if _, err := os.Stat(png); !os.IsNotExist(err) {
+		t.Error("pruned file still on disk")
+	}
+
+	if err := runCache([]string{"-dir", dir, "clean"}, &stdout, &stderr); err == nil {
+		t.Error("expected error for unknown command")
+	}
+	if err := runCache([]string{"-max-age", "soon", "prune"}, &stdout, &stderr); err == nil {
+		t.Error("expected error for invalid -max-age")
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{"1024": 1024, "512K": 512 << 10, "200MB": 200 << 20, "1.5GiB": 3 << 29, "-1": -1} {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := parseByteSize("lots"); err == nil {
+		t.Error("expected error")
+	}
+	if got, err := parseAge("7d"); err != nil || got != 7*24*time.Hour {
+		t.Errorf("parseAge(7d) = %v, %v", got, err)
+	}
+}
@@ -3,6 +3,7 @@ package ansi
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"strings"
 	"sync"
@@ -18,7 +19,7 @@ import (
 )
 
 const (
-	// The chroma style theme name used for rendering.
+	// The prefix of the chroma style names registered for style configs.
 	chromaStyleTheme = "charm"
 
 	// The chroma formatter name used for rendering.
@@ -41,6 +42,8 @@ var mutex = sync.Mutex{}
 type CodeBlockElement struct {
 	Code     string
 	Language string
+
+	attrs fenceAttrs // line numbers, highlighted lines, title
 }
 
 func chromaStyle(style StylePrimitive) string {
@@ -77,6 +80,43 @@ func chromaStyle(style StylePrimitive) string {
 	return s
 }
 
+// ChromaStyleEntries returns the chroma style entries for c.
+func ChromaStyleEntries(c *Chroma) chroma.StyleEntries {
+	return chroma.StyleEntries{
+		chroma.Text:                chromaStyle(c.Text),
+		chroma.Error:               chromaStyle(c.Error),
+		chroma.Comment:             chromaStyle(c.Comment),
+		chroma.CommentPreproc:      chromaStyle(c.CommentPreproc),
+		chroma.Keyword:             chromaStyle(c.Keyword),
+		chroma.KeywordReserved:     chromaStyle(c.KeywordReserved),
+		chroma.KeywordNamespace:    chromaStyle(c.KeywordNamespace),
+		chroma.KeywordType:         chromaStyle(c.KeywordType),
+		chroma.Operator:            chromaStyle(c.Operator),
+		chroma.Punctuation:         chromaStyle(c.Punctuation),
+		chroma.Name:                chromaStyle(c.Name),
+		chroma.NameBuiltin:         chromaStyle(c.NameBuiltin),
+		chroma.NameTag:             chromaStyle(c.NameTag),
+		chroma.NameAttribute:       chromaStyle(c.NameAttribute),
+		chroma.NameClass:           chromaStyle(c.NameClass),
+		chroma.NameConstant:        chromaStyle(c.NameConstant),
+		chroma.NameDecorator:       chromaStyle(c.NameDecorator),
+		chroma.NameException:       chromaStyle(c.NameException),
+		chroma.NameFunction:        chromaStyle(c.NameFunction),
+		chroma.NameOther:           chromaStyle(c.NameOther),
+		chroma.Literal:             chromaStyle(c.Literal),
+		chroma.LiteralNumber:       chromaStyle(c.LiteralNumber),
+		chroma.LiteralDate:         chromaStyle(c.LiteralDate),
+		chroma.LiteralString:       chromaStyle(c.LiteralString),
+		chroma.LiteralStringEscape: chromaStyle(c.LiteralStringEscape),
+		chroma.GenericDeleted:      chromaStyle(c.GenericDeleted),
+		chroma.GenericEmph:         chromaStyle(c.GenericEmph),
+		chroma.GenericInserted:     chromaStyle(c.GenericInserted),
+		chroma.GenericStrong:       chromaStyle(c.GenericStrong),
+		chroma.GenericSubheading:   chromaStyle(c.GenericSubheading),
+		chroma.Background:          chromaStyle(c.Background),
+	}
+}
+
 // buildBorder builds a horizontal border line with optional language label.
 // cornerLeft/cornerRight are the corner characters (e.g., "╭"/"╮" or "╰"/"╯").
 func buildBorder(cornerLeft, cornerRight, label string, width int) string {
@@ -105,40 +145,69 @@ func buildBorder(cornerLeft, cornerRight, label string, width int) string {
 	return cornerLeft + strings.Repeat("─", inner) + cornerRight
 }
 
+// codeLineDecor decorates the lines of a code block with a gutter (line
+// numbers or highlight marks) and per-line backgrounds.
+type codeLineDecor struct {
+	gutters     []string // rendered gutter of each line
+	gutterWidth int
+	backgrounds []string // background escape of each line; "" keeps the block's
+}
+
+func (d codeLineDecor) hasBackgrounds() bool {
+	for _, bg := range d.backgrounds {
+		if bg != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // writeCodeLines pads each code line to innerWidth and wraps with side borders.
 func writeCodeLines(w io.Writer, lines []string, innerWidth int,
 	marginPrefix, bgEscape string, hasBorders bool,
-	profile termenv.Profile, borderStyle StylePrimitive) {
+	profile termenv.Profile, borderStyle StylePrimitive, decor codeLineDecor) {
+
+	textWidth := innerWidth
+	if textWidth > 0 {
+		textWidth = max(innerWidth-decor.gutterWidth, 1)
+	}
 
 	var lineBuf bytes.Buffer
-	for _, line := range lines {
+	for i, line := range lines {
 		lineBuf.Reset()
 		visWidth := ansi.PrintableRuneWidth(line)
-		if innerWidth > 0 && visWidth > innerWidth {
-			line = xansi.Truncate(line, innerWidth, "")
-			visWidth = innerWidth
+		if textWidth > 0 && visWidth > textWidth {
+			line = xansi.Truncate(line, textWidth, "")
+			visWidth = textWidth
 		}
-		pad := innerWidth - visWidth
+		pad := textWidth - visWidth
 		if pad < 0 {
 			pad = 0
 		}
+		bg := bgEscape
+		if i < len(decor.backgrounds) && decor.backgrounds[i] != "" {
+			bg = decor.backgrounds[i]
+		}
 
 		lineBuf.WriteString(marginPrefix)
 		if hasBorders {
 			renderText(&lineBuf, profile, borderStyle, "│")
 		}
-		if bgEscape != "" {
-			lineBuf.WriteString(bgEscape)
+		if bg != "" {
+			lineBuf.WriteString(bg)
 		}
 		if hasBorders {
 			lineBuf.WriteString(" ")
 		}
+		if i < len(decor.gutters) {
+			lineBuf.WriteString(decor.gutters[i])
+		}
 		lineBuf.WriteString(line)
 		lineBuf.WriteString(strings.Repeat(" ", pad))
 		if hasBorders {
 			lineBuf.WriteString(" ")
 		}
-		if bgEscape != "" {
+		if bg != "" {
 			lineBuf.WriteString(ansiFullReset)
 		}
 		if hasBorders {
@@ -170,50 +239,42 @@ func (e *CodeBlockElement) Render(w io.Writer, ctx RenderContext) error {
 	theme := rules.Theme
 
 	if rules.Chroma != nil && profile != termenv.Ascii {
-		theme = chromaStyleTheme
+		// each distinct color set gets its own registered style, so
+		// switching styles does not reuse the first one's colors
+		entries := ChromaStyleEntries(rules.Chroma)
+		h := fnv.New64a()
+		_, _ = fmt.Fprint(h, entries)
+		theme = fmt.Sprintf("%s-%x", chromaStyleTheme, h.Sum64())
 		mutex.Lock()
 		_, ok := styles.Registry[theme]
 		if !ok {
-			styles.Register(chroma.MustNewStyle(theme,
-				chroma.StyleEntries{
-					chroma.Text:                chromaStyle(rules.Chroma.Text),
-					chroma.Error:               chromaStyle(rules.Chroma.Error),
-					chroma.Comment:             chromaStyle(rules.Chroma.Comment),
-					chroma.CommentPreproc:      chromaStyle(rules.Chroma.CommentPreproc),
-					chroma.Keyword:             chromaStyle(rules.Chroma.Keyword),
-					chroma.KeywordReserved:     chromaStyle(rules.Chroma.KeywordReserved),
-					chroma.KeywordNamespace:    chromaStyle(rules.Chroma.KeywordNamespace),
-					chroma.KeywordType:         chromaStyle(rules.Chroma.KeywordType),
-					chroma.Operator:            chromaStyle(rules.Chroma.Operator),
-					chroma.Punctuation:         chromaStyle(rules.Chroma.Punctuation),
-					chroma.Name:                chromaStyle(rules.Chroma.Name),
-					chroma.NameBuiltin:         chromaStyle(rules.Chroma.NameBuiltin),
-					chroma.NameTag:             chromaStyle(rules.Chroma.NameTag),
-					chroma.NameAttribute:       chromaStyle(rules.Chroma.NameAttribute),
-					chroma.NameClass:           chromaStyle(rules.Chroma.NameClass),
-					chroma.NameConstant:        chromaStyle(rules.Chroma.NameConstant),
-					chroma.NameDecorator:       chromaStyle(rules.Chroma.NameDecorator),
-					chroma.NameException:       chromaStyle(rules.Chroma.NameException),
-					chroma.NameFunction:        chromaStyle(rules.Chroma.NameFunction),
-					chroma.NameOther:           chromaStyle(rules.Chroma.NameOther),
-					chroma.Literal:             chromaStyle(rules.Chroma.Literal),
-					chroma.LiteralNumber:       chromaStyle(rules.Chroma.LiteralNumber),
-					chroma.LiteralDate:         chromaStyle(rules.Chroma.LiteralDate),
-					chroma.LiteralString:       chromaStyle(rules.Chroma.LiteralString),
-					chroma.LiteralStringEscape: chromaStyle(rules.Chroma.LiteralStringEscape),
-					chroma.GenericDeleted:      chromaStyle(rules.Chroma.GenericDeleted),
-					chroma.GenericEmph:         chromaStyle(rules.Chroma.GenericEmph),
-					chroma.GenericInserted:     chromaStyle(rules.Chroma.GenericInserted),
-					chroma.GenericStrong:       chromaStyle(rules.Chroma.GenericStrong),
-					chroma.GenericSubheading:   chromaStyle(rules.Chroma.GenericSubheading),
-					chroma.Background:          chromaStyle(rules.Chroma.Background),
-				}))
+			styles.Register(chroma.MustNewStyle(theme, entries))
 		}
 		mutex.Unlock()
 	}
 
 	width := int(bs.Width(ctx)) //nolint:gosec // terminal width is structurally bounded
 
+	// strip trailing newlines from code — it's a markdown parsing artifact
+	// and would create an empty bordered line at the end
+	code := strings.TrimRight(e.Code, "\r\n")
+	// replace tabs with spaces — tabs cause width miscalculation in padding
+	code = strings.ReplaceAll(code, "\t", "    ")
+
+	codeLines := strings.Split(code, "\n")
+	gutterWidth := e.gutterWidth(len(codeLines), profile)
+
+	// wide blocks grow to fit the longest line instead of truncating it
+	if ctx.options.WideBlocks {
+		natural := longestLine(code) + gutterWidth
+		if profile != termenv.Ascii {
+			natural += 4 // side borders and padding
+		}
+		if natural > width {
+			width = natural
+		}
+	}
+
 	// resolve background color for the code block
 	bgColor := resolveCodeBlockBg(rules, profile)
 
@@ -238,11 +299,30 @@ func (e *CodeBlockElement) Render(w io.Writer, ctx RenderContext) error {
 
 	marginPrefix := strings.Repeat(" ", int(indentation+margin)) //nolint:gosec // terminal indent is structurally bounded
 
+	// wide blocks are buffered and masked so the block wrap leaves them be
+	if ctx.options.WideBlocks {
+		var wide bytes.Buffer
+		out := w
+		w = &wide
+		defer func() {
+			_, _ = io.WriteString(out, ctx.wideBlocks.mask(wide.String()))
+		}()
+	}
+
+	// a title replaces the language in the top border, or gets its own line
+	label := e.Language
+	if e.attrs.title != "" {
+		label = e.attrs.title
+	}
 	if hasBorders {
-		topBorder := buildBorder("╭", "╮", e.Language, width)
+		topBorder := buildBorder("╭", "╮", label, width)
 		_, _ = io.WriteString(w, marginPrefix)
 		renderText(w, profile, borderStyle, topBorder)
 		_, _ = io.WriteString(w, "\n")
+	} else if e.attrs.title != "" {
+		_, _ = io.WriteString(w, marginPrefix)
+		renderText(w, profile, borderStyle, e.attrs.title)
+		_, _ = io.WriteString(w, "\n")
 	}
 
 	// buffer Chroma output so we can process per-line
@@ -252,12 +332,6 @@ func (e *CodeBlockElement) Render(w io.Writer, ctx RenderContext) error {
 		renderText(&codeBuf, profile, bs.Current().Style.StylePrimitive, " ")
 	})
 
-	// strip trailing newlines from code — it's a markdown parsing artifact
-	// and would create an empty bordered line at the end
-	code := strings.TrimRight(e.Code, "\r\n")
-	// replace tabs with spaces — tabs cause width miscalculation in padding
-	code = strings.ReplaceAll(code, "\t", "    ")
-
 	if len(theme) > 0 {
 		renderText(iw, profile, bs.Current().Style.StylePrimitive, rules.BlockPrefix)
 
@@ -282,16 +356,22 @@ func (e *CodeBlockElement) Render(w io.Writer, ctx RenderContext) error {
 		bgEscape = fmt.Sprintf("\x1b[%sm", profile.Color(bgColor).Sequence(true))
 	}
 
+	decor := e.decorate(codeLines, gutterWidth, rules, bgColor, profile)
+
 	// replace full ANSI resets (\x1b[0m) with fg-only + attr-only resets
 	// so the background color set at line start persists across Chroma tokens
 	codeOutput := codeBuf.String()
-	if bgEscape != "" {
+	if bgEscape != "" || decor.hasBackgrounds() {
 		codeOutput = strings.ReplaceAll(codeOutput, ansiFullReset, ansiFgAttrReset)
 	}
 
 	codeOutput = strings.ReplaceAll(codeOutput, "\r\n", "\n")
 	lines := strings.Split(strings.TrimRight(codeOutput, "\n"), "\n")
-	writeCodeLines(w, lines, innerWidth, marginPrefix, bgEscape, hasBorders, profile, borderStyle)
+	// the diff lexer ends with a styled newline, leaving a blank last line
+	for isDiffLanguage(e.Language) && len(lines) > len(codeLines) && strings.TrimSpace(xansi.Strip(lines[len(lines)-1])) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	writeCodeLines(w, lines, innerWidth, marginPrefix, bgEscape, hasBorders, profile, borderStyle, decor)
 
 	if hasBorders {
 		bottomBorder := buildBorder("╰", "╯", "", width)
@@ -317,3 +397,14 @@ func resolveCodeBlockBg(rules StyleCodeBlock, profile termenv.Profile) string {
 	}
 	return ""
 }
+
+// longestLine returns the display width of the widest line of code.
+func longestLine(code string) int {
+	maxW := 0
+	for _, line := range strings.Split(code, "\n") {
+		if w := ansi.PrintableRuneWidth(line); w > maxW {
+			maxW = w
+		}
+	}
+	return maxW
+}
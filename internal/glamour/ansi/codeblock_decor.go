@@ -0,0 +1,184 @@
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/muesli/termenv"
+)
+
+// codeBlockColors are the colors of code block decorations.
+type codeBlockColors struct {
+	lineNumber string // gutter foreground
+	highlight  string // background of highlighted lines
+	inserted   string // background of added diff lines
+	deleted    string // background of removed diff lines
+}
+
+// resolveCodeBlockColors picks decoration colors from the style's chroma
+// colors, or from the named chroma theme (WithCodeTheme), falling back to
+// tints of the block background bg.
+func resolveCodeBlockColors(rules StyleCodeBlock, bg string) codeBlockColors {
+	var c codeBlockColors
+	var insertedFg, deletedFg string
+	switch {
+	case rules.Chroma != nil:
+		c.lineNumber = colorOf(rules.Chroma.Comment.Color)
+		insertedFg = colorOf(rules.Chroma.GenericInserted.Color)
+		deletedFg = colorOf(rules.Chroma.GenericDeleted.Color)
+	case rules.Theme != "":
+		cs := styles.Get(rules.Theme)
+		c.lineNumber = chromaColour(cs, chroma.LineNumbers, false)
+		if c.lineNumber == "" {
+			c.lineNumber = chromaColour(cs, chroma.Comment, false)
+		}
+		c.highlight = chromaColour(cs, chroma.LineHighlight, true)
+		insertedFg = chromaColour(cs, chroma.GenericInserted, false)
+		deletedFg = chromaColour(cs, chroma.GenericDeleted, false)
+	}
+	if c.lineNumber == "" {
+		c.lineNumber = colorOf(rules.Color)
+	}
+	if insertedFg == "" {
+		insertedFg = "#00d787"
+	}
+	if deletedFg == "" {
+		deletedFg = "#fd5b5b"
+	}
+
+	// without a background the terminal's is assumed to be dark
+	if bg == "" {
+		bg = "#000000"
+	}
+	if c.highlight == "" || c.highlight == bg {
+		c.highlight = brightenOrDarken(bg, 0.12)
+	}
+	c.inserted = blendColors(bg, insertedFg, 0.25)
+	c.deleted = blendColors(bg, deletedFg, 0.25)
+	return c
+}
+
+// CodeHighlightBackground returns the escape sequence setting the background
+// of highlighted code lines (the {hl_lines=...} fence attribute) under rules
+// and profile, or "" in the ASCII profile, which has no colors.
+func CodeHighlightBackground(rules StyleCodeBlock, profile termenv.Profile) string {
+	if profile == termenv.Ascii {
+		return ""
+	}
+	c := resolveCodeBlockColors(rules, resolveCodeBlockBg(rules, profile))
+	return fmt.Sprintf("\x1b[%sm", profile.Color(c.highlight).Sequence(true))
+}
+
+func colorOf(c *string) string {
+	if c == nil {
+		return ""
+	}
+	return *c
+}
+
+// chromaColour returns the foreground (or background) of a chroma style
+// entry, or "" if unset.
+func chromaColour(s *chroma.Style, t chroma.TokenType, background bool) string {
+	e := s.Get(t)
+	col := e.Colour
+	if background {
+		col = e.Background
+	}
+	if !col.IsSet() {
+		return ""
+	}
+	return col.String()
+}
+
+// blendColors mixes t of color b into color a. Both may be hex colors or
+// ANSI color numbers.
+func blendColors(a, b string, t float64) string {
+	ca := termenv.ConvertToRGB(termenv.TrueColor.Color(a))
+	cb := termenv.ConvertToRGB(termenv.TrueColor.Color(b))
+	return ca.BlendRgb(cb, t).Hex()
+}
+
+// brightenOrDarken moves color c toward white if it is dark, or toward
+// black if it is light.
+func brightenOrDarken(c string, t float64) string {
+	if _, _, l := termenv.ConvertToRGB(termenv.TrueColor.Color(c)).Hsl(); l > 0.5 {
+		return blendColors(c, "#000000", t)
+	}
+	return blendColors(c, "#ffffff", t)
+}
+
+// gutterWidth returns the width of the gutter before each of n lines: the
+// line numbers, and in ASCII a mark column for highlighted lines.
+func (e *CodeBlockElement) gutterWidth(n int, profile termenv.Profile) int {
+	w := 0
+	if e.attrs.lineNumbers {
+		w += len(strconv.Itoa(e.attrs.firstLine+n-1)) + 2
+	}
+	if profile == termenv.Ascii && len(e.attrs.highlight) > 0 {
+		w += 2
+	}
+	return w
+}
+
+// decorate builds the gutters and line backgrounds of the code lines:
+// line numbers, highlighted lines, and added and removed lines of diffs.
+func (e *CodeBlockElement) decorate(lines []string, gutterWidth int,
+	rules StyleCodeBlock, bg string, profile termenv.Profile) codeLineDecor {
+
+	diff := isDiffLanguage(e.Language)
+	if gutterWidth == 0 && len(e.attrs.highlight) == 0 && !diff {
+		return codeLineDecor{}
+	}
+
+	colored := profile != termenv.Ascii
+	var colors codeBlockColors
+	if colored {
+		colors = resolveCodeBlockColors(rules, bg)
+	}
+	escape := func(color string, background bool) string {
+		if !colored || color == "" {
+			return ""
+		}
+		return fmt.Sprintf("\x1b[%sm", profile.Color(color).Sequence(background))
+	}
+
+	decor := codeLineDecor{
+		gutters:     make([]string, len(lines)),
+		gutterWidth: gutterWidth,
+		backgrounds: make([]string, len(lines)),
+	}
+	digits := len(strconv.Itoa(e.attrs.firstLine + len(lines) - 1))
+	for i, line := range lines {
+		highlighted := e.attrs.highlighted(i + 1)
+
+		var gutter strings.Builder
+		if !colored && len(e.attrs.highlight) > 0 {
+			if highlighted {
+				gutter.WriteString("> ")
+			} else {
+				gutter.WriteString("  ")
+			}
+		}
+		if e.attrs.lineNumbers {
+			number := fmt.Sprintf("%*d  ", digits, e.attrs.firstLine+i)
+			if esc := escape(colors.lineNumber, false); esc != "" {
+				number = esc + number + ansiFgAttrReset
+			}
+			gutter.WriteString(number)
+		}
+		decor.gutters[i] = gutter.String()
+
+		switch {
+		case highlighted:
+			decor.backgrounds[i] = escape(colors.highlight, true)
+		case diff && strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++"):
+			decor.backgrounds[i] = escape(colors.inserted, true)
+		case diff && strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---"):
+			decor.backgrounds[i] = escape(colors.deleted, true)
+		}
+	}
+	return decor
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	xansi "github.com/charmbracelet/x/ansi"
 	"github.com/mattn/go-runewidth"
 	"github.com/muesli/termenv"
 )
@@ -124,7 +125,7 @@ func TestWriteCodeLines(t *testing.T) {
 
 	t.Run("pads short lines", func(t *testing.T) {
 		var buf bytes.Buffer
-		writeCodeLines(&buf, []string{"hi"}, 10, "", "", false, profile, noBorder)
+		writeCodeLines(&buf, []string{"hi"}, 10, "", "", false, profile, noBorder, codeLineDecor{})
 		got := buf.String()
 		// "hi" + 8 spaces + newline
 		if got != "hi        \n" {
@@ -134,7 +135,7 @@ func TestWriteCodeLines(t *testing.T) {
 
 	t.Run("truncates long lines", func(t *testing.T) {
 		var buf bytes.Buffer
-		writeCodeLines(&buf, []string{"abcdefghij"}, 5, "", "", false, profile, noBorder)
+		writeCodeLines(&buf, []string{"abcdefghij"}, 5, "", "", false, profile, noBorder, codeLineDecor{})
 		line := strings.TrimRight(buf.String(), "\n")
 		visWidth := runewidth.StringWidth(line)
 		if visWidth != 5 {
@@ -145,7 +146,7 @@ func TestWriteCodeLines(t *testing.T) {
 	t.Run("with borders wraps lines", func(t *testing.T) {
 		var buf bytes.Buffer
 		borderStyle := StylePrimitive{}
-		writeCodeLines(&buf, []string{"x"}, 8, "", "", true, profile, borderStyle)
+		writeCodeLines(&buf, []string{"x"}, 8, "", "", true, profile, borderStyle, codeLineDecor{})
 		got := buf.String()
 		// should contain border characters
 		if !strings.Contains(got, "│") {
@@ -156,7 +157,7 @@ func TestWriteCodeLines(t *testing.T) {
 	t.Run("with bgEscape injects escape and reset", func(t *testing.T) {
 		var buf bytes.Buffer
 		bgEsc := "\x1b[48;2;55;55;55m"
-		writeCodeLines(&buf, []string{"code"}, 10, "", bgEsc, false, profile, noBorder)
+		writeCodeLines(&buf, []string{"code"}, 10, "", bgEsc, false, profile, noBorder, codeLineDecor{})
 		got := buf.String()
 		if !strings.Contains(got, bgEsc) {
 			t.Errorf("expected bg escape in output: %q", got)
@@ -168,7 +169,7 @@ func TestWriteCodeLines(t *testing.T) {
 
 	t.Run("margin prefix prepended", func(t *testing.T) {
 		var buf bytes.Buffer
-		writeCodeLines(&buf, []string{"x"}, 5, "  ", "", false, profile, noBorder)
+		writeCodeLines(&buf, []string{"x"}, 5, "  ", "", false, profile, noBorder, codeLineDecor{})
 		got := buf.String()
 		if !strings.HasPrefix(got, "  ") {
 			t.Errorf("expected margin prefix, got: %q", got)
@@ -219,3 +220,97 @@ func TestResolveCodeBlockBg(t *testing.T) {
 		}
 	})
 }
+
+func renderCodeBlock(t *testing.T, md string, rules StyleCodeBlock, profile termenv.Profile) []string {
+	t.Helper()
+	out, _ := renderANSI(t, md, Options{WordWrap: 40, ColorProfile: profile, Styles: StyleConfig{CodeBlock: rules}})
+	return strings.Split(out, "\n")
+}
+
+// lineWith returns the first line whose text contains s.
+func lineWith(t *testing.T, lines []string, s string) string {
+	t.Helper()
+	for _, line := range lines {
+		if strings.Contains(xansi.Strip(line), s) {
+			return line
+		}
+	}
+	t.Fatalf("no line contains %q", s)
+	return ""
+}
+
+func TestCodeBlock_LineNumbersAndHighlight(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	rules := StyleCodeBlock{Chroma: &Chroma{
+		Comment:    StylePrimitive{Color: strPtr("#676767")},
+		Background: StylePrimitive{BackgroundColor: strPtr("#373737")},
+	}}
+	md := "```go {linenos=true, linenostart=9, hl_lines=[2], title=\"main.go\"}\npackage main\nvar x = 1\nfunc main() {}\n```\n"
+
+	for name, rules := range map[string]StyleCodeBlock{"chroma colors": rules, "chroma theme": {Theme: "monokai"}} {
+		t.Run(name, func(t *testing.T) {
+			lines := renderCodeBlock(t, md, rules, termenv.TrueColor)
+			if top := lineWith(t, lines, "╭"); !strings.Contains(xansi.Strip(top), " main.go ") {
+				t.Errorf("title not in the top border: %q", xansi.Strip(top))
+			}
+			for n, code := range map[string]string{" 9  ": "package", "10  ": "var", "11  ": "func"} {
+				if got := xansi.Strip(lineWith(t, lines, code)); !strings.Contains(got, n+code) {
+					t.Errorf("line %q lacks number %q", got, n)
+				}
+			}
+			plain, highlighted := lineWith(t, lines, "package"), lineWith(t, lines, "var x")
+			if bgOf(plain) == bgOf(highlighted) {
+				t.Errorf("highlighted line has the block background %q", bgOf(plain))
+			}
+		})
+	}
+}
+
+func TestCodeBlock_Diff(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	rules := StyleCodeBlock{Chroma: &Chroma{
+		GenericInserted: StylePrimitive{Color: strPtr("#00ff00")},
+		GenericDeleted:  StylePrimitive{Color: strPtr("#ff0000")},
+		Background:      StylePrimitive{BackgroundColor: strPtr("#000000")},
+	}}
+	lines := renderCodeBlock(t, "```diff\n--- a\n+++ b\n-old\n+new\n same\n```\n", rules, termenv.TrueColor)
+
+	block := bgOf(lineWith(t, lines, "same"))
+	added, removed := bgOf(lineWith(t, lines, "+new")), bgOf(lineWith(t, lines, "-old"))
+	if added == block || removed == block || added == removed {
+		t.Errorf("backgrounds: block %q, added %q, removed %q", block, added, removed)
+	}
+	if bgOf(lineWith(t, lines, "+++ b")) != block {
+		t.Error("file header lines should keep the block background")
+	}
+	var code int
+	for _, line := range lines {
+		if strings.HasPrefix(xansi.Strip(line), "│") {
+			code++
+		}
+	}
+	if code != 5 {
+		t.Errorf("%d code lines, want 5", code)
+	}
+}
+
+func TestCodeBlock_ASCIIHighlightMarks(t *testing.T) {
+	lines := renderCodeBlock(t, "```{hl_lines=[2]}\none\ntwo\n```\n", StyleCodeBlock{}, termenv.Ascii)
+	if got := lineWith(t, lines, "two"); !strings.Contains(got, "> two") {
+		t.Errorf("highlighted line = %q, want a > mark", got)
+	}
+	if got := lineWith(t, lines, "one"); !strings.Contains(got, "  one") {
+		t.Errorf("plain line = %q, want a blank mark", got)
+	}
+}
+
+// bgOf returns the first background escape of the code part of a line.
+func bgOf(line string) string {
+	_, code, _ := strings.Cut(line, "│")
+	if i := strings.Index(code, "\x1b[48;"); i >= 0 {
+		if j := strings.IndexByte(code[i:], 'm'); j >= 0 {
+			return code[i : i+j+1]
+		}
+	}
+	return ""
+}
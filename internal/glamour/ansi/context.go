@@ -14,6 +14,9 @@ type RenderContext struct {
 	blockStack  *BlockStack
 	table       *TableElement
 	imageTokens *imageTokenTable
+	hyperlinks  *hyperlinkTable
+	wideBlocks  *wideBlockTable
+	sourceMap   *sourceMapTable
 
 	stripper *bluemonday.Policy
 }
@@ -25,6 +28,9 @@ func NewRenderContext(options Options) RenderContext {
 		blockStack:  &BlockStack{},
 		table:       &TableElement{},
 		imageTokens: &imageTokenTable{},
+		hyperlinks:  &hyperlinkTable{},
+		wideBlocks:  &wideBlockTable{},
+		sourceMap:   &sourceMapTable{},
 		stripper:    bluemonday.StrictPolicy(),
 	}
 }
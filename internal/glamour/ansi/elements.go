@@ -201,6 +201,19 @@ func (tr *ANSIRenderer) NewElement(node ast.Node, source []byte) Element {
 			},
 		}
 
+	case ast.KindString:
+		// inserted by HTMLTransformer, e.g. for <br> and <sup>
+		n, ok := node.(*ast.String)
+		if !ok {
+			return Element{}
+		}
+		return Element{
+			Renderer: &BaseElement{
+				Token: string(n.Value),
+				Style: ctx.options.Styles.Text,
+			},
+		}
+
 	case ast.KindEmphasis:
 		n, ok := node.(*ast.Emphasis)
 		if !ok {
@@ -249,6 +262,19 @@ func (tr *ANSIRenderer) NewElement(node ast.Node, source []byte) Element {
 		if !ok {
 			return Element{}
 		}
+		if _, ok := n.AttributeString(DetailsAttribute); ok {
+			// a <details> summary: navigable, but there is no target to show
+			var children []ElementRenderer
+			for nn := n.FirstChild(); nn != nil; nn = nn.NextSibling() {
+				children = append(children, tr.NewElement(nn, source).Renderer)
+			}
+			return Element{
+				Renderer: &LinkElement{
+					Children: children,
+					SkipHref: true,
+				},
+			}
+		}
 		isFooterLinks := !ctx.options.InlineTableLinks && isInsideTable(node)
 
 		var children []ElementRenderer
@@ -352,12 +378,20 @@ func (tr *ANSIRenderer) NewElement(node ast.Node, source []byte) Element {
 			text = linkWithSuffix(tl, ctx.table.tableImages)
 		}
 
+		var width string
+		if w, ok := n.AttributeString("width"); ok {
+			if b, ok := w.([]byte); ok {
+				width = string(b)
+			}
+		}
+
 		return Element{
 			Renderer: &ImageElement{
 				Text:     text,
 				BaseURL:  ctx.options.BaseURL,
 				URL:      string(n.Destination),
 				TextOnly: isFooterLinks,
+				Width:    width,
 			},
 		}
 
@@ -373,11 +407,17 @@ func (tr *ANSIRenderer) NewElement(node ast.Node, source []byte) Element {
 			line := n.Lines().At(i)
 			s += string(line.Value(source))
 		}
+		var info string
+		if n.Info != nil {
+			info = string(n.Info.Segment.Value(source))
+		}
+		lang, attrs := parseFenceInfo(info)
 		return Element{
 			Entering: "\n",
 			Renderer: &CodeBlockElement{
 				Code:     s,
-				Language: string(n.Language(source)),
+				Language: lang,
+				attrs:    attrs,
 			},
 		}
 
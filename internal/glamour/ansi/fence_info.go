@@ -0,0 +1,139 @@
+package ansi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// fenceAttrs are the attributes of a fenced code block's info string, in
+// Hugo's syntax:
+//
+//	```go {linenos=true, hl_lines=[3,5-7], title="main.go"}
+type fenceAttrs struct {
+	lineNumbers bool
+	firstLine   int      // number of the first line, default 1
+	highlight   [][2]int // inclusive line ranges, counted from 1
+	title       string
+}
+
+// parseFenceInfo splits a fence info string into the language and the
+// attributes in braces after it. Unknown attributes are ignored.
+func parseFenceInfo(info string) (string, fenceAttrs) {
+	attrs := fenceAttrs{firstLine: 1}
+	info = strings.TrimSpace(info)
+	lang := info
+	if i := strings.IndexByte(info, '{'); i >= 0 {
+		lang = info[:i]
+		body := info[i+1:]
+		if j := strings.LastIndexByte(body, '}'); j >= 0 {
+			body = body[:j]
+		}
+		for key, value := range splitFenceAttrs(body) {
+			attrs.set(key, value)
+		}
+	}
+	if fields := strings.Fields(lang); len(fields) > 0 {
+		lang = fields[0]
+	} else {
+		lang = ""
+	}
+	return lang, attrs
+}
+
+func (a *fenceAttrs) set(key, value string) {
+	switch strings.ToLower(key) {
+	case "linenos":
+		a.lineNumbers = value != "false"
+	case "linenostart":
+		if n, err := strconv.Atoi(value); err == nil {
+			a.firstLine = n
+		}
+	case "hl_lines":
+		a.highlight = parseLineRanges(value)
+	case "title", "filename":
+		a.title = value
+	}
+}
+
+// splitFenceAttrs splits `key=value` pairs separated by spaces or commas.
+// Values may be quoted or bracketed; a bare key means "true".
+func splitFenceAttrs(s string) map[string]string {
+	attrs := map[string]string{}
+	for s = strings.TrimLeft(s, " ,"); s != ""; s = strings.TrimLeft(s, " ,") {
+		end := strings.IndexAny(s, "= ,")
+		if end < 0 {
+			attrs[s] = "true"
+			break
+		}
+		key := s[:end]
+		if s[end] != '=' {
+			attrs[key] = "true"
+			s = s[end:]
+			continue
+		}
+		s = s[end+1:]
+		var value string
+		switch {
+		case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+			stop := strings.IndexByte(s[1:], s[0])
+			if stop < 0 {
+				stop = len(s) - 1
+			}
+			value, s = s[1:stop+1], s[min(stop+2, len(s)):]
+		case strings.HasPrefix(s, "["):
+			stop := strings.IndexByte(s, ']')
+			if stop < 0 {
+				stop = len(s)
+			}
+			value, s = s[1:stop], s[min(stop+1, len(s)):]
+		default:
+			stop := strings.IndexAny(s, " ,")
+			if stop < 0 {
+				stop = len(s)
+			}
+			value, s = s[:stop], s[stop:]
+		}
+		attrs[key] = value
+	}
+	return attrs
+}
+
+// parseLineRanges parses line numbers and ranges such as `3,5-7` or
+// `"3 5-7"`. Invalid entries are skipped.
+func parseLineRanges(s string) [][2]int {
+	var ranges [][2]int
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '"' || r == '\'' }) {
+		from, to, isRange := strings.Cut(f, "-")
+		a, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		b := a
+		if isRange {
+			if b, err = strconv.Atoi(to); err != nil || b < a {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{a, b})
+	}
+	return ranges
+}
+
+// highlighted reports whether line n, counted from 1, is highlighted.
+func (a fenceAttrs) highlighted(n int) bool {
+	for _, r := range a.highlight {
+		if n >= r[0] && n <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// isDiffLanguage reports whether lang names a unified diff.
+func isDiffLanguage(lang string) bool {
+	switch strings.ToLower(lang) {
+	case "diff", "patch", "udiff":
+		return true
+	}
+	return false
+}
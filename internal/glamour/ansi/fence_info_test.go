@@ -0,0 +1,33 @@
+package ansi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFenceInfo(t *testing.T) {
+	tests := []struct {
+		info  string
+		lang  string
+		attrs fenceAttrs
+	}{
+		{"go", "go", fenceAttrs{firstLine: 1}},
+		{"", "", fenceAttrs{firstLine: 1}},
+		{"go {linenos=true}", "go", fenceAttrs{lineNumbers: true, firstLine: 1}},
+		{"go{linenos=false}", "go", fenceAttrs{firstLine: 1}},
+		{`go {hl_lines=[3,5-7], title="main.go"}`, "go", fenceAttrs{
+			firstLine: 1, highlight: [][2]int{{3, 3}, {5, 7}}, title: "main.go",
+		}},
+		{`python {linenos=table linenostart=10 hl_lines="1 2-3" filename='a b.py'}`, "python", fenceAttrs{
+			lineNumbers: true, firstLine: 10, highlight: [][2]int{{1, 1}, {2, 3}}, title: "a b.py",
+		}},
+		{"sh {linenos, unknown=1}", "sh", fenceAttrs{lineNumbers: true, firstLine: 1}},
+		{"go {hl_lines=[x,4-2,9]}", "go", fenceAttrs{firstLine: 1, highlight: [][2]int{{9, 9}}}},
+	}
+	for _, tt := range tests {
+		lang, attrs := parseFenceInfo(tt.info)
+		if lang != tt.lang || !reflect.DeepEqual(attrs, tt.attrs) {
+			t.Errorf("parseFenceInfo(%q) = %q, %+v; want %q, %+v", tt.info, lang, attrs, tt.lang, tt.attrs)
+		}
+	}
+}
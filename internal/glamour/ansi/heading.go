@@ -9,8 +9,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-
-	"github.com/muesli/reflow/wordwrap"
 )
 
 // Marker constants for header position tracking
@@ -99,16 +97,12 @@ func (e *HeadingElement) Finish(w io.Writer, ctx RenderContext) error {
 	rules := bs.Current().Style
 	mw := NewMarginWriter(ctx, w, rules)
 
-	flow := wordwrap.NewWriter(int(bs.Width(ctx))) //nolint: gosec
-	_, err := flow.Write(bs.Current().Block.Bytes())
+	wrapped, err := wrapText(bs.Current().Block.String(), int(bs.Width(ctx)), false) //nolint: gosec
 	if err != nil {
-		return fmt.Errorf("glamour: error writing bytes: %w", err)
-	}
-	if err := flow.Close(); err != nil {
 		return fmt.Errorf("glamour: error closing flow: %w", err)
 	}
 
-	_, err = mw.Write(flow.Bytes())
+	_, err = mw.Write(wrapped)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,31 @@
+package ansi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// renderANSI renders md through an ANSIRenderer with options, parsing it
+// with GFM and the HTML transformer as glamour.NewTermRenderer does. The
+// renderer is returned for what it records, such as the source map.
+func renderANSI(t *testing.T, md string, options Options) (string, *ANSIRenderer) {
+	t.Helper()
+	gm := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithASTTransformers(util.Prioritized(HTMLTransformer{}, 1000))),
+	)
+	ar := NewRenderer(options)
+	gm.SetRenderer(renderer.NewRenderer(renderer.WithNodeRenderers(util.Prioritized(ar, 1000))))
+
+	var buf bytes.Buffer
+	if err := gm.Convert([]byte(md), &buf); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	return buf.String(), ar
+}
@@ -0,0 +1,620 @@
+package ansi
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	astext "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DetailsAttribute marks the link HTMLTransformer makes of the summary of a
+// <details> block. Its value is the block's data-details attribute, which
+// identifies the block to whoever set it (see navidown's MarkdownSession).
+const DetailsAttribute = "data-details"
+
+// HTMLTransformer is a goldmark AST transformer that interprets a safe subset
+// of raw HTML by rewriting it into markdown nodes before rendering: <a href>
+// becomes a link and <img src> an image, so both join the link marker and
+// image token pipeline like their markdown forms; <b>, <i>, <del>, <code>,
+// <kbd>, <sub>, <sup> and <br> become the matching inline nodes; and HTML
+// blocks become paragraphs, headings, lists and rules, with a <details> block
+// shown as its summary line, followed by its content if the block has the
+// open attribute. Other tags are dropped and their text kept, as the
+// sanitizer did.
+type HTMLTransformer struct{}
+
+// Transform implements parser.ASTTransformer.
+func (HTMLTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+
+	// collect first, since rewriting moves nodes around
+	var blocks []*ast.HTMLBlock
+	var parents []ast.Node
+	seen := map[ast.Node]bool{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.HTMLBlock:
+			blocks = append(blocks, n)
+		case *ast.RawHTML:
+			if p := n.Parent(); !seen[p] {
+				seen[p] = true
+				parents = append(parents, p)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, p := range parents {
+		rewriteInlineHTML(p, source)
+	}
+	for _, b := range blocks {
+		rewriteHTMLBlock(b, source)
+	}
+}
+
+// htmlTag is a parsed start, end or self-closing tag.
+type htmlTag struct {
+	name  string
+	attrs map[string]string
+	end   bool
+	void  bool // <br>, <img/> and the like, which have no end tag
+}
+
+func parseHTMLTag(raw string) (htmlTag, bool) {
+	z := html.NewTokenizer(strings.NewReader(raw))
+	tt := z.Next()
+	if tt != html.StartTagToken && tt != html.EndTagToken && tt != html.SelfClosingTagToken {
+		return htmlTag{}, false
+	}
+	tok := z.Token()
+	tag := htmlTag{
+		name:  tok.Data,
+		attrs: make(map[string]string, len(tok.Attr)),
+		end:   tt == html.EndTagToken,
+		void:  tt == html.SelfClosingTagToken || isVoidElement(tok.Data),
+	}
+	for _, a := range tok.Attr {
+		tag.attrs[a.Key] = a.Val
+	}
+	return tag, true
+}
+
+func isVoidElement(name string) bool {
+	switch name {
+	case "br", "img", "hr", "wbr", "input", "source", "meta", "link", "area", "col", "embed":
+		return true
+	}
+	return false
+}
+
+// rewriteInlineHTML rewrites the raw HTML tags among the children of parent.
+// A start tag and its matching end tag become a node wrapping the siblings
+// between them.
+func rewriteInlineHTML(parent ast.Node, source []byte) {
+	for c := parent.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		raw, ok := c.(*ast.RawHTML)
+		if !ok {
+			c = next
+			continue
+		}
+
+		tag, ok := parseHTMLTag(rawHTMLText(raw, source))
+		switch {
+		case !ok || tag.end:
+			parent.RemoveChild(parent, c)
+		case tag.void:
+			if n := htmlVoidNode(tag); n != nil {
+				parent.ReplaceChild(parent, c, n)
+			} else {
+				parent.RemoveChild(parent, c)
+			}
+		default:
+			end := matchingEndTag(c, tag.name, source)
+			wrapper := htmlInlineNode(tag)
+			if end == nil || wrapper == nil {
+				// unwrap: the children stay, the unmatched end tag is
+				// dropped when the loop reaches it
+				parent.RemoveChild(parent, c)
+				break
+			}
+			for n := c.NextSibling(); n != end; {
+				nn := n.NextSibling()
+				parent.RemoveChild(parent, n)
+				wrapper.AppendChild(wrapper, n)
+				n = nn
+			}
+			next = end.NextSibling()
+			parent.RemoveChild(parent, end)
+			parent.ReplaceChild(parent, c, finishHTMLInline(wrapper, tag, source))
+		}
+		c = next
+	}
+}
+
+func rawHTMLText(n *ast.RawHTML, source []byte) string {
+	var b strings.Builder
+	for i := 0; i < n.Segments.Len(); i++ {
+		seg := n.Segments.At(i)
+		b.Write(seg.Value(source))
+	}
+	return b.String()
+}
+
+// matchingEndTag returns the sibling after start closing the tag name, or
+// nil.
+func matchingEndTag(start ast.Node, name string, source []byte) ast.Node {
+	depth := 0
+	for n := start.NextSibling(); n != nil; n = n.NextSibling() {
+		raw, ok := n.(*ast.RawHTML)
+		if !ok {
+			continue
+		}
+		tag, ok := parseHTMLTag(rawHTMLText(raw, source))
+		if !ok || tag.name != name || tag.void {
+			continue
+		}
+		if !tag.end {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return n
+		}
+		depth--
+	}
+	return nil
+}
+
+// htmlVoidNode returns the node for a tag without content: a line break or
+// an image. It returns nil for tags that are dropped.
+func htmlVoidNode(tag htmlTag) ast.Node {
+	switch tag.name {
+	case "br":
+		return ast.NewString([]byte("\n"))
+	case "img":
+		src := tag.attrs["src"]
+		if src == "" || !isSafeURL(src) {
+			if alt := tag.attrs["alt"]; alt != "" {
+				return ast.NewString([]byte(alt))
+			}
+			return nil
+		}
+		img := ast.NewImage(ast.NewLink())
+		img.Destination = []byte(src)
+		img.Title = []byte(tag.attrs["title"])
+		if alt := tag.attrs["alt"]; alt != "" {
+			img.AppendChild(img, ast.NewString([]byte(alt)))
+		}
+		if w := tag.attrs["width"]; w != "" {
+			img.SetAttributeString("width", []byte(w))
+		}
+		return img
+	}
+	return nil
+}
+
+// htmlInlineNode returns the node wrapping the content of an inline tag, or
+// nil if the tag is dropped and its content kept.
+func htmlInlineNode(tag htmlTag) ast.Node {
+	switch tag.name {
+	case "a":
+		href := tag.attrs["href"]
+		if href == "" || !isSafeURL(href) {
+			return nil
+		}
+		link := ast.NewLink()
+		link.Destination = []byte(href)
+		link.Title = []byte(tag.attrs["title"])
+		return link
+	case "b", "strong":
+		return ast.NewEmphasis(2)
+	case "i", "em", "cite", "var", "dfn":
+		return ast.NewEmphasis(1)
+	case "s", "del", "strike":
+		return astext.NewStrikethrough()
+	case "code", "kbd", "samp", "tt":
+		return ast.NewCodeSpan()
+	case "sub", "sup":
+		return ast.NewString(nil)
+	}
+	return nil
+}
+
+// finishHTMLInline completes a wrapper holding its content: sub- and
+// superscripts become text, and tags inside other wrappers are rewritten.
+func finishHTMLInline(wrapper ast.Node, tag htmlTag, source []byte) ast.Node {
+	if s, ok := wrapper.(*ast.String); ok {
+		content := string(nodeText(wrapper, source))
+		if tag.name == "sup" {
+			s.Value = []byte(scriptText(content, superscripts, "^"))
+		} else {
+			s.Value = []byte(scriptText(content, subscripts, "_"))
+		}
+		wrapper.RemoveChildren(wrapper)
+		return s
+	}
+	rewriteInlineHTML(wrapper, source)
+	return wrapper
+}
+
+// nodeText returns the text of the children of n.
+func nodeText(n ast.Node, source []byte) []byte {
+	var b bytes.Buffer
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(source))
+		case *ast.String:
+			b.Write(c.Value)
+		default:
+			b.Write(nodeText(c, source))
+		}
+	}
+	return b.Bytes()
+}
+
+var (
+	superscripts = strings.NewReplacer(
+		"0", "⁰", "1", "¹", "2", "²", "3", "³", "4", "⁴", "5", "⁵", "6", "⁶", "7", "⁷", "8", "⁸", "9", "⁹",
+		"+", "⁺", "-", "⁻", "=", "⁼", "(", "⁽", ")", "⁾", "n", "ⁿ", "i", "ⁱ",
+	)
+	subscripts = strings.NewReplacer(
+		"0", "₀", "1", "₁", "2", "₂", "3", "₃", "4", "₄", "5", "₅", "6", "₆", "7", "₇", "8", "₈", "9", "₉",
+		"+", "₊", "-", "₋", "=", "₌", "(", "₍", ")", "₎", "a", "ₐ", "e", "ₑ", "o", "ₒ", "x", "ₓ",
+		"h", "ₕ", "k", "ₖ", "l", "ₗ", "m", "ₘ", "n", "ₙ", "p", "ₚ", "s", "ₛ", "t", "ₜ",
+	)
+)
+
+// scriptText writes s in Unicode sub- or superscript characters, or after
+// mark (as in x^2) if some character has none.
+func scriptText(s string, r *strings.Replacer, mark string) string {
+	converted := r.Replace(s)
+	for _, c := range converted {
+		if c < 0x80 {
+			if strings.ContainsRune(s, ' ') || len(s) > 1 {
+				return mark + "(" + s + ")"
+			}
+			return mark + s
+		}
+	}
+	return converted
+}
+
+// isSafeURL reports whether u is relative or uses a scheme that is safe to
+// follow: http, https, mailto or ftp.
+func isSafeURL(u string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "", "http", "https", "mailto", "ftp":
+		return true
+	}
+	return false
+}
+
+// rewriteHTMLBlock replaces an HTML block by the markdown blocks its markup
+// describes.
+func rewriteHTMLBlock(block *ast.HTMLBlock, source []byte) {
+	var raw bytes.Buffer
+	for i := 0; i < block.Lines().Len(); i++ {
+		seg := block.Lines().At(i)
+		raw.Write(seg.Value(source))
+	}
+	if block.HasClosure() {
+		raw.Write(block.ClosureLine.Value(source))
+	}
+	nodes, err := html.ParseFragment(&raw, &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return
+	}
+
+	var b htmlBlockBuilder
+	for _, n := range nodes {
+		b.block(n)
+	}
+	b.flush()
+
+	// the blocks take the position of the markup, for SourceOffset
+	parent := block.Parent()
+	for _, n := range b.blocks {
+		if n.Lines().Len() == 0 && block.Lines().Len() > 0 {
+			n.Lines().Append(block.Lines().At(0))
+		}
+		parent.InsertBefore(parent, block, n)
+	}
+	parent.RemoveChild(parent, block)
+}
+
+// htmlBlockBuilder turns parsed HTML into markdown blocks. Inline content
+// collects in a paragraph until a block-level element ends it.
+type htmlBlockBuilder struct {
+	blocks []ast.Node
+	para   *ast.Paragraph
+}
+
+func (b *htmlBlockBuilder) paragraph() *ast.Paragraph {
+	if b.para == nil {
+		b.para = ast.NewParagraph()
+	}
+	return b.para
+}
+
+// flush ends the current paragraph, dropping it if it holds only whitespace.
+func (b *htmlBlockBuilder) flush() {
+	if b.para == nil {
+		return
+	}
+	p := b.para
+	b.para = nil
+	trimInlineSpace(p)
+	if p.HasChildren() {
+		b.blocks = append(b.blocks, p)
+	}
+}
+
+func (b *htmlBlockBuilder) block(n *html.Node) {
+	if n.Type != html.ElementNode {
+		b.inline(n, b.paragraph())
+		return
+	}
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Template, atom.Head, atom.Title:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		b.flush()
+		level, _ := strconv.Atoi(n.Data[1:])
+		h := ast.NewHeading(level)
+		b.inlineChildren(n, h)
+		trimInlineSpace(h)
+		b.blocks = append(b.blocks, h)
+	case atom.Hr:
+		b.flush()
+		b.blocks = append(b.blocks, ast.NewThematicBreak())
+	case atom.Ul, atom.Ol:
+		b.flush()
+		b.blocks = append(b.blocks, htmlList(n, b))
+	case atom.Pre:
+		b.flush()
+		p := ast.NewParagraph()
+		for i, line := range strings.Split(strings.Trim(textContent(n), "\n"), "\n") {
+			if i > 0 {
+				p.AppendChild(p, ast.NewString([]byte("\n")))
+			}
+			code := ast.NewCodeSpan()
+			code.AppendChild(code, ast.NewString([]byte(line)))
+			p.AppendChild(p, code)
+		}
+		b.blocks = append(b.blocks, p)
+	case atom.Details:
+		// a closed block shows only its summary, as in a browser
+		b.flush()
+		open := hasAttr(n, "open")
+		var summary *html.Node
+		for c := n.FirstChild; c != nil && summary == nil; c = c.NextSibling {
+			if c.DataAtom == atom.Summary {
+				summary = c
+			}
+		}
+		b.summary(summary, open, attr(n, DetailsAttribute))
+		if open {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c != summary {
+					b.block(c)
+				}
+			}
+		}
+		b.flush()
+	case atom.Summary:
+		// the summary of a block whose <details> tag is in an earlier HTML
+		// block, which is open
+		b.summary(n, true, "")
+	case atom.P, atom.Div, atom.Center, atom.Section, atom.Article, atom.Header, atom.Footer,
+		atom.Main, atom.Nav, atom.Aside, atom.Figure, atom.Figcaption, atom.Blockquote,
+		atom.Table, atom.Thead, atom.Tbody, atom.Tfoot, atom.Tr, atom.Dl, atom.Dt, atom.Dd, atom.Address:
+		b.flush()
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+				// table cells flow into the row's paragraph
+				b.inlineChildren(c, b.paragraph())
+				b.paragraph().AppendChild(b.para, ast.NewString([]byte("  ")))
+				continue
+			}
+			b.block(c)
+		}
+		b.flush()
+	default:
+		b.inline(n, b.paragraph())
+	}
+}
+
+// summary adds the summary line of a <details> block: a disclosure
+// triangle and the summary text in bold, or "Details" if there is no
+// summary. The line is a link carrying DetailsAttribute with the block's id,
+// so it is navigable and can toggle the block.
+func (b *htmlBlockBuilder) summary(n *html.Node, open bool, id string) {
+	b.flush()
+	link := ast.NewLink()
+	link.SetAttributeString(DetailsAttribute, []byte(id))
+	if open {
+		link.AppendChild(link, ast.NewString([]byte("▾ ")))
+	} else {
+		link.AppendChild(link, ast.NewString([]byte("▸ ")))
+	}
+	strong := ast.NewEmphasis(2)
+	if n != nil {
+		b.inlineChildren(n, strong)
+		trimInlineSpace(strong)
+	}
+	if !strong.HasChildren() {
+		strong.AppendChild(strong, ast.NewString([]byte("Details")))
+	}
+	link.AppendChild(link, strong)
+	p := b.paragraph()
+	p.AppendChild(p, link)
+	b.flush()
+}
+
+func (b *htmlBlockBuilder) inlineChildren(n *html.Node, into ast.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.inline(c, into)
+	}
+}
+
+// inline appends the inline content of n to into.
+func (b *htmlBlockBuilder) inline(n *html.Node, into ast.Node) {
+	switch n.Type {
+	case html.TextNode:
+		text := collapseSpace(n.Data)
+		if text == "" || text == " " && !into.HasChildren() {
+			return
+		}
+		into.AppendChild(into, ast.NewString([]byte(text)))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	tag := htmlTag{name: n.Data, attrs: map[string]string{}}
+	for _, a := range n.Attr {
+		tag.attrs[a.Key] = a.Val
+	}
+	switch {
+	case n.DataAtom == atom.Script || n.DataAtom == atom.Style:
+	case isVoidElement(n.Data):
+		if v := htmlVoidNode(tag); v != nil {
+			into.AppendChild(into, v)
+		}
+	default:
+		wrapper := htmlInlineNode(tag)
+		if wrapper == nil {
+			b.inlineChildren(n, into)
+			return
+		}
+		if s, ok := wrapper.(*ast.String); ok {
+			content := collapseSpace(textContent(n))
+			if tag.name == "sup" {
+				s.Value = []byte(scriptText(content, superscripts, "^"))
+			} else {
+				s.Value = []byte(scriptText(content, subscripts, "_"))
+			}
+		} else {
+			b.inlineChildren(n, wrapper)
+		}
+		into.AppendChild(into, wrapper)
+	}
+}
+
+// htmlList converts a <ul> or <ol> element.
+func htmlList(n *html.Node, b *htmlBlockBuilder) *ast.List {
+	marker := byte('-')
+	if n.DataAtom == atom.Ol {
+		marker = '.'
+	}
+	list := ast.NewList(marker)
+	list.IsTight = true
+	if list.IsOrdered() {
+		list.Start = 1
+		if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+			list.Start = start
+		}
+	}
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.DataAtom != atom.Li {
+			continue
+		}
+		item := ast.NewListItem(2)
+		tb := ast.NewTextBlock()
+		item.AppendChild(item, tb)
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Ul || c.DataAtom == atom.Ol {
+				item.AppendChild(item, htmlList(c, b))
+				continue
+			}
+			b.inline(c, tb)
+		}
+		trimInlineSpace(tb)
+		list.AppendChild(list, item)
+	}
+	return list
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// textContent returns the text inside n.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+// collapseSpace collapses runs of whitespace into one space, as HTML does.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// trimInlineSpace trims the whitespace at the start and end of the text of
+// n, dropping strings left empty.
+func trimInlineSpace(n ast.Node) {
+	if s, ok := n.FirstChild().(*ast.String); ok {
+		s.Value = bytes.TrimLeft(s.Value, " ")
+		if len(s.Value) == 0 {
+			n.RemoveChild(n, s)
+		}
+	}
+	if s, ok := n.LastChild().(*ast.String); ok {
+		s.Value = bytes.TrimRight(s.Value, " ")
+		if len(s.Value) == 0 {
+			n.RemoveChild(n, s)
+		}
+	}
+}
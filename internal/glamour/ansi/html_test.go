@@ -0,0 +1,117 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// renderHTML renders markdown with the HTML transformer applied, as
+// glamour.NewTermRenderer does.
+func renderHTML(t *testing.T, md string) string {
+	t.Helper()
+	out, _ := renderANSI(t, md, Options{WordWrap: 80, ColorProfile: termenv.Ascii})
+	return out
+}
+
+func TestHTML_InlineTags(t *testing.T) {
+	out := renderHTML(t, "Press <kbd>Ctrl</kbd>+<kbd>C</kbd>, <b>bold</b> <span>plain</span> H<sub>2</sub>O x<sup>2</sup>\n")
+	for _, want := range []string{"Ctrl+C", "bold", "plain", "H₂O", "x²"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %q", want, out)
+		}
+	}
+	for _, tag := range []string{"<kbd>", "</kbd>", "<span>", "<sub>", "<b>"} {
+		if strings.Contains(out, tag) {
+			t.Errorf("tag %s printed in %q", tag, out)
+		}
+	}
+}
+
+func TestHTML_LineBreak(t *testing.T) {
+	out := renderHTML(t, "first<br>second\n")
+	first := strings.Index(out, "first")
+	second := strings.Index(out, "second")
+	if first < 0 || second < 0 || !strings.Contains(out[first:second], "\n") {
+		t.Errorf("expected a line break between the words: %q", out)
+	}
+}
+
+func TestHTML_LinkGetsMarkers(t *testing.T) {
+	out := renderHTML(t, `See <a href="https://example.com">the site</a>.`+"\n")
+	if !strings.Contains(out, linkStartMarker+"the site"+linkEndMarker) {
+		t.Errorf("link text not marked: %q", out)
+	}
+	if !strings.Contains(out, "https://example.com") {
+		t.Errorf("link URL missing: %q", out)
+	}
+}
+
+func TestHTML_UnsafeLinkDropped(t *testing.T) {
+	out := renderHTML(t, `<a href="javascript:alert(1)">click</a>`+"\n")
+	if !strings.Contains(out, "click") {
+		t.Errorf("link text lost: %q", out)
+	}
+	if strings.Contains(out, "javascript") || strings.Contains(out, linkStartMarker) {
+		t.Errorf("unsafe link rendered: %q", out)
+	}
+}
+
+func TestHTML_ImageToken(t *testing.T) {
+	out := renderHTML(t, `<img src="logo.png" alt="Logo" width="120">`+"\n")
+	want := imageTokenStart + "IMG:logo.png" + imageFieldSep + "Logo" + imageFieldSep + "120" + imageTokenEnd
+	if !strings.Contains(out, want) {
+		t.Errorf("expected token %q in %q", want, out)
+	}
+}
+
+func TestHTML_Block(t *testing.T) {
+	md := `<div align="center">
+  <h2>Title</h2>
+  <p>Some <b>text</b>.</p>
+  <ul><li>one</li><li>two</li></ul>
+  <script>alert(1)</script>
+</div>
+`
+	out := renderHTML(t, md)
+	for _, want := range []string{headerStartMarker(2) + "Title", "Some text.", "one", "two"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %q", want, out)
+		}
+	}
+	if strings.Contains(out, "alert") || strings.Contains(out, "<") {
+		t.Errorf("markup or script leaked: %q", out)
+	}
+}
+
+func TestIsSafeURL(t *testing.T) {
+	for u, want := range map[string]bool{
+		"https://example.com":    true,
+		"docs/readme.md":         true,
+		"#section":               true,
+		"mailto:me@example.com":  true,
+		"javascript:alert(1)":    false,
+		" JavaScript:alert(1)":   false,
+		"data:text/html;base64,": false,
+	} {
+		if got := isSafeURL(u); got != want {
+			t.Errorf("isSafeURL(%q) = %v, want %v", u, got, want)
+		}
+	}
+}
+
+func TestHTML_Details(t *testing.T) {
+	closed := renderHTML(t, "<details data-details=\"3\"><summary>More <i>info</i></summary>Hidden body</details>\n")
+	if !strings.Contains(closed, linkStartMarker+"▸ More info"+linkEndMarker) {
+		t.Errorf("expected a marked closed summary: %q", closed)
+	}
+	if strings.Contains(closed, "Hidden") {
+		t.Errorf("closed block shows its body: %q", closed)
+	}
+
+	open := renderHTML(t, "<details open><summary>More</summary>Shown body</details>\n")
+	if !strings.Contains(open, "▾ More") || !strings.Contains(open, "Shown body") {
+		t.Errorf("unexpected open block: %q", open)
+	}
+}
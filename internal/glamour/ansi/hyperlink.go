@@ -0,0 +1,99 @@
+package ansi
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// OSC 8 hyperlinks wrap link text in "ESC ] 8 ; ; url ST" ... "ESC ] 8 ; ; ST".
+// The wrap writers only understand CSI sequences and would count the URL as
+// printable text, so while rendering, each hyperlink is marked with zero-width
+// placeholder runes that are not breakpoints, and the sequences are written in
+// when the document is flushed (hyperlinkTable.restore), like image tokens.
+const (
+	hyperlinkStartRune = '\u206A' // INHIBIT SYMMETRIC SWAPPING
+	hyperlinkEndRune   = '\u206B' // ACTIVATE SYMMETRIC SWAPPING
+	hyperlinkStart     = string(hyperlinkStartRune)
+	hyperlinkEnd       = string(hyperlinkEndRune)
+)
+
+// osc8 returns the sequence opening a hyperlink to target, or closing the
+// current one when target is empty.
+func osc8(target string) string {
+	return "\x1b]8;;" + target + "\x1b\\"
+}
+
+// hyperlinkTable records hyperlink targets in emission order. It hangs off
+// RenderContext via a pointer so the by-value context copies share one table.
+type hyperlinkTable struct {
+	targets []string
+}
+
+// wrap returns the placeholders to write around the text of a link to href,
+// resolved against the hyperlink base URL. Both are empty if hyperlinks are
+// disabled or the target is not absolute.
+func (t *hyperlinkTable) wrap(ctx RenderContext, href string) (start, end string) {
+	if !ctx.options.Hyperlinks || href == "" {
+		return "", ""
+	}
+	target := href
+	if ctx.options.HyperlinkBaseURL != "" {
+		target = resolveHyperlink(ctx.options.HyperlinkBaseURL, href)
+	}
+	u, err := url.Parse(target)
+	if err != nil || !u.IsAbs() || strings.ContainsFunc(target, unicode.IsControl) {
+		return "", ""
+	}
+	t.targets = append(t.targets, target)
+	return hyperlinkStart, hyperlinkEnd
+}
+
+// resolveHyperlink resolves href against base. Unlike resolveRelativeURL,
+// root-relative paths stay root-relative, so "/docs/a.md" under a site base
+// points at the site root.
+func resolveHyperlink(base, href string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return href
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return b.ResolveReference(u).String()
+}
+
+// restore replaces the placeholders in s with OSC 8 sequences, in order. A
+// hyperlink spanning several lines is closed at each line end and reopened
+// on the next line, so every line stands alone.
+func (t *hyperlinkTable) restore(s string) string {
+	if t == nil || len(t.targets) == 0 {
+		return s
+	}
+	var b strings.Builder
+	i := 0
+	current := ""
+	for _, r := range s {
+		switch {
+		case r == hyperlinkStartRune:
+			if i < len(t.targets) {
+				current = t.targets[i]
+				i++
+				b.WriteString(osc8(current))
+			}
+		case r == hyperlinkEndRune:
+			if current != "" {
+				b.WriteString(osc8(""))
+				current = ""
+			}
+		case r == '\n' && current != "":
+			b.WriteString(osc8(""))
+			b.WriteRune(r)
+			b.WriteString(osc8(current))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package ansi
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+func renderHyperlinks(t *testing.T, md, base string, wordWrap int) string {
+	t.Helper()
+	out, _ := renderANSI(t, md, Options{
+		WordWrap:         wordWrap,
+		ColorProfile:     termenv.Ascii,
+		Hyperlinks:       true,
+		HyperlinkBaseURL: base,
+	})
+	return out
+}
+
+var osc8Open = regexp.MustCompile(`\x1b\]8;;([^\x1b]+)\x1b\\`)
+
+func TestHyperlinks_Links(t *testing.T) {
+	out := renderHyperlinks(t, "A [guide](docs/guide.md#setup), [site](https://example.com) and <https://go.dev>.\n",
+		"file:///home/me/notes/readme.md", 0)
+
+	var targets []string
+	for _, m := range osc8Open.FindAllStringSubmatch(out, -1) {
+		targets = append(targets, m[1])
+	}
+	want := []string{"file:///home/me/notes/docs/guide.md#setup", "https://example.com", "https://go.dev"}
+	if strings.Join(targets, " ") != strings.Join(want, " ") {
+		t.Errorf("targets = %q, want %q", targets, want)
+	}
+	if opens, closes := len(targets), strings.Count(out, "\x1b]8;;\x1b\\"); opens != closes {
+		t.Errorf("%d hyperlinks opened, %d closed", opens, closes)
+	}
+	if strings.ContainsAny(out, hyperlinkStart+hyperlinkEnd) {
+		t.Error("placeholders left in output")
+	}
+}
+
+func TestHyperlinks_RelativeWithoutBase(t *testing.T) {
+	out := renderHyperlinks(t, "[guide](guide.md) and [web](https://example.com)\n", "", 0)
+	if m := osc8Open.FindAllStringSubmatch(out, -1); len(m) != 1 || m[0][1] != "https://example.com" {
+		t.Errorf("only the absolute link should be a hyperlink: %q", out)
+	}
+}
+
+func TestHyperlinks_WrappedLinkReopensPerLine(t *testing.T) {
+	md := "Some words before [a link whose text is long enough to wrap](https://example.com/page) after.\n"
+	out := renderHyperlinks(t, md, "", 30)
+
+	lines := strings.Split(out, "\n")
+	var linked int
+	for _, line := range lines {
+		opens := len(osc8Open.FindAllString(line, -1))
+		closes := strings.Count(line, "\x1b]8;;\x1b\\")
+		if opens != closes {
+			t.Errorf("line %q opens %d and closes %d hyperlinks", line, opens, closes)
+		}
+		linked += opens
+	}
+	if linked < 2 {
+		t.Errorf("wrapped link should span lines, got %d linked lines:\n%s", linked, out)
+	}
+}
+
+func TestHyperlinks_TableFooterLinks(t *testing.T) {
+	md := "| a |\n|---|\n| [x](https://example.com/a/very/long/path) |\n"
+	out := renderHyperlinks(t, md, "", 20)
+	if m := osc8Open.FindStringSubmatch(out); m == nil || m[1] != "https://example.com/a/very/long/path" {
+		t.Errorf("footer link should open the full URL: %q", out)
+	}
+}
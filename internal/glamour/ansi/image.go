@@ -19,12 +19,13 @@ type ImageElement struct {
 	URL      string
 	Child    ElementRenderer
 	TextOnly bool
+	Width    string // from <img width>: pixels, or a percentage of the line
 }
 
 // Render renders an ImageElement.
 // When the URL points to an image file, it emits a deferred placeholder token
 // that will be replaced with Kitty Unicode placeholders during post-processing.
-// The token format is: \uFFF0IMG:<resolved-url>\x00<alt-text>\uFFF1
+// The token format is: \uFFF0IMG:<resolved-url>\x00<alt-text>[\x00<width>]\uFFF1
 func (e *ImageElement) Render(w io.Writer, ctx RenderContext) error {
 	resolvedURL := e.URL
 	if len(e.URL) > 0 && e.BaseURL != "" {
@@ -36,7 +37,11 @@ func (e *ImageElement) Render(w io.Writer, ctx RenderContext) error {
 	// run of one-column placeholder runes for the duration of the width-sensitive
 	// writers and restored intact when the document is flushed — see image_wrap.go.
 	if !e.TextOnly && len(resolvedURL) > 0 && looksLikeImage(resolvedURL) {
-		token := imageTokenStart + "IMG:" + resolvedURL + imageFieldSep + e.Text + imageTokenEnd
+		token := imageTokenStart + "IMG:" + resolvedURL + imageFieldSep + e.Text
+		if e.Width != "" {
+			token += imageFieldSep + e.Width
+		}
+		token += imageTokenEnd
 		_, err := io.WriteString(w, ctx.imageTokens.mask(token, int(ctx.blockStack.Width(ctx)))) //nolint: gosec
 		return err
 	}
@@ -0,0 +1,255 @@
+package ansi
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/width"
+)
+
+// wrapText wraps paragraph or heading text to limit columns. reflow's word
+// wrap only breaks at spaces, so text with East Asian wide characters, which
+// has no spaces between words, is broken at UAX #14 line break opportunities
+// instead (breakLines). Other text keeps reflow's wrapping.
+func wrapText(s string, limit int, keepNewlines bool) ([]byte, error) {
+	if limit > 0 && hasWideRunes(s) {
+		return []byte(breakLines(s, limit, keepNewlines)), nil
+	}
+	flow := wordwrap.NewWriter(limit)
+	flow.KeepNewlines = keepNewlines
+	if _, err := flow.Write([]byte(s)); err != nil {
+		return nil, err
+	}
+	if err := flow.Close(); err != nil {
+		return nil, err
+	}
+	return flow.Bytes(), nil
+}
+
+// hasWideRunes reports whether s holds East Asian wide or fullwidth runes.
+func hasWideRunes(s string) bool {
+	for _, r := range s {
+		if r < 0x1100 {
+			continue
+		}
+		switch width.LookupRune(r).Kind() {
+		case width.EastAsianWide, width.EastAsianFullwidth:
+			return true
+		}
+	}
+	return false
+}
+
+// isZeroWidthMark reports whether r is a position marker, a source map mark
+// or a hyperlink placeholder. They are left out of the line break analysis, where the
+// zero-width space of the link markers would allow breaks inside a marker.
+func isZeroWidthMark(r rune) bool {
+	switch r {
+	case '\u200B', '\u200C', '\u200D', '\u2060', hyperlinkStartRune, hyperlinkEndRune,
+		sourceStartRune, sourceEndRune, sourceZeroRune, sourceOneRune:
+		return true
+	}
+	return false
+}
+
+// breakAtom is a grapheme cluster of the text being wrapped, with the escape
+// sequences and zero-width marks around it.
+type breakAtom struct {
+	lead, cluster, trail string
+	width                int
+	space                bool // whitespace, dropped where a line breaks
+	newline              bool
+	canBreak             bool // a line may break after the atom
+}
+
+// invisible returns the atom without its cluster, for dropped whitespace.
+func (a breakAtom) invisible() string { return a.lead + a.trail }
+
+func (a breakAtom) String() string { return a.lead + a.cluster + a.trail }
+
+// breakLines wraps s, which may hold CSI sequences, to limit columns at UAX
+// #14 line break opportunities. Whitespace at a break is dropped; a word
+// wider than the line overflows it, as with reflow.
+func breakLines(s string, limit int, keepNewlines bool) string {
+	atoms := splitBreakAtoms(s)
+	if len(atoms) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	lineWidth := 0
+	var pending []breakAtom // whitespace ending the previous word
+	flushPending := func(visible bool) {
+		for _, a := range pending {
+			if visible {
+				b.WriteString(a.String())
+				lineWidth += a.width
+			} else {
+				b.WriteString(a.invisible())
+			}
+		}
+		pending = pending[:0]
+	}
+
+	for i := 0; i < len(atoms); {
+		a := atoms[i]
+		if a.newline {
+			if keepNewlines {
+				flushPending(false)
+				b.WriteString(a.String())
+				lineWidth = 0
+			} else {
+				a.cluster, a.width, a.space = " ", 1, true
+				pending = append(pending, a)
+			}
+			i++
+			continue
+		}
+
+		// a word runs to the next break opportunity, less its trailing spaces
+		end := i
+		for end < len(atoms) && !atoms[end].newline {
+			end++
+			if atoms[end-1].canBreak {
+				break
+			}
+		}
+		wordEnd := end
+		for wordEnd > i && atoms[wordEnd-1].space {
+			wordEnd--
+		}
+		wordWidth := 0
+		for _, w := range atoms[i:wordEnd] {
+			wordWidth += w.width
+		}
+		pendingWidth := 0
+		for _, p := range pending {
+			pendingWidth += p.width
+		}
+
+		if wordWidth > 0 && lineWidth > 0 && lineWidth+pendingWidth+wordWidth > limit {
+			flushPending(false)
+			b.WriteString("\n")
+			lineWidth = 0
+		} else {
+			flushPending(true)
+		}
+		for _, w := range atoms[i:wordEnd] {
+			b.WriteString(w.String())
+		}
+		lineWidth += wordWidth
+		pending = append(pending, atoms[wordEnd:end]...)
+		i = end
+	}
+	flushPending(false)
+	return b.String()
+}
+
+// splitBreakAtoms splits s into grapheme clusters with their line break
+// opportunities. Escape sequences and zero-width marks between two clusters
+// go with the next one, except that everything up to a closing link, header
+// or hyperlink mark stays with the text it closes.
+func splitBreakAtoms(s string) []breakAtom {
+	// the visible text, and the zero-width runs before each of its bytes
+	var plain strings.Builder
+	var gaps []string
+	var gap strings.Builder
+	for i := 0; i < len(s); {
+		if n := escapeLen(s, i); n > 0 {
+			gap.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if isZeroWidthMark(r) {
+			gap.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		for range n {
+			gaps = append(gaps, gap.String())
+			gap.Reset()
+		}
+		plain.WriteString(s[i : i+n])
+		i += n
+	}
+	if plain.Len() == 0 {
+		return nil
+	}
+
+	var atoms []breakAtom
+	text := plain.String()
+	pos, state := 0, -1
+	for rest := text; rest != ""; {
+		var cluster string
+		var boundaries int
+		cluster, rest, boundaries, state = uniseg.StepString(rest, state)
+
+		var lead strings.Builder
+		for k := pos; k < pos+len(cluster); k++ {
+			lead.WriteString(gaps[k])
+		}
+		trail, next := splitClosingMarks(lead.String())
+		if n := len(atoms); n > 0 {
+			atoms[n-1].trail += trail
+		} else {
+			next = trail + next
+		}
+
+		atoms = append(atoms, breakAtom{
+			lead:     next,
+			cluster:  cluster,
+			width:    boundaries >> uniseg.ShiftWidth,
+			space:    strings.TrimFunc(cluster, unicode.IsSpace) == "" && !strings.Contains(cluster, "\n"),
+			newline:  strings.Contains(cluster, "\n"),
+			canBreak: boundaries&uniseg.MaskLine != uniseg.LineDontBreak,
+		})
+		pos += len(cluster)
+	}
+	atoms[len(atoms)-1].trail += gap.String()
+
+	// between two narrow clusters, break only where reflow would, so words
+	// and URLs in Latin script stay whole
+	for i := range atoms[:len(atoms)-1] {
+		a, next := &atoms[i], atoms[i+1]
+		if a.width < 2 && next.width < 2 && !a.space && !strings.HasSuffix(a.cluster, "-") {
+			a.canBreak = false
+		}
+	}
+	return atoms
+}
+
+// splitClosingMarks splits the zero-width run between two clusters after the
+// last mark closing a link, header or hyperlink.
+func splitClosingMarks(gap string) (closing, opening string) {
+	end := -1
+	for _, mark := range []string{linkEndMarker, headerEndMarker, hyperlinkEnd} {
+		if i := strings.LastIndex(gap, mark); i >= 0 {
+			end = max(end, i+len(mark))
+		}
+	}
+	if end < 0 {
+		return "", gap
+	}
+	return gap[:end], gap[end:]
+}
+
+// escapeLen returns the length of the CSI or two-byte escape sequence at
+// s[i], or 0 if there is none.
+func escapeLen(s string, i int) int {
+	if s[i] != '\x1b' || i+1 >= len(s) {
+		return 0
+	}
+	if s[i+1] != '[' {
+		return 2
+	}
+	for j := i + 2; j < len(s); j++ {
+		if s[j] >= '@' && s[j] <= '~' { // final byte
+			return j + 1 - i
+		}
+	}
+	return len(s) - i
+}
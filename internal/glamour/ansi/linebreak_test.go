@@ -0,0 +1,60 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+func TestBreakLines_CJK(t *testing.T) {
+	s := "\x1b[1m日本語の文章は単語の間に空白がありません。\x1b[0m"
+	out := breakLines(s, 10, true)
+	lines := strings.Split(out, "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected several lines, got %q", out)
+	}
+	for _, line := range lines {
+		if w := xansi.StringWidth(line); w > 10 {
+			t.Errorf("line %q is %d columns wide", xansi.Strip(line), w)
+		}
+	}
+	if got := xansi.Strip(strings.ReplaceAll(out, "\n", "")); got != xansi.Strip(s) {
+		t.Errorf("text changed: %q", got)
+	}
+	// closing punctuation never starts a line
+	for _, line := range lines {
+		if strings.HasPrefix(xansi.Strip(line), "。") {
+			t.Errorf("line starts with a full stop: %q", xansi.Strip(line))
+		}
+	}
+}
+
+func TestBreakLines_KeepsMarkersWithText(t *testing.T) {
+	s := "これは" + linkStartMarker + "リンク" + linkEndMarker + "です。それから長い文章が続きます。"
+	for limit := 4; limit < 20; limit++ {
+		for _, line := range strings.Split(breakLines(s, limit, true), "\n") {
+			if strings.HasSuffix(line, linkStartMarker) || strings.HasPrefix(line, linkEndMarker) {
+				t.Fatalf("width %d: link marker split from its text in %q", limit, line)
+			}
+		}
+	}
+}
+
+func TestWrapText_LatinUnchanged(t *testing.T) {
+	s := "plain latin text keeps the reflow word wrapping"
+	out, err := wrapText(s, 12, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "plain latin\ntext keeps\nthe reflow\nword\nwrapping" {
+		t.Errorf("unexpected wrap %q", out)
+	}
+}
+
+func TestBreakLines_LatinWordsStayWhole(t *testing.T) {
+	out := breakLines("リンク https://example.com です", 12, true)
+	if !strings.Contains(out, "https://example.com") {
+		t.Errorf("URL broken across lines: %q", out)
+	}
+}
@@ -24,6 +24,10 @@ type LinkElement struct {
 	Children []ElementRenderer
 	SkipText bool
 	SkipHref bool
+
+	// SourceStart and SourceEnd are the source map marks around the link
+	// text, empty without a source map.
+	SourceStart, SourceEnd string
 }
 
 // Render renders a LinkElement.
@@ -43,7 +47,11 @@ func (e *LinkElement) Render(w io.Writer, ctx RenderContext) error {
 
 func (e *LinkElement) renderTextPart(w io.Writer, ctx RenderContext) error {
 	// Inject start marker for position tracking
-	if _, err := io.WriteString(w, linkStartMarker); err != nil {
+	if _, err := io.WriteString(w, linkStartMarker+e.SourceStart); err != nil {
+		return err
+	}
+	start, end := ctx.hyperlinks.wrap(ctx, e.URL)
+	if _, err := io.WriteString(w, start); err != nil {
 		return err
 	}
 
@@ -68,6 +76,10 @@ func (e *LinkElement) renderTextPart(w io.Writer, ctx RenderContext) error {
 		}
 	}
 
+	if _, err := io.WriteString(w, end+e.SourceEnd); err != nil {
+		return err
+	}
+
 	// Inject end marker for position tracking
 	if _, err := io.WriteString(w, linkEndMarker); err != nil {
 		return err
@@ -84,8 +96,10 @@ func (e *LinkElement) renderHrefPart(w io.Writer, ctx RenderContext) error {
 	u, err := url.Parse(e.URL)
 	if err == nil && "#"+u.Fragment != e.URL { // if the URL only consists of an anchor, ignore it
 		// For auto-links (SkipText=true), the href IS the clickable text, so inject markers here
+		var start, end string
 		if e.SkipText {
-			if _, err := io.WriteString(w, linkStartMarker); err != nil {
+			start, end = ctx.hyperlinks.wrap(ctx, e.URL)
+			if _, err := io.WriteString(w, linkStartMarker+e.SourceStart+start); err != nil {
 				return err
 			}
 		}
@@ -98,7 +112,7 @@ func (e *LinkElement) renderHrefPart(w io.Writer, ctx RenderContext) error {
 			return err
 		}
 		if e.SkipText {
-			if _, err := io.WriteString(w, linkEndMarker); err != nil {
+			if _, err := io.WriteString(w, end+e.SourceEnd+linkEndMarker); err != nil {
 				return err
 			}
 		}
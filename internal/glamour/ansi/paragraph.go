@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-
-	"github.com/muesli/reflow/wordwrap"
 )
 
 // A ParagraphElement is used to render individual paragraphs.
@@ -40,14 +38,12 @@ func (e *ParagraphElement) Finish(w io.Writer, ctx RenderContext) error {
 
 	mw := NewMarginWriter(ctx, w, rules)
 	if len(strings.TrimSpace(bs.Current().Block.String())) > 0 {
-		flow := wordwrap.NewWriter(int(bs.Width(ctx))) //nolint: gosec
-		flow.KeepNewlines = true
-		_, _ = flow.Write(bs.Current().Block.Bytes())
-		if err := flow.Close(); err != nil {
+		wrapped, err := wrapText(bs.Current().Block.String(), int(bs.Width(ctx)), true) //nolint: gosec
+		if err != nil {
 			return fmt.Errorf("glamour: error closing flow: %w", err)
 		}
 
-		_, err := mw.Write(flow.Bytes())
+		_, err = mw.Write(wrapped)
 		if err != nil {
 			return err
 		}
@@ -25,6 +25,20 @@ type Options struct {
 	ColorProfile     termenv.Profile
 	Styles           StyleConfig
 	ChromaFormatter  string
+
+	// Hyperlinks wraps link text in OSC 8 sequences, resolving relative
+	// targets against HyperlinkBaseURL; links that stay relative are plain.
+	Hyperlinks       bool
+	HyperlinkBaseURL string
+
+	// WideBlocks renders tables and code blocks at their natural width
+	// instead of squeezing or truncating them to WordWrap, for viewers that
+	// scroll horizontally.
+	WideBlocks bool
+
+	// SourceMap records where tracked nodes were rendered, for
+	// ANSIRenderer.SourceMap.
+	SourceMap bool
 }
 
 // ANSIRenderer renders markdown content as ANSI escaped sequences.
@@ -107,13 +121,43 @@ func (r *ANSIRenderer) renderNode(w util.BufWriter, source []byte, node ast.Node
 			writeTo = io.Writer(bs.Current().Block)
 		}
 
+		// the document's own prefix bypasses the flush, so the source map
+		// must know what came before it
+		var docStart *bytes.Buffer
+		if node.Type() == ast.TypeDocument {
+			docStart = &bytes.Buffer{}
+			writeTo = docStart
+		}
+
 		_, _ = io.WriteString(writeTo, e.Entering)
+		start, end := r.context.sourceMap.marks(r.context, node, source)
+		if le, ok := e.Renderer.(*LinkElement); ok {
+			// links mark their text, not their URL
+			le.SourceStart, le.SourceEnd = start, end
+			start, end = "", ""
+		}
+		depth, at := bs.Len(), 0
+		if buf, ok := writeTo.(*bytes.Buffer); ok {
+			at = buf.Len()
+		}
 		if e.Renderer != nil {
 			err := e.Renderer.Render(writeTo, r.context)
 			if err != nil {
 				return ast.WalkStop, fmt.Errorf("glamour: error rendering: %w", err)
 			}
 		}
+		if start != "" {
+			r.context.sourceMap.markStart(writeTo, bs, depth, at, start)
+		}
+		if end != "" {
+			r.context.sourceMap.deferEnd(node, end, bs.Len() > depth)
+		}
+		if docStart != nil {
+			r.context.sourceMap.prefix = docStart.String()
+			if _, err := w.Write(docStart.Bytes()); err != nil {
+				return ast.WalkStop, fmt.Errorf("glamour: error rendering: %w", err)
+			}
+		}
 	} else {
 		// everything below the Document element gets rendered into a block buffer
 		if bs.Len() > 0 {
@@ -129,15 +173,25 @@ func (r *ANSIRenderer) renderNode(w util.BufWriter, source []byte, node ast.Node
 			writeTo = docBuf
 		}
 
+		end, pushed := r.context.sourceMap.takeEnd(node)
+		if end != "" && pushed {
+			_, _ = io.WriteString(bs.Current().Block, end)
+		}
 		if e.Finisher != nil {
 			err := e.Finisher.Finish(writeTo, r.context)
 			if err != nil {
 				return ast.WalkStop, fmt.Errorf("glamour: error finishing render: %w", err)
 			}
 		}
+		if end != "" && !pushed && bs.Len() > 0 {
+			_, _ = io.WriteString(bs.Current().Block, end)
+		}
 
 		if docBuf != nil {
-			restored := r.context.imageTokens.restore(docBuf.String())
+			restored := r.context.wideBlocks.restore(docBuf.String())
+			restored = r.context.imageTokens.restore(restored)
+			restored = r.context.hyperlinks.restore(restored)
+			restored = r.context.sourceMap.extract(restored)
 			if _, err := io.WriteString(w, restored); err != nil {
 				return ast.WalkStop, fmt.Errorf("glamour: error flushing document: %w", err)
 			}
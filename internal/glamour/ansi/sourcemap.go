@@ -0,0 +1,341 @@
+package ansi
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark/ast"
+	astext "github.com/yuin/goldmark/extension/ast"
+)
+
+// With Options.SourceMap, the renderer writes a zero-width mark where each
+// tracked node starts and ends, numbered in a sourceMapTable. The marks ride
+// through the wrap and margin writers like the position markers; when the
+// document is flushed they are removed and their positions in the output
+// are recorded as SourceMapEntry values (sourceMapTable.extract).
+const (
+	sourceStartRune = '\u206C' // INHIBIT ARABIC FORM SHAPING
+	sourceEndRune   = '\u206D' // ACTIVATE ARABIC FORM SHAPING
+	sourceZeroRune  = '\u206E' // NATIONAL DIGIT SHAPES
+	sourceOneRune   = '\u206F' // NOMINAL DIGIT SHAPES
+)
+
+// SourceKind tells what kind of node a SourceMapEntry stands for.
+type SourceKind int
+
+const (
+	SourceBlock   SourceKind = iota // a paragraph, rule or table
+	SourceHeading                   // a heading
+	SourceLink                      // a link or autolink
+	SourceCode                      // a code block, rendered a line per source line
+)
+
+// SourceMapEntry ties a node of the markdown source to where it was
+// rendered. Lines count from 0 in the rendered output; positions within a
+// line are byte offsets into it, escape sequences included.
+type SourceMapEntry struct {
+	Kind       SourceKind
+	Offset     int // byte offset in the source, see SourceOffset
+	SourceLine int // 1-based line of Offset, 0 if unknown
+
+	Line, Byte       int // where the node starts
+	EndLine, EndByte int // where it ends
+}
+
+// SourceOffset returns the byte offset in the source a node is mapped by:
+// the start of its first line or text, else the end of the nearest text
+// before it. It returns -1 if there is neither, as for a node built from
+// markup that has no text position.
+func SourceOffset(n ast.Node) int {
+	if o := startOffset(n); o >= 0 {
+		return o
+	}
+	for ; n != nil; n = n.Parent() {
+		for p := n.PreviousSibling(); p != nil; p = p.PreviousSibling() {
+			if o := endOffset(p); o >= 0 {
+				return o
+			}
+		}
+		if n.Parent() != nil {
+			if o := blockStart(n.Parent()); o >= 0 {
+				return o
+			}
+		}
+	}
+	return -1
+}
+
+func blockStart(n ast.Node) int {
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		return n.Lines().At(0).Start
+	}
+	return -1
+}
+
+// startOffset returns where the first line or text of n starts.
+func startOffset(n ast.Node) int {
+	if t, ok := n.(*ast.Text); ok {
+		return t.Segment.Start
+	}
+	if o := blockStart(n); o >= 0 {
+		return o
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if o := startOffset(c); o >= 0 {
+			return o
+		}
+	}
+	return -1
+}
+
+// endOffset returns where the last line or text of n ends.
+func endOffset(n ast.Node) int {
+	if t, ok := n.(*ast.Text); ok {
+		return t.Segment.Stop
+	}
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		return n.Lines().At(n.Lines().Len() - 1).Stop
+	}
+	for c := n.LastChild(); c != nil; c = c.PreviousSibling() {
+		if o := endOffset(c); o >= 0 {
+			return o
+		}
+	}
+	return -1
+}
+
+// sourceKind returns the kind of entry a node gets, if it is tracked.
+func sourceKind(n ast.Node) (SourceKind, bool) {
+	switch n.Kind() {
+	case ast.KindHeading:
+		return SourceHeading, true
+	case ast.KindLink, ast.KindAutoLink:
+		return SourceLink, true
+	case ast.KindCodeBlock, ast.KindFencedCodeBlock:
+		return SourceCode, true
+	case ast.KindParagraph, ast.KindTextBlock, ast.KindThematicBreak, astext.KindTable:
+		return SourceBlock, true
+	}
+	return 0, false
+}
+
+// sourceMapTable numbers the tracked nodes of the document being rendered.
+// It hangs off RenderContext via a pointer so the by-value context copies
+// share one table.
+type sourceMapTable struct {
+	source     []byte
+	lineStarts []int  // byte offsets of the source lines, built on demand
+	prefix     string // written before the flushed document
+	entries    []SourceMapEntry
+	ends       map[ast.Node]sourceEnd // end marks of the nodes being rendered
+	last       []SourceMapEntry       // the entries of the last flushed document
+}
+
+// marks returns the marks to write where node starts and ends, or empty
+// strings if source maps are disabled or the node is not tracked.
+func (t *sourceMapTable) marks(ctx RenderContext, node ast.Node, source []byte) (start, end string) {
+	if !ctx.options.SourceMap {
+		return "", ""
+	}
+	kind, ok := sourceKind(node)
+	if !ok {
+		return "", ""
+	}
+	if len(t.entries) == 0 {
+		t.source, t.lineStarts = source, nil
+	}
+	offset := SourceOffset(node)
+	t.entries = append(t.entries, SourceMapEntry{
+		Kind:       kind,
+		Offset:     offset,
+		SourceLine: t.sourceLine(offset),
+		Line:       -1,
+		EndLine:    -1,
+	})
+	id := len(t.entries) - 1
+	return sourceMark(sourceStartRune, id), sourceMark(sourceEndRune, id)
+}
+
+// sourceLine returns the 1-based line of offset in the source.
+func (t *sourceMapTable) sourceLine(offset int) int {
+	if offset < 0 || offset > len(t.source) {
+		return 0
+	}
+	if t.lineStarts == nil {
+		t.lineStarts = []int{0}
+		for i, c := range t.source {
+			if c == '\n' {
+				t.lineStarts = append(t.lineStarts, i+1)
+			}
+		}
+	}
+	return sort.SearchInts(t.lineStarts, offset+1)
+}
+
+type sourceEnd struct {
+	mark   string
+	pushed bool // the node pushed a block, so it ends before its finisher
+}
+
+// markStart writes the start mark of a node that was just rendered to w. A
+// node that pushed a block starts in it, after its prefix; any other starts
+// where its output to w, at byte at, does.
+func (t *sourceMapTable) markStart(w io.Writer, bs *BlockStack, depth, at int, mark string) {
+	if bs.Len() > depth {
+		_, _ = io.WriteString(bs.Current().Block, mark)
+		return
+	}
+	buf, ok := w.(*bytes.Buffer)
+	if !ok {
+		_, _ = io.WriteString(w, mark)
+		return
+	}
+	tail := append([]byte(nil), buf.Bytes()[at:]...)
+	buf.Truncate(at)
+	buf.WriteString(mark)
+	buf.Write(tail)
+}
+
+// deferEnd keeps the end mark of node until the node is exited.
+func (t *sourceMapTable) deferEnd(node ast.Node, mark string, pushed bool) {
+	if t.ends == nil {
+		t.ends = map[ast.Node]sourceEnd{}
+	}
+	t.ends[node] = sourceEnd{mark, pushed}
+}
+
+// takeEnd returns the end mark kept for node.
+func (t *sourceMapTable) takeEnd(node ast.Node) (string, bool) {
+	e, ok := t.ends[node]
+	if !ok {
+		return "", false
+	}
+	delete(t.ends, node)
+	return e.mark, e.pushed
+}
+
+// sourceMark encodes id in binary between two delim runes.
+func sourceMark(delim rune, id int) string {
+	var b strings.Builder
+	b.WriteRune(delim)
+	for i := bitLen(id) - 1; i >= 0; i-- {
+		if id>>i&1 == 1 {
+			b.WriteRune(sourceOneRune)
+		} else {
+			b.WriteRune(sourceZeroRune)
+		}
+	}
+	b.WriteRune(delim)
+	return b.String()
+}
+
+func bitLen(id int) int {
+	n := 1
+	for id>>n > 0 {
+		n++
+	}
+	return n
+}
+
+// extract removes the marks from s and records where they were. Entries
+// whose marks got lost stay out of the map. A start mark that ends a line
+// is moved to the start of the next one, where its node's text begins, and
+// an end mark that starts a line to the end of the one before.
+func (t *sourceMapTable) extract(s string) string {
+	if t == nil {
+		return s
+	}
+	if len(t.entries) == 0 {
+		t.prefix, t.last = "", nil
+		return s
+	}
+	// b holds the prefix too, so positions count from the first line
+	var b strings.Builder
+	b.WriteString(t.prefix)
+	line := strings.Count(t.prefix, "\n")
+	lineStart := strings.LastIndex(t.prefix, "\n") + 1
+	prevLineStart := strings.LastIndex(t.prefix[:max(lineStart-1, 0)], "\n") + 1
+	var pending []int // start marks waiting for text on their line
+	for i := 0; i < len(s); {
+		r, id, n := readSourceMark(s[i:])
+		if n == 0 {
+			if s[i] == '\n' {
+				kept := pending[:0]
+				for _, p := range pending {
+					if rest := b.String()[lineStart+t.entries[p].Byte:]; strings.TrimSpace(xansi.Strip(rest)) == "" {
+						t.entries[p].Line, t.entries[p].Byte = line+1, 0
+						kept = append(kept, p)
+					}
+				}
+				pending = kept
+				line++
+				prevLineStart, lineStart = lineStart, b.Len()+1
+			}
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		i += n
+		if id >= len(t.entries) {
+			continue
+		}
+		e := &t.entries[id]
+		if r == sourceStartRune {
+			e.Line, e.Byte = line, b.Len()-lineStart
+			pending = append(pending, id)
+		} else {
+			e.EndLine, e.EndByte = line, b.Len()-lineStart
+			if line > e.Line && strings.TrimSpace(xansi.Strip(b.String()[lineStart:])) == "" {
+				// a block ending with a line break ends on the line before
+				e.EndLine, e.EndByte = line-1, lineStart-1-prevLineStart
+			}
+		}
+	}
+
+	t.last = nil
+	for _, e := range t.entries {
+		if e.Line >= 0 && e.EndLine >= e.Line {
+			if e.EndLine == e.Line && e.EndByte < e.Byte {
+				e.EndByte = e.Byte
+			}
+			t.last = append(t.last, e)
+		}
+	}
+	out := b.String()[len(t.prefix):]
+	t.prefix, t.entries, t.ends, t.source, t.lineStarts = "", nil, nil, nil, nil
+	return out
+}
+
+// readSourceMark decodes the mark at the start of s, returning its delimiter,
+// id and length, or a zero length if there is none.
+func readSourceMark(s string) (delim rune, id, n int) {
+	if !strings.HasPrefix(s, string(sourceStartRune)) && !strings.HasPrefix(s, string(sourceEndRune)) {
+		return 0, 0, 0
+	}
+	for i, r := range s {
+		if i == 0 {
+			delim = r
+			continue
+		}
+		switch r {
+		case sourceZeroRune:
+			id <<= 1
+		case sourceOneRune:
+			id = id<<1 | 1
+		case delim:
+			return delim, id, i + len(string(r))
+		default:
+			return 0, 0, 0
+		}
+	}
+	return 0, 0, 0
+}
+
+// SourceMap returns the entries recorded for the last document rendered
+// with Options.SourceMap, in document order.
+func (r *ANSIRenderer) SourceMap() []SourceMapEntry {
+	return r.context.sourceMap.last
+}
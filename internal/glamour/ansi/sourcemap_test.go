@@ -0,0 +1,68 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+)
+
+// renderSourceMap renders markdown with a source map and returns the output
+// lines and the map.
+func renderSourceMap(t *testing.T, md string, width int) ([]string, []SourceMapEntry) {
+	t.Helper()
+	out, ar := renderANSI(t, md, Options{WordWrap: width, ColorProfile: termenv.Ascii, SourceMap: true})
+	return strings.Split(out, "\n"), ar.SourceMap()
+}
+
+func TestSourceMap(t *testing.T) {
+	md := "# Title\n\nSome text with a [link](https://example.com) that wraps around.\n\n```go\nfmt.Println()\n```\n"
+	lines, entries := renderSourceMap(t, md, 30)
+	for _, l := range lines {
+		if strings.ContainsAny(l, string([]rune{sourceStartRune, sourceEndRune, sourceZeroRune, sourceOneRune})) {
+			t.Fatalf("source mark left in %q", l)
+		}
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %+v", entries)
+	}
+
+	// text returns what an entry spans on its first line
+	text := func(e SourceMapEntry) string {
+		end := len(lines[e.Line])
+		if e.EndLine == e.Line {
+			end = e.EndByte
+		}
+		return strings.Trim(lines[e.Line][e.Byte:end], "\u200b\u200c\u200d\u2060 ")
+	}
+	heading, para, link, code := entries[0], entries[1], entries[2], entries[3]
+	if heading.Kind != SourceHeading || heading.SourceLine != 1 || text(heading) != "Title" {
+		t.Errorf("unexpected heading entry %+v", heading)
+	}
+	if para.Kind != SourceBlock || para.SourceLine != 3 || para.EndLine <= para.Line {
+		t.Errorf("unexpected paragraph entry %+v", para)
+	}
+	if link.Kind != SourceLink || link.Offset != strings.Index(md, "link]") || text(link) != "link" {
+		t.Errorf("unexpected link entry %+v", link)
+	}
+	if code.Kind != SourceCode || code.SourceLine != 6 || text(code) != "fmt.Println()" {
+		t.Errorf("unexpected code block entry %+v", code)
+	}
+}
+
+func TestSourceMap_Disabled(t *testing.T) {
+	_, ar := renderANSI(t, "# Title\n\n[link](x)\n", Options{WordWrap: 40, ColorProfile: termenv.Ascii})
+	if ar.SourceMap() != nil {
+		t.Errorf("unexpected source map %+v", ar.SourceMap())
+	}
+}
+
+func TestSourceMark(t *testing.T) {
+	for _, id := range []int{0, 1, 2, 5, 1000} {
+		mark := sourceMark(sourceEndRune, id)
+		delim, got, n := readSourceMark(mark + "x")
+		if delim != sourceEndRune || got != id || n != len(mark) {
+			t.Errorf("mark of %d read as %c %d %d", id, delim, got, n)
+		}
+	}
+}
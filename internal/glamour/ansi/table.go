@@ -137,11 +137,16 @@ func (e *TableElement) Finish(_ io.Writer, ctx RenderContext) error {
 	rendered := ctx.table.lipgloss.String()
 
 	// if natural width exceeds available width, re-render with constraint
-	if naturalWidth(rendered) > ctx.table.maxWidth {
+	// unless wide blocks are scrolled by the viewer
+	if !ctx.options.WideBlocks && naturalWidth(rendered) > ctx.table.maxWidth {
 		ctx.table.lipgloss.Width(ctx.table.maxWidth)
 		rendered = ctx.table.lipgloss.String()
 	}
 
+	if ctx.options.WideBlocks {
+		rendered = ctx.wideBlocks.mask(rendered)
+	}
+
 	ow := ctx.blockStack.Current().Block
 	if _, err := ow.WriteString(rendered); err != nil {
 		return fmt.Errorf("glamour: error writing to buffer: %w", err)
@@ -65,14 +65,15 @@ func (e *TableElement) printTableLinks(ctx RenderContext) {
 			style = ctx.options.Styles.Image
 		}
 
-		// XXX(@andreynering): Once #411 is merged, use the hyperlink
-		// protocol to make the link work for the full URL even if we
-		// show it truncated.
+		// with hyperlinks, the truncated href still opens the full URL
 		linkMaxWidth := max(termWidth-xansi.StringWidth(linkText)-1, 0)
 		token := xansi.Truncate(link.href, linkMaxWidth, "…")
 
+		start, end := ctx.hyperlinks.wrap(ctx, link.href)
+		_, _ = io.WriteString(w, start)
 		el := &BaseElement{Token: token, Style: style}
 		_ = el.Render(w, ctx)
+		_, _ = io.WriteString(w, end)
 	}
 
 	renderString := func(str string) {
@@ -204,6 +205,10 @@ func nodeContent(node ast.Node, source []byte) ([]byte, error) {
 				if _, err := builder.Write(nn.Segment.Value(source)); err != nil {
 					return fmt.Errorf("glamour: error writing text node: %w", err)
 				}
+			case *ast.String:
+				if _, err := builder.Write(nn.Value); err != nil {
+					return fmt.Errorf("glamour: error writing string node: %w", err)
+				}
 			default:
 				if err := traverse(nn); err != nil {
 					return err
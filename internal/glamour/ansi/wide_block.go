@@ -0,0 +1,72 @@
+package ansi
+
+import (
+	"strings"
+	"unicode"
+)
+
+// With Options.WideBlocks, tables and code blocks are rendered wider than the
+// wrap width, but the block element's ansi.Wordwrap would still break their
+// lines at spaces and punctuation. So their breakable runes are swapped for
+// a one-column placeholder rune that is not a breakpoint (wideBlockTable.mask)
+// and put back when the document is flushed, like image tokens. A line with
+// no breakpoints is one long word, which the wrap writers leave intact.
+// U+E001 sits next to the image placeholder in the Private Use Area.
+const wideBlockPlaceholderRune = '\ue001'
+
+// wideBlockBreakpoints are the runes the block element wraps on besides
+// whitespace; the hyphen is always a breakpoint.
+const wideBlockBreakpoints = " ,.;-+|"
+
+// wideBlockTable records masked runes in emission order. It hangs off
+// RenderContext via a pointer so the by-value context copies share one table.
+type wideBlockTable struct {
+	runes []rune
+}
+
+// mask returns s with every breakable rune outside escape sequences replaced
+// by the placeholder. Newlines are kept.
+func (t *wideBlockTable) mask(s string) string {
+	var b strings.Builder
+	esc := 0 // 1 after ESC, 2 inside a CSI sequence
+	for _, r := range s {
+		switch {
+		case esc == 1:
+			esc = 0
+			if r == '[' {
+				esc = 2
+			}
+		case esc == 2:
+			if r >= '@' && r <= '~' { // final byte
+				esc = 0
+			}
+		case r == '\x1b':
+			esc = 1
+		case r != '\n' && (unicode.IsSpace(r) || strings.ContainsRune(wideBlockBreakpoints, r)):
+			t.runes = append(t.runes, r)
+			b.WriteRune(wideBlockPlaceholderRune)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// restore puts the masked runes back in order. It is a no-op when nothing
+// was masked.
+func (t *wideBlockTable) restore(s string) string {
+	if t == nil || len(t.runes) == 0 {
+		return s
+	}
+	var b strings.Builder
+	i := 0
+	for _, r := range s {
+		if r == wideBlockPlaceholderRune && i < len(t.runes) {
+			b.WriteRune(t.runes[i])
+			i++
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package ansi
+
+import (
+	"strings"
+	"testing"
+
+	xansi "github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func renderWide(t *testing.T, md string, wide bool) string {
+	t.Helper()
+	out, _ := renderANSI(t, md, Options{WordWrap: 20, ColorProfile: termenv.TrueColor, WideBlocks: wide})
+	return out
+}
+
+func widestLine(s string) int {
+	maxW := 0
+	for _, line := range strings.Split(s, "\n") {
+		if w := xansi.StringWidth(line); w > maxW {
+			maxW = w
+		}
+	}
+	return maxW
+}
+
+func TestWideBlocks_CodeBlock(t *testing.T) {
+	line := "fmt.Println(\"a line much wider than twenty columns\")"
+	md := "```\n" + line + "\n```\n"
+
+	narrow := xansi.Strip(renderWide(t, md, false))
+	if strings.Contains(narrow, line) {
+		t.Error("narrow code block should truncate the long line")
+	}
+
+	wide := xansi.Strip(renderWide(t, md, true))
+	if !strings.Contains(wide, line) {
+		t.Errorf("wide code block should keep the whole line:\n%s", wide)
+	}
+	// borders stay aligned with the widened content
+	var widths []int
+	for _, l := range strings.Split(wide, "\n") {
+		if strings.ContainsAny(l, "╭│╰") {
+			widths = append(widths, xansi.StringWidth(l))
+		}
+	}
+	for _, w := range widths {
+		if w != widths[0] {
+			t.Fatalf("border lines differ in width: %v", widths)
+		}
+	}
+}
+
+func TestWideBlocks_Table(t *testing.T) {
+	md := "| name | description |\n|---|---|\n| wide | a cell with enough words to exceed the wrap width |\n"
+
+	if w := widestLine(renderWide(t, md, false)); w > 20 {
+		t.Errorf("narrow table is %d columns wide, want at most 20", w)
+	}
+	wide := renderWide(t, md, true)
+	if !strings.Contains(xansi.Strip(wide), "a cell with enough words to exceed the wrap width") {
+		t.Errorf("wide table should keep cells on one line:\n%s", wide)
+	}
+}
@@ -37,6 +37,7 @@ type TermRendererOption func(*TermRenderer) error
 type TermRenderer struct {
 	md          goldmark.Markdown
 	ansiOptions ansi.Options
+	ansi        *ansi.ANSIRenderer
 	buf         bytes.Buffer
 	renderBuf   bytes.Buffer
 }
@@ -81,6 +82,7 @@ func NewTermRenderer(options ...TermRendererOption) (*TermRenderer, error) {
 			),
 			goldmark.WithParserOptions(
 				parser.WithAutoHeadingID(),
+				parser.WithASTTransformers(util.Prioritized(ansi.HTMLTransformer{}, highPriority)),
 			),
 		),
 		ansiOptions: ansi.Options{
@@ -93,11 +95,11 @@ func NewTermRenderer(options ...TermRendererOption) (*TermRenderer, error) {
 			return nil, err
 		}
 	}
-	ar := ansi.NewRenderer(tr.ansiOptions)
+	tr.ansi = ansi.NewRenderer(tr.ansiOptions)
 	tr.md.SetRenderer(
 		renderer.NewRenderer(
 			renderer.WithNodeRenderers(
-				util.Prioritized(ar, highPriority),
+				util.Prioritized(tr.ansi, highPriority),
 			),
 		),
 	)
@@ -112,6 +114,36 @@ func WithBaseURL(baseURL string) TermRendererOption {
 	}
 }
 
+// WithHyperlinks wraps link text in OSC 8 hyperlink sequences so terminals
+// can open links. Relative targets are resolved against baseURL (e.g.
+// "file:///home/me/docs/"); links that stay relative are left plain.
+func WithHyperlinks(baseURL string) TermRendererOption {
+	return func(tr *TermRenderer) error {
+		tr.ansiOptions.Hyperlinks = true
+		tr.ansiOptions.HyperlinkBaseURL = baseURL
+		return nil
+	}
+}
+
+// WithWideBlocks renders tables and code blocks at their natural width
+// rather than fitting them to the word wrap, for viewers that scroll
+// horizontally.
+func WithWideBlocks() TermRendererOption {
+	return func(tr *TermRenderer) error {
+		tr.ansiOptions.WideBlocks = true
+		return nil
+	}
+}
+
+// WithSourceMap records where headings, links and blocks are rendered, for
+// TermRenderer.SourceMap.
+func WithSourceMap() TermRendererOption {
+	return func(tr *TermRenderer) error {
+		tr.ansiOptions.SourceMap = true
+		return nil
+	}
+}
+
 // WithColorProfile sets the TermRenderer's color profile
 // (TrueColor / ANSI256 / ANSI).
 func WithColorProfile(profile termenv.Profile) TermRendererOption {
@@ -299,6 +331,12 @@ func (tr *TermRenderer) RenderBytes(in []byte) ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// SourceMap returns the source map of the last rendered markdown, if the
+// renderer was created WithSourceMap.
+func (tr *TermRenderer) SourceMap() []ansi.SourceMapEntry {
+	return tr.ansi.SourceMap()
+}
+
 func getEnvironmentStyle() string {
 	glamourStyle := os.Getenv("GLAMOUR_STYLE")
 	if len(glamourStyle) == 0 {
@@ -0,0 +1,194 @@
+package loaders
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// indexFileNames are shown in place of a generated listing, in order of
+// preference. Matching is case-insensitive.
+var indexFileNames = []string{"README.md", "README.markdown", "index.md", "index.markdown"}
+
+// markdownExtensions are the file extensions listed in generated indexes.
+var markdownExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".mdown": true, ".mkd": true, ".mkdn": true,
+}
+
+// titleScanLimit bounds how much of each file is read when extracting titles.
+const titleScanLimit = 64 * 1024
+
+// DirectoryIndex returns the markdown shown for a local directory: the
+// directory's README.md or index.md when present, otherwise a generated
+// listing of its markdown files and subdirectories. Listed files are titled
+// by their frontmatter title or first heading. Hidden entries are skipped.
+//
+// Links in the listing are relative to dir, so the result should be loaded
+// with navidown.DirectorySourcePath(dir) as its source path.
+func DirectoryIndex(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	if index := findIndexFile(entries); index != "" {
+		content, err := os.ReadFile(filepath.Join(dir, index))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", index, err)
+		}
+		return string(content), nil
+	}
+
+	var dirs, docs []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		isDir := e.IsDir()
+		if e.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		switch {
+		case isDir:
+			dirs = append(dirs, name)
+		case markdownExtensions[strings.ToLower(filepath.Ext(name))]:
+			docs = append(docs, name)
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(docs)
+
+	var b strings.Builder
+	b.WriteString("# " + escapeMarkdown(directoryTitle(dir)) + "\n\n")
+	b.WriteString("[Parent directory](../)\n")
+
+	if len(dirs) > 0 {
+		b.WriteString("\n## Directories\n\n")
+		for _, name := range dirs {
+			fmt.Fprintf(&b, "- [%s/](%s)\n", escapeMarkdown(name), markdownDestination(name+"/"))
+		}
+	}
+
+	if len(docs) > 0 {
+		b.WriteString("\n## Documents\n\n")
+		for _, name := range docs {
+			title := markdownTitle(filepath.Join(dir, name))
+			link := markdownDestination(name)
+			if title == "" || title == name {
+				fmt.Fprintf(&b, "- [%s](%s)\n", escapeMarkdown(name), link)
+				continue
+			}
+			fmt.Fprintf(&b, "- [%s](%s) — `%s`\n", escapeMarkdown(title), link, name)
+		}
+	}
+
+	if len(dirs) == 0 && len(docs) == 0 {
+		b.WriteString("\n*No markdown files or subdirectories.*\n")
+	}
+	return b.String(), nil
+}
+
+func findIndexFile(entries []os.DirEntry) string {
+	for _, want := range indexFileNames {
+		for _, e := range entries {
+			if !e.IsDir() && strings.EqualFold(e.Name(), want) {
+				return e.Name()
+			}
+		}
+	}
+	return ""
+}
+
+func directoryTitle(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	if base := filepath.Base(abs); base != "" && base != string(filepath.Separator) && base != "." {
+		return base
+	}
+	return abs
+}
+
+var (
+	atxHeading      = regexp.MustCompile(`^ {0,3}#{1,6}[ \t]+(.+?)[ \t#]*$`)
+	setextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	yamlTitle       = regexp.MustCompile(`^title:[ \t]*(.+?)[ \t]*$`)
+	tomlTitle       = regexp.MustCompile(`^title[ \t]*=[ \t]*(.+?)[ \t]*$`)
+)
+
+// markdownTitle returns a file's frontmatter title, or failing that its first
+// ATX or setext heading. Returns "" if neither is found.
+func markdownTitle(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, titleScanLimit))
+	var prev string
+	inFence := false
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if first {
+			first = false
+			line = strings.TrimPrefix(line, "\ufeff")
+			if line == "---" || line == "+++" {
+				if title := frontmatterTitle(scanner, line); title != "" {
+					return title
+				}
+				continue
+			}
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			prev = ""
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+		if prev != "" && setextUnderline.MatchString(line) {
+			return prev
+		}
+		prev = trimmed
+	}
+	return ""
+}
+
+// frontmatterTitle consumes a YAML (---) or TOML (+++) frontmatter block and
+// returns its title field, unquoted.
+func frontmatterTitle(scanner *bufio.Scanner, delim string) string {
+	re := yamlTitle
+	if delim == "+++" {
+		re = tomlTitle
+	}
+	title := ""
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == delim || (delim == "---" && line == "...") {
+			break
+		}
+		if m := re.FindStringSubmatch(line); m != nil && title == "" {
+			title = strings.Trim(m[1], `"'`)
+		}
+	}
+	return title
+}
@@ -0,0 +1,111 @@
+package loaders
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boolean-maybe/navidown/navidown"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirectoryIndex_PrefersReadme(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "readme.md"), "# Project\n")
+	writeTestFile(t, filepath.Join(dir, "index.md"), "# Index\n")
+
+	got, err := DirectoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "# Project\n" {
+		t.Errorf("expected README content, got %q", got)
+	}
+}
+
+func TestDirectoryIndex_GeneratedListing(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "guide.md"), "Intro text\n\n## Install Guide\n")
+	writeTestFile(t, filepath.Join(dir, "api.md"), "---\ntitle: \"API Reference\"\nlayout: doc\n---\n# Ignored\n")
+	writeTestFile(t, filepath.Join(dir, "setext.markdown"), "Setext Title\n============\n")
+	writeTestFile(t, filepath.Join(dir, "plain.md"), "no headings here\n")
+	writeTestFile(t, filepath.Join(dir, "fenced.md"), "```\n# not a heading\n```\n# Real\n")
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "skip me")
+	writeTestFile(t, filepath.Join(dir, ".hidden.md"), "# Hidden\n")
+	writeTestFile(t, filepath.Join(dir, "sub", "x.md"), "# X\n")
+	writeTestFile(t, filepath.Join(dir, "my docs", "y.md"), "# Y\n")
+
+	got, err := DirectoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wants := []string{
+		"# " + filepath.Base(dir),
+		"[Parent directory](../)",
+		"- [my docs/](<my docs/>)\n- [sub/](sub/)",
+		"- [API Reference](api.md) — `api.md`",
+		"- [Real](fenced.md) — `fenced.md`",
+		"- [Install Guide](guide.md) — `guide.md`",
+		"- [plain.md](plain.md)",
+		"- [Setext Title](setext.markdown) — `setext.markdown`",
+	}
+	for _, want := range wants {
+		if !strings.Contains(got, want) {
+			t.Errorf("listing missing %q\n--- got ---\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"notes.txt", "Hidden", "Ignored"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("listing should not contain %q", unwanted)
+		}
+	}
+}
+
+func TestDirectoryIndex_LinksNavigable(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.md"), "# A\n")
+	writeTestFile(t, filepath.Join(dir, "sub", "b.md"), "# B\n")
+
+	listing, err := DirectoryIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := navidown.DirectorySourcePath(dir)
+	f := &FileHTTP{}
+
+	got, err := f.FetchContent(navidown.NavElement{URL: "a.md", SourceFilePath: source})
+	if err != nil || got != "# A\n" {
+		t.Fatalf("following file link: %q, %v\nlisting:\n%s", got, err, listing)
+	}
+
+	got, err = f.FetchContent(navidown.NavElement{URL: "sub/", SourceFilePath: source})
+	if err != nil || !strings.Contains(got, "[B](b.md)") {
+		t.Fatalf("following directory link: %q, %v", got, err)
+	}
+}
+
+func TestFileHTTP_DirectoryLink(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "index.md")
+	writeTestFile(t, source, "[docs](docs)\n")
+	writeTestFile(t, filepath.Join(dir, "docs", "README.md"), "# Docs\n")
+
+	got, err := (&FileHTTP{}).FetchContent(navidown.NavElement{URL: "docs", SourceFilePath: source})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "# Docs\n" {
+		t.Errorf("got %q, want README content", got)
+	}
+}
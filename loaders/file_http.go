@@ -2,21 +2,43 @@ package loaders
 
 import (
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/boolean-maybe/navidown/navidown"
 )
 
 // FileHTTP implements navidown.ContentProvider to fetch content from HTTP(S) URLs and local files.
+// Source files such as main.go or Makefile are returned wrapped in a fenced
+// code block so they are syntax highlighted; see SourceAsMarkdown.
 type FileHTTP struct {
 	// SearchRoots are extra directories to try when resolving relative file links.
 	SearchRoots []string
 
+	// Policy decides which local files links may open; nil means
+	// navidown.DefaultPathPolicy. Refusals are *navidown.PathPolicyError.
+	Policy *navidown.PathPolicy
+
 	// Client is used for HTTP(S) requests; if nil, http.DefaultClient is used.
 	Client *http.Client
+
+	// Cache, when set, stores fetched documents on disk and revalidates them
+	// with conditional requests. Share it with ImageResolver.SetHTTPCache.
+	Cache *navidown.HTTPCache
+
+	// Credentials, when set, attaches per-host authentication headers.
+	// Credentials are re-resolved on redirect so they never reach other hosts.
+	Credentials navidown.CredentialResolver
+
+	// Rewriter, when set, maps http(s) URLs before fetching (e.g. forge blob
+	// URLs to raw-content URLs). See navidown.ForgeRewriter.
+	Rewriter navidown.URLRewriter
+
+	// RawHTML disables HTML-to-markdown conversion, returning HTML pages
+	// (text/html responses and local .html files) unchanged.
+	RawHTML bool
 }
 
 func (f *FileHTTP) FetchContent(elem navidown.NavElement) (string, error) {
@@ -25,49 +47,102 @@ func (f *FileHTTP) FetchContent(elem navidown.NavElement) (string, error) {
 		return "", nil
 	}
 
-	resolvedPath, err := navidown.ResolveMarkdownPath(url, elem.SourceFilePath, f.SearchRoots)
+	resolvedPath, err := navidown.ResolveMarkdownPathWithPolicy(url, elem.SourceFilePath, f.SearchRoots, f.Policy)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve path %q: %w", url, err)
 	}
 
 	if strings.HasPrefix(resolvedPath, "http://") || strings.HasPrefix(resolvedPath, "https://") {
-		return f.fetchFromWeb(resolvedPath)
+		return f.fetchFromWeb(navidown.RewriteFetchURL(f.Rewriter, resolvedPath))
 	}
 	return f.fetchFromLocal(resolvedPath)
 }
 
-func (f *FileHTTP) fetchFromWeb(url string) (content string, err error) {
-	client := f.Client
+// ContentType returns the Content-Type of an http(s) URL, for use as a
+// navidown.LinkDispatcher probe. A cached response answers without network
+// access; otherwise a HEAD request is sent with the configured credentials.
+func (f *FileHTTP) ContentType(rawURL string) (string, error) {
+	rawURL = navidown.RewriteFetchURL(f.Rewriter, rawURL)
+	if entry, ok := f.Cache.Lookup(rawURL); ok && entry.ContentType != "" {
+		return entry.ContentType, nil
+	}
+
+	client, req, err := navidown.AuthorizedRequest(f.Client, f.Credentials, rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Method = http.MethodHead
 	if client == nil {
 		client = http.DefaultClient
 	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to probe URL: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned non-200 status: %d", resp.StatusCode)
+	}
+	return resp.Header.Get("Content-Type"), nil
+}
 
-	resp, err := client.Get(url)
+func (f *FileHTTP) fetchFromWeb(url string) (string, error) {
+	client, req, err := navidown.AuthorizedRequest(f.Client, f.Credentials, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	resp, err := f.Cache.Do(client, req)
 	if err != nil {
 		return "", fmt.Errorf("failed to fetch URL: %w", err)
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("failed to close response body: %w", closeErr)
-		}
-	}()
 
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("server returned non-200 status: %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+	if !f.RawHTML && htmlMediaTypes[resp.ContentType()] && looksLikeHTML(resp.Body) {
+		md, err := HTMLToMarkdown(resp.Body, resp.URL)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert HTML: %w", err)
+		}
+		return md, nil
 	}
 
-	return string(body), nil
+	if !isMarkdownMediaType(resp.ContentType()) {
+		if md, ok := SourceAsMarkdown(urlFileName(resp.URL), resp.Body); ok {
+			return md, nil
+		}
+	}
+
+	return string(resp.Body), nil
 }
 
 func (f *FileHTTP) fetchFromLocal(path string) (string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return DirectoryIndex(path)
+	}
+
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read local file: %w", err)
 	}
+
+	if !f.RawHTML && isHTMLFile(path) && looksLikeHTML(content) {
+		md, err := HTMLToMarkdown(content, "")
+		if err != nil {
+			return "", fmt.Errorf("failed to convert HTML: %w", err)
+		}
+		return md, nil
+	}
+
+	if md, ok := SourceAsMarkdown(path, content); ok {
+		return md, nil
+	}
 	return string(content), nil
 }
+
+func isHTMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm" || ext == ".xhtml"
+}
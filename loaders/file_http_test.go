@@ -1,6 +1,7 @@
 package loaders
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -145,3 +146,115 @@ func TestFileHTTP_DirectoryTraversal(t *testing.T) {
 		t.Fatal("expected error for directory traversal, got nil")
 	}
 }
+
+func TestFileHTTP_HTTPCache(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=600")
+		_, _ = w.Write([]byte("# Cached"))
+	}))
+	defer srv.Close()
+
+	cache := navidown.NewHTTPCache(navidown.HTTPCacheOptions{CacheDir: t.TempDir()})
+	f := &FileHTTP{Cache: cache}
+	for i := 0; i < 2; i++ {
+		got, err := f.FetchContent(navidown.NavElement{URL: srv.URL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != "# Cached" {
+			t.Errorf("got %q", got)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hits = %d, want 1", hits)
+	}
+}
+
+func TestFileHTTP_Credentials(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("# Private"))
+	}))
+	defer srv.Close()
+
+	f := &FileHTTP{Credentials: navidown.StaticCredentials{{Match: srv.URL + "/", BearerToken: "tok"}}}
+	if _, err := f.FetchContent(navidown.NavElement{URL: srv.URL}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotAuth != "Bearer tok" {
+		t.Errorf("Authorization = %q, want bearer token", gotAuth)
+	}
+}
+
+func TestFileHTTP_Rewriter(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte("# Raw"))
+	}))
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	f := &FileHTTP{Rewriter: navidown.NewForgeRewriter(navidown.ForgeRule{Kind: navidown.ForgeGitLab, Host: host})}
+	got, err := f.FetchContent(navidown.NavElement{URL: srv.URL + "/team/proj/-/blob/main/docs/a.md"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "# Raw" || gotPath != "/team/proj/-/raw/main/docs/a.md" {
+		t.Errorf("got %q from %q", got, gotPath)
+	}
+}
+
+func TestFileHTTP_PathPolicyRefusal(t *testing.T) {
+	repo := t.TempDir()
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.md")
+	if err := os.WriteFile(secret, []byte("# Secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &FileHTTP{Policy: &navidown.PathPolicy{AllowedRoots: []string{repo}}}
+	_, err := f.FetchContent(navidown.NavElement{URL: secret})
+	var perr *navidown.PathPolicyError
+	if !errors.As(err, &perr) || perr.Reason != navidown.RefusedOutsideRoots {
+		t.Fatalf("expected outside-roots refusal, got %v", err)
+	}
+}
+
+func TestFileHTTP_ContentType(t *testing.T) {
+	var heads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads++
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "max-age=600")
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer srv.Close()
+
+	cache := navidown.NewHTTPCache(navidown.HTTPCacheOptions{CacheDir: t.TempDir()})
+	defer cache.Close()
+	f := &FileHTTP{Cache: cache}
+
+	ct, err := f.ContentType(srv.URL + "/page")
+	if err != nil || ct != "text/html; charset=utf-8" {
+		t.Fatalf("ContentType = %q, %v", ct, err)
+	}
+	if heads != 1 {
+		t.Errorf("HEAD requests = %d, want 1", heads)
+	}
+
+	if _, err := cache.Get(nil, srv.URL+"/cached"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.ContentType(srv.URL + "/cached"); err != nil {
+		t.Fatal(err)
+	}
+	if heads != 1 {
+		t.Error("cached URL should be answered without a HEAD request")
+	}
+}
@@ -0,0 +1,600 @@
+package loaders
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/boolean-maybe/navidown/util"
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlMediaTypes are the content types converted by HTMLToMarkdown, as
+// returned by navidown.HTTPResponse.ContentType.
+var htmlMediaTypes = map[string]bool{
+	"text/html":             true,
+	"application/xhtml+xml": true,
+}
+
+// looksLikeHTML guards against servers that label markdown as text/html:
+// the body must start with a tag after optional whitespace and BOM.
+func looksLikeHTML(body []byte) bool {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '<'
+}
+
+// htmlSanitizer is the bluemonday policy applied to extracted page content
+// before conversion. It keeps document structure and drops scripts, styles,
+// event handlers, and unsafe URL schemes.
+var htmlSanitizer = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowElements("main")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("pre", "code", "div")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(?i:left|right|center)$`)).OnElements("th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	return p
+}()
+
+// prunedElements are removed from the page before conversion: site chrome
+// and non-content elements whose text would otherwise survive sanitizing.
+var prunedElements = map[atom.Atom]bool{
+	atom.Nav: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Iframe: true, atom.Svg: true, atom.Select: true,
+}
+
+// HTMLToMarkdown extracts the main content of an HTML page and converts it
+// to markdown. Headings, paragraphs, links, images, lists, code blocks,
+// block quotes, and tables are preserved. Relative URLs are resolved against
+// pageURL (or the page's <base href>); pass "" to leave them unchanged.
+// The page title becomes a top-level heading when the content has none.
+func HTMLToMarkdown(page []byte, pageURL string) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return "", fmt.Errorf("parse HTML: %w", err)
+	}
+
+	var base *url.URL
+	if pageURL != "" {
+		base, _ = url.Parse(pageURL)
+	}
+	if href := findBaseHref(doc); href != "" {
+		if ref, err := url.Parse(href); err == nil {
+			if base != nil {
+				base = base.ResolveReference(ref)
+			} else if ref.IsAbs() {
+				base = ref
+			}
+		}
+	}
+
+	title := ""
+	if t := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); t != nil {
+		title = strings.Join(strings.Fields(textContent(t)), " ")
+	}
+
+	root := mainContent(doc)
+	pruneNodes(root, root.DataAtom == atom.Body)
+
+	var extracted bytes.Buffer
+	if err := html.Render(&extracted, root); err != nil {
+		return "", fmt.Errorf("render HTML: %w", err)
+	}
+	sanitized := htmlSanitizer.SanitizeBytes(extracted.Bytes())
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(bytes.NewReader(sanitized), body)
+	if err != nil {
+		return "", fmt.Errorf("parse sanitized HTML: %w", err)
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	c := &htmlConverter{base: base}
+	blocks := c.blocks(body)
+	if title != "" && !hasLevelOneHeading(blocks) {
+		blocks = append([]string{"# " + escapeMarkdown(title)}, blocks...)
+	}
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+func findBaseHref(doc *html.Node) string {
+	n := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Base && attr(n, "href") != "" })
+	if n == nil {
+		return ""
+	}
+	return attr(n, "href")
+}
+
+// mainContent picks the element most likely to hold the page's content:
+// a markdown-body article (forges), <main>, role=main, the first <article>,
+// #content, and finally <body>.
+func mainContent(doc *html.Node) *html.Node {
+	candidates := []func(*html.Node) bool{
+		func(n *html.Node) bool { return hasClass(n, "markdown-body") },
+		func(n *html.Node) bool { return n.DataAtom == atom.Main },
+		func(n *html.Node) bool { return attr(n, "role") == "main" },
+		func(n *html.Node) bool { return n.DataAtom == atom.Article },
+		func(n *html.Node) bool { return attr(n, "id") == "content" },
+		func(n *html.Node) bool { return n.DataAtom == atom.Body },
+	}
+	for _, match := range candidates {
+		if n := findFirst(doc, match); n != nil {
+			return n
+		}
+	}
+	return doc
+}
+
+// pruneNodes removes non-content elements beneath root. Page-level <header>
+// elements are dropped only when converting the whole body.
+func pruneNodes(root *html.Node, dropHeaders bool) {
+	var next *html.Node
+	for c := root.FirstChild; c != nil; c = next {
+		next = c.NextSibling
+		if c.Type == html.CommentNode ||
+			(c.Type == html.ElementNode && (prunedElements[c.DataAtom] || (dropHeaders && c.DataAtom == atom.Header) ||
+				hasAttr(c, "hidden") || attr(c, "aria-hidden") == "true")) {
+			root.RemoveChild(c)
+			continue
+		}
+		pruneNodes(c, dropHeaders)
+	}
+}
+
+// htmlConverter renders sanitized HTML nodes as markdown.
+type htmlConverter struct {
+	base *url.URL
+}
+
+// hardBreak marks <br> inside inline content until whitespace is collapsed.
+const hardBreak = "\x00"
+
+var (
+	spaceRun     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	levelOneHead = regexp.MustCompile(`^# `)
+)
+
+// blocks converts the children of a block container into markdown blocks,
+// grouping consecutive inline nodes into paragraphs.
+func (c *htmlConverter) blocks(n *html.Node) []string {
+	var out []string
+	var inline strings.Builder
+
+	flush := func() {
+		if p := finishInline(inline.String(), "  \n"); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if isBlockNode(child) {
+			flush()
+			out = append(out, c.block(child)...)
+			continue
+		}
+		inline.WriteString(c.inline(child))
+	}
+	flush()
+	return out
+}
+
+func (c *htmlConverter) block(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		text := finishInline(c.inlineChildren(n), " ")
+		if text == "" {
+			return nil
+		}
+		return []string{strings.Repeat("#", level) + " " + text}
+	case atom.P:
+		if text := finishInline(c.inlineChildren(n), "  \n"); text != "" {
+			return []string{text}
+		}
+		return nil
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Pre:
+		return []string{c.codeBlock(n)}
+	case atom.Ul, atom.Ol:
+		if list := c.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case atom.Blockquote:
+		inner := strings.Join(c.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "> ", ">")}
+	case atom.Table:
+		if table := c.table(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	case atom.Dt:
+		if text := finishInline(c.inlineChildren(n), " "); text != "" {
+			return []string{"**" + text + "**"}
+		}
+		return nil
+	default:
+		return c.blocks(n)
+	}
+}
+
+func (c *htmlConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(c.inline(child))
+	}
+	return b.String()
+}
+
+func (c *htmlConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return escapeMarkdown(spaceRun.ReplaceAllString(n.Data, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return hardBreak
+	case atom.A:
+		text := strings.TrimSpace(finishInline(c.inlineChildren(n), " "))
+		href := c.resolve(attr(n, "href"))
+		if href == "" {
+			return text
+		}
+		if text == "" {
+			text = escapeMarkdown(href)
+		}
+		return "[" + text + "](" + markdownDestination(href) + ")"
+	case atom.Img:
+		src := c.resolve(attr(n, "src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(attr(n, "alt")) + "](" + markdownDestination(src) + ")"
+	case atom.Strong, atom.B:
+		return wrapInline(c.inlineChildren(n), "**")
+	case atom.Em, atom.I, atom.Cite, atom.Dfn:
+		return wrapInline(c.inlineChildren(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrapInline(c.inlineChildren(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return codeSpan(spaceRun.ReplaceAllString(textContent(n), " "))
+	default:
+		return c.inlineChildren(n)
+	}
+}
+
+func (c *htmlConverter) codeBlock(pre *html.Node) string {
+	lang := codeLanguage(pre)
+	if lang == "" {
+		if code := findFirst(pre, func(n *html.Node) bool { return n.DataAtom == atom.Code }); code != nil {
+			lang = codeLanguage(code)
+		}
+	}
+	code := strings.TrimRight(strings.TrimPrefix(textContent(pre), "\n"), "\n")
+
+	fence := strings.Repeat("`", max(3, util.LongestRun(code, '`')+1))
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func (c *htmlConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		content := strings.Join(c.blocks(li), "\n")
+		if content == "" {
+			items = append(items, strings.TrimSpace(marker))
+			continue
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.TrimPrefix(prefixLines(content, indent, ""), indent))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *htmlConverter) table(n *html.Node) string {
+	var rows [][]string
+	var aligns []string
+	headerRow := false
+
+	var collect func(*html.Node)
+	collect = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(child)
+			case atom.Tr:
+				var cells []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom != atom.Th && cell.DataAtom != atom.Td {
+						continue
+					}
+					if len(rows) == 0 {
+						headerRow = headerRow || cell.DataAtom == atom.Th || node.DataAtom == atom.Thead
+						aligns = append(aligns, strings.ToLower(attr(cell, "align")))
+					}
+					text := finishInline(c.inlineChildren(cell), " ")
+					cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, r := range rows {
+		cols = max(cols, len(r))
+	}
+	if !headerRow {
+		// GFM tables require a header; synthesize an empty one
+		rows = append([][]string{make([]string, cols)}, rows...)
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	b.WriteString("|")
+	for i := 0; i < cols; i++ {
+		align := ""
+		if i < len(aligns) {
+			align = aligns[i]
+		}
+		switch align {
+		case "left":
+			b.WriteString(" :--- |")
+		case "right":
+			b.WriteString(" ---: |")
+		case "center":
+			b.WriteString(" :---: |")
+		default:
+			b.WriteString(" --- |")
+		}
+	}
+	b.WriteString("\n")
+	for _, r := range rows[1:] {
+		writeRow(r)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// resolve makes href absolute against the page URL. Fragment-only links are
+// kept so in-document anchors still work. Returns "" for unusable links.
+func (c *htmlConverter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" {
+		return ""
+	}
+	if strings.HasPrefix(href, "#") || c.base == nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	return c.base.ResolveReference(ref).String()
+}
+
+// isBlockNode reports whether n starts a new markdown block.
+func isBlockNode(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header,
+		atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Hgroup,
+		atom.Ul, atom.Ol, atom.Li, atom.Dl, atom.Dt, atom.Dd,
+		atom.Pre, atom.Blockquote, atom.Table, atom.Hr,
+		atom.Figure, atom.Figcaption, atom.Details, atom.Summary, atom.Center, atom.Address:
+		return true
+	}
+	return false
+}
+
+// finishInline collapses whitespace in converted inline content and replaces
+// hard-break markers with br.
+func finishInline(s, br string) string {
+	s = spaceRun.ReplaceAllString(s, " ")
+	parts := strings.Split(s, hardBreak)
+	for i := range parts {
+		parts[i] = escapeLineStart(strings.TrimSpace(parts[i]))
+	}
+	// drop leading/trailing breaks
+	for len(parts) > 0 && parts[0] == "" {
+		parts = parts[1:]
+	}
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, br)
+}
+
+func wrapInline(s, marker string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	// keep surrounding spaces outside the markers so emphasis still parses
+	lead := s[:len(s)-len(strings.TrimLeft(s, " "))]
+	trail := s[len(strings.TrimRight(s, " ")):]
+	return lead + marker + trimmed + marker + trail
+}
+
+func codeSpan(s string) string {
+	if s == "" {
+		return ""
+	}
+	fence := strings.Repeat("`", util.LongestRun(s, '`')+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return fence + " " + s + " " + fence
+	}
+	return fence + s + fence
+}
+
+// markdownDestination wraps URLs containing spaces or parentheses in angle brackets.
+func markdownDestination(u string) string {
+	if strings.ContainsAny(u, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(u) + ">"
+	}
+	return u
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+var (
+	// blockMarker matches text at the start of a line that markdown would
+	// read as a heading, list item, quote, table row, fence or setext
+	// underline; orderedMarker matches an ordered list number.
+	blockMarker   = regexp.MustCompile(`^[#>+\-=|~]`)
+	orderedMarker = regexp.MustCompile(`^(\d{1,9})([.)])`)
+)
+
+// escapeLineStart escapes a block marker starting line, a line of inline
+// content, so it stays text. Inline escaping does not cover these, as they
+// only mean something at the start of a line.
+func escapeLineStart(line string) string {
+	if blockMarker.MatchString(line) {
+		return `\` + line
+	}
+	return orderedMarker.ReplaceAllString(line, `$1\$2`)
+}
+
+func codeLanguage(n *html.Node) string {
+	for _, cls := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-", "highlight-source-"} {
+			if lang, ok := strings.CutPrefix(cls, prefix); ok && lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+func hasLevelOneHeading(blocks []string) bool {
+	for _, b := range blocks {
+		if levelOneHead.MatchString(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func prefixLines(s, prefix, blankPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textContent concatenates the text beneath n, turning <br> into newlines.
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			b.WriteString(node.Data)
+		case node.Type == html.ElementNode && node.DataAtom == atom.Br:
+			b.WriteByte('\n')
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findFirst(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package loaders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boolean-maybe/navidown/navidown"
+)
+
+const samplePage = `<!doctype html>
+<html><head><title>Sample Docs</title><script>alert(1)</script></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<main>
+<h1>Getting <em>Started</em></h1>
+<p>Read the <a href="guide/intro.md">intro</a> or see <a href="#setup">setup</a>.<br>Second line.</p>
+<h2 id="setup">Setup</h2>
+<ul>
+  <li>First <strong>item</strong></li>
+  <li>Second
+    <ol start="3"><li>nested</li></ol>
+  </li>
+</ul>
+<pre><code class="language-go">func main() {
+	fmt.Println("hi")
+}
+</code></pre>
+<p>Inline <code>x := 1</code> and <img src="/img/logo.png" alt="logo"></p>
+<blockquote><p>Quoted</p></blockquote>
+<table>
+  <thead><tr><th>Name</th><th align="right">Size</th></tr></thead>
+  <tbody><tr><td>a|b</td><td>10</td></tr></tbody>
+</table>
+<p onclick="evil()">Safe <a href="javascript:alert(1)">bad link</a></p>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+func TestHTMLToMarkdown(t *testing.T) {
+	md, err := HTMLToMarkdown([]byte(samplePage), "https://example.com/docs/index.html")
+	if err != nil {
+		t.Fatalf("HTMLToMarkdown: %v", err)
+	}
+
+	wants := []string{
+		"# Getting *Started*",
+		"[intro](https://example.com/docs/guide/intro.md)",
+		"[setup](#setup)",
+		"  \nSecond line.",
+		"## Setup",
+		"- First **item**",
+		"- Second\n  3. nested",
+		"```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```",
+		"`x := 1`",
+		"![logo](https://example.com/img/logo.png)",
+		"> Quoted",
+		"| Name | Size |\n| --- | ---: |\n| a\\|b | 10 |",
+	}
+	for _, want := range wants {
+		if !strings.Contains(md, want) {
+			t.Errorf("output missing %q\n--- got ---\n%s", want, md)
+		}
+	}
+
+	for _, unwanted := range []string{"alert", "Home", "Copyright", "javascript:", "onclick", "Sample Docs"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("output should not contain %q\n--- got ---\n%s", unwanted, md)
+		}
+	}
+	if !strings.Contains(md, "bad link") {
+		t.Error("text of a stripped unsafe link should be kept")
+	}
+}
+
+func TestHTMLToMarkdown_TitleAndBase(t *testing.T) {
+	page := `<html><head><title>Only Title</title><base href="https://cdn.example.com/assets/"></head>
+<body><p>See <a href="page.html">page</a></p></body></html>`
+	md, err := HTMLToMarkdown([]byte(page), "https://example.com/x/y.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(md, "# Only Title\n\n") {
+		t.Errorf("expected title heading first, got %q", md)
+	}
+	if !strings.Contains(md, "(https://cdn.example.com/assets/page.html)") {
+		t.Errorf("expected link resolved against <base>, got %q", md)
+	}
+}
+
+func TestHTMLToMarkdown_ParsesAsNavigableDocument(t *testing.T) {
+	md, err := HTMLToMarkdown([]byte(samplePage), "https://example.com/docs/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := navidown.New(navidown.Options{})
+	if err := session.SetMarkdownWithSource(md, "https://example.com/docs/index.html", false); err != nil {
+		t.Fatal(err)
+	}
+	var links, headers int
+	for _, e := range session.Elements() {
+		switch e.Type {
+		case navidown.NavElementURL:
+			links++
+		case navidown.NavElementHeader:
+			headers++
+		}
+	}
+	if links < 2 || headers < 2 {
+		t.Errorf("expected navigable links and headers, got %d links, %d headers", links, headers)
+	}
+}
+
+func TestHTMLToMarkdown_EscapesBlockMarkers(t *testing.T) {
+	page := `<html><body><main>
+<p># not a heading</p><p>- not an item<br>+ nor this<br>1. nor a number</p>
+<p>&gt; not a quote</p><p>| not | a table |</p><p>===</p><p>2024) was a year</p>
+</main></body></html>`
+	md, err := HTMLToMarkdown([]byte(page), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`\# not a heading`, `\- not an item`, `\+ nor this`, `1\. nor a number`,
+		`\> not a quote`, `\| not | a table |`, `\===`, `2024\) was a year`} {
+		if !strings.Contains(md, want) {
+			t.Errorf("missing %q in:\n%s", want, md)
+		}
+	}
+}
+
+func TestFileHTTP_ConvertsHTMLResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><body><main><h1>Hi</h1><p><a href="next.md">next</a></p></main></body></html>`))
+	}))
+	defer srv.Close()
+
+	f := &FileHTTP{}
+	got, err := f.FetchContent(navidown.NavElement{URL: srv.URL + "/docs/page"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "# Hi") || !strings.Contains(got, "]("+srv.URL+"/docs/next.md)") {
+		t.Errorf("expected converted markdown, got %q", got)
+	}
+
+	f.RawHTML = true
+	got, err = f.FetchContent(navidown.NavElement{URL: srv.URL + "/docs/page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "<h1>Hi</h1>") {
+		t.Errorf("RawHTML should return the page unchanged, got %q", got)
+	}
+}
+
+func TestFileHTTP_MarkdownLabelledHTMLNotConverted(t *testing.T) {
+	want := "# Actually markdown\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(want))
+	}))
+	defer srv.Close()
+
+	got, err := (&FileHTTP{}).FetchContent(navidown.NavElement{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileHTTP_ConvertsLocalHTMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.html")
+	if err := os.WriteFile(path, []byte(`<h2>Local</h2><p><a href="other.md">other</a></p>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&FileHTTP{}).FetchContent(navidown.NavElement{URL: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "## Local") || !strings.Contains(got, "[other](other.md)") {
+		t.Errorf("expected converted local HTML with relative link kept, got %q", got)
+	}
+}
@@ -0,0 +1,72 @@
+package loaders
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/boolean-maybe/navidown/util"
+)
+
+// SourceLanguage returns the chroma language for a non-markdown file name,
+// e.g. "go" for main.go or "make" for Makefile. Returns "" for markdown,
+// HTML, and files chroma does not recognize, which are shown as-is.
+func SourceLanguage(name string) string {
+	base := filepath.Base(name)
+	ext := strings.ToLower(filepath.Ext(base))
+	if markdownExtensions[ext] || isHTMLFile(base) {
+		return ""
+	}
+	lexer := lexers.Match(base)
+	if lexer == nil {
+		return ""
+	}
+	cfg := lexer.Config()
+	if strings.EqualFold(cfg.Name, "markdown") {
+		return ""
+	}
+	if len(cfg.Aliases) > 0 {
+		return cfg.Aliases[0]
+	}
+	return strings.ToLower(cfg.Name)
+}
+
+// SourceAsMarkdown wraps the contents of a source file in a fenced code block
+// tagged with its SourceLanguage, so it is syntax highlighted rather than
+// parsed as markdown. ok is false when name is not a recognized source file.
+func SourceAsMarkdown(name string, content []byte) (md string, ok bool) {
+	lang := SourceLanguage(name)
+	if lang == "" {
+		return "", false
+	}
+
+	code := strings.TrimPrefix(string(content), "\ufeff")
+	fence := strings.Repeat("`", max(3, util.LongestRun(code, '`')+1))
+
+	var b strings.Builder
+	b.Grow(len(code) + 2*len(fence) + len(lang) + 3)
+	b.WriteString(fence + lang + "\n")
+	b.WriteString(code)
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(fence + "\n")
+	return b.String(), true
+}
+
+// urlFileName returns the last path segment of a URL, or "" if it has none.
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+// isMarkdownMediaType reports whether a media type, as returned by
+// navidown.HTTPResponse.ContentType, names markdown.
+func isMarkdownMediaType(mediaType string) bool {
+	return mediaType == "text/markdown" || mediaType == "text/x-markdown"
+}
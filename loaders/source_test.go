@@ -0,0 +1,100 @@
+package loaders
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boolean-maybe/navidown/navidown"
+)
+
+func TestSourceLanguage(t *testing.T) {
+	tests := map[string]string{
+		"main.go":          "go",
+		"/x/config.yaml":   "yaml",
+		"Makefile":         "make",
+		"README.md":        "",
+		"guide.markdown":   "",
+		"page.html":        "",
+		"LICENSE":          "",
+		"unknown.zzzunkno": "",
+	}
+	for name, want := range tests {
+		if got := SourceLanguage(name); got != want {
+			t.Errorf("SourceLanguage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSourceAsMarkdown(t *testing.T) {
+	md, ok := SourceAsMarkdown("main.go", []byte("package main\n\nfunc main() {}"))
+	if !ok {
+		t.Fatal("expected main.go to be wrapped")
+	}
+	want := "```go\npackage main\n\nfunc main() {}\n```\n"
+	if md != want {
+		t.Errorf("got %q, want %q", md, want)
+	}
+
+	if _, ok := SourceAsMarkdown("notes.md", []byte("# hi")); ok {
+		t.Error("markdown should not be wrapped")
+	}
+}
+
+func TestSourceAsMarkdown_LongerFenceThanContent(t *testing.T) {
+	md, ok := SourceAsMarkdown("gen.sh", []byte("cat <<EOF\n````\nEOF\n"))
+	if !ok {
+		t.Fatal("expected gen.sh to be wrapped")
+	}
+	if !strings.HasPrefix(md, "`````bash\n") || !strings.HasSuffix(md, "\n`````\n") {
+		t.Errorf("fence should outrun the backticks in the file, got %q", md)
+	}
+}
+
+func TestFileHTTP_LocalSourceFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("key: value\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := (&FileHTTP{}).FetchContent(navidown.NavElement{URL: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "```yaml\nkey: value\n```\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileHTTP_RemoteSourceFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".md") {
+			w.Header().Set("Content-Type", "text/markdown")
+		} else {
+			w.Header().Set("Content-Type", "text/plain")
+		}
+		_, _ = w.Write([]byte("package x\n"))
+	}))
+	defer srv.Close()
+
+	f := &FileHTTP{}
+	got, err := f.FetchContent(navidown.NavElement{URL: srv.URL + "/src/x.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "```go\npackage x\n```\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err = f.FetchContent(navidown.NavElement{URL: srv.URL + "/doc.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "package x\n" {
+		t.Errorf("markdown should be returned unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,305 @@
+package navidown
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Terminals draw text in logical order, so right-to-left text (Arabic,
+// Hebrew) would read backwards. reorderBidi rearranges lines holding such
+// text into display order with a basic form of the Unicode bidi algorithm:
+// x/text resolves the direction runs, and runs are reversed as in rule L2.
+// Only the span from the first strongly directional character to the last
+// one moves, so margins, list bullets and block borders stay in place.
+
+var ansiEscapePattern = regexp.MustCompile(ansiOSC8Pattern.String() + `|` + ansiSGRPattern.String())
+
+const osc8Close = "\x1b]8;;\x1b\\"
+
+// bidiCell is a character of a line with what it is drawn with: the
+// character itself, the zero-width runes after it, and the SGR state and
+// hyperlink in effect.
+type bidiCell struct {
+	text  string
+	base  rune
+	sgr   string // SGR sequences since the last reset
+	link  string // open OSC 8 sequence, or ""
+	width int
+	col   int // logical column
+}
+
+// SetLogicalOrder sets whether right-to-left text is left in logical order,
+// for terminals that reorder bidirectional text themselves.
+func (v *MarkdownSession) SetLogicalOrder(logical bool) {
+	if logical == v.logicalOrder {
+		return
+	}
+	v.logicalOrder = logical
+	if v.markdown != "" {
+		_ = v.reRenderWithWidth(v.currentWidth)
+	}
+}
+
+// reorderBidi puts right-to-left text of the rendered lines in display order
+// and moves the columns of the elements on those lines along with it. It is
+// a no-op with Options.LogicalOrder.
+func (v *MarkdownSession) reorderBidi() {
+	if v.logicalOrder {
+		return
+	}
+	for i, line := range v.renderedLines {
+		reordered, visualCols, ok := reorderLine(line)
+		if !ok {
+			continue
+		}
+		v.renderedLines[i] = reordered
+		for j := range v.elements {
+			e := &v.elements[j]
+			if e.StartLine == i && e.EndCol > e.StartCol {
+				e.StartCol, e.EndCol = visualSpan(visualCols, e.StartCol, e.EndCol)
+			}
+		}
+	}
+}
+
+// reorderLine returns line in display order, with the display column of each
+// logical column. ok is false if the line has no right-to-left text.
+func reorderLine(line string) (string, []int, bool) {
+	if !hasRTL(line) {
+		return line, nil, false
+	}
+	cells, tail := splitBidiCells(line)
+
+	// the span from the first strong character to the last strong character
+	// or number is reordered
+	first, last := -1, -1
+	var base bidi.Direction = bidi.LeftToRight
+	for i, c := range cells {
+		switch bidiClass(c.base) {
+		case bidi.R, bidi.AL:
+			if first < 0 {
+				first, base = i, bidi.RightToLeft
+			}
+		case bidi.L:
+			if first < 0 {
+				first = i
+			}
+		case bidi.EN, bidi.AN:
+			if first < 0 {
+				continue
+			}
+		default:
+			continue
+		}
+		last = i
+	}
+	if first < 0 {
+		return line, nil, false
+	}
+	span := cells[first : last+1]
+	levels, ok := bidiLevels(span, base)
+	if !ok {
+		return line, nil, false
+	}
+
+	order := make([]int, len(span))
+	for i := range order {
+		order[i] = i
+	}
+	maxLevel := 0
+	for _, l := range levels {
+		maxLevel = max(maxLevel, l)
+	}
+	// L2: from the highest level down to 1, reverse every run at that level
+	// or higher
+	for level := maxLevel; level >= 1; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+
+	visual := make([]bidiCell, 0, len(cells))
+	visual = append(visual, cells[:first]...)
+	for _, idx := range order {
+		c := span[idx]
+		if levels[idx]%2 == 1 {
+			// mirror brackets in right-to-left runs
+			c.text = bidi.ReverseString(string(c.base)) + c.text[len(string(c.base)):]
+		}
+		visual = append(visual, c)
+	}
+	visual = append(visual, cells[last+1:]...)
+
+	var b strings.Builder
+	var sgr, link string
+	visualCols := make([]int, cells[len(cells)-1].col+cells[len(cells)-1].width)
+	col := 0
+	for _, c := range visual {
+		if c.link != link {
+			if link != "" {
+				b.WriteString(osc8Close)
+			}
+			b.WriteString(c.link)
+			link = c.link
+		}
+		if c.sgr != sgr {
+			b.WriteString("\x1b[0m" + c.sgr)
+			sgr = c.sgr
+		}
+		b.WriteString(c.text)
+		for k := range c.width {
+			visualCols[c.col+k] = col + k
+		}
+		col += c.width
+	}
+	if link != "" {
+		b.WriteString(osc8Close)
+	}
+	b.WriteString(tail)
+	return b.String(), visualCols, true
+}
+
+// bidiLevels resolves the embedding level of each cell. x/text finds the
+// direction runs; left-to-right runs get level 2 inside right-to-left text,
+// that is in a right-to-left line or when they are numbers after
+// right-to-left text.
+func bidiLevels(cells []bidiCell, base bidi.Direction) ([]int, bool) {
+	runes := make([]rune, len(cells))
+	for i, c := range cells {
+		runes[i] = c.base
+	}
+	text := string(runes)
+	var p bidi.Paragraph
+	if n, err := p.SetString(text, bidi.DefaultDirection(base)); err != nil || n != 0 && n != len(text) {
+		return nil, false
+	}
+	o, err := p.Order()
+	if err != nil {
+		return nil, false
+	}
+
+	levels := make([]int, len(cells))
+	prevRTL := false
+	for i := range o.NumRuns() {
+		run := o.Run(i)
+		start, end := run.Pos()
+		level := 0
+		switch {
+		case run.Direction() == bidi.RightToLeft:
+			level = 1
+		case base == bidi.RightToLeft:
+			level = 2
+		case prevRTL && isBidiNumber(runes[start]):
+			level = 2
+		}
+		for k := start; k <= end; k++ {
+			levels[k] = level
+		}
+		prevRTL = level%2 == 1
+	}
+	return levels, true
+}
+
+func isBidiNumber(r rune) bool {
+	c := bidiClass(r)
+	return c == bidi.EN || c == bidi.AN
+}
+
+// visualSpan returns the display columns covered by logical columns
+// [start, end).
+func visualSpan(visualCols []int, start, end int) (int, int) {
+	lo, hi := -1, -1
+	for c := start; c < end && c < len(visualCols); c++ {
+		v := visualCols[c]
+		if lo < 0 || v < lo {
+			lo = v
+		}
+		hi = max(hi, v+1)
+	}
+	if lo < 0 {
+		return start, end
+	}
+	return lo, hi
+}
+
+// hasRTL reports whether s holds right-to-left characters.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if r < 0x0590 {
+			continue
+		}
+		if c := bidiClass(r); c == bidi.R || c == bidi.AL {
+			return true
+		}
+	}
+	return false
+}
+
+func bidiClass(r rune) bidi.Class {
+	p, _ := bidi.LookupRune(r)
+	return p.Class()
+}
+
+// splitBidiCells splits a rendered line into its characters. tail holds the
+// escape sequences after the last character.
+func splitBidiCells(line string) ([]bidiCell, string) {
+	var cells []bidiCell
+	var sgr, link, pending string
+	col := 0
+	for line != "" {
+		if seq := leadingEscape(line); seq != "" {
+			switch {
+			case strings.HasPrefix(seq, "\x1b]8;"):
+				if strings.HasPrefix(seq, "\x1b]8;;\x1b") || strings.HasPrefix(seq, "\x1b]8;;\x07") {
+					link = ""
+				} else {
+					link = seq
+				}
+			case seq == "\x1b[0m" || seq == "\x1b[m":
+				sgr = ""
+			default:
+				sgr += seq
+			}
+			pending += seq
+			line = line[len(seq):]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line)
+		text := line[:size]
+		line = line[size:]
+		w := RuneColumns(r)
+		if w == 0 && len(cells) > 0 {
+			cells[len(cells)-1].text += text
+			continue
+		}
+		cells = append(cells, bidiCell{text: text, base: r, sgr: sgr, link: link, width: w, col: col})
+		col += w
+		pending = ""
+	}
+	return cells, pending
+}
+
+// leadingEscape returns the SGR or OSC 8 sequence s starts with, or "".
+func leadingEscape(s string) string {
+	if s[0] != '\x1b' {
+		return ""
+	}
+	if loc := ansiEscapePattern.FindStringIndex(s); loc != nil && loc[0] == 0 {
+		return s[:loc[1]]
+	}
+	return ""
+}
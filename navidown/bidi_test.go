@@ -0,0 +1,66 @@
+package navidown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReorderLine(t *testing.T) {
+	tests := []struct {
+		name, line, want string
+	}{
+		{"hebrew", "  שלום עולם", "  םלוע םולש"},
+		{"mixed", "see שלום here", "see םולש here"},
+		{"numbers stay left to right", "שלום 123", "123 םולש"},
+		{"brackets are mirrored", "(שלום)", "(םולש)"},
+		{"borders stay", "│ שלום │", "│ םולש │"},
+	}
+	for _, tt := range tests {
+		got, _, ok := reorderLine(tt.line)
+		if !ok {
+			t.Errorf("%s: not reordered", tt.name)
+			continue
+		}
+		if clean := stripANSIAndMarkers(got); clean != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, clean, tt.want)
+		}
+	}
+
+	if _, _, ok := reorderLine("plain text"); ok {
+		t.Error("left-to-right line should be left alone")
+	}
+}
+
+func TestReorderLine_KeepsStyles(t *testing.T) {
+	line := "\x1b[1mשל\x1b[0m\x1b[3mום\x1b[0m"
+	got, cols, _ := reorderLine(line)
+	if !strings.Contains(got, "\x1b[0m\x1b[3mםו") || !strings.Contains(got, "\x1b[0m\x1b[1mלש") {
+		t.Errorf("styles not carried with their characters: %q", got)
+	}
+	if want := []int{3, 2, 1, 0}; len(cols) != 4 || cols[0] != want[0] || cols[3] != want[3] {
+		t.Errorf("columns = %v, want %v", cols, want)
+	}
+}
+
+func TestSession_BidiMovesLinkColumns(t *testing.T) {
+	s := New(Options{})
+	s.SetWidth(40)
+	if err := s.SetMarkdown("שלום [קישור](https://example.com) עולם\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.MoveToNextLink(10) {
+		t.Fatal("no link")
+	}
+	sel := s.Selected()
+	clean := []rune(stripANSIAndMarkers(s.RenderedLines()[sel.StartLine]))
+	if got := string(clean[sel.StartCol:sel.EndCol]); got != "רושיק" {
+		t.Errorf("highlight covers %q, want the reversed link text", got)
+	}
+
+	s = New(Options{LogicalOrder: true})
+	s.SetWidth(40)
+	_ = s.SetMarkdown("שלום [קישור](https://example.com) עולם\n")
+	if !strings.Contains(stripANSIAndMarkers(strings.Join(s.RenderedLines(), "\n")), "שלום קישור") {
+		t.Error("LogicalOrder should keep the text as written")
+	}
+}
@@ -8,9 +8,27 @@ import (
 // resolveCacheDir determines which directory to use for persistent caching.
 // It tries (in order): explicit path, os.UserCacheDir()/navidown/<subdir>, temp dir.
 // Returns (persistentDir, tempDir, workDir). workDir is the dir to actually use.
+// workDir is registered as trusted so PathPolicy allows the files written there,
+// and persistentDir with the shared CacheManager so it is kept within bounds.
 func resolveCacheDir(explicit string, subdir string) (persistentDir, tempDir, workDir string) {
+	persistentDir, tempDir, workDir = findCacheDir(explicit, subdir)
+	registerWorkDir(workDir)
+	SharedCacheManager().AddDir(persistentDir)
+	return persistentDir, tempDir, workDir
+}
+
+// cacheDirMarker is the file navidown leaves in the explicit cache
+// directories it creates, so the CacheManager may prune them. Directories
+// that existed before are the user's and left alone.
+const cacheDirMarker = ".navidown-cache"
+
+func findCacheDir(explicit string, subdir string) (persistentDir, tempDir, workDir string) {
 	if explicit != "" {
+		_, statErr := os.Stat(explicit)
 		if err := os.MkdirAll(explicit, 0700); err == nil {
+			if os.IsNotExist(statErr) {
+				_ = os.WriteFile(filepath.Join(explicit, cacheDirMarker), nil, 0600) // #nosec G703 -- path from filepath.Join(explicit, cacheDirMarker)
+			}
 			return explicit, "", explicit
 		}
 	}
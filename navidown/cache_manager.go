@@ -0,0 +1,492 @@
+package navidown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The PNGs rendered for diagrams (mermaid, graphviz, ...) and SVG images are
+// cached on disk as <sha256>.png files, in a directory per kind under the
+// navidown cache dir or where their options say. A CacheManager keeps them
+// within a size and an age: each use of a cached file sets its modification
+// time, and pruning removes files unused for longer than the maximum age,
+// then the least recently used ones until the rest fit. Only directories
+// under the manager's root and those navidown created (marked with
+// cacheDirMarker) are pruned; a cache dir option naming an existing
+// directory leaves its files to the user. Files shown by a live session
+// are kept. Several processes can share the directories; pruning and the
+// statistics file are guarded by a lock file. Other files there (configs,
+// the HTTP cache) are left alone.
+
+const (
+	defaultCacheMaxSize = 512 << 20
+	defaultCacheMaxAge  = 30 * 24 * time.Hour
+
+	// touchInterval is how stale a file's time must be before a use sets it
+	// again, so hits on a file do not each write to the disk.
+	touchInterval = time.Hour
+	// pruneInterval is the least time between prunes triggered by writes.
+	pruneInterval = time.Minute
+
+	cacheLockWait  = 2 * time.Second
+	cacheLockStale = 30 * time.Second
+)
+
+// CacheOptions bounds the disk cache of rendered images.
+type CacheOptions struct {
+	MaxSize int64         // total bytes; 0 = 512 MiB, negative = unbounded
+	MaxAge  time.Duration // since last use; 0 = 30 days, negative = unbounded
+}
+
+func (o CacheOptions) resolvedMaxSize() int64 {
+	if o.MaxSize == 0 {
+		return defaultCacheMaxSize
+	}
+	return o.MaxSize
+}
+
+func (o CacheOptions) resolvedMaxAge() time.Duration {
+	if o.MaxAge == 0 {
+		return defaultCacheMaxAge
+	}
+	return o.MaxAge
+}
+
+// CacheStats describes the disk cache and how it has been used.
+type CacheStats struct {
+	Dirs  []CacheDirStats // the directories holding cached files
+	Files int
+	Bytes int64
+
+	// Hits, Misses and Evictions count since the statistics were reset, in
+	// all processes sharing the cache.
+	Hits, Misses, Evictions int64
+	LastPrune               time.Time // zero if never pruned
+}
+
+// CacheDirStats describes one directory of the cache.
+type CacheDirStats struct {
+	Dir   string
+	Files int
+	Bytes int64
+}
+
+// PruneResult tells what a prune removed and left.
+type PruneResult struct {
+	Removed   int
+	Freed     int64
+	Remaining int
+	Bytes     int64
+}
+
+// cacheCounters is the statistics file shared by the processes.
+type cacheCounters struct {
+	Hits      int64     `json:"hits"`
+	Misses    int64     `json:"misses"`
+	Evictions int64     `json:"evictions"`
+	LastPrune time.Time `json:"last_prune"`
+}
+
+// ErrCacheLocked is returned when another process holds the cache lock for
+// too long.
+var ErrCacheLocked = errors.New("cache is locked by another process")
+
+// CacheManager keeps the cached images of the directories it knows within
+// CacheOptions and counts hits and misses. Safe for concurrent use.
+type CacheManager struct {
+	root string // holds the lock and statistics files, and default cache dirs
+
+	mu        sync.Mutex
+	opts      CacheOptions
+	dirs      map[string]bool         // registered cache directories
+	inUse     map[any]map[string]bool // cached files shown, by session
+	lastPrune time.Time
+
+	// counts not yet written to the statistics file
+	hits, misses atomic.Int64
+	pruning      atomic.Bool
+}
+
+// NewCacheManager creates a manager for the cache under root, where the
+// default cache directories are, such as os.UserCacheDir()/navidown.
+func NewCacheManager(root string, opts CacheOptions) *CacheManager {
+	return &CacheManager{root: root, opts: opts, dirs: map[string]bool{}}
+}
+
+var (
+	sharedCacheManagerOnce sync.Once
+	sharedCacheManager     *CacheManager
+)
+
+// SharedCacheManager returns the manager the renderers of this process
+// report to, for os.UserCacheDir()/navidown. Directories of renderers with
+// an explicit cache dir are added to it as they are created.
+func SharedCacheManager() *CacheManager {
+	sharedCacheManagerOnce.Do(func() {
+		root := ""
+		if ucd, err := os.UserCacheDir(); err == nil {
+			root = filepath.Join(ucd, "navidown")
+		}
+		sharedCacheManager = NewCacheManager(root, CacheOptions{})
+	})
+	return sharedCacheManager
+}
+
+// SetOptions changes the limits, which apply from the next prune.
+func (m *CacheManager) SetOptions(opts CacheOptions) {
+	m.mu.Lock()
+	m.opts = opts
+	m.mu.Unlock()
+}
+
+// AddDir adds a directory of cached images. It is pruned only if it is
+// under root or navidown created it.
+func (m *CacheManager) AddDir(dir string) {
+	if dir == "" {
+		return
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	m.mu.Lock()
+	m.dirs[dir] = true
+	m.mu.Unlock()
+}
+
+// cacheDirs returns the directories under root and the registered ones
+// that are under root or carry cacheDirMarker.
+func (m *CacheManager) cacheDirs() []string {
+	m.mu.Lock()
+	seen := make(map[string]bool, len(m.dirs))
+	for dir := range m.dirs {
+		if m.managed(dir) {
+			seen[dir] = true
+		}
+	}
+	m.mu.Unlock()
+	if m.root != "" {
+		entries, _ := os.ReadDir(m.root)
+		for _, e := range entries {
+			if e.IsDir() {
+				seen[filepath.Join(m.root, e.Name())] = true
+			}
+		}
+	}
+	dirs := make([]string, 0, len(seen))
+	for dir := range seen {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// managed reports whether the files of dir are the manager's to prune.
+func (m *CacheManager) managed(dir string) bool {
+	if root, err := filepath.Abs(m.root); err == nil && m.root != "" {
+		if rel, err := filepath.Rel(root, dir); err == nil && filepath.IsLocal(rel) {
+			return true
+		}
+	}
+	_, err := os.Stat(filepath.Join(dir, cacheDirMarker))
+	return err == nil
+}
+
+// setInUse records the cached files owner (a session) shows, replacing
+// those it showed before; nil releases them. Prune keeps them.
+func (m *CacheManager) setInUse(owner any, paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(paths) == 0 {
+		delete(m.inUse, owner)
+		return
+	}
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		set[path] = true
+	}
+	if m.inUse == nil {
+		m.inUse = map[any]map[string]bool{}
+	}
+	m.inUse[owner] = set
+}
+
+// used reports whether a live session shows the file at path.
+func (m *CacheManager) used(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, set := range m.inUse {
+		if set[path] {
+			return true
+		}
+	}
+	return false
+}
+
+// hit records a use of the cached file at path, setting its time if stale.
+func (m *CacheManager) hit(path string, info os.FileInfo) {
+	m.hits.Add(1)
+	if now := time.Now(); now.Sub(info.ModTime()) > touchInterval {
+		_ = os.Chtimes(path, now, now)
+	}
+}
+
+// miss records a lookup that found nothing cached.
+func (m *CacheManager) miss() {
+	m.misses.Add(1)
+}
+
+// added records a file written to the cache, and prunes the cache in the
+// background if it was not pruned lately.
+func (m *CacheManager) added() {
+	m.mu.Lock()
+	due := time.Since(m.lastPrune) > pruneInterval
+	if due {
+		m.lastPrune = time.Now()
+	}
+	m.mu.Unlock()
+	if due && m.pruning.CompareAndSwap(false, true) {
+		go func() {
+			defer m.pruning.Store(false)
+			_, _ = m.Prune()
+		}()
+	}
+}
+
+type cachedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// scan returns the cached images of dirs, by directory.
+func scanCache(dirs []string) map[string][]cachedFile {
+	files := make(map[string][]cachedFile, len(dirs))
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		list := []cachedFile{}
+		for _, e := range entries {
+			if !hexHashPNG.MatchString(e.Name()) {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			list = append(list, cachedFile{filepath.Join(dir, e.Name()), info.Size(), info.ModTime()})
+		}
+		files[dir] = list
+	}
+	return files
+}
+
+// Prune removes the cached images unused for longer than the maximum age,
+// then the least recently used ones until the rest fit the maximum size,
+// and writes the counts of this process to the statistics file.
+func (m *CacheManager) Prune() (PruneResult, error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return PruneResult{}, err
+	}
+	defer unlock()
+
+	m.mu.Lock()
+	opts := m.opts
+	m.mu.Unlock()
+	maxSize, maxAge := opts.resolvedMaxSize(), opts.resolvedMaxAge()
+
+	var files []cachedFile
+	for _, list := range scanCache(m.cacheDirs()) {
+		files = append(files, list...)
+	}
+	// least recently used first
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	var result PruneResult
+	now := time.Now()
+	for _, f := range files {
+		expired := maxAge > 0 && now.Sub(f.modTime) > maxAge
+		if (!expired && (maxSize < 0 || total <= maxSize)) || m.used(f.path) {
+			result.Remaining++
+			result.Bytes += f.size
+			continue
+		}
+		// another process may have removed it already
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			result.Remaining++
+			result.Bytes += f.size
+			continue
+		}
+		total -= f.size
+		result.Removed++
+		result.Freed += f.size
+	}
+
+	err = m.updateCounters(func(c *cacheCounters) {
+		c.Evictions += int64(result.Removed)
+		c.LastPrune = now
+	})
+	return result, err
+}
+
+// Stats returns the files in the cache and the counts of all processes,
+// including those of this process not written yet.
+func (m *CacheManager) Stats() (CacheStats, error) {
+	var stats CacheStats
+	dirs := m.cacheDirs()
+	files := scanCache(dirs)
+	for _, dir := range dirs {
+		list, ok := files[dir]
+		if !ok {
+			continue
+		}
+		d := CacheDirStats{Dir: dir, Files: len(list)}
+		for _, f := range list {
+			d.Bytes += f.size
+		}
+		stats.Dirs = append(stats.Dirs, d)
+		stats.Files += d.Files
+		stats.Bytes += d.Bytes
+	}
+
+	c, err := m.readCounters()
+	stats.Hits = c.Hits + m.hits.Load()
+	stats.Misses = c.Misses + m.misses.Load()
+	stats.Evictions = c.Evictions
+	stats.LastPrune = c.LastPrune
+	return stats, err
+}
+
+// Flush writes the counts of this process to the statistics file, as
+// Prune does. Call it before the process exits.
+func (m *CacheManager) Flush() error {
+	if m.hits.Load() == 0 && m.misses.Load() == 0 {
+		return nil
+	}
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.updateCounters(func(*cacheCounters) {})
+}
+
+// ResetStats sets the counts of all processes to zero.
+func (m *CacheManager) ResetStats() error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	m.hits.Store(0)
+	m.misses.Store(0)
+	return m.writeCounters(cacheCounters{})
+}
+
+func (m *CacheManager) statsPath() string { return filepath.Join(m.root, "cache-stats.json") }
+
+func (m *CacheManager) readCounters() (cacheCounters, error) {
+	var c cacheCounters
+	if m.root == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(m.statsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		// a damaged file starts the counts over
+		return cacheCounters{}, nil
+	}
+	return c, nil
+}
+
+func (m *CacheManager) writeCounters(c cacheCounters) error {
+	if m.root == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.statsPath(), data)
+}
+
+// updateCounters adds the counts of this process to the statistics file and
+// applies update. The caller holds the lock.
+func (m *CacheManager) updateCounters(update func(*cacheCounters)) error {
+	c, err := m.readCounters()
+	if err != nil {
+		return err
+	}
+	c.Hits += m.hits.Swap(0)
+	c.Misses += m.misses.Swap(0)
+	update(&c)
+	return m.writeCounters(c)
+}
+
+// lock takes the lock file in root, shared with other processes, waiting
+// for it up to cacheLockWait. A lock older than cacheLockStale is left
+// from a process that died and is broken.
+func (m *CacheManager) lock() (unlock func(), err error) {
+	if m.root == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(m.root, 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(m.root, "cache.lock")
+	deadline := time.Now().Add(cacheLockWait)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600) // #nosec G304 -- path from filepath.Join(root, "cache.lock")
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > cacheLockStale {
+			breakStaleLock(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrCacheLocked
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// breakStaleLock removes the stale lock file at path. The lock is first
+// renamed to a name of its own, so only one of several processes breaking it
+// succeeds, and its age is checked again in case another process took the
+// lock after the caller saw it stale; such a lock is put back.
+func breakStaleLock(path string) {
+	claimed := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, claimed); err != nil {
+		return
+	}
+	if info, err := os.Stat(claimed); err == nil && time.Since(info.ModTime()) <= cacheLockStale {
+		_ = os.Link(claimed, path)
+	}
+	_ = os.Remove(claimed)
+}
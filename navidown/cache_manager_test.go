@@ -0,0 +1,217 @@
+package navidown
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCachedPNG writes a cache file of size bytes to dir, last used age ago.
+func writeCachedPNG(t *testing.T, dir, name string, size int, age time.Duration) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(name))
+	path := filepath.Join(dir, hex.EncodeToString(sum[:])+".png")
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	used := time.Now().Add(-age)
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestCacheManager_Prune(t *testing.T) {
+	root := t.TempDir()
+	mermaid := filepath.Join(root, "mermaid")
+	if err := os.MkdirAll(mermaid, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// an explicit cache dir navidown created, and one that was there
+	created, _, _ := findCacheDir(filepath.Join(t.TempDir(), "diagrams"), "x")
+	users := t.TempDir()
+
+	expired := writeCachedPNG(t, mermaid, "expired", 10, 48*time.Hour)
+	oldest := writeCachedPNG(t, created, "oldest", 100, 3*time.Hour)
+	older := writeCachedPNG(t, mermaid, "older", 100, 2*time.Hour)
+	newest := writeCachedPNG(t, created, "newest", 100, time.Hour)
+	owned := writeCachedPNG(t, users, "owned", 100, 48*time.Hour)
+	other := filepath.Join(mermaid, "config.json")
+	if err := os.WriteFile(other, make([]byte, 1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewCacheManager(root, CacheOptions{MaxSize: 250, MaxAge: 24 * time.Hour})
+	m.AddDir(created)
+	m.AddDir(users)
+	result, err := m.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Removed != 2 || result.Freed != 110 || result.Remaining != 2 || result.Bytes != 200 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if exists(expired) || exists(oldest) || !exists(older) || !exists(newest) || !exists(other) {
+		t.Error("wrong files removed")
+	}
+	if !exists(owned) {
+		t.Error("file in a directory navidown did not create removed")
+	}
+
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Bytes != 200 || len(stats.Dirs) != 2 || stats.Evictions != 2 || stats.LastPrune.IsZero() {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// unbounded
+	m.SetOptions(CacheOptions{MaxSize: -1, MaxAge: -1})
+	if result, _ := m.Prune(); result.Removed != 0 {
+		t.Errorf("unbounded prune removed %d files", result.Removed)
+	}
+}
+
+func TestCacheManager_KeepsFilesInUse(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "d2")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := writeCachedPNG(t, dir, "shown", 10, 48*time.Hour)
+	m := NewCacheManager(root, CacheOptions{MaxAge: time.Hour})
+
+	session := New(Options{})
+	m.setInUse(session, []string{path})
+	if _, err := m.Prune(); err != nil || !exists(path) {
+		t.Fatalf("file shown by a session pruned: %v", err)
+	}
+	m.setInUse(session, nil)
+	if _, err := m.Prune(); err != nil || exists(path) {
+		t.Errorf("released file not pruned: %v", err)
+	}
+}
+
+func TestSession_KeepsCachedImages(t *testing.T) {
+	path := writeCachedPNG(t, t.TempDir(), "diagram", 10, 0)
+	session := New(Options{})
+	if err := session.SetMarkdown("![d](" + path + ")\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !SharedCacheManager().used(path) {
+		t.Error("image of the document not kept")
+	}
+	session.Close()
+	if SharedCacheManager().used(path) {
+		t.Error("image kept after Close")
+	}
+}
+
+func TestCacheManager_Stats(t *testing.T) {
+	root := t.TempDir()
+	path := writeCachedPNG(t, root, "a", 1, 2*touchInterval)
+
+	m := NewCacheManager(root, CacheOptions{})
+	info, _ := os.Stat(path)
+	m.hit(path, info)
+	m.miss()
+	if info, _ := os.Stat(path); time.Since(info.ModTime()) > touchInterval {
+		t.Error("hit did not record the use")
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// another process sees the counts and adds its own
+	other := NewCacheManager(root, CacheOptions{})
+	other.miss()
+	stats, err := other.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("hits %d, misses %d", stats.Hits, stats.Misses)
+	}
+
+	if err := other.ResetStats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := m.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("counts not reset: %+v", stats)
+	}
+}
+
+func TestCacheManager_Lock(t *testing.T) {
+	root := t.TempDir()
+	m := NewCacheManager(root, CacheOptions{})
+	unlock, err := m.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := NewCacheManager(root, CacheOptions{}).Prune()
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	unlock()
+	if err := <-done; err != nil {
+		t.Errorf("prune after unlock: %v", err)
+	}
+
+	// a lock left by a dead process is broken
+	lockPath := filepath.Join(root, "cache.lock")
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * cacheLockStale)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Prune(); errors.Is(err, ErrCacheLocked) {
+		t.Error("stale lock not broken")
+	}
+
+	// a lock taken after it was seen stale is put back, not broken
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	breakStaleLock(lockPath)
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("fresh lock removed: %v", err)
+	}
+	if matches, _ := filepath.Glob(lockPath + ".*"); len(matches) != 0 {
+		t.Errorf("claimed lock files left behind: %v", matches)
+	}
+}
+
+func TestDiagramCache_Pruned(t *testing.T) {
+	renderer, logPath := newTestD2Renderer(t, D2Options{})
+	path, err := renderer.RenderToFile("a -> b\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pruned by another process: rendered again rather than served stale
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := renderer.RenderToFile("a -> b\n"); err != nil || !exists(again) {
+		t.Errorf("render after prune: %q, %v", again, err)
+	}
+	if runs, _ := os.ReadFile(logPath); bytes.Count(runs, []byte("\n")) != 2 {
+		t.Errorf("d2 ran %d times, want 2", bytes.Count(runs, []byte("\n")))
+	}
+}
@@ -372,8 +372,8 @@ func TestMarkdownSession_ClearCachesForDocument_SelectiveRefresh(t *testing.T) {
 
 	// set up session with document A's elements
 	session := New(Options{})
-	session.mermaidRenderer = mermaid
-	session.graphvizRenderer = graphviz
+	session.Diagrams().Register("mermaid", mermaid, mermaidLanguages...)
+	session.Diagrams().Register("graphviz", graphviz, graphvizLanguages...)
 	session.elements = []NavElement{
 		{Type: NavElementImage, URL: pathMA},
 		{Type: NavElementImage, URL: pathGA},
@@ -423,8 +423,8 @@ func TestMarkdownSession_ClearCaches(t *testing.T) {
 	// with renderers
 	mermaid := newTestMermaidRenderer(t)
 	graphviz := newTestGraphvizRenderer(t)
-	session.mermaidRenderer = mermaid
-	session.graphvizRenderer = graphviz
+	session.Diagrams().Register("mermaid", mermaid, mermaidLanguages...)
+	session.Diagrams().Register("graphviz", graphviz, graphvizLanguages...)
 
 	// populate caches
 	mermaidPath, err := mermaid.RenderToFile("graph TD\n    A-->B\n")
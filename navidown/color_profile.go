@@ -0,0 +1,137 @@
+package navidown
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+	"github.com/muesli/termenv"
+)
+
+// ColorProfile is the range of colors rendered output may use. The zero
+// value is ProfileTrueColor.
+type ColorProfile int
+
+const (
+	ProfileTrueColor  ColorProfile = iota // 24-bit colors
+	ProfileANSI256                        // the xterm 256-color palette
+	ProfileANSI                           // the 16 basic terminal colors
+	ProfileMonochrome                     // no colors; emphasis through bold, underline, and reverse
+)
+
+func (p ColorProfile) String() string {
+	switch p {
+	case ProfileANSI256:
+		return "256"
+	case ProfileANSI:
+		return "16"
+	case ProfileMonochrome:
+		return "none"
+	}
+	return "truecolor"
+}
+
+// ParseColorProfile parses a profile name: "truecolor" (or "24bit"), "256",
+// "16", "none" (or "mono"), or "auto" for DetectColorProfile.
+func ParseColorProfile(name string) (ColorProfile, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return DetectColorProfile(), nil
+	case "truecolor", "24bit", "16m":
+		return ProfileTrueColor, nil
+	case "256", "ansi256":
+		return ProfileANSI256, nil
+	case "16", "ansi":
+		return ProfileANSI, nil
+	case "none", "mono", "monochrome":
+		return ProfileMonochrome, nil
+	}
+	return ProfileTrueColor, fmt.Errorf("unknown color profile %q (use auto, truecolor, 256, 16, or none)", name)
+}
+
+// DetectColorProfile picks a profile from the environment. A non-empty
+// NO_COLOR or TERM=dumb disables colors; COLORTERM=truecolor (or 24bit)
+// selects true color; a TERM naming 256 colors selects the 256-color
+// palette; anything else gets the 16 basic colors.
+func DetectColorProfile() ColorProfile {
+	if os.Getenv("NO_COLOR") != "" {
+		return ProfileMonochrome
+	}
+	term := strings.ToLower(os.Getenv("TERM"))
+	if term == "dumb" {
+		return ProfileMonochrome
+	}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ProfileTrueColor
+	}
+	switch {
+	case strings.HasSuffix(term, "-direct"), strings.Contains(term, "truecolor"):
+		return ProfileTrueColor
+	case strings.Contains(term, "256color"):
+		return ProfileANSI256
+	}
+	return ProfileANSI
+}
+
+func (p ColorProfile) termenvProfile() termenv.Profile {
+	switch p {
+	case ProfileANSI256:
+		return termenv.ANSI256
+	case ProfileANSI:
+		return termenv.ANSI
+	case ProfileMonochrome:
+		return termenv.Ascii
+	}
+	return termenv.TrueColor
+}
+
+// chromaFormatter returns the chroma formatter for code blocks.
+func (p ColorProfile) chromaFormatter() string {
+	switch p {
+	case ProfileANSI256:
+		return "terminal256"
+	case ProfileANSI:
+		return "terminal16"
+	case ProfileMonochrome:
+		return "noop"
+	}
+	return "terminal16m"
+}
+
+// monochromeStyle adjusts style so emphasis stays visible without colors:
+// headings and strong text are bold, links are underlined, and inline code
+// is shown in reverse video.
+func monochromeStyle(style ansi.StyleConfig) ansi.StyleConfig {
+	on := func() *bool { v := true; return &v }
+
+	style.Heading.Bold = on()
+	style.Strong.Bold = on()
+	style.Emph.Italic = on()
+	style.Strikethrough.CrossedOut = on()
+	style.Link.Underline = on()
+	style.LinkText.Underline = on()
+	style.Image.Underline = on()
+	style.Code.Inverse = on()
+	return style
+}
+
+// compactSGR drops the empty color parameters termenv leaves in sequences
+// without colors ("\x1b[;;1m" becomes "\x1b[1m"), which terminals would
+// otherwise read as resets.
+func compactSGR(s string) string {
+	return ansiSGRPattern.ReplaceAllStringFunc(s, func(seq string) string {
+		params := strings.Split(seq[2:len(seq)-1], ";")
+		kept := params[:0]
+		for _, p := range params {
+			if p != "" {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			return seq
+		}
+		return "\x1b[" + strings.Join(kept, ";") + "m"
+	})
+}
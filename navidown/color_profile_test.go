@@ -0,0 +1,124 @@
+package navidown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDetectColorProfile(t *testing.T) {
+	tests := []struct {
+		name      string
+		noColor   string
+		term      string
+		colorterm string
+		want      ColorProfile
+	}{
+		{"NO_COLOR wins", "1", "xterm-256color", "truecolor", ProfileMonochrome},
+		{"dumb terminal", "", "dumb", "", ProfileMonochrome},
+		{"COLORTERM truecolor", "", "xterm-256color", "truecolor", ProfileTrueColor},
+		{"COLORTERM 24bit", "", "xterm", "24bit", ProfileTrueColor},
+		{"direct TERM", "", "xterm-direct", "", ProfileTrueColor},
+		{"256color TERM", "", "screen-256color", "", ProfileANSI256},
+		{"plain xterm", "", "xterm", "", ProfileANSI},
+		{"no TERM", "", "", "", ProfileANSI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("TERM", tt.term)
+			t.Setenv("COLORTERM", tt.colorterm)
+			if got := DetectColorProfile(); got != tt.want {
+				t.Errorf("DetectColorProfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColorProfile(t *testing.T) {
+	for name, want := range map[string]ColorProfile{
+		"truecolor": ProfileTrueColor,
+		"24bit":     ProfileTrueColor,
+		"256":       ProfileANSI256,
+		"16":        ProfileANSI,
+		"none":      ProfileMonochrome,
+		"Mono":      ProfileMonochrome,
+	} {
+		got, err := ParseColorProfile(name)
+		if err != nil || got != want {
+			t.Errorf("ParseColorProfile(%q) = %v, %v; want %v", name, got, err, want)
+		}
+		if name == strings.ToLower(name) {
+			if again, _ := ParseColorProfile(got.String()); again != got {
+				t.Errorf("%v does not round-trip through String()", got)
+			}
+		}
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if got, err := ParseColorProfile("auto"); err != nil || got != ProfileMonochrome {
+		t.Errorf("ParseColorProfile(auto) = %v, %v; want detected none", got, err)
+	}
+	if _, err := ParseColorProfile("sepia"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+var colorSGRParam = regexp.MustCompile(`\x1b\[[0-9;]*(?:38|48);[25];`)
+
+func TestANSIStyleRenderer_ColorProfiles(t *testing.T) {
+	md := "# Title\n\nSome **strong** and `code` with a [link](x.md).\n\n```go\nfunc main() {}\n```\n"
+
+	render := func(p ColorProfile) string {
+		t.Helper()
+		res, err := NewANSIRenderer().WithColorProfile(p).WithWordWrap(60).Render(md)
+		if err != nil {
+			t.Fatalf("render with %v: %v", p, err)
+		}
+		return strings.Join(res.Lines, "\n")
+	}
+
+	if out := render(ProfileTrueColor); !strings.Contains(out, "38;2;") {
+		t.Error("truecolor output should use 24-bit colors")
+	}
+	if out := render(ProfileANSI256); strings.Contains(out, "38;2;") || !strings.Contains(out, "38;5;") {
+		t.Error("256-color output should use only palette colors")
+	}
+	if out := render(ProfileANSI); colorSGRParam.MatchString(out) {
+		t.Error("16-color output should use only basic colors")
+	}
+
+	mono := render(ProfileMonochrome)
+	if colorSGRParam.MatchString(mono) || regexp.MustCompile(`\x1b\[[0-9;]*\b(3[0-7]|9[0-7])m`).MatchString(mono) {
+		t.Errorf("monochrome output contains colors: %q", mono)
+	}
+	for _, attr := range []string{"\x1b[1m", "\x1b[4m", "\x1b[7m"} {
+		if !strings.Contains(mono, attr) {
+			t.Errorf("monochrome output should keep emphasis %q: %q", attr, mono)
+		}
+	}
+	if !strings.Contains(stripANSIAndMarkers(mono), "func main() {}") {
+		t.Error("monochrome output lost the code block")
+	}
+}
+
+func TestHighlightLine_Profiles(t *testing.T) {
+	marks := map[string]string{}
+	for name, r := range map[string]*ANSIStyleRenderer{
+		"dark":  NewANSIRendererWithStyle("dark"),
+		"light": NewANSIRendererWithStyle("light"),
+		"ansi":  NewANSIRenderer().WithColorProfile(ProfileANSI),
+		"mono":  NewANSIRenderer().WithColorProfile(ProfileMonochrome),
+	} {
+		marks[name] = New(Options{Renderer: r}).lineHighlightSGR()
+	}
+	if !strings.HasPrefix(marks["dark"], "\x1b[48;") || marks["dark"] == marks["light"] {
+		t.Errorf("highlight not taken from the theme: dark %q, light %q", marks["dark"], marks["light"])
+	}
+	if marks["ansi"] != "\x1b[100m" || marks["mono"] != lineHighlightReverse {
+		t.Errorf("ansi %q, monochrome %q", marks["ansi"], marks["mono"])
+	}
+	if got := highlightLine("a\x1b[0mb", lineHighlightReverse); got != "\x1b[7ma\x1b[0m\x1b[7mb\x1b[0m" {
+		t.Errorf("monochrome highlight = %q", got)
+	}
+}
@@ -0,0 +1,33 @@
+package navidown
+
+import (
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// RuneColumns returns the number of terminal cells r takes: two for East
+// Asian wide characters, none for markers, combining marks and other format
+// characters, and one otherwise. NavElement columns count cells this way, so
+// viewers must advance by RuneColumns to land highlights on the right cells.
+func RuneColumns(r rune) int {
+	if IsMarkerRune(r) {
+		return 0
+	}
+	if w := runewidth.RuneWidth(r); w > 0 {
+		return w
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	return 1
+}
+
+// stringColumns returns the number of terminal cells s takes.
+func stringColumns(s string) int {
+	n := 0
+	for _, r := range s {
+		n += RuneColumns(r)
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package navidown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuneColumns(t *testing.T) {
+	for r, want := range map[rune]int{'a': 1, '日': 2, '｡': 1, '\u0301': 0, '\u200B': 0, 'ש': 1} {
+		if got := RuneColumns(r); got != want {
+			t.Errorf("RuneColumns(%q) = %d, want %d", r, got, want)
+		}
+	}
+}
+
+func TestSession_CJKWrapAndLinkColumns(t *testing.T) {
+	s := New(Options{})
+	s.SetWidth(30)
+	md := "日本語の[リンク](https://example.com)です。" + strings.Repeat("長い文章が続きます。", 6) + "\n"
+	if err := s.SetMarkdown(md); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range s.RenderedLines() {
+		if w := stringColumns(stripANSIAndMarkers(line)); w > 30 {
+			t.Errorf("line is %d columns wide: %q", w, stripANSIAndMarkers(line))
+		}
+	}
+
+	if !s.MoveToNextLink(20) {
+		t.Fatal("no link")
+	}
+	sel := s.Selected()
+	clean := stripANSIAndMarkers(s.RenderedLines()[sel.StartLine])
+	before := strings.Index(clean, "リンク")
+	if before < 0 {
+		t.Fatalf("link text not on line %q", clean)
+	}
+	if want := stringColumns(clean[:before]); sel.StartCol != want || sel.EndCol != want+6 {
+		t.Errorf("link columns %d-%d, want %d-%d", sel.StartCol, sel.EndCol, want, want+6)
+	}
+}
@@ -3,6 +3,7 @@ package navidown
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Sentinel errors for ContentFetcher operations.
@@ -25,6 +26,8 @@ type ContentProvider interface {
 type ContentFetcher struct {
 	provider    ContentProvider
 	searchRoots []string
+	rewriter    URLRewriter
+	dispatcher  *LinkDispatcher
 }
 
 // NewContentFetcher creates a new ContentFetcher with the specific provider implementation.
@@ -35,10 +38,36 @@ func NewContentFetcher(provider ContentProvider, searchRoots []string) *ContentF
 	}
 }
 
+// SetURLRewriter sets the rewriter used to derive the source path of fetched
+// remote documents, so relative links resolve against the original page URL
+// rather than the raw-content URL. Use the same rewriter as the provider.
+func (cf *ContentFetcher) SetURLRewriter(r URLRewriter) {
+	cf.rewriter = r
+}
+
+// SetLinkDispatcher routes links through d before loading them, so links
+// such as mailto: or PDFs can be opened externally. Pass nil to load every link.
+func (cf *ContentFetcher) SetLinkDispatcher(d *LinkDispatcher) {
+	cf.dispatcher = d
+}
+
 // OnSelect loads linked markdown and replaces current content, pushing history when navigating.
+// Links routed elsewhere by the link dispatcher are not loaded.
+// A URL fragment is stripped before fetching; line anchors such as #L42-L60
+// then highlight and scroll to those lines, and other fragments scroll to the
+// matching header.
+// The viewport height is not known, so anchors always scroll; hosts that know
+// it should call OnSelectInViewport.
 // Returns an error if the operation fails. Use OnSelectWithErrorDisplay for backward-compatible
 // behavior that shows errors in the viewer.
 func (cf *ContentFetcher) OnSelect(viewer *MarkdownSession, elem NavElement) error {
+	return cf.OnSelectInViewport(viewer, elem, 0)
+}
+
+// OnSelectInViewport is OnSelect for a viewer showing viewportHeight lines.
+// A same-document anchor already on screen is then left where it is, without
+// scrolling or pushing history.
+func (cf *ContentFetcher) OnSelectInViewport(viewer *MarkdownSession, elem NavElement, viewportHeight int) error {
 	if viewer == nil {
 		return ErrNilViewer
 	}
@@ -46,7 +75,24 @@ func (cf *ContentFetcher) OnSelect(viewer *MarkdownSession, elem NavElement) err
 		return ErrNotLink
 	}
 
-	content, err := cf.provider.FetchContent(elem)
+	linkPath, fragment, _ := strings.Cut(elem.URL, "#")
+	if linkPath == "" {
+		// same-document anchor
+		viewer.ScrollToAnchor(fragment, viewportHeight, true)
+		return nil
+	}
+
+	handled, err := cf.dispatcher.Dispatch(elem)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", elem.URL, err)
+	}
+	if handled {
+		return nil
+	}
+	fetchElem := elem
+	fetchElem.URL = linkPath
+
+	content, err := cf.provider.FetchContent(fetchElem)
 	if err != nil {
 		return fmt.Errorf("fetch content for %q: %w", elem.URL, err)
 	}
@@ -55,15 +101,26 @@ func (cf *ContentFetcher) OnSelect(viewer *MarkdownSession, elem NavElement) err
 		return ErrEmptyContent
 	}
 
-	newSourcePath := elem.URL
-	if !isHTTPURL(elem.URL) && elem.SourceFilePath != "" {
-		resolved, rerr := ResolveMarkdownPath(elem.URL, elem.SourceFilePath, cf.searchRoots)
+	newSourcePath := linkPath
+	if !isHTTPURL(linkPath) && elem.SourceFilePath != "" {
+		resolved, rerr := ResolveMarkdownPath(linkPath, elem.SourceFilePath, cf.searchRoots)
 		if rerr == nil && resolved != "" {
 			newSourcePath = resolved
 		}
 	}
+	if isHTTPURL(newSourcePath) {
+		newSourcePath = RewriteSourceURL(cf.rewriter, newSourcePath)
+	}
 
-	return viewer.SetMarkdownWithSource(content, newSourcePath, true)
+	if err := viewer.SetMarkdownWithSource(content, newSourcePath, true); err != nil {
+		return err
+	}
+	if r, ok := ParseLineAnchor(fragment); ok {
+		viewer.ScrollToSourceLines(r, viewportHeight, false)
+	} else if fragment != "" {
+		viewer.ScrollToAnchor(fragment, viewportHeight, false)
+	}
+	return nil
 }
 
 // OnSelectWithErrorDisplay loads content, showing errors in the viewer.
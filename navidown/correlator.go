@@ -22,7 +22,7 @@ type PositionCorrelator interface {
 	// CorrelatePosition finds the best position match for an element in rendered lines.
 	// Returns (lineIdx, startCol, endCol, found).
 	//
-	// startCol/endCol are rune columns in the cleaned line.
+	// startCol/endCol are cell columns in the cleaned line (see RuneColumns).
 	CorrelatePosition(elem *NavElement, renderedLines []string, cleaner LineCleaner) (int, int, int, bool)
 }
 
@@ -130,11 +130,12 @@ func (sc *ScoringCorrelator) findCandidateMatches(elemText string, cleanLine str
 
 		score := sc.scoreMatchRunes(cleanRunes, runeIdx, endRuneIdx, elemType, elemLevel, cleanLine)
 		if score > 0 {
+			startCol := stringColumns(cleanLine[:actualByteIdx])
 			candidates = append(candidates, matchCandidate{
 				lineIdx:  lineIdx,
 				score:    score,
-				startCol: runeIdx,
-				endCol:   endRuneIdx,
+				startCol: startCol,
+				endCol:   startCol + stringColumns(elemText),
 			})
 		}
 
@@ -195,15 +196,15 @@ func (ssc *SimpleSubstringCorrelator) CorrelatePosition(elem *NavElement, render
 		cleaner = LineCleanerFunc(func(s string) string { return s })
 	}
 
-	// Pre-compute element rune length once
-	elemRuneLen := utf8.RuneCountInString(elemText)
+	// Pre-compute element width once
+	elemCols := stringColumns(elemText)
 
 	for lineIdx, line := range renderedLines {
 		cleanLine := cleaner.Clean(line)
 		byteIdx := strings.Index(cleanLine, elemText)
 		if byteIdx >= 0 {
-			runeIdx := utf8.RuneCountInString(cleanLine[:byteIdx])
-			return lineIdx, runeIdx, runeIdx + elemRuneLen, true
+			startCol := stringColumns(cleanLine[:byteIdx])
+			return lineIdx, startCol, startCol + elemCols, true
 		}
 	}
 	return 0, 0, 0, false
@@ -0,0 +1,410 @@
+package navidown
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CredentialResolver supplies authentication headers for outgoing document
+// and image requests. Resolve returns the headers to attach for u, or nil when
+// the URL needs no credentials.
+type CredentialResolver interface {
+	Resolve(u *url.URL) (http.Header, error)
+}
+
+// CredentialResolverFunc adapts a function to the CredentialResolver interface.
+type CredentialResolverFunc func(u *url.URL) (http.Header, error)
+
+func (f CredentialResolverFunc) Resolve(u *url.URL) (http.Header, error) { return f(u) }
+
+// Credential attaches authentication to URLs matching Match.
+//
+// Match is one of:
+//   - a host ("docs.example.com" or "docs.example.com:8443")
+//   - a subdomain wildcard ("*.example.com", which does not match example.com itself)
+//   - a URL prefix ("https://example.com/private/"), matched on scheme, host, and path
+//
+// Host and wildcard patterns only match https URLs, so a redirect to plain
+// http never carries the credentials in cleartext; use a URL prefix such as
+// "http://intranet/" to send them over http.
+//
+// Secret values may be given literally or read from the environment at
+// request time via the *Env fields; the Env field wins when both are set.
+type Credential struct {
+	Match string
+
+	BearerToken    string
+	BearerTokenEnv string
+
+	Username    string
+	Password    string
+	UsernameEnv string
+	PasswordEnv string
+
+	// Header holds extra headers (e.g. "PRIVATE-TOKEN") added verbatim.
+	// HeaderEnv maps header names to environment variables holding their values.
+	Header    http.Header
+	HeaderEnv map[string]string
+}
+
+// ErrCredentialEnvUnset is returned when a credential references an
+// environment variable that is not set.
+var ErrCredentialEnvUnset = errors.New("credential environment variable not set")
+
+// Matches reports whether the credential applies to u.
+func (c Credential) Matches(u *url.URL) bool {
+	return matchCredentialPattern(c.Match, u)
+}
+
+func (c Credential) headers() (http.Header, error) {
+	h := http.Header{}
+
+	token, err := envOr(c.BearerToken, c.BearerTokenEnv)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+
+	user, err := envOr(c.Username, c.UsernameEnv)
+	if err != nil {
+		return nil, err
+	}
+	pass, err := envOr(c.Password, c.PasswordEnv)
+	if err != nil {
+		return nil, err
+	}
+	if user != "" || pass != "" {
+		r := &http.Request{Header: http.Header{}}
+		r.SetBasicAuth(user, pass)
+		h.Set("Authorization", r.Header.Get("Authorization"))
+	}
+
+	for name, values := range c.Header {
+		for _, v := range values {
+			h.Add(name, v)
+		}
+	}
+	for name, env := range c.HeaderEnv {
+		v, err := envOr("", env)
+		if err != nil {
+			return nil, err
+		}
+		h.Set(name, v)
+	}
+	return h, nil
+}
+
+func envOr(literal, env string) (string, error) {
+	if env == "" {
+		return literal, nil
+	}
+	v, ok := os.LookupEnv(env)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrCredentialEnvUnset, env)
+	}
+	return v, nil
+}
+
+// StaticCredentials resolves credentials from a fixed rule list.
+// The first matching rule wins.
+type StaticCredentials []Credential
+
+func (s StaticCredentials) Resolve(u *url.URL) (http.Header, error) {
+	for _, c := range s {
+		if c.Matches(u) {
+			return c.headers()
+		}
+	}
+	return nil, nil
+}
+
+// ChainCredentials tries each resolver in order and returns the first
+// non-empty header set.
+func ChainCredentials(resolvers ...CredentialResolver) CredentialResolver {
+	return CredentialResolverFunc(func(u *url.URL) (http.Header, error) {
+		for _, r := range resolvers {
+			if r == nil {
+				continue
+			}
+			h, err := r.Resolve(u)
+			if err != nil {
+				return nil, err
+			}
+			if len(h) > 0 {
+				return h, nil
+			}
+		}
+		return nil, nil
+	})
+}
+
+// NetrcCredentials resolves basic-auth credentials from a netrc file.
+// The file is read once, on first use.
+type NetrcCredentials struct {
+	// Path is the netrc file; "" uses $NETRC, then ~/.netrc (~/_netrc on Windows).
+	Path string
+
+	once    sync.Once
+	entries []netrcEntry
+	err     error
+}
+
+type netrcEntry struct {
+	machine  string // "" for the default entry
+	login    string
+	password string
+}
+
+// Resolve returns basic auth for https URLs whose host has a netrc entry.
+// Like host patterns, netrc entries never apply to plain http.
+func (n *NetrcCredentials) Resolve(u *url.URL) (http.Header, error) {
+	n.once.Do(n.load)
+	if n.err != nil {
+		return nil, n.err
+	}
+	if !strings.EqualFold(u.Scheme, "https") {
+		return nil, nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, e := range n.entries {
+		if e.machine == "" || e.machine == host {
+			r := &http.Request{Header: http.Header{}}
+			r.SetBasicAuth(e.login, e.password)
+			return http.Header{"Authorization": r.Header.Values("Authorization")}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (n *NetrcCredentials) load() {
+	path := n.Path
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(home, name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// a missing default netrc simply means no credentials
+		if n.Path != "" || !errors.Is(err, os.ErrNotExist) {
+			n.err = fmt.Errorf("read netrc: %w", err)
+		}
+		return
+	}
+	n.entries = parseNetrc(string(data))
+}
+
+// parseNetrc parses the machine/default/login/password subset of the netrc
+// format. Macro definitions are skipped. The default entry, if any, is moved
+// to the end so specific machines take precedence.
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var defaultEntry *netrcEntry
+	var cur *netrcEntry
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if cur.machine == "" {
+			defaultEntry = cur
+		} else {
+			entries = append(entries, *cur)
+		}
+		cur = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				flush()
+				if i+1 < len(fields) {
+					i++
+					cur = &netrcEntry{machine: strings.ToLower(fields[i])}
+				}
+			case "default":
+				flush()
+				cur = &netrcEntry{}
+			case "login":
+				if cur != nil && i+1 < len(fields) {
+					i++
+					cur.login = fields[i]
+				}
+			case "password":
+				if cur != nil && i+1 < len(fields) {
+					i++
+					cur.password = fields[i]
+				}
+			case "macdef":
+				flush()
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	flush()
+	if defaultEntry != nil {
+		entries = append(entries, *defaultEntry)
+	}
+	return entries
+}
+
+// matchCredentialPattern implements the Credential.Match rules.
+func matchCredentialPattern(pattern string, u *url.URL) bool {
+	if pattern == "" || u == nil {
+		return false
+	}
+	if strings.Contains(pattern, "://") {
+		p, err := url.Parse(pattern)
+		if err != nil {
+			return false
+		}
+		// "https://host" and "https://host/" are the same resource
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return strings.EqualFold(p.Scheme, u.Scheme) &&
+			sameHostPort(p, u) &&
+			strings.HasPrefix(path, p.EscapedPath())
+	}
+
+	if !strings.EqualFold(u.Scheme, "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	pattern = strings.ToLower(pattern)
+	if _, port, err := net.SplitHostPort(pattern); err == nil {
+		if port != effectivePort(u) {
+			return false
+		}
+		pattern, _, _ = net.SplitHostPort(pattern)
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// sameHostPort compares hosts case-insensitively, treating an omitted port as
+// the scheme default.
+func sameHostPort(a, b *url.URL) bool {
+	return strings.EqualFold(a.Hostname(), b.Hostname()) && effectivePort(a) == effectivePort(b)
+}
+
+func effectivePort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	if strings.EqualFold(u.Scheme, "http") {
+		return "80"
+	}
+	return "443"
+}
+
+// credentialUseKey is the request context key of a *credentialUse.
+type credentialUseKey struct{}
+
+// credentialUse records whether credentials were attached to a request from
+// AuthorizedRequest or to one of its redirects, so HTTPCache can tell
+// authenticated responses apart even when the resolver added custom headers.
+type credentialUse struct {
+	attached atomic.Bool
+}
+
+// AuthorizedRequest builds a GET request for rawURL with credentials from
+// creds applied, and returns a client whose redirect policy re-scopes them:
+// on every redirect the headers the resolver added are removed and
+// credentials are resolved afresh for the target URL. This stops tokens from
+// leaking to other hosts, including custom headers that net/http would
+// otherwise forward. A nil creds returns client and a plain request unchanged.
+func AuthorizedRequest(client *http.Client, creds CredentialResolver, rawURL string) (*http.Client, *http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds == nil {
+		return client, req, nil
+	}
+
+	added, err := creds.Resolve(req.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve credentials: %w", err)
+	}
+	for name, values := range added {
+		req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	use := &credentialUse{}
+	use.attached.Store(len(added) > 0)
+	req = req.WithContext(context.WithValue(req.Context(), credentialUseKey{}, use))
+
+	base := client
+	if base == nil {
+		base = http.DefaultClient
+	}
+	scoped := *base
+	scoped.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if base.CheckRedirect != nil {
+			if err := base.CheckRedirect(next, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		// net/http forwards custom headers everywhere and Authorization to
+		// subdomains; drop both and resolve again for the redirect target.
+		for name := range added {
+			next.Header.Del(name)
+		}
+		next.Header.Del("Authorization")
+		h, err := creds.Resolve(next.URL)
+		if err != nil {
+			return fmt.Errorf("resolve credentials: %w", err)
+		}
+		for name, values := range h {
+			next.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+		if len(h) > 0 {
+			use.attached.Store(true)
+		}
+		return nil
+	}
+	return &scoped, req, nil
+}
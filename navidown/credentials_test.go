@@ -0,0 +1,240 @@
+package navidown
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestMatchCredentialPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"docs.example.com", "https://docs.example.com/a.md", true},
+		{"docs.example.com", "https://DOCS.example.com/a.md", true},
+		{"docs.example.com", "https://evil.com/docs.example.com", false},
+		{"docs.example.com", "http://docs.example.com/a.md", false},
+		{"*.example.com", "http://a.example.com/x", false},
+		{"http://docs.example.com/", "http://docs.example.com/a.md", true},
+		{"docs.example.com:8443", "https://docs.example.com:8443/a.md", true},
+		{"docs.example.com:8443", "https://docs.example.com/a.md", false},
+		{"example.com:443", "https://example.com/a.md", true},
+		{"*.example.com", "https://a.example.com/x", true},
+		{"*.example.com", "https://example.com/x", false},
+		{"*.example.com", "https://notexample.com/x", false},
+		{"https://example.com/private/", "https://example.com/private/a.md", true},
+		{"https://example.com/private/", "https://example.com/public/a.md", false},
+		{"https://example.com/private/", "http://example.com/private/a.md", false},
+		{"", "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		if got := matchCredentialPattern(tt.pattern, mustParseURL(t, tt.url)); got != tt.want {
+			t.Errorf("match(%q, %q) = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestStaticCredentials_Resolve(t *testing.T) {
+	t.Setenv("NAVIDOWN_TEST_TOKEN", "s3cret")
+	creds := StaticCredentials{
+		{Match: "bearer.example.com", BearerTokenEnv: "NAVIDOWN_TEST_TOKEN"},
+		{Match: "basic.example.com", Username: "alice", Password: "pw"},
+		{Match: "gitlab.example.com", Header: http.Header{"Private-Token": {"glpat"}}},
+		{Match: "missing.example.com", BearerTokenEnv: "NAVIDOWN_TEST_UNSET_VAR"},
+	}
+
+	h, err := creds.Resolve(mustParseURL(t, "https://bearer.example.com/x"))
+	if err != nil || h.Get("Authorization") != "Bearer s3cret" {
+		t.Errorf("bearer: %v %v", h, err)
+	}
+
+	h, err = creds.Resolve(mustParseURL(t, "https://basic.example.com/x"))
+	if err != nil || !strings.HasPrefix(h.Get("Authorization"), "Basic ") {
+		t.Errorf("basic: %v %v", h, err)
+	}
+
+	h, err = creds.Resolve(mustParseURL(t, "https://gitlab.example.com/x"))
+	if err != nil || h.Get("Private-Token") != "glpat" {
+		t.Errorf("header: %v %v", h, err)
+	}
+
+	h, err = creds.Resolve(mustParseURL(t, "https://other.example.com/x"))
+	if err != nil || h != nil {
+		t.Errorf("unmatched host should yield no headers, got %v %v", h, err)
+	}
+
+	_, err = creds.Resolve(mustParseURL(t, "https://missing.example.com/x"))
+	if !errors.Is(err, ErrCredentialEnvUnset) {
+		t.Errorf("expected ErrCredentialEnvUnset, got %v", err)
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	data := `# comment
+machine docs.example.com login alice password one
+macdef init
+  cd /pub
+
+default login anon password guest
+machine Other.Example.com
+  login bob
+  password two
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	n := &NetrcCredentials{Path: path}
+
+	check := func(rawURL, wantUser, wantPass string) {
+		t.Helper()
+		h, err := n.Resolve(mustParseURL(t, rawURL))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &http.Request{Header: h}
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != wantUser || pass != wantPass {
+			t.Errorf("%s: got %q/%q ok=%v, want %q/%q", rawURL, user, pass, ok, wantUser, wantPass)
+		}
+	}
+	check("https://docs.example.com/a.md", "alice", "one")
+	check("https://other.example.com/a.md", "bob", "two")
+	check("https://unknown.example.com/a.md", "anon", "guest")
+	if h, _ := n.Resolve(mustParseURL(t, "http://docs.example.com/a.md")); h != nil {
+		t.Errorf("netrc credentials sent over http: %v", h)
+	}
+
+	missing := &NetrcCredentials{Path: filepath.Join(t.TempDir(), "nope")}
+	if _, err := missing.Resolve(mustParseURL(t, "https://x/")); err == nil {
+		t.Error("explicit missing netrc path should error")
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	first := StaticCredentials{{Match: "a.example.com", BearerToken: "a"}}
+	second := StaticCredentials{{Match: "*.example.com", BearerToken: "wild"}}
+	chain := ChainCredentials(first, nil, second)
+
+	h, _ := chain.Resolve(mustParseURL(t, "https://a.example.com/"))
+	if h.Get("Authorization") != "Bearer a" {
+		t.Errorf("first resolver should win, got %v", h)
+	}
+	h, _ = chain.Resolve(mustParseURL(t, "https://b.example.com/"))
+	if h.Get("Authorization") != "Bearer wild" {
+		t.Errorf("fallback resolver should apply, got %v", h)
+	}
+}
+
+func TestAuthorizedRequest_StripsCredentialsOnCrossHostRedirect(t *testing.T) {
+	type seen struct{ auth, custom string }
+	var other seen
+	otherSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = seen{r.Header.Get("Authorization"), r.Header.Get("Private-Token")}
+		_, _ = w.Write([]byte("other"))
+	}))
+	defer otherSrv.Close()
+
+	var origin seen
+	originSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/start" {
+			http.Redirect(w, r, "/hop", http.StatusFound)
+			return
+		}
+		origin = seen{r.Header.Get("Authorization"), r.Header.Get("Private-Token")}
+		http.Redirect(w, r, otherSrv.URL+"/target", http.StatusFound)
+	}))
+	defer originSrv.Close()
+
+	creds := StaticCredentials{{
+		Match:       originSrv.URL + "/",
+		BearerToken: "tok",
+		Header:      http.Header{"Private-Token": {"glpat"}},
+	}}
+
+	client, req, err := AuthorizedRequest(nil, creds, originSrv.URL+"/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := doHTTP(client, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "other" {
+		t.Fatalf("unexpected body %q", resp.Body)
+	}
+	if origin.auth != "Bearer tok" || origin.custom != "glpat" {
+		t.Errorf("same-host redirect should keep credentials, got %+v", origin)
+	}
+	if other.auth != "" || other.custom != "" {
+		t.Errorf("credentials leaked to other host: %+v", other)
+	}
+}
+
+func TestAuthorizedRequest_StripsCredentialsOnDowngradeRedirect(t *testing.T) {
+	var plainAuth string
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plainAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("plain"))
+	}))
+	defer plain.Close()
+
+	var tlsAuth string
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tlsAuth = r.Header.Get("Authorization")
+		http.Redirect(w, r, plain.URL+"/a.md", http.StatusFound)
+	}))
+	defer secure.Close()
+
+	// the host pattern matches both servers, which differ only in scheme and port
+	creds := ChainCredentials(StaticCredentials{{Match: "127.0.0.1", BearerToken: "tok"}}, &NetrcCredentials{Path: writeNetrc(t, "default login u password p\n")})
+	client, req, err := AuthorizedRequest(secure.Client(), creds, secure.URL+"/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doHTTP(client, req); err != nil {
+		t.Fatal(err)
+	}
+	if tlsAuth != "Bearer tok" {
+		t.Errorf("https request lacks credentials: %q", tlsAuth)
+	}
+	if plainAuth != "" {
+		t.Errorf("credentials sent over http after redirect: %q", plainAuth)
+	}
+}
+
+// writeNetrc writes a netrc file with data and returns its path.
+func writeNetrc(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAuthorizedRequest_NilCredentials(t *testing.T) {
+	client, req, err := AuthorizedRequest(nil, nil, "https://example.com/a.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client != nil || len(req.Header) != 0 {
+		t.Errorf("nil creds should pass through client and add no headers")
+	}
+}
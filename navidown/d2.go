@@ -0,0 +1,185 @@
+package navidown
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// D2Languages are the fence languages of D2 blocks, for registering a
+// D2Renderer in a DiagramRegistry.
+var D2Languages = []string{"d2"}
+
+// d2DarkTheme is the theme used in dark mode when no theme is set: "Dark
+// Mauve". d2's --dark-theme is not used, as it switches themes with a CSS
+// media query that rasterizers ignore.
+const d2DarkTheme = 200
+
+// D2Options configures D2 diagram rendering.
+type D2Options struct {
+	D2Path   string        // path to d2 binary; "" = lookup "d2" in PATH
+	Layout   string        // layout engine (dagre, elk, tala); "" = "dagre"
+	Theme    int           // theme ID; 0 = d2's default, or Dark Mauve (200) in dark mode
+	Scale    int           // rasterization scale; 0 = 2 (retina)
+	Timeout  time.Duration // render timeout; 0 = 30s
+	CacheDir string        // persistent cache dir; "" = auto (os.UserCacheDir()/navidown/d2)
+	// DarkMode renders with a dark theme (unless Theme is set) and lightens
+	// dark gray text and lines like other SVG images. Set to false for light
+	// terminals. Default true.
+	DarkMode *bool
+	// Unsafe lets d2 fetch the remote icon and image URLs of a diagram and
+	// read the local files they name, embedding them in the output. By
+	// default images are not bundled and are left out of the PNG. Sources
+	// are always compiled alone in a private directory, so relative @import
+	// paths find nothing either way.
+	Unsafe bool
+}
+
+func (o *D2Options) resolvedDarkMode() bool {
+	if o.DarkMode != nil {
+		return *o.DarkMode
+	}
+	return true
+}
+
+func (o *D2Options) resolvedLayout() string {
+	if o.Layout != "" {
+		return o.Layout
+	}
+	return "dagre"
+}
+
+func (o *D2Options) resolvedTheme() int {
+	if o.Theme == 0 && o.resolvedDarkMode() {
+		return d2DarkTheme
+	}
+	return o.Theme
+}
+
+func (o *D2Options) resolvedScale() int {
+	if o.Scale > 0 {
+		return o.Scale
+	}
+	return 2
+}
+
+func (o *D2Options) resolvedTimeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return 30 * time.Second
+}
+
+// D2Renderer renders D2 source code to PNG files with the d2 CLI,
+// rasterizing its SVG output with the wasm rasterizer.
+type D2Renderer struct {
+	diagramCache
+	opts       D2Options
+	d2Path     string
+	rasterizer SVGRasterizer
+}
+
+// NewD2Renderer creates a new renderer. Returns nil if d2 is not found or
+// the wasm rasterizer cannot be initialized.
+func NewD2Renderer(opts D2Options) *D2Renderer {
+	d2Path := opts.D2Path
+	if d2Path == "" {
+		resolved, err := exec.LookPath("d2")
+		if err != nil {
+			return nil
+		}
+		d2Path = resolved
+	}
+
+	rasterizer, err := sharedWasmRasterizer()
+	if err != nil {
+		return nil
+	}
+
+	persistentDir, tempDir, workDir := resolveCacheDir(opts.CacheDir, "d2")
+	if workDir == "" {
+		return nil
+	}
+
+	return &D2Renderer{
+		diagramCache: diagramCache{persistentDir: persistentDir, tempDir: tempDir, workDir: workDir},
+		opts:         opts,
+		d2Path:       d2Path,
+		rasterizer:   rasterizer,
+	}
+}
+
+func (r *D2Renderer) cacheKey(source string) string {
+	h := sha256.New()
+	h.Write([]byte(source))
+	h.Write([]byte{0})
+	h.Write([]byte(r.opts.resolvedLayout()))
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%d", r.opts.resolvedTheme())
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%d", r.opts.resolvedScale())
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%t", r.opts.resolvedDarkMode())
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%t", r.opts.Unsafe)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// RenderToFile renders D2 source to a PNG file and returns its absolute path.
+// Results are cached by content hash (in-memory and on disk).
+func (r *D2Renderer) RenderToFile(source string) (string, error) {
+	key := r.cacheKey(source)
+	outputPath := filepath.Join(r.workDir, key+".png")
+
+	if path, ok := r.lookup(key, outputPath); ok {
+		return path, nil
+	}
+
+	// a directory of its own, so @import finds no other files
+	srcDir, err := os.MkdirTemp(r.workDir, "d2-")
+	if err != nil {
+		return "", fmt.Errorf("create d2 source dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(srcDir) }()
+	inputPath := filepath.Join(srcDir, "diagram.d2")
+	if err := os.WriteFile(inputPath, []byte(source), 0600); err != nil {
+		return "", fmt.Errorf("write d2 source: %w", err)
+	}
+	svgPath := filepath.Join(srcDir, "diagram.svg")
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.resolvedTimeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.d2Path, // #nosec G204 -- d2Path from LookPath("d2") or user-provided
+		fmt.Sprintf("--theme=%d", r.opts.resolvedTheme()),
+		"--layout="+r.opts.resolvedLayout(),
+		fmt.Sprintf("--bundle=%t", r.opts.Unsafe),
+		inputPath, svgPath,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", &DiagramToolError{Tool: "d2", Err: err, Output: string(out)}
+	}
+
+	svg, err := os.ReadFile(svgPath) // #nosec G304 -- svgPath from filepath.Join(srcDir, "diagram.svg")
+	if err != nil {
+		return "", fmt.Errorf("read d2 svg: %w", err)
+	}
+	if r.opts.resolvedDarkMode() {
+		svg = preprocessSVGForDarkMode(svg)
+	}
+	png, err := rasterizeDiagramSVG(r.rasterizer, svg, r.opts.resolvedScale())
+	if err != nil {
+		return "", fmt.Errorf("rasterize d2 svg: %w", err)
+	}
+	if err := os.WriteFile(outputPath, png, 0600); err != nil { // #nosec G703 -- outputPath from filepath.Join(workDir, hash+".png")
+		return "", fmt.Errorf("write d2 png: %w", err)
+	}
+
+	r.store(key, outputPath)
+	return outputPath, nil
+}
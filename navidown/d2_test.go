@@ -0,0 +1,155 @@
+package navidown
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const testD2SVG = `<svg xmlns="http://www.w3.org/2000/svg" width="30" height="20" viewBox="0 0 30 20">` +
+	`<rect x="1" y="1" width="28" height="18" fill="#1E1E2E" stroke="#000000"/></svg>`
+
+// newTestD2Renderer creates a D2Renderer whose fake d2 copies an SVG
+// fixture to its output argument and logs its arguments, a line per run.
+func newTestD2Renderer(t *testing.T, opts D2Options) (*D2Renderer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	fixturePath := filepath.Join(dir, "fixture.svg")
+	if err := os.WriteFile(fixturePath, []byte(testD2SVG), 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	logPath := filepath.Join(dir, "args")
+	opts.D2Path = writeFakeD2(t, dir, fixturePath, logPath)
+	opts.CacheDir = t.TempDir()
+	renderer := NewD2Renderer(opts)
+	if renderer == nil {
+		t.Fatal("NewD2Renderer returned nil")
+	}
+	t.Cleanup(renderer.Close)
+	return renderer, logPath
+}
+
+// writeFakeD2 creates a platform-appropriate fake d2 that logs its
+// arguments to logPath and copies fixturePath to its last argument.
+func writeFakeD2(t *testing.T, dir, fixturePath, logPath string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		p := filepath.Join(dir, "fake-d2.bat")
+		script := fmt.Sprintf("@echo off\r\necho %%*>>\"%s\"\r\n:loop\r\nif \"%%~2\"==\"\" goto end\r\nshift\r\ngoto loop\r\n:end\r\ncopy /Y \"%s\" \"%%~1\" >nul\r\n",
+			filepath.FromSlash(logPath), filepath.FromSlash(fixturePath))
+		if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+			t.Fatalf("write fake d2.bat: %v", err)
+		}
+		return p
+	}
+	p := filepath.Join(dir, "fake-d2")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> \"%s\"\nfor out; do :; done\ncp \"%s\" \"$out\"\n", logPath, fixturePath)
+	if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+		t.Fatalf("write fake d2: %v", err)
+	}
+	return p
+}
+
+func TestD2Renderer_RenderToFile(t *testing.T) {
+	renderer, logPath := newTestD2Renderer(t, D2Options{Layout: "elk"})
+
+	path, err := renderer.RenderToFile("a -> b\n")
+	if err != nil {
+		t.Fatalf("RenderToFile: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.HasPrefix(data, minimalPNG()[:8]) {
+		t.Fatal("output is not a PNG")
+	}
+	log, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(log), "--theme=200 --layout=elk") {
+		t.Errorf("unexpected d2 arguments: %s", log)
+	}
+	// only the PNG is kept
+	entries, _ := os.ReadDir(renderer.WorkDir())
+	if len(entries) != 1 {
+		t.Errorf("work dir holds %d files, want 1", len(entries))
+	}
+
+	// cached in memory, then on disk
+	if again, err := renderer.RenderToFile("a -> b\n"); err != nil || again != path {
+		t.Errorf("second render: %q, %v", again, err)
+	}
+	renderer.cache.Delete(renderer.cacheKey("a -> b\n"))
+	if again, err := renderer.RenderToFile("a -> b\n"); err != nil || again != path {
+		t.Errorf("render from disk: %q, %v", again, err)
+	}
+	if runs, _ := os.ReadFile(logPath); strings.Count(string(runs), "\n") != 1 {
+		t.Errorf("d2 ran %d times, want 1", strings.Count(string(runs), "\n"))
+	}
+
+	renderer.EvictKeys([]string{renderer.cacheKey("a -> b\n")})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("evicted PNG still on disk")
+	}
+}
+
+func TestD2Renderer_Theme(t *testing.T) {
+	light := false
+	for _, tc := range []struct {
+		opts D2Options
+		want string
+	}{
+		{D2Options{DarkMode: &light}, "--theme=0 "},
+		{D2Options{Theme: 4}, "--theme=4 "},
+	} {
+		renderer, logPath := newTestD2Renderer(t, tc.opts)
+		if _, err := renderer.RenderToFile("x\n"); err != nil {
+			t.Fatal(err)
+		}
+		if log, _ := os.ReadFile(logPath); !strings.Contains(string(log), tc.want) {
+			t.Errorf("%+v: d2 arguments %s, want %q", tc.opts, log, tc.want)
+		}
+	}
+
+	dark, _ := newTestD2Renderer(t, D2Options{})
+	other, _ := newTestD2Renderer(t, D2Options{DarkMode: &light})
+	if dark.cacheKey("x") == other.cacheKey("x") {
+		t.Error("cache key ignores the theme")
+	}
+}
+
+func TestD2Renderer_Sandbox(t *testing.T) {
+	renderer, logPath := newTestD2Renderer(t, D2Options{})
+	if _, err := renderer.RenderToFile("x: {icon: https://example.com/x.svg}\n"); err != nil {
+		t.Fatal(err)
+	}
+	log, _ := os.ReadFile(logPath)
+	if !strings.Contains(string(log), "--bundle=false ") {
+		t.Errorf("d2 may fetch images: %s", log)
+	}
+	// the source is compiled in a directory of its own
+	args := strings.Fields(string(log))
+	if input := args[len(args)-2]; filepath.Dir(input) == renderer.WorkDir() {
+		t.Errorf("source written to the cache dir: %s", input)
+	}
+
+	unsafe, logPath := newTestD2Renderer(t, D2Options{Unsafe: true})
+	if _, err := unsafe.RenderToFile("x\n"); err != nil {
+		t.Fatal(err)
+	}
+	if log, _ := os.ReadFile(logPath); !strings.Contains(string(log), "--bundle=true ") {
+		t.Errorf("unsafe d2 does not bundle images: %s", log)
+	}
+	if renderer.cacheKey("x") == unsafe.cacheKey("x") {
+		t.Error("cache key ignores Unsafe")
+	}
+}
+
+func TestD2Renderer_Failure(t *testing.T) {
+	renderer := NewD2Renderer(D2Options{D2Path: writeFailingDot(t, t.TempDir()), CacheDir: t.TempDir()})
+	if _, err := renderer.RenderToFile("a -> \n"); err == nil || !strings.HasPrefix(err.Error(), "d2 failed") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if r := preprocessDiagram("```d2\na -> \n```\n", "d2", renderer, D2Languages...); !strings.Contains(r, "```d2") {
+		t.Errorf("failed block not kept:\n%s", r)
+	}
+}
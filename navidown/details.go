@@ -0,0 +1,257 @@
+package navidown
+
+import (
+	"maps"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// <details> blocks are collapsible. Before rendering, preprocessDetails
+// numbers every <details> tag of the document in a data-details attribute
+// and sets its open attribute from the session's state; a closed block
+// loses its body, so nothing inside it (links, diagrams) is rendered or
+// navigable. The HTML transformer shows the summary as a navigable line
+// (NavElementDetails) that ToggleSelectedDetails opens and closes.
+
+var (
+	detailsOpenTagRe  = regexp.MustCompile(`(?i)<details(\s[^>]*)?>`)
+	detailsCloseTagRe = regexp.MustCompile(`(?i)</details\s*>`)
+	summaryCloseTagRe = regexp.MustCompile(`(?i)</summary\s*>`)
+	openAttrRe        = regexp.MustCompile(`(?i)\sopen(\s|=|/|$)`)
+	codeFenceRe       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+)
+
+// detailsPrefix starts the URL of a NavElementDetails.
+const detailsPrefix = "details:"
+
+// detailsBlock is a <details> block of the markdown source, by byte offsets.
+type detailsBlock struct {
+	tagStart, tagEnd int  // the <details> tag
+	bodyStart        int  // after </summary>, or after the tag without one
+	end              int  // the </details> tag, or the end of the document
+	open             bool // the tag has the open attribute
+	hasSummary       bool
+}
+
+// findDetailsBlocks returns the <details> blocks of markdown in the order of
+// their tags, skipping tags in code.
+func findDetailsBlocks(markdown string) []detailsBlock {
+	type tag struct {
+		start, end int
+		open       bool // a <details> tag, else </details>
+		attrs      string
+	}
+	var tags []tag
+	offset := 0
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		lineStart := offset
+		offset += len(line)
+		if m := codeFenceRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case m[1][0] == fence[0] && len(m[1]) >= len(fence):
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+		for _, m := range detailsOpenTagRe.FindAllStringSubmatchIndex(line, -1) {
+			if !inCodeSpan(line, m[0]) {
+				attrs := ""
+				if m[2] >= 0 {
+					attrs = line[m[2]:m[3]]
+				}
+				tags = append(tags, tag{lineStart + m[0], lineStart + m[1], true, attrs})
+			}
+		}
+		for _, m := range detailsCloseTagRe.FindAllStringIndex(line, -1) {
+			if !inCodeSpan(line, m[0]) {
+				tags = append(tags, tag{start: lineStart + m[0], end: lineStart + m[1]})
+			}
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].start < tags[j].start })
+
+	var blocks []detailsBlock
+	var stack []int // indexes of unclosed blocks
+	for _, t := range tags {
+		if t.open {
+			stack = append(stack, len(blocks))
+			blocks = append(blocks, detailsBlock{
+				tagStart: t.start,
+				tagEnd:   t.end,
+				end:      len(markdown),
+				open:     openAttrRe.MatchString(t.attrs),
+			})
+			continue
+		}
+		if len(stack) > 0 {
+			blocks[stack[len(stack)-1]].end = t.start
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	// the summary is the first one before the end of the block or a nested
+	// block
+	for i := range blocks {
+		b := &blocks[i]
+		b.bodyStart = b.tagEnd
+		limit := b.end
+		if i+1 < len(blocks) && blocks[i+1].tagStart < limit {
+			limit = blocks[i+1].tagStart
+		}
+		if loc := summaryCloseTagRe.FindStringIndex(markdown[b.tagEnd:limit]); loc != nil {
+			b.bodyStart = b.tagEnd + loc[1]
+			b.hasSummary = true
+		}
+	}
+	return blocks
+}
+
+// inCodeSpan reports whether the byte at i of line is inside a code span,
+// that is after an odd number of backticks.
+func inCodeSpan(line string, i int) bool {
+	return strings.Count(line[:i], "`")%2 == 1
+}
+
+// preprocessDetails numbers the <details> blocks of markdown and opens or
+// closes them: open[i], if set, overrides the open attribute of block i.
+// Closed blocks keep only their summary; a block without a summary gets one.
+func preprocessDetails(markdown string, open map[int]bool) string {
+	blocks := findDetailsBlocks(markdown)
+	if len(blocks) == 0 {
+		return markdown
+	}
+
+	var b strings.Builder
+	pos := 0
+	for i, block := range blocks {
+		if block.tagStart < pos {
+			continue // inside a closed block
+		}
+		isOpen, ok := open[i]
+		if !ok {
+			isOpen = block.open
+		}
+
+		b.WriteString(markdown[pos:block.tagStart])
+		b.WriteString(`<details data-details="` + strconv.Itoa(i) + `"`)
+		if isOpen {
+			b.WriteString(" open")
+		}
+		b.WriteString(">")
+		if !block.hasSummary {
+			b.WriteString("<summary>Details</summary>")
+		}
+		pos = block.tagEnd
+		if !isOpen {
+			b.WriteString(markdown[pos:block.bodyStart])
+			pos = block.end
+		}
+	}
+	b.WriteString(markdown[pos:])
+	return b.String()
+}
+
+// detailsID returns the block number of a NavElementDetails URL.
+func detailsID(url string) (int, bool) {
+	s, ok := strings.CutPrefix(url, detailsPrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(s)
+	return id, err == nil
+}
+
+// ToggleSelectedDetails opens the <details> block whose summary is selected,
+// or closes it if it is open. The document is re-rendered with the scroll
+// position and the selection kept. It returns false if no summary is
+// selected.
+func (v *MarkdownSession) ToggleSelectedDetails() bool {
+	sel := v.Selected()
+	if sel == nil || sel.Type != NavElementDetails {
+		return false
+	}
+	if key, ok := strings.CutPrefix(sel.URL, detailsPrefix+diagramDetailsPrefix); ok {
+		return v.toggleDiagramSource(key, *sel)
+	}
+	id, ok := detailsID(sel.URL)
+	blocks := findDetailsBlocks(v.markdown)
+	if !ok || id < 0 || id >= len(blocks) {
+		return false
+	}
+
+	isOpen, set := v.detailsOpen[id]
+	if !set {
+		isOpen = blocks[id].open
+	}
+	// a new map, since saved history states may share the old one
+	previous := v.detailsOpen
+	v.detailsOpen = maps.Clone(previous)
+	if v.detailsOpen == nil {
+		v.detailsOpen = map[int]bool{}
+	}
+	v.detailsOpen[id] = !isOpen
+
+	if err := v.refreshDocument(*sel); err != nil {
+		v.detailsOpen = previous
+		return false
+	}
+	return true
+}
+
+// toggleDiagramSource shows or hides the source of the failed diagrams
+// with key (DiagramFailureError).
+func (v *MarkdownSession) toggleDiagramSource(key string, selected NavElement) bool {
+	previous := v.diagramSourceOpen
+	v.diagramSourceOpen = maps.Clone(previous)
+	if v.diagramSourceOpen == nil {
+		v.diagramSourceOpen = map[string]bool{}
+	}
+	v.diagramSourceOpen[key] = !previous[key]
+	if err := v.refreshDocument(selected); err != nil {
+		v.diagramSourceOpen = previous
+		return false
+	}
+	return true
+}
+
+// refreshDocument parses and renders the current document again, after a
+// change to what it shows, and selects selected again if it is still there.
+func (v *MarkdownSession) refreshDocument(selected NavElement) error {
+	processed, failures := v.preprocessForRender(v.markdown, v.detailsOpen)
+	elements := v.parseMarkdownWithSource([]byte(processed), v.currentSourceFile)
+	rendered, err := v.rendererFor(v.currentWidth, v.currentSourceFile).Render(processed)
+	if err != nil {
+		return err
+	}
+
+	v.diagramErrors = failures
+	v.elements = elements
+	v.renderedLines = rendered.Lines
+	v.setCleaner(rendered.Cleaner)
+	v.renderedSourceMap = rendered.SourceMap
+	v.applyLineHighlight()
+	v.postProcessImages()
+	v.correlatePositions()
+	v.reorderBidi()
+	v.measureWideBlocks()
+
+	v.selectedIndex = -1
+	for i := range v.elements {
+		if v.elementsMatch(&v.elements[i], &selected) {
+			v.selectedIndex = i
+			break
+		}
+	}
+	if v.scrollOffset >= len(v.renderedLines) {
+		v.scrollOffset = max(len(v.renderedLines)-1, 0)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package navidown
+
+import (
+	"strings"
+	"testing"
+)
+
+const detailsDoc = `# Doc
+
+<details>
+<summary>More</summary>
+
+Hidden [link](https://hidden.example).
+
+</details>
+
+[after](https://after.example)
+`
+
+func TestPreprocessDetails(t *testing.T) {
+	closed := preprocessDetails(detailsDoc, nil)
+	if strings.Contains(closed, "Hidden") {
+		t.Errorf("closed block kept its body:\n%s", closed)
+	}
+	if !strings.Contains(closed, `<details data-details="0">`) || !strings.Contains(closed, "<summary>More</summary></details>") {
+		t.Errorf("unexpected closed block:\n%s", closed)
+	}
+
+	open := preprocessDetails(detailsDoc, map[int]bool{0: true})
+	if !strings.Contains(open, `<details data-details="0" open>`) || !strings.Contains(open, "Hidden") {
+		t.Errorf("unexpected open block:\n%s", open)
+	}
+}
+
+func TestPreprocessDetails_NestedAndCode(t *testing.T) {
+	md := "<details open><summary>Outer</summary>\n<details><summary>Inner</summary>inner body</details>\nouter body\n</details>\n\n" +
+		"```html\n<details>\n```\n\nInline `<details>` tag.\n"
+	out := preprocessDetails(md, nil)
+	if strings.Contains(out, "inner body") || !strings.Contains(out, "outer body") {
+		t.Errorf("expected the inner block closed and the outer one open:\n%s", out)
+	}
+	if !strings.Contains(out, `<details data-details="1">`) {
+		t.Errorf("inner block not numbered:\n%s", out)
+	}
+	if !strings.Contains(out, "```html\n<details>\n```") || !strings.Contains(out, "`<details>`") {
+		t.Errorf("tags in code rewritten:\n%s", out)
+	}
+
+	// closing the outer block drops the inner one
+	out = preprocessDetails(md, map[int]bool{0: false})
+	if strings.Contains(out, "Inner") || strings.Contains(out, "outer body") {
+		t.Errorf("expected the outer block closed:\n%s", out)
+	}
+}
+
+func TestPreprocessDetails_MissingSummary(t *testing.T) {
+	out := preprocessDetails("<details>\nbody\n</details>\n", nil)
+	if !strings.Contains(out, "<summary>Details</summary>") || strings.Contains(out, "body") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+// selectDetails selects the first details summary of the session.
+func selectDetails(t *testing.T, v *MarkdownSession) {
+	t.Helper()
+	for v.MoveToNextLink(100) {
+		if v.Selected().Type == NavElementDetails {
+			return
+		}
+	}
+	t.Fatal("no details summary to select")
+}
+
+func hasElementURL(v *MarkdownSession, url string) bool {
+	for _, e := range v.Elements() {
+		if e.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+func TestToggleSelectedDetails(t *testing.T) {
+	v := New(Options{Renderer: NewPlainTextRenderer()})
+	_ = v.SetMarkdownWithSource(detailsDoc, "/tmp/details.md", false)
+	if hasElementURL(v, "https://hidden.example") {
+		t.Fatal("link inside a closed block is navigable")
+	}
+
+	selectDetails(t, v)
+	summary := *v.Selected()
+	if summary.Text != "More" || summary.URL != "details:0" || summary.EndCol <= summary.StartCol {
+		t.Fatalf("unexpected summary element: %#v", summary)
+	}
+
+	if !v.ToggleSelectedDetails() {
+		t.Fatal("toggle failed")
+	}
+	if !hasElementURL(v, "https://hidden.example") {
+		t.Fatal("link inside an opened block is not navigable")
+	}
+	if sel := v.Selected(); sel == nil || sel.URL != "details:0" {
+		t.Fatalf("summary no longer selected: %#v", sel)
+	}
+
+	// the state survives re-rendering and reloading the same file
+	v.SetWidth(40)
+	if !strings.Contains(strings.Join(v.RenderedLines(), "\n"), "Hidden") {
+		t.Error("block closed by a re-render")
+	}
+	_ = v.SetMarkdownWithSource(detailsDoc, "/tmp/details.md", false)
+	if !hasElementURL(v, "https://hidden.example") {
+		t.Error("block closed by a reload")
+	}
+
+	selectDetails(t, v)
+	v.ToggleSelectedDetails()
+	if hasElementURL(v, "https://hidden.example") {
+		t.Error("block not closed by a second toggle")
+	}
+}
+
+func TestToggleSelectedDetails_History(t *testing.T) {
+	v := New(Options{Renderer: NewPlainTextRenderer()})
+	_ = v.SetMarkdownWithSource(detailsDoc, "/tmp/details.md", false)
+	selectDetails(t, v)
+	v.ToggleSelectedDetails()
+
+	_ = v.SetMarkdownWithSource(detailsDoc, "/tmp/other.md", true)
+	if hasElementURL(v, "https://hidden.example") {
+		t.Fatal("another document starts with the block open")
+	}
+	if !v.GoBack() {
+		t.Fatal("GoBack failed")
+	}
+	if !hasElementURL(v, "https://hidden.example") {
+		t.Error("block closed after going back")
+	}
+	v.SetWidth(50)
+	if !strings.Contains(strings.Join(v.RenderedLines(), "\n"), "Hidden") {
+		t.Error("block closed by a re-render after going back")
+	}
+}
+
+func TestToggleSelectedDetails_NotDetails(t *testing.T) {
+	v := New(Options{Renderer: NewPlainTextRenderer()})
+	_ = v.SetMarkdown("[link](https://example.com)\n")
+	v.MoveToNextLink(10)
+	if v.ToggleSelectedDetails() {
+		t.Error("toggled a link")
+	}
+}
@@ -1,9 +1,19 @@
 package navidown
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+
+	"github.com/boolean-maybe/navidown/util"
 )
 
 // DiagramRenderer renders diagram source code to an image file and returns its path.
@@ -11,8 +21,434 @@ type DiagramRenderer interface {
 	RenderToFile(source string) (string, error)
 }
 
+// DiagramToolError is returned by renderers whose diagram tool fails. It
+// keeps what the tool printed apart, for display (DiagramFailureError).
+type DiagramToolError struct {
+	Tool   string // the command, such as "dot"
+	Err    error  // how it failed, such as its exit status
+	Output string // what it printed
+}
+
+func (e *DiagramToolError) Error() string {
+	return fmt.Sprintf("%s failed: %v\n%s", e.Tool, e.Err, e.Output)
+}
+
+func (e *DiagramToolError) Unwrap() error { return e.Err }
+
+// DiagramFailureMode tells what a diagram block that fails to render shows.
+type DiagramFailureMode int
+
+const (
+	// DiagramFailureCode keeps the block as code, as if no renderer were
+	// registered for it.
+	DiagramFailureCode DiagramFailureMode = iota
+	// DiagramFailureError shows an error box with the tool's output in its
+	// place, followed by the source in a collapsed <details> block.
+	DiagramFailureError
+)
+
+// DiagramError describes a diagram block that failed to render.
+type DiagramError struct {
+	Renderer string // the name the renderer is registered under
+	Language string // the fence language
+	Line     int    // 1-based line of the opening fence in the document
+	Source   string
+	Output   string // the tool's output, trimmed; "" if there is none
+	Err      error
+}
+
+func (e DiagramError) Error() string {
+	return fmt.Sprintf("line %d: %s diagram: %v", e.Line, e.Language, e.Err)
+}
+
+func (e DiagramError) Unwrap() error { return e.Err }
+
+// DiagramCacher is implemented by diagram renderers that cache their images
+// as <key>.png files in a work directory, key being a 64-digit hex hash, so
+// the session can clear them (ClearCaches, ClearCachesForDocument).
+type DiagramCacher interface {
+	ClearCache()
+	WorkDir() string
+	EvictKeys(keys []string)
+}
+
+// DiagramRegistry maps fence languages to the renderers that turn fenced
+// code blocks tagged with them into images. Blocks are rendered before the
+// markdown is parsed and replaced by images; a block that fails to render
+// stays a code block, and its failure is remembered so the tool is not run
+// for it again until the caches are cleared. Renderers implementing
+// DiagramCacher have their caches cleared with the session's, and those
+// with a Close method are closed with it. Safe for concurrent use.
+type DiagramRegistry struct {
+	mu      sync.RWMutex
+	entries []diagramEntry   // in registration order
+	failed  map[string]error // failures of blocks, by diagramKey
+}
+
+type diagramEntry struct {
+	name     string
+	langs    []string
+	renderer DiagramRenderer
+}
+
+// NewDiagramRegistry creates an empty registry.
+func NewDiagramRegistry() *DiagramRegistry {
+	return &DiagramRegistry{}
+}
+
+// Register renders blocks fenced with any of langs (```lang) with renderer,
+// replacing and closing the renderer registered before under name. Images
+// are given the first language followed by " diagram" as alt text. A nil
+// renderer, such as a constructor returns when its tool is not installed,
+// unregisters name. Languages registered again under another name move to
+// the later renderer.
+func (r *DiagramRegistry) Register(name string, renderer DiagramRenderer, langs ...string) {
+	if isNilRenderer(renderer) || len(langs) == 0 {
+		r.Unregister(name)
+		return
+	}
+	r.mu.Lock()
+	var old DiagramRenderer
+	entry := diagramEntry{name: name, langs: langs, renderer: renderer}
+	if i := r.index(name); i >= 0 {
+		old = r.entries[i].renderer
+		r.entries[i] = entry
+	} else {
+		r.entries = append(r.entries, entry)
+	}
+	r.failed = nil
+	r.mu.Unlock()
+	closeRenderer(old)
+}
+
+// Unregister removes and closes the renderer registered under name.
+func (r *DiagramRegistry) Unregister(name string) {
+	r.mu.Lock()
+	var old DiagramRenderer
+	if i := r.index(name); i >= 0 {
+		old = r.entries[i].renderer
+		r.entries = append(r.entries[:i], r.entries[i+1:]...)
+	}
+	r.failed = nil
+	r.mu.Unlock()
+	closeRenderer(old)
+}
+
+// index returns the position of the entry registered under name, or -1.
+// The caller holds r.mu.
+func (r *DiagramRegistry) index(name string) int {
+	for i, e := range r.entries {
+		if e.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Renderer returns the renderer registered under name, or nil.
+func (r *DiagramRegistry) Renderer(name string) DiagramRenderer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if i := r.index(name); i >= 0 {
+		return r.entries[i].renderer
+	}
+	return nil
+}
+
+// Languages returns the fence languages that have a renderer.
+func (r *DiagramRegistry) Languages() []string {
+	var langs []string
+	for lang := range r.byLanguage() {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// byLanguage returns the entry rendering each fence language.
+func (r *DiagramRegistry) byLanguage() map[string]diagramEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m := make(map[string]diagramEntry)
+	for _, e := range r.entries {
+		for _, lang := range e.langs {
+			m[lang] = e
+		}
+	}
+	return m
+}
+
+// renderers returns the registered renderers in registration order.
+func (r *DiagramRegistry) renderers() []DiagramRenderer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]DiagramRenderer, len(r.entries))
+	for i, e := range r.entries {
+		out[i] = e.renderer
+	}
+	return out
+}
+
+// Preprocess replaces the fenced blocks of registered languages in markdown
+// with images rendered by their renderers. Blocks are rendered in parallel;
+// those that fail stay code blocks, also in later calls until ClearCaches.
+func (r *DiagramRegistry) Preprocess(markdown string) string {
+	result, _ := r.preprocess(markdown, DiagramFailureCode, nil)
+	return result
+}
+
+// preprocess is Preprocess showing failed blocks as mode says, and returns
+// the failures. sourceOpen holds the diagramKey of the failed blocks whose
+// source is shown.
+func (r *DiagramRegistry) preprocess(markdown string, mode DiagramFailureMode, sourceOpen map[string]bool) (string, []DiagramError) {
+	if r == nil {
+		return markdown, nil
+	}
+	byLang := r.byLanguage()
+	if len(byLang) == 0 {
+		return markdown, nil
+	}
+	langs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		langs = append(langs, lang)
+	}
+
+	lines, blocks := extractDiagramBlocks(markdown, diagramFenceRe(langs...))
+	if len(blocks) == 0 {
+		return markdown, nil
+	}
+	known := r.knownFailures(blocks)
+	rendered, errs := renderDiagramBlocks(blocks, func(i int) DiagramRenderer {
+		if err, ok := known[i]; ok {
+			return failedDiagram{err}
+		}
+		return byLang[blocks[i].lang].renderer
+	})
+	r.recordFailures(blocks, errs)
+
+	var failures []DiagramError
+	result := reassembleDiagram(lines, blocks, func(i int) (string, bool) {
+		block := blocks[i]
+		alt := diagramAltText(byLang[block.lang].langs)
+		if path, ok := rendered[i]; ok {
+			return "![" + alt + "](" + path + ")", true
+		}
+		failure := DiagramError{
+			Renderer: byLang[block.lang].name,
+			Language: block.lang,
+			Line:     block.openLine + 1,
+			Source:   block.source,
+			Err:      errs[i],
+		}
+		var toolErr *DiagramToolError
+		if errors.As(failure.Err, &toolErr) {
+			failure.Output = trimToolOutput(toolErr.Output)
+		}
+		failures = append(failures, failure)
+		if mode != DiagramFailureError {
+			return "", false
+		}
+		key := diagramKey(block.lang, block.source)
+		return diagramErrorMarkdown(failure, alt, lines[block.openLine:block.closeLine], key, sourceOpen[key]), true
+	})
+	return result, failures
+}
+
+// knownFailures returns the failures remembered for blocks, by index.
+func (r *DiagramRegistry) knownFailures(blocks []diagramBlock) map[int]error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	known := make(map[int]error)
+	for i, block := range blocks {
+		if err, ok := r.failed[diagramKey(block.lang, block.source)]; ok {
+			known[i] = err
+		}
+	}
+	return known
+}
+
+// recordFailures remembers the failures of blocks, by block index.
+func (r *DiagramRegistry) recordFailures(blocks []diagramBlock, errs map[int]error) {
+	if len(errs) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed == nil {
+		r.failed = make(map[string]error)
+	}
+	for i, err := range errs {
+		r.failed[diagramKey(blocks[i].lang, blocks[i].source)] = err
+	}
+}
+
+// forgetFailures drops the remembered failures of failures, so their
+// blocks are rendered again.
+func (r *DiagramRegistry) forgetFailures(failures []DiagramError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range failures {
+		delete(r.failed, diagramKey(f.Language, f.Source))
+	}
+}
+
+// failedDiagram stands in for the renderer of a block that failed before,
+// failing the same way without running the tool again.
+type failedDiagram struct{ err error }
+
+func (f failedDiagram) RenderToFile(string) (string, error) { return "", f.err }
+
+// ClearCaches flushes the caches of all renderers that keep one, and the
+// remembered failures.
+func (r *DiagramRegistry) ClearCaches() {
+	r.mu.Lock()
+	r.failed = nil
+	r.mu.Unlock()
+	for _, renderer := range r.renderers() {
+		if c, ok := renderer.(DiagramCacher); ok {
+			c.ClearCache()
+		}
+	}
+}
+
+// EvictImages evicts the cache entries of the diagram images among elements.
+func (r *DiagramRegistry) EvictImages(elements []NavElement) {
+	for _, renderer := range r.renderers() {
+		if c, ok := renderer.(DiagramCacher); ok {
+			c.EvictKeys(diagramKeysForRenderer(elements, c.WorkDir()))
+		}
+	}
+}
+
+// Close unregisters and closes all renderers.
+func (r *DiagramRegistry) Close() {
+	r.mu.Lock()
+	entries := r.entries
+	r.entries = nil
+	r.mu.Unlock()
+	for _, e := range entries {
+		closeRenderer(e.renderer)
+	}
+}
+
+// closeRenderer calls the Close method of renderer, if it has one.
+func closeRenderer(renderer DiagramRenderer) {
+	switch c := renderer.(type) {
+	case interface{ Close() }:
+		c.Close()
+	case interface{ Close() error }:
+		_ = c.Close()
+	}
+}
+
+// isNilRenderer reports whether renderer is nil or a nil pointer, as the
+// constructors of the built-in renderers return when their tool is missing.
+func isNilRenderer(renderer DiagramRenderer) bool {
+	if renderer == nil {
+		return true
+	}
+	v := reflect.ValueOf(renderer)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// diagramAltText returns the alt text of images rendered for langs.
+func diagramAltText(langs []string) string {
+	return langs[0] + " diagram"
+}
+
+// diagramFenceRe returns a pattern matching the opening fence of a block
+// tagged with one of langs, capturing the fence and the language.
+func diagramFenceRe(langs ...string) *regexp.Regexp {
+	quoted := make([]string, len(langs))
+	for i, lang := range langs {
+		quoted[i] = regexp.QuoteMeta(lang)
+	}
+	// longest first, so no language matches as a prefix of another
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("^(\\s*`{3,})(" + strings.Join(quoted, "|") + ")\\s*$")
+}
+
+// diagramCache is the image cache the built-in renderers share: images are
+// named by the hex hash of what they are rendered from, found in memory or
+// else on disk in workDir.
+type diagramCache struct {
+	cache         sync.Map // cache key hex -> absolute PNG path
+	persistentDir string   // persistent cache (never deleted by Close)
+	tempDir       string   // temp fallback (deleted by Close); "" if persistent worked
+	workDir       string   // whichever dir is actually used
+}
+
+// lookup returns the image cached for key, checking memory and then
+// outputPath, where it is rendered to, on disk. The file is checked even
+// for a memory hit, as the cache may have been pruned since.
+func (c *diagramCache) lookup(key, outputPath string) (string, bool) {
+	path := outputPath
+	if cached, ok := c.cache.Load(key); ok {
+		if p, ok := cached.(string); ok {
+			path = p
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		c.cache.Delete(key)
+		SharedCacheManager().miss()
+		return "", false
+	}
+	c.cache.Store(key, path)
+	SharedCacheManager().hit(path, info)
+	return path, true
+}
+
+// store records that the image for key was rendered to path.
+func (c *diagramCache) store(key, path string) {
+	c.cache.Store(key, path)
+	SharedCacheManager().added()
+}
+
+// ClearCache flushes the in-memory cache and removes disk-cached PNGs.
+// Other files in the work directory, such as configs, are preserved.
+func (c *diagramCache) ClearCache() {
+	c.cache.Range(func(key, _ any) bool { c.cache.Delete(key); return true })
+	entries, _ := os.ReadDir(c.workDir)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".png") {
+			_ = os.Remove(filepath.Join(c.workDir, e.Name()))
+		}
+	}
+}
+
+// WorkDir returns the cache working directory.
+func (c *diagramCache) WorkDir() string { return c.workDir }
+
+// EvictKeys removes specific cache entries by key (hex hash, no extension).
+func (c *diagramCache) EvictKeys(keys []string) {
+	for _, key := range keys {
+		c.cache.Delete(key)
+		_ = os.Remove(filepath.Join(c.workDir, key+".png"))
+	}
+}
+
+// Close releases resources. Only removes the temp dir (if used as fallback).
+// Persistent cache directories are preserved for future sessions.
+func (c *diagramCache) Close() {
+	if c.tempDir != "" {
+		_ = os.RemoveAll(c.tempDir)
+	}
+}
+
+// rasterizeDiagramSVG rasterizes a rendered diagram at scale times its
+// natural width, or at the rasterizer's default if the SVG has none.
+func rasterizeDiagramSVG(rasterizer SVGRasterizer, svg []byte, scale int) ([]byte, error) {
+	width := 0
+	if w, _, ok := parseSVGDimensions(svg); ok {
+		width = int(w) * scale
+	}
+	return rasterizer.Rasterize(svg, width)
+}
+
 // diagramBlock represents a parsed fenced code block from markdown.
 type diagramBlock struct {
+	lang      string // the fence language
 	source    string
 	openLine  int // first line of the opening fence
 	closeLine int // line after closing fence (exclusive)
@@ -33,6 +469,10 @@ func extractDiagramBlocks(markdown string, fenceRe *regexp.Regexp) ([]string, []
 		}
 
 		fencePrefix := match[1]
+		lang := ""
+		if len(match) > 2 {
+			lang = match[2]
+		}
 		backticks := strings.TrimLeft(fencePrefix, " \t")
 		fenceLen := len(backticks)
 		openLine := i
@@ -54,6 +494,7 @@ func extractDiagramBlocks(markdown string, fenceRe *regexp.Regexp) ([]string, []
 
 		if closed {
 			blocks = append(blocks, diagramBlock{
+				lang:      lang,
 				source:    source.String(),
 				openLine:  openLine,
 				closeLine: i,
@@ -64,12 +505,14 @@ func extractDiagramBlocks(markdown string, fenceRe *regexp.Regexp) ([]string, []
 	return lines, blocks
 }
 
-// renderDiagramBlocks renders all blocks with limited concurrency and returns
-// a map of block index → image path. Missing entries indicate render errors.
-// Concurrency is capped to avoid overwhelming mmdc/Puppeteer with too many
-// simultaneous Chrome instances.
-func renderDiagramBlocks(blocks []diagramBlock, renderer DiagramRenderer) map[int]string {
+// renderDiagramBlocks renders all blocks with limited concurrency, each with
+// the renderer rendererFor returns for its index, and returns maps of block index → image
+// path and, for blocks that failed (including by a renderer panicking),
+// block index → error. Concurrency is capped to avoid overwhelming
+// mmdc/Puppeteer with too many simultaneous Chrome instances.
+func renderDiagramBlocks(blocks []diagramBlock, rendererFor func(i int) DiagramRenderer) (map[int]string, map[int]error) {
 	results := make(map[int]string)
+	errs := make(map[int]error)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
@@ -77,28 +520,43 @@ func renderDiagramBlocks(blocks []diagramBlock, renderer DiagramRenderer) map[in
 
 	for idx, block := range blocks {
 		wg.Add(1)
-		go func(idx int, source string) {
+		go func(idx int, block diagramBlock) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
-			path, err := renderer.RenderToFile(source)
+			path, err := renderDiagram(rendererFor(idx), block.source)
+			mu.Lock()
+			defer mu.Unlock()
 			if err != nil {
+				errs[idx] = err
 				return
 			}
-			mu.Lock()
 			results[idx] = path
-			mu.Unlock()
-		}(idx, block.source)
+		}(idx, block)
 	}
 
 	wg.Wait()
-	return results
+	return results, errs
 }
 
-// reassembleDiagram rebuilds markdown from lines, substituting rendered blocks
-// with image syntax using the given altText. Blocks missing from the rendered
-// map are preserved as original fenced code.
-func reassembleDiagram(lines []string, blocks []diagramBlock, rendered map[int]string, altText string) string {
+// renderDiagram renders source, turning a panic of the renderer into an
+// error so one faulty renderer cannot take the viewer down.
+func renderDiagram(renderer DiagramRenderer, source string) (path string, err error) {
+	if renderer == nil {
+		return "", fmt.Errorf("no diagram renderer")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("diagram renderer panicked: %v", p)
+		}
+	}()
+	return renderer.RenderToFile(source)
+}
+
+// reassembleDiagram rebuilds markdown from lines, substituting blocks with
+// what replace returns for their index. Blocks it returns false for are
+// preserved as original fenced code.
+func reassembleDiagram(lines []string, blocks []diagramBlock, replace func(i int) (string, bool)) string {
 	var result strings.Builder
 	result.Grow(len(lines) * 40)
 
@@ -107,8 +565,8 @@ func reassembleDiagram(lines []string, blocks []diagramBlock, rendered map[int]s
 	for i < len(lines) {
 		if blockIdx < len(blocks) && i == blocks[blockIdx].openLine {
 			block := blocks[blockIdx]
-			if pngPath, ok := rendered[blockIdx]; ok {
-				result.WriteString("![" + altText + "](" + pngPath + ")")
+			if replacement, ok := replace(blockIdx); ok {
+				result.WriteString(replacement)
 				if block.closeLine < len(lines) {
 					result.WriteByte('\n')
 				}
@@ -135,6 +593,65 @@ func reassembleDiagram(lines []string, blocks []diagramBlock, rendered map[int]s
 	return result.String()
 }
 
+// diagramDetailsPrefix starts the data-details id of the <details> block
+// holding the source of a failed diagram, which is toggled in
+// MarkdownSession.diagramSourceOpen rather than with the document's own
+// <details> blocks.
+const diagramDetailsPrefix = "diagram-"
+
+// diagramKey identifies a failed block for showing its source: blocks with
+// the same language and source share one.
+func diagramKey(lang, source string) string {
+	h := sha256.Sum256([]byte(lang + "\x00" + source))
+	return hex.EncodeToString(h[:8])
+}
+
+// diagramErrorLines caps the lines of tool output shown for a failure.
+const diagramErrorLines = 12
+
+// trimToolOutput trims what a tool printed for display: escape sequences,
+// surrounding blank lines and lines beyond diagramErrorLines go.
+func trimToolOutput(output string) string {
+	lines := strings.Split(strings.TrimSpace(stripANSIAndMarkers(output)), "\n")
+	if len(lines) > diagramErrorLines {
+		lines = append(lines[:diagramErrorLines], "…")
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diagramErrorMarkdown returns what a failed block shows in
+// DiagramFailureError mode: a quote box with the tool's output (or else the
+// error), then a <details> block with the original fenced lines, shown if
+// open. The <details> block has the shape preprocessDetails gives them.
+func diagramErrorMarkdown(failure DiagramError, alt string, fenced []string, key string, open bool) string {
+	message := failure.Output
+	if message == "" && failure.Err != nil {
+		message = trimToolOutput(failure.Err.Error())
+	}
+	fence := strings.Repeat("`", max(3, util.LongestRun(message, '`')+1))
+
+	var b strings.Builder
+	b.WriteString("> **⚠ " + alt + " failed**\n>\n> " + fence + "\n")
+	for _, line := range strings.Split(message, "\n") {
+		b.WriteString("> " + line + "\n")
+	}
+	b.WriteString("> " + fence + "\n\n")
+
+	b.WriteString(`<details data-details="` + diagramDetailsPrefix + key + `"`)
+	if open {
+		b.WriteString(" open")
+	}
+	b.WriteString("><summary>" + failure.Language + " source</summary>")
+	if open {
+		b.WriteString("\n\n" + strings.Join(fenced, "\n") + "\n\n")
+	}
+	b.WriteString("</details>")
+	return b.String()
+}
+
 // countLeadingBackticks returns the number of leading backtick characters in s.
 func countLeadingBackticks(s string) int {
 	count := 0
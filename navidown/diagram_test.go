@@ -0,0 +1,210 @@
+package navidown
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// stubDiagramRenderer renders every block to a fixed path, or fails or
+// panics, and counts renders and Close and ClearCache calls.
+type stubDiagramRenderer struct {
+	path    string
+	err     error
+	panics  bool
+	runs    atomic.Int32
+	closed  int
+	cleared int
+}
+
+func (s *stubDiagramRenderer) RenderToFile(string) (string, error) {
+	s.runs.Add(1)
+	if s.panics {
+		panic("boom")
+	}
+	return s.path, s.err
+}
+
+func (s *stubDiagramRenderer) Close() { s.closed++ }
+
+func (s *stubDiagramRenderer) ClearCache()        { s.cleared++ }
+func (s *stubDiagramRenderer) WorkDir() string    { return filepath.Dir(s.path) }
+func (s *stubDiagramRenderer) EvictKeys([]string) {}
+
+// preprocessDiagram renders the blocks of langs in markdown with renderer,
+// through a registry of its own. A nil renderer leaves markdown as it is.
+func preprocessDiagram(markdown, name string, renderer DiagramRenderer, langs ...string) string {
+	diagrams := NewDiagramRegistry()
+	diagrams.Register(name, renderer, langs...)
+	result, _ := diagrams.preprocess(markdown, DiagramFailureCode, nil)
+	return result
+}
+
+func TestDiagramRegistry_Preprocess(t *testing.T) {
+	reg := NewDiagramRegistry()
+	reg.Register("pikchr", &stubDiagramRenderer{path: "/tmp/p.png"}, "pikchr", "pic")
+	reg.Register("broken", &stubDiagramRenderer{err: errors.New("exit status 1")}, "broken")
+	reg.Register("panicky", &stubDiagramRenderer{panics: true}, "panicky")
+
+	md := "```pic\nbox\n```\n\n```broken\nx\n```\n\n```panicky\ny\n```\n\n```go\nz\n```\n"
+	got := reg.Preprocess(md)
+	if !strings.Contains(got, "![pikchr diagram](/tmp/p.png)") {
+		t.Errorf("block not replaced:\n%s", got)
+	}
+	for _, kept := range []string{"```broken\nx\n```", "```panicky\ny\n```", "```go\nz\n```"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("block %q not kept:\n%s", kept, got)
+		}
+	}
+}
+
+func TestDiagramRegistry_Register(t *testing.T) {
+	reg := NewDiagramRegistry()
+	first := &stubDiagramRenderer{path: "/tmp/a.png"}
+	second := &stubDiagramRenderer{path: "/tmp/b.png"}
+	reg.Register("x", first, "x")
+	reg.Register("x", second, "x")
+	if first.closed != 1 || reg.Renderer("x") != second {
+		t.Errorf("replaced renderer: closed %d times, registered %v", first.closed, reg.Renderer("x"))
+	}
+
+	// a renderer whose tool is missing unregisters the name
+	reg.Register("x", (*GraphvizRenderer)(nil), "x")
+	if second.closed != 1 || reg.Renderer("x") != nil || len(reg.Languages()) != 0 {
+		t.Errorf("nil renderer still registered: %v", reg.Languages())
+	}
+}
+
+func TestSession_CustomDiagramRenderer(t *testing.T) {
+	stub := &stubDiagramRenderer{path: "/tmp/c.png"}
+	session := New(Options{})
+	session.Diagrams().Register("chart", stub, "chart")
+	if err := session.SetMarkdown("```chart\nbars\n```\n"); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, e := range session.Elements() {
+		found = found || (e.Type == NavElementImage && e.URL == "/tmp/c.png")
+	}
+	if !found {
+		t.Errorf("no image element for the chart block: %+v", session.Elements())
+	}
+
+	session.ClearCaches()
+	session.Close()
+	if stub.cleared != 1 || stub.closed != 1 {
+		t.Errorf("cleared %d, closed %d times", stub.cleared, stub.closed)
+	}
+}
+
+func TestSession_DiagramFailureError(t *testing.T) {
+	stub := &stubDiagramRenderer{err: &DiagramToolError{
+		Tool:   "chart",
+		Err:    errors.New("exit status 1"),
+		Output: "\x1b[31mError: bad syntax\x1b[0m\n\n",
+	}}
+	var reported []DiagramError
+	session := New(Options{
+		DiagramFailureMode:  DiagramFailureError,
+		DiagramErrorHandler: func(e DiagramError) { reported = append(reported, e) },
+	})
+	session.Diagrams().Register("chart", stub, "chart")
+
+	// the closed <details> block before the diagram loses lines when rendered
+	md := "<details>\n<summary>More</summary>\n\nhidden\n\n</details>\n\n```chart\nbars\n```\n"
+	if err := session.SetMarkdown(md); err != nil {
+		t.Fatal(err)
+	}
+	errs := session.DiagramErrors()
+	if len(errs) != 1 || errs[0].Line != 8 || errs[0].Output != "Error: bad syntax" || errs[0].Renderer != "chart" {
+		t.Fatalf("unexpected diagnostics: %+v", errs)
+	}
+	if len(reported) != 1 {
+		t.Errorf("handler called %d times", len(reported))
+	}
+
+	text := stripANSIAndMarkers(strings.Join(session.RenderedLines(), "\n"))
+	for _, want := range []string{"⚠ chart diagram failed", "Error: bad syntax", "chart source"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "bars") {
+		t.Errorf("source shown before it is toggled:\n%s", text)
+	}
+
+	// the source is behind the <details> toggle
+	for session.Selected() == nil || session.Selected().Text != "chart source" {
+		if !session.MoveToNextLink(100) {
+			t.Fatal("no toggle for the source")
+		}
+	}
+	if !session.ToggleSelectedDetails() {
+		t.Fatal("toggle failed")
+	}
+	if text := stripANSIAndMarkers(strings.Join(session.RenderedLines(), "\n")); !strings.Contains(text, "bars") {
+		t.Errorf("source not shown:\n%s", text)
+	}
+	if sel := session.Selected(); sel == nil || sel.Text != "chart source" {
+		t.Errorf("selection lost: %+v", sel)
+	}
+}
+
+func TestDiagramRegistry_FailureCodeMode(t *testing.T) {
+	reg := NewDiagramRegistry()
+	reg.Register("chart", &stubDiagramRenderer{err: errors.New("no")}, "chart")
+	md := "text\n\n```chart\nbars\n```\n"
+	got, failures := reg.preprocess(md, DiagramFailureCode, nil)
+	if got != md || len(failures) != 1 || failures[0].Line != 3 || failures[0].Output != "" {
+		t.Errorf("got %q, %+v", got, failures)
+	}
+}
+
+func TestDiagramRegistry_RemembersFailures(t *testing.T) {
+	stub := &stubDiagramRenderer{err: errors.New("no")}
+	var reported int
+	session := New(Options{DiagramErrorHandler: func(DiagramError) { reported++ }})
+	session.Diagrams().Register("chart", stub, "chart")
+	if err := session.SetMarkdown("```chart\nbars\n```\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-renders report the failure without running the renderer
+	session.SetWidth(40)
+	session.SetWidth(60)
+	if stub.runs.Load() != 1 || reported != 3 || len(session.DiagramErrors()) != 1 {
+		t.Errorf("renderer ran %d times, %d reports, errors %+v", stub.runs.Load(), reported, session.DiagramErrors())
+	}
+
+	session.ClearCachesForDocument()
+	session.SetWidth(50)
+	if stub.runs.Load() != 2 {
+		t.Errorf("renderer ran %d times after ClearCachesForDocument, want 2", stub.runs.Load())
+	}
+	session.ClearCaches()
+	session.SetWidth(40)
+	if stub.runs.Load() != 3 {
+		t.Errorf("renderer ran %d times after ClearCaches, want 3", stub.runs.Load())
+	}
+
+	// a block that renders once its renderer is fixed
+	stub.err = nil
+	stub.path = "/tmp/c.png"
+	session.Diagrams().Register("chart", stub, "chart")
+	session.SetWidth(60)
+	if len(session.DiagramErrors()) != 0 {
+		t.Errorf("failure kept after registering the renderer again: %+v", session.DiagramErrors())
+	}
+}
+
+func TestTrimToolOutput(t *testing.T) {
+	long := strings.Repeat("line\n", diagramErrorLines+5)
+	if got := strings.Count(trimToolOutput(long), "\n"); got != diagramErrorLines {
+		t.Errorf("%d lines kept", got+1)
+	}
+	if got := trimToolOutput("\n  \x1b[1mbad\x1b[0m  \n\n"); got != "bad" {
+		t.Errorf("got %q", got)
+	}
+}
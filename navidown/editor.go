@@ -0,0 +1,138 @@
+package navidown
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// EditPosition returns the local file shown and the 1-based line of it that
+// matches the selected element, or else the top of the view, for opening the
+// document in an editor. ok is false for documents that are not local files,
+// such as web pages and directory listings.
+func (v *MarkdownSession) EditPosition() (path string, line int, ok bool) {
+	path = v.currentSourceFile
+	if path == "" || looksLikeHTTPURL(path) {
+		return "", 0, false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", 0, false
+	}
+	return path, v.editLine(path), true
+}
+
+// editLine returns the line of the file at path shown at the selection or
+// the top of the view, or 1 if it is unknown.
+func (v *MarkdownSession) editLine(path string) int {
+	m := v.sourceMap
+	line := 0
+	if sel := v.Selected(); sel != nil && sel.StartLine >= v.scrollOffset {
+		line = m.lineOf(sel.sourceOffset)
+	}
+	// blank lines between blocks map to nothing, so take the next block
+	for l := v.scrollOffset; line == 0 && l < len(v.renderedLines); l++ {
+		line = m.SourceLineAt(l)
+	}
+	if line == 0 {
+		return 1
+	}
+	line = originalLine(m.source, v.markdown, line)
+
+	// a source file is shown wrapped in a fence, one line down
+	if data, err := os.ReadFile(path); err == nil && string(data) != v.markdown { // #nosec G304 -- the document being shown
+		if code, ok := fencedSourceCode(v.markdown); ok {
+			line = min(max(line-1, 1), len(code))
+		}
+	}
+	return line
+}
+
+// originalLine returns the line of original that line of processed comes
+// from. Preprocessing only replaces some blocks (diagrams, the bodies of
+// closed <details>), so the line is looked up by its text, taking the
+// occurrence nearest to where it is in processed.
+func originalLine(processed, original string, line int) int {
+	if processed == original {
+		return line
+	}
+	processedLines := strings.Split(processed, "\n")
+	originalLines := strings.Split(original, "\n")
+	if line < 1 || line > len(processedLines) {
+		return min(max(line, 1), len(originalLines))
+	}
+	text := processedLines[line-1]
+	best := -1
+	for i, l := range originalLines {
+		if l == text && (best < 0 || abs(i-(line-1)) < abs(best-(line-1))) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return min(line, len(originalLines))
+	}
+	return best + 1
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Reload replaces the document with content, a new version of the same
+// file such as after editing it, keeping the reading position and the
+// selection as a change of width does.
+func (v *MarkdownSession) Reload(content string) error {
+	selected := v.Selected()
+	anchor := v.scrollAnchor()
+	scroll := v.scrollOffset
+	if err := v.SetMarkdownWithSource(content, v.currentSourceFile, false); err != nil {
+		return err
+	}
+	v.scrollOffset = scroll
+	v.restoreScrollAndSelection(anchor, selected)
+	return nil
+}
+
+// EditorCommand returns the command opening path at line in the user's
+// editor: $VISUAL, else $EDITOR, else vi. Editors that take the line as
+// "file:line" (VS Code, Sublime Text, Helix, Zed) get it that way; others
+// get "+line file", which vi, Emacs, nano and most terminal editors accept.
+// Like openers, the variable is split on whitespace without quoting.
+func EditorCommand(path string, line int) (*exec.Cmd, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		return nil, errors.New("empty editor command")
+	}
+	// a path starting with '-' would be parsed as an option
+	if strings.HasPrefix(path, "-") {
+		path = "." + string(filepath.Separator) + path
+	}
+
+	if line > 0 {
+		at := path + ":" + strconv.Itoa(line)
+		switch strings.TrimSuffix(strings.ToLower(filepath.Base(args[0])), ".exe") {
+		case "code", "code-insiders", "codium", "cursor":
+			args = append(args, "--goto", at)
+		case "subl", "hx", "helix", "zed":
+			args = append(args, at)
+		default:
+			args = append(args, "+"+strconv.Itoa(line), path)
+		}
+	} else {
+		args = append(args, path)
+	}
+	return exec.Command(args[0], args[1:]...), nil // #nosec G204 -- editor command is configured by the user
+}
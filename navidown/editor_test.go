@@ -0,0 +1,135 @@
+package navidown
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// editDoc has its "Second" heading on line 9, with a closed <details>
+// block before it, whose body is not rendered.
+const editDoc = `# First
+
+<details>
+<summary>More</summary>
+hidden
+</details>
+
+Text with [a link](https://example.com).
+## Second
+
+Body.
+`
+
+// openFile loads content from a new file named name into a session.
+func openFile(t *testing.T, name, content, markdown string) (*MarkdownSession, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v := New(Options{})
+	if err := v.SetMarkdownWithSource(markdown, path, false); err != nil {
+		t.Fatal(err)
+	}
+	return v, path
+}
+
+func TestEditPosition(t *testing.T) {
+	v, path := openFile(t, "doc.md", editDoc, editDoc)
+	if p, line, ok := v.EditPosition(); !ok || p != path || line != 1 {
+		t.Errorf("at the top: %q %d %v", p, line, ok)
+	}
+
+	v.ScrollToAnchor("second", 3, false)
+	if _, line, _ := v.EditPosition(); line != 9 {
+		t.Errorf("at the second heading: line %d, want 9", line)
+	}
+
+	v.ScrollToAnchor("first", 3, false)
+	v.MoveToNextLink(100)
+	for v.Selected() != nil && v.Selected().Type != NavElementURL {
+		v.MoveToNextLink(100)
+	}
+	if _, line, _ := v.EditPosition(); line != 8 {
+		t.Errorf("with the link selected: line %d, want 8", line)
+	}
+}
+
+func TestEditPosition_SourceFile(t *testing.T) {
+	code := "package main\n\nfunc main() {}\n"
+	v, _ := openFile(t, "main.go", code, "```go\n"+code+"```\n")
+	for i, l := range v.RenderedLines() {
+		if strings.Contains(stripANSIAndMarkers(l), "func main") {
+			v.scrollOffset = i
+		}
+	}
+	if _, line, _ := v.EditPosition(); line != 3 {
+		t.Errorf("line %d, want 3", line)
+	}
+}
+
+func TestEditPosition_NotLocal(t *testing.T) {
+	v := New(Options{})
+	_ = v.SetMarkdownWithSource("# Page\n", "https://example.com/page.md", false)
+	if _, _, ok := v.EditPosition(); ok {
+		t.Error("web page offered for editing")
+	}
+	_ = v.SetMarkdownWithSource("# Dir\n", t.TempDir(), false)
+	if _, _, ok := v.EditPosition(); ok {
+		t.Error("directory offered for editing")
+	}
+}
+
+func TestReload_KeepsPosition(t *testing.T) {
+	long := "# Top\n\n" + strings.Repeat("Filler paragraph.\n\n", 30) + "## Target\n\nBody with [link](https://example.com).\n"
+	v, _ := openFile(t, "long.md", long, long)
+	v.ScrollToAnchor("target", 5, false)
+	v.MoveToNextLink(5)
+	before := v.ScrollOffset()
+
+	if err := v.Reload(strings.Replace(long, "Body with", "Body now with", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if v.ScrollOffset() != before {
+		t.Errorf("scroll offset %d, want %d", v.ScrollOffset(), before)
+	}
+	if sel := v.Selected(); sel == nil || sel.Text != "link" {
+		t.Errorf("selection lost: %#v", sel)
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	for _, tc := range []struct {
+		visual, editor string
+		want           []string
+	}{
+		{"", "", []string{"vi", "+12", "f.md"}},
+		{"", "nvim", []string{"nvim", "+12", "f.md"}},
+		{"code -w", "nvim", []string{"code", "-w", "--goto", "f.md:12"}},
+		{"", "hx", []string{"hx", "f.md:12"}},
+	} {
+		t.Setenv("VISUAL", tc.visual)
+		t.Setenv("EDITOR", tc.editor)
+		cmd, err := EditorCommand("f.md", 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(cmd.Args, tc.want) {
+			t.Errorf("VISUAL=%q EDITOR=%q: got %q, want %q", tc.visual, tc.editor, cmd.Args, tc.want)
+		}
+	}
+}
+
+func TestOriginalLine(t *testing.T) {
+	original := "a\n<details>\nbody\n</details>\nb\n"
+	processed := "a\n<details data-details=\"0\">\n</details>\nb\n"
+	if got := originalLine(processed, original, 4); got != 5 {
+		t.Errorf("line of b = %d, want 5", got)
+	}
+	if got := originalLine(original, original, 3); got != 3 {
+		t.Errorf("unchanged document: %d", got)
+	}
+}
@@ -0,0 +1,226 @@
+package navidown
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/boolean-maybe/navidown/navidown/theme"
+)
+
+// ExportFormat is an output format of an Exporter.
+type ExportFormat string
+
+const (
+	ExportHTML ExportFormat = "html" // standalone HTML pages
+	ExportText ExportFormat = "text" // plain text
+)
+
+// ParseExportFormat parses an export format name: "html", or "text" (also
+// "txt" and "plain").
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "html", "htm":
+		return ExportHTML, nil
+	case "text", "txt", "plain":
+		return ExportText, nil
+	}
+	return "", fmt.Errorf("unknown export format %q (use html or text)", name)
+}
+
+// Ext returns the file extension of exported files.
+func (f ExportFormat) Ext() string {
+	if f == ExportText {
+		return ".txt"
+	}
+	return ".html"
+}
+
+// ExportOptions configures an Exporter.
+type ExportOptions struct {
+	Format ExportFormat // default ExportHTML
+	Theme  *theme.Theme // HTML page colors; nil means the dark style
+	Width  int          // plain-text word wrap; 0 means 80 columns
+
+	// Mermaid and Graphviz, if set, render diagram blocks to images embedded
+	// in HTML pages. Plain text keeps the diagram source.
+	Mermaid  *MermaidOptions
+	Graphviz *GraphvizOptions
+
+	// PathPolicy decides which local images may be embedded in HTML pages;
+	// nil means DefaultPathPolicy.
+	PathPolicy *PathPolicy
+}
+
+func (o ExportOptions) resolvedFormat() ExportFormat {
+	if o.Format == "" {
+		return ExportHTML
+	}
+	return o.Format
+}
+
+func (o ExportOptions) resolvedWidth() int {
+	if o.Width <= 0 {
+		return 80
+	}
+	return o.Width
+}
+
+// exportExtensions are the markdown file extensions converted by ExportTree
+// and rewritten in links between exported files.
+var exportExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".mdown": true, ".mkd": true, ".mkdn": true,
+}
+
+func isMarkdownFile(path string) bool {
+	return exportExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// Exporter converts markdown files to plain text or standalone HTML pages.
+// In HTML, links to other markdown files point at their exported pages, and
+// local images and rendered diagrams are embedded as data URIs so pages
+// stand alone.
+type Exporter struct {
+	opts     ExportOptions
+	diagrams *DiagramRegistry
+}
+
+// NewExporter creates an exporter. Diagram renderers whose tools are not
+// installed are silently disabled. Call Close when done.
+func NewExporter(opts ExportOptions) *Exporter {
+	e := &Exporter{opts: opts, diagrams: NewDiagramRegistry()}
+	if opts.resolvedFormat() == ExportHTML {
+		if opts.Mermaid != nil {
+			e.diagrams.Register("mermaid", NewMermaidRenderer(*opts.Mermaid), mermaidLanguages...)
+		}
+		if opts.Graphviz != nil {
+			e.diagrams.Register("graphviz", NewGraphvizRenderer(*opts.Graphviz), graphvizLanguages...)
+		}
+	}
+	return e
+}
+
+// Diagrams returns the registry of diagram renderers used for HTML pages,
+// where renderers for further fence languages can be registered.
+func (e *Exporter) Diagrams() *DiagramRegistry {
+	return e.diagrams
+}
+
+// Close releases the diagram renderers and writes the cache hit and miss
+// counts to the shared statistics.
+func (e *Exporter) Close() {
+	e.diagrams.Close()
+	_ = SharedCacheManager().Flush()
+}
+
+// Export converts markdown read from sourcePath, which locates relative
+// images; it may be empty.
+func (e *Exporter) Export(markdown, sourcePath string) (string, error) {
+	if e.opts.resolvedFormat() == ExportText {
+		return NewPlainTextRenderer().WithWordWrap(e.opts.resolvedWidth()).PlainText(markdown)
+	}
+
+	processed := e.diagrams.Preprocess(markdown)
+	r := NewHTMLRenderer(HTMLOptions{
+		Theme: e.opts.Theme,
+		RewriteLink: func(dest string) string {
+			return exportLink(dest, ExportHTML.Ext())
+		},
+		RewriteImage: func(src string) string {
+			return embedImage(src, sourcePath, e.opts.PathPolicy)
+		},
+	})
+	return r.Page(processed)
+}
+
+// ExportFile converts the markdown file src and writes the result to dst,
+// creating its directory if needed.
+func (e *Exporter) ExportFile(src, dst string) error {
+	data, err := os.ReadFile(src) // #nosec G304 -- export source is chosen by the user
+	if err != nil {
+		return err
+	}
+	out, err := e.Export(string(data), src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, []byte(out), 0o644) // #nosec G306 -- exported pages are meant to be shared
+}
+
+// ExportTree converts every markdown file under srcDir into the same
+// relative location under dstDir, with the format's extension. Hidden
+// directories are skipped. It returns the paths written.
+func (e *Exporter) ExportTree(srcDir, dstDir string) ([]string, error) {
+	var written []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != srcDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isMarkdownFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, strings.TrimSuffix(rel, filepath.Ext(rel))+e.opts.resolvedFormat().Ext())
+		if err := e.ExportFile(path, dst); err != nil {
+			return err
+		}
+		written = append(written, dst)
+		return nil
+	})
+	return written, err
+}
+
+// exportLink points a relative link to a markdown file at its exported
+// file with extension ext, keeping any query and fragment.
+func exportLink(dest, ext string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || !isMarkdownFile(u.Path) {
+		return dest
+	}
+	u.Path = strings.TrimSuffix(u.Path, filepath.Ext(u.Path)) + ext
+	return u.String()
+}
+
+// embedImage returns a local image (or rendered diagram) as a data URI.
+// Remote images, images the policy refuses, and formats browsers may not
+// show inline are returned unchanged.
+func embedImage(src, sourcePath string, policy *PathPolicy) string {
+	if src == "" || isHTTPURL(src) || strings.HasPrefix(src, "data:") {
+		return src
+	}
+	path, err := ResolveMarkdownPathWithPolicy(src, sourcePath, nil, policy)
+	if err != nil || isHTTPURL(path) {
+		return src
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path was checked by the path policy
+	if err != nil {
+		return src
+	}
+
+	mime := http.DetectContentType(data)
+	if strings.EqualFold(filepath.Ext(path), ".svg") || isSVGData(data) {
+		mime = "image/svg+xml"
+	}
+	switch mime {
+	case "image/png", "image/gif", "image/jpeg", "image/webp", "image/svg+xml":
+		return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return src
+}
@@ -0,0 +1,248 @@
+package navidown
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boolean-maybe/navidown/navidown/theme"
+)
+
+const exportSample = "# Title\n\nSome **strong** text with a [link](other.md#usage).\n\n## Usage\n\n## Usage\n\n```go\nfunc main() {}\n```\n"
+
+func TestPlainTextRenderer(t *testing.T) {
+	out, err := NewPlainTextRenderer().WithWordWrap(60).PlainText(exportSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("plain text contains escape sequences: %q", out)
+	}
+	if strings.ContainsFunc(out, IsMarkerRune) {
+		t.Errorf("plain text contains markers: %q", out)
+	}
+	for _, want := range []string{"# Title", "## Usage", "func main() {}", "link"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plain text missing %q:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasSuffix(line, " ") {
+			t.Errorf("line has trailing spaces: %q", line)
+		}
+	}
+}
+
+func TestPlainTextRenderer_Session(t *testing.T) {
+	s := New(Options{Renderer: NewPlainTextRenderer()})
+	if err := s.SetMarkdown(exportSample); err != nil {
+		t.Fatal(err)
+	}
+	s.SetWidth(40)
+	h := s.FindHeaderBySlug("usage-1")
+	if h == nil {
+		t.Fatal("header usage-1 not found")
+	}
+	if got := StripMarkers(s.RenderedLines()[h.StartLine]); !strings.Contains(got, "Usage") {
+		t.Errorf("header line = %q, want the Usage heading", got)
+	}
+}
+
+func TestHTMLRenderer_Page(t *testing.T) {
+	th, err := theme.Builtin("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := NewHTMLRenderer(HTMLOptions{Theme: th}).Page(exportSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"<title>Title</title>",
+		`<h1 id="title">`,
+		`<h2 id="usage">`,
+		`<h2 id="usage-1">`,
+		`<a href="other.md#usage">`,
+		"<strong>strong</strong>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+	// the theme's heading color appears in the style sheet
+	heading, err := th.Style(theme.Heading)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cssColor(heading.Color); c == "" || !strings.Contains(page, "color: "+c) {
+		t.Errorf("page style sheet lacks heading color %q", c)
+	}
+	// code blocks are highlighted with inline styles
+	if !strings.Contains(page, `<span style="`) || !strings.Contains(page, "main") {
+		t.Error("code block not highlighted")
+	}
+}
+
+func TestHTMLRenderer_SlugsMatchSession(t *testing.T) {
+	md := "# What's New?\n\n## Hello World\n\n## Hello World\n\n### café & co\n"
+	s := New(Options{})
+	if err := s.SetMarkdown(md); err != nil {
+		t.Fatal(err)
+	}
+	page, err := NewHTMLRenderer(HTMLOptions{}).Page(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range s.Elements() {
+		if e.Type == NavElementHeader && !strings.Contains(page, `id="`+e.Slug+`"`) {
+			t.Errorf("page has no id %q", e.Slug)
+		}
+	}
+}
+
+func TestHTMLRenderer_Rewrites(t *testing.T) {
+	page, err := NewHTMLRenderer(HTMLOptions{
+		Title:        "Custom <Title>",
+		RewriteLink:  func(dest string) string { return "L:" + dest },
+		RewriteImage: func(src string) string { return "data:image/png;base64,AA" },
+	}).Page("# Heading\n\n[a](b.md) ![alt](pic.png)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>Custom &lt;Title&gt;</title>",
+		`href="L:b.md"`,
+		`src="data:image/png;base64,AA"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page missing %q", want)
+		}
+	}
+}
+
+func TestPageBackground(t *testing.T) {
+	if got := pageBackground(stringPtr("#f8f8f2")); got != "#1e1e1e" {
+		t.Errorf("light text: background %q, want dark", got)
+	}
+	if got := pageBackground(stringPtr("234")); got != "#ffffff" {
+		t.Errorf("dark text: background %q, want white", got)
+	}
+	if got := pageBackground(nil); got != "#ffffff" {
+		t.Errorf("no text color: background %q, want white", got)
+	}
+}
+
+func TestExportLink(t *testing.T) {
+	tests := map[string]string{
+		"other.md":              "other.html",
+		"docs/guide.markdown#x": "docs/guide.html#x",
+		"../up.MD?raw=1":        "../up.html?raw=1",
+		"#local":                "#local",
+		"image.png":             "image.png",
+		"https://x.org/a.md":    "https://x.org/a.md",
+		"mailto:me@example.com": "mailto:me@example.com",
+	}
+	for in, want := range tests {
+		if got := exportLink(in, ".html"); got != want {
+			t.Errorf("exportLink(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEmbedImage(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	if err := os.WriteFile(filepath.Join(dir, "pic.png"), png, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pic.svg"), []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "doc.md")
+
+	if got := embedImage("pic.png", source, nil); !strings.HasPrefix(got, "data:image/png;base64,") {
+		t.Errorf("png = %q, want a data URI", got)
+	}
+	if got := embedImage("pic.svg", source, nil); !strings.HasPrefix(got, "data:image/svg+xml;base64,") {
+		t.Errorf("svg = %q, want a data URI", got)
+	}
+	for _, src := range []string{"missing.png", "https://example.com/x.png"} {
+		if got := embedImage(src, source, nil); got != src {
+			t.Errorf("embedImage(%q) = %q, want unchanged", src, got)
+		}
+	}
+	deny := &PathPolicy{Deny: []string{"*.png"}}
+	if got := embedImage("pic.png", source, deny); got != "pic.png" {
+		t.Errorf("refused image = %q, want unchanged", got)
+	}
+}
+
+func TestExporter_ExportTree(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"README.md":         "# Home\n\nSee [guide](docs/guide.md#setup).\n",
+		"docs/guide.md":     "# Guide\n\n## Setup\n",
+		"docs/notes.txt":    "not markdown",
+		".git/HEAD.md":      "# hidden",
+		"docs/img/logo.svg": `<svg xmlns="http://www.w3.org/2000/svg"/>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := t.TempDir()
+	e := NewExporter(ExportOptions{})
+	defer e.Close()
+	written, err := e.ExportTree(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("written = %v, want README and guide", written)
+	}
+
+	home, err := os.ReadFile(filepath.Join(dst, "README.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(home), `href="docs/guide.html#setup"`) {
+		t.Errorf("link not rewritten:\n%s", home)
+	}
+	guide, err := os.ReadFile(filepath.Join(dst, "docs", "guide.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(guide), `id="setup"`) {
+		t.Error("guide page lacks the setup anchor")
+	}
+
+	text := NewExporter(ExportOptions{Format: ExportText})
+	defer text.Close()
+	out := filepath.Join(dst, "guide.txt")
+	if err := text.ExportFile(filepath.Join(src, "docs", "guide.md"), out); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(out); !strings.HasPrefix(string(data), "# Guide") {
+		t.Errorf("text export = %q", data)
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for name, want := range map[string]ExportFormat{"html": ExportHTML, "TEXT": ExportText, "txt": ExportText} {
+		if got, err := ParseExportFormat(name); err != nil || got != want {
+			t.Errorf("ParseExportFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseExportFormat("pdf"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -7,9 +7,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strings"
-	"sync"
 	"time"
 )
 
@@ -56,12 +53,9 @@ func (o *GraphvizOptions) resolvedTimeout() time.Duration {
 
 // GraphvizRenderer renders graphviz dot source code to PNG files.
 type GraphvizRenderer struct {
-	opts          GraphvizOptions
-	cache         sync.Map
-	persistentDir string
-	tempDir       string
-	workDir       string
-	dotPath       string
+	diagramCache
+	opts    GraphvizOptions
+	dotPath string
 }
 
 // NewGraphvizRenderer creates a new renderer. Returns nil if dot is not found.
@@ -81,11 +75,9 @@ func NewGraphvizRenderer(opts GraphvizOptions) *GraphvizRenderer {
 	}
 
 	return &GraphvizRenderer{
-		opts:          opts,
-		persistentDir: persistentDir,
-		tempDir:       tempDir,
-		workDir:       workDir,
-		dotPath:       dotPath,
+		diagramCache: diagramCache{persistentDir: persistentDir, tempDir: tempDir, workDir: workDir},
+		opts:         opts,
+		dotPath:      dotPath,
 	}
 }
 
@@ -107,15 +99,8 @@ func (r *GraphvizRenderer) RenderToFile(source string) (string, error) {
 	key := r.cacheKey(source)
 	outputPath := filepath.Join(r.workDir, key+".png")
 
-	if cached, ok := r.cache.Load(key); ok {
-		if path, ok := cached.(string); ok {
-			return path, nil
-		}
-	}
-
-	if _, err := os.Stat(outputPath); err == nil {
-		r.cache.Store(key, outputPath)
-		return outputPath, nil
+	if path, ok := r.lookup(key, outputPath); ok {
+		return path, nil
 	}
 
 	inputPath := filepath.Join(r.workDir, key+".dot")
@@ -145,59 +130,14 @@ func (r *GraphvizRenderer) RenderToFile(source string) (string, error) {
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("dot failed: %w\n%s", err, string(out))
+		return "", &DiagramToolError{Tool: "dot", Err: err, Output: string(out)}
 	}
 
 	_ = os.Remove(inputPath)
 
-	r.cache.Store(key, outputPath)
+	r.store(key, outputPath)
 	return outputPath, nil
 }
 
-// ClearCache flushes the in-memory cache and removes disk-cached PNGs.
-func (r *GraphvizRenderer) ClearCache() {
-	r.cache.Range(func(key, _ any) bool { r.cache.Delete(key); return true })
-	entries, _ := os.ReadDir(r.workDir)
-	for _, e := range entries {
-		if strings.HasSuffix(e.Name(), ".png") {
-			_ = os.Remove(filepath.Join(r.workDir, e.Name()))
-		}
-	}
-}
-
-// WorkDir returns the cache working directory.
-func (r *GraphvizRenderer) WorkDir() string { return r.workDir }
-
-// EvictKeys removes specific cache entries by key (hex hash, no extension).
-func (r *GraphvizRenderer) EvictKeys(keys []string) {
-	for _, key := range keys {
-		r.cache.Delete(key)
-		_ = os.Remove(filepath.Join(r.workDir, key+".png"))
-	}
-}
-
-// Close releases resources. Only removes the temp dir (if used as fallback).
-func (r *GraphvizRenderer) Close() {
-	if r.tempDir != "" {
-		_ = os.RemoveAll(r.tempDir)
-	}
-}
-
-// graphvizFenceRe matches the opening of a dot/graphviz fenced code block.
-var graphvizFenceRe = regexp.MustCompile(`^(\s*` + "`{3,}" + `)(?:dot|graphviz)\s*$`)
-
-// preprocessGraphviz scans raw markdown for ```dot / ```graphviz blocks and
-// replaces them with ![dot diagram](path.png) using the given renderer.
-func preprocessGraphviz(markdown string, renderer *GraphvizRenderer) string {
-	if renderer == nil {
-		return markdown
-	}
-
-	lines, blocks := extractDiagramBlocks(markdown, graphvizFenceRe)
-	if len(blocks) == 0 {
-		return markdown
-	}
-
-	rendered := renderDiagramBlocks(blocks, renderer)
-	return reassembleDiagram(lines, blocks, rendered, "dot diagram")
-}
+// graphvizLanguages are the fence languages of graphviz blocks.
+var graphvizLanguages = []string{"dot", "graphviz"}
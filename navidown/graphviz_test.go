@@ -137,7 +137,7 @@ func TestPreprocessGraphviz_DetectsBlocks(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "# Title\n\n```dot\ndigraph { A -> B }\n```\n\nSome text.\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if strings.Contains(result, "```dot") {
 		t.Error("dot fence should have been replaced")
@@ -160,7 +160,7 @@ func TestPreprocessGraphviz_GraphvizFenceName(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "```graphviz\ndigraph { A -> B }\n```\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if strings.Contains(result, "```graphviz") {
 		t.Error("graphviz fence should have been replaced")
@@ -174,7 +174,7 @@ func TestPreprocessGraphviz_PreservesNonGraphviz(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "```go\nfunc main() {}\n```\n\n```python\nprint('hello')\n```\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if result != md {
 		t.Errorf("non-graphviz blocks should be untouched.\ngot:  %q\nwant: %q", result, md)
@@ -185,7 +185,7 @@ func TestPreprocessGraphviz_MixedContent(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "# Intro\n\n```go\nfunc main() {}\n```\n\n```dot\ndigraph { A -> B }\n```\n\nEnd.\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if !strings.Contains(result, "```go") {
 		t.Error("go block should be preserved")
@@ -210,7 +210,7 @@ func TestPreprocessGraphviz_ErrorPreservesBlock(t *testing.T) {
 	t.Cleanup(renderer.Close)
 
 	md := "```dot\ndigraph { A -> B }\n```\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if !strings.Contains(result, "```dot") {
 		t.Error("on error, original block should be preserved")
@@ -219,7 +219,7 @@ func TestPreprocessGraphviz_ErrorPreservesBlock(t *testing.T) {
 
 func TestPreprocessGraphviz_NilRenderer(t *testing.T) {
 	md := "```dot\ndigraph { A -> B }\n```\n"
-	result := preprocessGraphviz(md, nil)
+	result := preprocessDiagram(md, "graphviz", nil, graphvizLanguages...)
 	if result != md {
 		t.Error("nil renderer should return markdown unchanged")
 	}
@@ -229,7 +229,7 @@ func TestPreprocessGraphviz_MultipleBlocks(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "```dot\ndigraph { A -> B }\n```\n\nMiddle text.\n\n```graphviz\ndigraph { C -> D }\n```\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	count := strings.Count(result, "![dot diagram](")
 	if count != 2 {
@@ -247,7 +247,7 @@ func TestPreprocessGraphviz_FourBacktickFence(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "````dot\ndigraph { A -> B }\n````\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if strings.Contains(result, "````dot") {
 		t.Error("4-backtick dot fence should have been replaced")
@@ -261,7 +261,7 @@ func TestPreprocessGraphviz_UnclosedFence(t *testing.T) {
 	renderer := newTestGraphvizRenderer(t)
 
 	md := "```dot\ndigraph { A -> B }\n"
-	result := preprocessGraphviz(md, renderer)
+	result := preprocessDiagram(md, "graphviz", renderer, graphvizLanguages...)
 
 	if result != md {
 		t.Errorf("unclosed fence should be left as-is.\ngot:  %q\nwant: %q", result, md)
@@ -410,7 +410,7 @@ func TestMarkdownSession_GraphvizRendersAsImage(t *testing.T) {
 		GraphvizOptions: &GraphvizOptions{DotPath: renderer.dotPath},
 	})
 	// replace the auto-created graphviz renderer with our test one
-	session.graphvizRenderer = renderer
+	session.Diagrams().Register("graphviz", renderer, graphvizLanguages...)
 
 	md := "# Title\n\n```dot\ndigraph { A -> B }\n```\n\nSome text.\n"
 	if err := session.SetMarkdown(md); err != nil {
@@ -447,8 +447,8 @@ func TestMarkdownSession_MixedMermaidAndGraphviz(t *testing.T) {
 		MermaidOptions:  &MermaidOptions{MmdcPath: mermaidRenderer.mmdcPath},
 		GraphvizOptions: &GraphvizOptions{DotPath: graphvizRenderer.dotPath},
 	})
-	session.mermaidRenderer = mermaidRenderer
-	session.graphvizRenderer = graphvizRenderer
+	session.Diagrams().Register("mermaid", mermaidRenderer, mermaidLanguages...)
+	session.Diagrams().Register("graphviz", graphvizRenderer, graphvizLanguages...)
 
 	md := "# Mixed\n\n```mermaid\ngraph TD\n    A-->B\n```\n\n```dot\ndigraph { C -> D }\n```\n"
 	if err := session.SetMarkdown(md); err != nil {
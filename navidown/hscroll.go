@@ -0,0 +1,235 @@
+package navidown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HScrollMode selects how a session scrolls content wider than the viewport.
+type HScrollMode int
+
+const (
+	// HScrollOff fits tables and code blocks to the width (the default).
+	HScrollOff HScrollMode = iota
+	// HScrollDocument scrolls the whole document sideways together.
+	HScrollDocument
+	// HScrollBlock scrolls each wide block on its own, leaving the text
+	// around it in place.
+	HScrollBlock
+)
+
+// String returns the mode name accepted by ParseHScrollMode.
+func (m HScrollMode) String() string {
+	switch m {
+	case HScrollDocument:
+		return "document"
+	case HScrollBlock:
+		return "block"
+	}
+	return "off"
+}
+
+// ParseHScrollMode parses a horizontal scroll mode name: "off", "document"
+// (or "doc"), or "block".
+func ParseHScrollMode(name string) (HScrollMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "off", "none":
+		return HScrollOff, nil
+	case "document", "doc":
+		return HScrollDocument, nil
+	case "block":
+		return HScrollBlock, nil
+	}
+	return HScrollOff, fmt.Errorf("unknown horizontal scroll mode %q (use off, document, or block)", name)
+}
+
+// wideBlock is a run of consecutive rendered lines wider than the viewport,
+// such as a table or code block rendered at its natural width.
+type wideBlock struct {
+	startLine, endLine int // inclusive
+	width              int // widest line, in columns
+	offset             int // horizontal offset in HScrollBlock mode
+}
+
+// SetHorizontalScroll sets the horizontal scroll mode. Turning scrolling on
+// or off re-renders the document, since tables and code blocks are only
+// rendered wider than the viewport while it is on.
+func (v *MarkdownSession) SetHorizontalScroll(mode HScrollMode) {
+	if mode == v.hscroll {
+		return
+	}
+	rerender := (mode == HScrollOff) != (v.hscroll == HScrollOff)
+	v.hscroll = mode
+	v.hOffset = 0
+	if rerender && v.markdown != "" {
+		_ = v.reRenderWithWidth(v.currentWidth)
+		return
+	}
+	v.measureWideBlocks()
+}
+
+// HorizontalScroll returns the horizontal scroll mode.
+func (v *MarkdownSession) HorizontalScroll() HScrollMode { return v.hscroll }
+
+// LineOffset returns the number of columns line lineIdx is scrolled to the
+// left. Viewers skip that many columns when drawing the line; element
+// columns stay relative to the start of the line.
+func (v *MarkdownSession) LineOffset(lineIdx int) int {
+	switch v.hscroll {
+	case HScrollDocument:
+		return v.hOffset
+	case HScrollBlock:
+		if b := v.wideBlockAt(lineIdx); b != nil {
+			return b.offset
+		}
+	}
+	return 0
+}
+
+// ScrollLeft scrolls left by cols columns: the whole document, or in
+// HScrollBlock mode the block holding the selection, else the first wide
+// block in the viewport.
+func (v *MarkdownSession) ScrollLeft(viewportHeight, cols int) bool {
+	return v.scrollHorizontally(viewportHeight, -cols)
+}
+
+// ScrollRight scrolls right by cols columns, up to where the widest line
+// (of the document, or of the block) ends at the viewport edge.
+func (v *MarkdownSession) ScrollRight(viewportHeight, cols int) bool {
+	return v.scrollHorizontally(viewportHeight, cols)
+}
+
+func (v *MarkdownSession) scrollHorizontally(viewportHeight, delta int) bool {
+	switch v.hscroll {
+	case HScrollDocument:
+		return setOffset(&v.hOffset, v.hOffset+delta, v.maxDocumentWidth()-v.currentWidth)
+	case HScrollBlock:
+		if b := v.focusBlock(viewportHeight); b != nil {
+			return setOffset(&b.offset, b.offset+delta, b.width-v.currentWidth)
+		}
+	}
+	return false
+}
+
+// setOffset clamps offset to [0, maxOffset], stores it in dst, and reports
+// whether it changed.
+func setOffset(dst *int, offset, maxOffset int) bool {
+	if offset > maxOffset {
+		offset = maxOffset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset == *dst {
+		return false
+	}
+	*dst = offset
+	return true
+}
+
+// focusBlock returns the wide block that HScrollBlock scrolls: the one
+// holding the selection, else the first one in the viewport.
+func (v *MarkdownSession) focusBlock(viewportHeight int) *wideBlock {
+	if sel := v.Selected(); sel != nil {
+		if b := v.wideBlockAt(sel.StartLine); b != nil {
+			return b
+		}
+	}
+	bottom := v.scrollOffset + viewportHeight
+	for i := range v.wideBlocks {
+		b := &v.wideBlocks[i]
+		if b.endLine >= v.scrollOffset && b.startLine < bottom {
+			return b
+		}
+	}
+	return nil
+}
+
+func (v *MarkdownSession) wideBlockAt(lineIdx int) *wideBlock {
+	for i := range v.wideBlocks {
+		b := &v.wideBlocks[i]
+		if lineIdx >= b.startLine && lineIdx <= b.endLine {
+			return b
+		}
+	}
+	return nil
+}
+
+func (v *MarkdownSession) maxDocumentWidth() int {
+	maxW := 0
+	for _, b := range v.wideBlocks {
+		maxW = max(maxW, b.width)
+	}
+	return maxW
+}
+
+// measureWideBlocks finds the runs of lines wider than the viewport after
+// the rendered lines change. Offsets of blocks that survive are kept and
+// clamped; new blocks start at 0.
+func (v *MarkdownSession) measureWideBlocks() {
+	old := v.wideBlocks
+	v.wideBlocks = nil
+	if v.hscroll == HScrollOff || v.currentWidth <= 0 {
+		v.hOffset = 0
+		return
+	}
+	for i, line := range v.renderedLines {
+		w := stringColumns(v.cleaner.Clean(line))
+		if w <= v.currentWidth {
+			continue
+		}
+		if n := len(v.wideBlocks); n > 0 && v.wideBlocks[n-1].endLine == i-1 {
+			last := &v.wideBlocks[n-1]
+			last.endLine = i
+			last.width = max(last.width, w)
+			continue
+		}
+		v.wideBlocks = append(v.wideBlocks, wideBlock{startLine: i, endLine: i, width: w})
+	}
+	for i := range v.wideBlocks {
+		b := &v.wideBlocks[i]
+		for _, o := range old {
+			if o.startLine == b.startLine {
+				setOffset(&b.offset, o.offset, b.width-v.currentWidth)
+			}
+		}
+	}
+	setOffset(&v.hOffset, v.hOffset, v.maxDocumentWidth()-v.currentWidth)
+}
+
+// resetHorizontalScroll scrolls everything back to the left edge, for a new
+// document.
+func (v *MarkdownSession) resetHorizontalScroll() {
+	v.hOffset = 0
+	v.wideBlocks = nil
+	v.measureWideBlocks()
+}
+
+// revealColumns scrolls horizontally so the columns of elem are visible.
+func (v *MarkdownSession) revealColumns(elem NavElement) {
+	if v.currentWidth <= 0 || elem.EndCol <= elem.StartCol {
+		return
+	}
+	var dst *int
+	var maxOffset int
+	switch v.hscroll {
+	case HScrollDocument:
+		dst, maxOffset = &v.hOffset, v.maxDocumentWidth()-v.currentWidth
+	case HScrollBlock:
+		b := v.wideBlockAt(elem.StartLine)
+		if b == nil {
+			return
+		}
+		dst, maxOffset = &b.offset, b.width-v.currentWidth
+	default:
+		return
+	}
+	offset := *dst
+	if elem.EndCol > offset+v.currentWidth {
+		offset = elem.EndCol - v.currentWidth
+	}
+	if elem.StartCol < offset {
+		offset = elem.StartCol
+	}
+	setOffset(dst, offset, maxOffset)
+}
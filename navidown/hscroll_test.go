@@ -0,0 +1,142 @@
+package navidown
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const hscrollSample = "# Wide\n\nIntro text.\n\n" +
+	"```\nfmt.Println(\"this code line is much wider than thirty columns\")\n```\n\n" +
+	"Between the blocks.\n\n" +
+	"| key | value |\n|---|---|\n| a | [link](https://example.com) in a cell wider than the viewport |\n"
+
+func newHScrollSession(t *testing.T, mode HScrollMode) *MarkdownSession {
+	t.Helper()
+	s := New(Options{HorizontalScroll: mode})
+	s.SetWidth(30)
+	if err := s.SetMarkdown(hscrollSample); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func widestRenderedLine(s *MarkdownSession) int {
+	maxW := 0
+	for _, line := range s.RenderedLines() {
+		maxW = max(maxW, utf8.RuneCountInString(stripANSIAndMarkers(line)))
+	}
+	return maxW
+}
+
+func TestHScroll_OffFitsWidth(t *testing.T) {
+	s := newHScrollSession(t, HScrollOff)
+	if w := widestRenderedLine(s); w > 30 {
+		t.Errorf("widest line is %d columns, want at most 30", w)
+	}
+	if s.ScrollRight(10, 5) {
+		t.Error("ScrollRight should do nothing when horizontal scrolling is off")
+	}
+}
+
+func TestHScroll_Document(t *testing.T) {
+	s := newHScrollSession(t, HScrollDocument)
+	widest := widestRenderedLine(s)
+	if widest <= 30 {
+		t.Fatalf("wide blocks should overflow the width, widest line is %d", widest)
+	}
+
+	if !s.ScrollRight(40, 5) {
+		t.Fatal("ScrollRight should move")
+	}
+	for i := range s.RenderedLines() {
+		if got := s.LineOffset(i); got != 5 {
+			t.Fatalf("line %d offset = %d, want 5", i, got)
+		}
+	}
+
+	// clamped so the widest line ends at the viewport edge
+	s.ScrollRight(40, 1000)
+	if got := s.LineOffset(0); got != widest-30 {
+		t.Errorf("offset = %d, want %d", got, widest-30)
+	}
+	if s.ScrollRight(40, 1) {
+		t.Error("ScrollRight past the end should not move")
+	}
+	s.ScrollLeft(40, 1000)
+	if got := s.LineOffset(0); got != 0 {
+		t.Errorf("offset after ScrollLeft = %d, want 0", got)
+	}
+}
+
+func TestHScroll_Block(t *testing.T) {
+	s := newHScrollSession(t, HScrollBlock)
+	lines := s.RenderedLines()
+	codeLine, textLine := -1, -1
+	for i, line := range lines {
+		clean := stripANSIAndMarkers(line)
+		if strings.Contains(clean, "fmt.Println") {
+			codeLine = i
+		}
+		if strings.Contains(clean, "Between the blocks") {
+			textLine = i
+		}
+	}
+	if codeLine < 0 || textLine < 0 {
+		t.Fatalf("sample lines not found:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// the first wide block in the viewport is the code block
+	if !s.ScrollRight(len(lines), 4) {
+		t.Fatal("ScrollRight should move")
+	}
+	if got := s.LineOffset(codeLine); got != 4 {
+		t.Errorf("code line offset = %d, want 4", got)
+	}
+	if got := s.LineOffset(textLine); got != 0 {
+		t.Errorf("text line offset = %d, want 0", got)
+	}
+
+	// selecting the link in the table scrolls the table to reveal it
+	if !s.MoveToNextLink(len(lines)) {
+		t.Fatal("no link to select")
+	}
+	sel := s.Selected()
+	if off := s.LineOffset(sel.StartLine); sel.StartCol < off || sel.EndCol > off+30 {
+		t.Errorf("link columns %d-%d not visible at offset %d", sel.StartCol, sel.EndCol, off)
+	}
+	if got := s.LineOffset(codeLine); got != 4 {
+		t.Errorf("code block offset changed to %d", got)
+	}
+
+	// a new document starts at the left edge
+	if err := s.SetMarkdown(hscrollSample); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.LineOffset(codeLine); got != 0 {
+		t.Errorf("offset after SetMarkdown = %d, want 0", got)
+	}
+}
+
+func TestHScroll_SetHorizontalScrollRerenders(t *testing.T) {
+	s := newHScrollSession(t, HScrollOff)
+	s.SetHorizontalScroll(HScrollDocument)
+	if w := widestRenderedLine(s); w <= 30 {
+		t.Errorf("turning scrolling on should re-render wide, widest line is %d", w)
+	}
+	s.SetHorizontalScroll(HScrollOff)
+	if w := widestRenderedLine(s); w > 30 {
+		t.Errorf("turning scrolling off should re-render narrow, widest line is %d", w)
+	}
+}
+
+func TestParseHScrollMode(t *testing.T) {
+	for name, want := range map[string]HScrollMode{"off": HScrollOff, "Document": HScrollDocument, "block": HScrollBlock} {
+		if got, err := ParseHScrollMode(name); err != nil || got != want {
+			t.Errorf("ParseHScrollMode(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseHScrollMode("sideways"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,287 @@
+package navidown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromaStyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+	"github.com/boolean-maybe/navidown/navidown/theme"
+	"github.com/muesli/termenv"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// HTMLOptions configures an HTMLRenderer.
+type HTMLOptions struct {
+	// Theme supplies the page colors. Nil means the dark style.
+	Theme *theme.Theme
+
+	// Title is the page title. Empty means the text of the first heading.
+	Title string
+
+	// RewriteLink, if set, maps each link destination, e.g. to point links
+	// between markdown files at their exported pages.
+	RewriteLink func(dest string) string
+
+	// RewriteImage, if set, maps each image source, e.g. to embed local
+	// images as data URIs.
+	RewriteImage func(src string) string
+}
+
+// HTMLRenderer renders markdown to standalone HTML pages styled with a
+// theme's colors. Heading ids are the same slugs sessions use for anchors,
+// so "#section" links work in both.
+type HTMLRenderer struct {
+	opts  HTMLOptions
+	style ansi.StyleConfig
+}
+
+// NewHTMLRenderer creates an HTML renderer.
+func NewHTMLRenderer(opts HTMLOptions) *HTMLRenderer {
+	style, _ := builtinStyle(styles.DarkStyle)
+	if opts.Theme != nil {
+		style = opts.Theme.StyleConfig()
+	}
+	return &HTMLRenderer{opts: opts, style: style}
+}
+
+// Render renders markdown to the lines of an HTML page. The cleaner reduces
+// a line to its text.
+func (r *HTMLRenderer) Render(markdown string) (RenderResult, error) {
+	page, err := r.Page(markdown)
+	if err != nil {
+		return RenderResult{Lines: strings.Split(markdown, "\n"), Cleaner: LineCleanerFunc(func(s string) string { return s })}, err
+	}
+	return RenderResult{Lines: strings.Split(page, "\n"), Cleaner: LineCleanerFunc(htmlLineText)}, nil
+}
+
+// Page renders markdown to a standalone HTML page.
+func (r *HTMLRenderer) Page(markdown string) (string, error) {
+	source := []byte(markdown)
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, extension.DefinitionList),
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.Prioritized(ansi.HTMLTransformer{}, 100)),
+		),
+		goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+			util.Prioritized(&htmlCodeBlockRenderer{style: r.codeStyle()}, 100),
+		)),
+	)
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	title := r.opts.Title
+	slugs := slugger{}
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			text := headingText(n, source)
+			if slug := slugs.slug(text); slug != "" {
+				n.SetAttributeString("id", []byte(slug))
+			}
+			if title == "" {
+				title = strings.TrimSpace(text)
+			}
+		case *ast.Link:
+			if r.opts.RewriteLink != nil {
+				n.Destination = []byte(r.opts.RewriteLink(string(n.Destination)))
+			}
+		case *ast.Image:
+			if r.opts.RewriteImage != nil {
+				n.Destination = []byte(r.opts.RewriteImage(string(n.Destination)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, source, doc); err != nil {
+		return "", err
+	}
+
+	var page strings.Builder
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&page, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(&page, "<style>\n%s</style>\n</head>\n<body>\n", r.css())
+	page.Write(body.Bytes())
+	page.WriteString("</body>\n</html>\n")
+	return page.String(), nil
+}
+
+// codeStyle returns the chroma style for code blocks: the style's named
+// chroma theme, or one built from its chroma colors.
+func (r *HTMLRenderer) codeStyle() *chroma.Style {
+	rules := r.style.CodeBlock
+	if rules.Theme != "" {
+		return chromaStyles.Get(rules.Theme)
+	}
+	if rules.Chroma != nil {
+		if s, err := chroma.NewStyle("navidown-export", ansi.ChromaStyleEntries(rules.Chroma)); err == nil {
+			return s
+		}
+	}
+	return chromaStyles.Fallback
+}
+
+// css returns the page style sheet derived from the theme.
+func (r *HTMLRenderer) css() string {
+	s := r.style
+	var b strings.Builder
+
+	body := cssDecls(s.Document.StylePrimitive)
+	if s.Document.BackgroundColor == nil {
+		body = append(body, "background-color: "+pageBackground(s.Document.Color))
+	}
+	body = append(body, "font-family: system-ui, sans-serif", "line-height: 1.5",
+		"max-width: 50rem", "margin: 0 auto", "padding: 1rem 2rem")
+	writeCSSRule(&b, "body", body)
+
+	writeCSSRule(&b, "h1, h2, h3, h4, h5, h6", cssDecls(s.Heading.StylePrimitive))
+	for i, h := range []ansi.StyleBlock{s.H1, s.H2, s.H3, s.H4, s.H5, s.H6} {
+		writeCSSRule(&b, fmt.Sprintf("h%d", i+1), cssDecls(h.StylePrimitive))
+	}
+	writeCSSRule(&b, "a", cssDecls(s.Link))
+	writeCSSRule(&b, "strong", cssDecls(s.Strong))
+	writeCSSRule(&b, "em", cssDecls(s.Emph))
+	writeCSSRule(&b, "del", cssDecls(s.Strikethrough))
+	writeCSSRule(&b, "code", append(cssDecls(s.Code.StylePrimitive), "padding: 0 .2em", "border-radius: 3px"))
+	writeCSSRule(&b, "pre", []string{"padding: .75em 1em", "overflow-x: auto", "border-radius: 4px"})
+	writeCSSRule(&b, "pre code", []string{"color: inherit", "background: none", "padding: 0"})
+
+	quote := cssDecls(s.BlockQuote.StylePrimitive)
+	quote = append(quote, "margin-left: 0", "padding-left: 1em", "border-left: 3px solid currentColor")
+	writeCSSRule(&b, "blockquote", quote)
+	if c := cssColor(s.HorizontalRule.Color); c != "" {
+		writeCSSRule(&b, "hr", []string{"border: 0", "border-top: 1px solid " + c})
+	}
+	writeCSSRule(&b, "table", append(cssDecls(s.Table.StylePrimitive), "border-collapse: collapse"))
+	writeCSSRule(&b, "th, td", []string{"border: 1px solid currentColor", "padding: .25em .75em"})
+	writeCSSRule(&b, "dt", cssDecls(s.DefinitionTerm))
+	writeCSSRule(&b, "img", []string{"max-width: 100%"})
+	return b.String()
+}
+
+func writeCSSRule(b *strings.Builder, selector string, decls []string) {
+	if len(decls) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s { %s; }\n", selector, strings.Join(decls, "; "))
+}
+
+// cssDecls converts the colors and text styles of p to CSS declarations.
+func cssDecls(p ansi.StylePrimitive) []string {
+	var decls []string
+	if c := cssColor(p.Color); c != "" {
+		decls = append(decls, "color: "+c)
+	}
+	if c := cssColor(p.BackgroundColor); c != "" {
+		decls = append(decls, "background-color: "+c)
+	}
+	on := func(v *bool) bool { return v != nil && *v }
+	if on(p.Bold) {
+		decls = append(decls, "font-weight: bold")
+	}
+	if on(p.Italic) {
+		decls = append(decls, "font-style: italic")
+	}
+	switch {
+	case on(p.Underline) && on(p.CrossedOut):
+		decls = append(decls, "text-decoration: underline line-through")
+	case on(p.Underline):
+		decls = append(decls, "text-decoration: underline")
+	case on(p.CrossedOut):
+		decls = append(decls, "text-decoration: line-through")
+	}
+	if on(p.Faint) {
+		decls = append(decls, "opacity: .7")
+	}
+	if on(p.Upper) {
+		decls = append(decls, "text-transform: uppercase")
+	}
+	return decls
+}
+
+// cssColor converts a theme color ("#rrggbb" or an ANSI color number) to a
+// CSS hex color, or "" if unset or invalid.
+func cssColor(c *string) string {
+	if c == nil {
+		return ""
+	}
+	col := termenv.TrueColor.Color(*c)
+	if col == nil {
+		return ""
+	}
+	return termenv.ConvertToRGB(col).Hex()
+}
+
+// pageBackground picks a page background that suits the document text
+// color: dark for light text, white otherwise.
+func pageBackground(fg *string) string {
+	c := cssColor(fg)
+	if c == "" {
+		return "#ffffff"
+	}
+	if _, _, l := termenv.ConvertToRGB(termenv.TrueColor.Color(c)).Hsl(); l > 0.5 {
+		return "#1e1e1e"
+	}
+	return "#ffffff"
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// htmlLineText reduces a line of HTML to its text.
+func htmlLineText(s string) string {
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+}
+
+// htmlCodeBlockRenderer renders fenced code blocks highlighted with chroma.
+type htmlCodeBlockRenderer struct {
+	style *chroma.Style
+}
+
+func (h *htmlCodeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, h.render)
+}
+
+func (h *htmlCodeBlockRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var code strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		seg := n.Lines().At(i)
+		code.Write(seg.Value(source))
+	}
+
+	lexer := lexers.Get(string(n.Language(source)))
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iter, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		_, _ = fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", html.EscapeString(code.String()))
+		return ast.WalkSkipChildren, nil
+	}
+	if err := chromahtml.New(chromahtml.WithClasses(false)).Format(w, h.style, iter); err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = w.WriteString("\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,525 @@
+package navidown
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPCacheOptions configures the on-disk HTTP cache.
+type HTTPCacheOptions struct {
+	CacheDir string // persistent cache dir; "" = auto (os.UserCacheDir()/navidown/http)
+	// Offline serves stored entries, however stale, when the network is
+	// unavailable (transport errors or 5xx responses). Entries marked
+	// no-store are never written, so they are never available offline.
+	Offline bool
+}
+
+// HTTPResponse is a fully-read HTTP response, possibly served from the cache.
+type HTTPResponse struct {
+	URL        string // final request URL
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FromCache  bool // body came from disk (fresh hit, 304 revalidation, or offline fallback)
+	Stale      bool // served from disk past its freshness lifetime because the network failed
+}
+
+// ContentType returns the media type of the response without parameters,
+// lowercased (e.g. "text/html"). Returns "" when the header is missing.
+func (r *HTTPResponse) ContentType() string {
+	ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// HTTPCacheEntry describes one stored response for inspection.
+type HTTPCacheEntry struct {
+	URL          string
+	Size         int64     // body size in bytes
+	StoredAt     time.Time // time of the last full fetch or successful revalidation
+	ExpiresAt    time.Time // end of freshness lifetime; zero means revalidate on every use
+	ETag         string
+	LastModified string
+	ContentType  string
+}
+
+// Fresh reports whether the entry can be served at now without revalidation.
+func (e HTTPCacheEntry) Fresh(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.Before(e.ExpiresAt)
+}
+
+// HTTPCache is a conditional-request HTTP cache stored on disk. It honors
+// Cache-Control (max-age, no-cache, no-store, private), Expires and Vary, and
+// revalidates stale entries with If-None-Match / If-Modified-Since. Requests
+// carrying credentials bypass it, so authenticated content is never written
+// to disk or served without them. A nil *HTTPCache is valid and performs
+// uncached requests, so callers can use it unconditionally.
+type HTTPCache struct {
+	persistentDir string
+	tempDir       string
+	workDir       string
+	offline       atomic.Bool
+
+	now func() time.Time // overridable for tests
+}
+
+// httpCacheMeta is the on-disk sidecar stored next to each cached body.
+type httpCacheMeta struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status"`
+	StoredAt   time.Time   `json:"stored_at"`
+	Header     http.Header `json:"header"`
+	// Vary holds the request header values named by the response's Vary
+	// header; the entry only serves requests with the same values.
+	Vary map[string]string `json:"vary,omitempty"`
+}
+
+// hexHash matches a 64-char lowercase hex SHA256 cache key.
+var hexHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// cachedHeaders lists the response headers persisted with each entry.
+var cachedHeaders = []string{
+	"Cache-Control", "Content-Type", "Date", "ETag", "Expires", "Last-Modified", "Vary",
+}
+
+// NewHTTPCache creates a cache rooted at the resolved cache dir.
+// Returns nil if no usable cache directory can be resolved.
+func NewHTTPCache(opts HTTPCacheOptions) *HTTPCache {
+	persistentDir, tempDir, workDir := resolveCacheDir(opts.CacheDir, "http")
+	if workDir == "" {
+		return nil
+	}
+	c := &HTTPCache{
+		persistentDir: persistentDir,
+		tempDir:       tempDir,
+		workDir:       workDir,
+		now:           time.Now,
+	}
+	c.offline.Store(opts.Offline)
+	return c
+}
+
+// SetOffline toggles offline fallback at runtime.
+func (c *HTTPCache) SetOffline(offline bool) {
+	if c != nil {
+		c.offline.Store(offline)
+	}
+}
+
+// Offline reports whether offline fallback is enabled.
+func (c *HTTPCache) Offline() bool {
+	return c != nil && c.offline.Load()
+}
+
+// WorkDir returns the cache working directory.
+func (c *HTTPCache) WorkDir() string {
+	if c == nil {
+		return ""
+	}
+	return c.workDir
+}
+
+// Get fetches url with a plain GET request through the cache.
+func (c *HTTPCache) Get(client *http.Client, url string) (*HTTPResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(client, req)
+}
+
+// Do executes a GET request through the cache and returns the fully-read
+// response. Only 200 responses are stored; other statuses are returned
+// as-is for the caller to report. Requests with credentials are neither
+// served from nor stored in the cache. If client is nil, http.DefaultClient
+// is used.
+func (c *HTTPCache) Do(client *http.Client, req *http.Request) (*HTTPResponse, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if c == nil || req.Method != http.MethodGet || requestHasCredentials(req) {
+		return doHTTP(client, req)
+	}
+
+	key := httpCacheKey(req.URL.String())
+	meta, hasEntry := c.readMeta(key)
+	if hasEntry && !meta.varyMatches(req) {
+		hasEntry = false
+	}
+	if hasEntry && c.entryFromMeta(meta, 0).Fresh(c.now()) {
+		if resp, err := c.responseFromDisk(key, meta, false); err == nil {
+			return resp, nil
+		}
+		hasEntry = false
+	}
+
+	if hasEntry {
+		req = req.Clone(req.Context())
+		if etag := meta.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := meta.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := doHTTP(client, req)
+	if err != nil || resp.StatusCode >= 500 {
+		if hasEntry && c.Offline() {
+			if stale, derr := c.responseFromDisk(key, meta, true); derr == nil {
+				return stale, nil
+			}
+		}
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasEntry {
+		for _, name := range cachedHeaders {
+			if v := resp.Header.Get(name); v != "" {
+				meta.Header.Set(name, v)
+			}
+		}
+		meta.StoredAt = c.now()
+		_ = c.writeMeta(key, meta)
+		return c.responseFromDisk(key, meta, false)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		// credentials may have been attached on a redirect
+		if !storable(resp.Header) || requestHasCredentials(req) {
+			c.remove(key)
+		} else {
+			_ = c.store(key, req, resp)
+		}
+	}
+	return resp, nil
+}
+
+// storable reports whether a response may be written to the disk cache.
+func storable(h http.Header) bool {
+	if hasDirective(h, "no-store") || hasDirective(h, "private") {
+		return false
+	}
+	for _, name := range varyHeaders(h) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHasCredentials reports whether req, or a redirect of it made by a
+// client from AuthorizedRequest, carries credentials.
+func requestHasCredentials(req *http.Request) bool {
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	use, _ := req.Context().Value(credentialUseKey{}).(*credentialUse)
+	return use != nil && use.attached.Load()
+}
+
+// varyHeaders returns the canonical header names listed by Vary.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyMatches reports whether req sends the header values the entry was
+// stored for.
+func (m httpCacheMeta) varyMatches(req *http.Request) bool {
+	for _, name := range varyHeaders(m.Header) {
+		if req.Header.Get(name) != m.Vary[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// Lookup returns the stored entry for url, if any.
+func (c *HTTPCache) Lookup(url string) (HTTPCacheEntry, bool) {
+	if c == nil {
+		return HTTPCacheEntry{}, false
+	}
+	key := httpCacheKey(url)
+	meta, ok := c.readMeta(key)
+	if !ok {
+		return HTTPCacheEntry{}, false
+	}
+	info, err := os.Stat(c.bodyPath(key))
+	if err != nil {
+		return HTTPCacheEntry{}, false
+	}
+	return c.entryFromMeta(meta, info.Size()), true
+}
+
+// Entries lists all stored entries in unspecified order.
+func (c *HTTPCache) Entries() []HTTPCacheEntry {
+	if c == nil {
+		return nil
+	}
+	dirEntries, _ := os.ReadDir(c.workDir)
+	var out []HTTPCacheEntry
+	for _, e := range dirEntries {
+		key, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !hexHash.MatchString(key) {
+			continue
+		}
+		meta, ok := c.readMeta(key)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(c.bodyPath(key))
+		if err != nil {
+			continue
+		}
+		out = append(out, c.entryFromMeta(meta, info.Size()))
+	}
+	return out
+}
+
+// Evict removes the stored entry for url. Returns true if one existed.
+func (c *HTTPCache) Evict(url string) bool {
+	if c == nil {
+		return false
+	}
+	key := httpCacheKey(url)
+	_, ok := c.readMeta(key)
+	c.remove(key)
+	return ok
+}
+
+// EvictOlderThan removes entries last stored or revalidated more than age ago.
+// Returns the number of entries removed.
+func (c *HTTPCache) EvictOlderThan(age time.Duration) int {
+	if c == nil {
+		return 0
+	}
+	cutoff := c.now().Add(-age)
+	removed := 0
+	for _, e := range c.Entries() {
+		if e.StoredAt.Before(cutoff) {
+			c.remove(httpCacheKey(e.URL))
+			removed++
+		}
+	}
+	return removed
+}
+
+// ClearCache removes all stored entries. The work directory is preserved.
+func (c *HTTPCache) ClearCache() {
+	if c == nil {
+		return
+	}
+	entries, _ := os.ReadDir(c.workDir)
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".body") {
+			_ = os.Remove(filepath.Join(c.workDir, name))
+		}
+	}
+}
+
+// Close releases resources. Only removes the temp dir (if used as fallback).
+func (c *HTTPCache) Close() {
+	if c != nil && c.tempDir != "" {
+		_ = os.RemoveAll(c.tempDir)
+	}
+}
+
+func httpCacheKey(url string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(url)))
+}
+
+func (c *HTTPCache) metaPath(key string) string { return filepath.Join(c.workDir, key+".json") }
+func (c *HTTPCache) bodyPath(key string) string { return filepath.Join(c.workDir, key+".body") }
+
+func (c *HTTPCache) readMeta(key string) (httpCacheMeta, bool) {
+	data, err := os.ReadFile(c.metaPath(key)) // #nosec G703 -- path from filepath.Join(workDir, hash+".json")
+	if err != nil {
+		return httpCacheMeta{}, false
+	}
+	var meta httpCacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.URL == "" {
+		return httpCacheMeta{}, false
+	}
+	if meta.Header == nil {
+		meta.Header = http.Header{}
+	}
+	return meta, true
+}
+
+func (c *HTTPCache) writeMeta(key string, meta httpCacheMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(c.metaPath(key), data)
+}
+
+// store writes the body before its sidecar so a reader never sees metadata
+// pointing at a missing or partial body.
+func (c *HTTPCache) store(key string, req *http.Request, resp *HTTPResponse) error {
+	if err := writeFileAtomic(c.bodyPath(key), resp.Body); err != nil {
+		return err
+	}
+	meta := httpCacheMeta{
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		StoredAt:   c.now(),
+		Header:     http.Header{},
+	}
+	for _, name := range cachedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			meta.Header.Set(name, v)
+		}
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if meta.Vary == nil {
+			meta.Vary = map[string]string{}
+		}
+		meta.Vary[name] = req.Header.Get(name)
+	}
+	return c.writeMeta(key, meta)
+}
+
+func (c *HTTPCache) remove(key string) {
+	_ = os.Remove(c.metaPath(key))
+	_ = os.Remove(c.bodyPath(key))
+}
+
+func (c *HTTPCache) responseFromDisk(key string, meta httpCacheMeta, stale bool) (*HTTPResponse, error) {
+	body, err := os.ReadFile(c.bodyPath(key)) // #nosec G703 -- path from filepath.Join(workDir, hash+".body")
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPResponse{
+		URL:        meta.URL,
+		StatusCode: http.StatusOK,
+		Header:     meta.Header.Clone(),
+		Body:       body,
+		FromCache:  true,
+		Stale:      stale,
+	}, nil
+}
+
+func (c *HTTPCache) entryFromMeta(meta httpCacheMeta, size int64) HTTPCacheEntry {
+	return HTTPCacheEntry{
+		URL:          meta.URL,
+		Size:         size,
+		StoredAt:     meta.StoredAt,
+		ExpiresAt:    freshUntil(meta.Header, meta.StoredAt),
+		ETag:         meta.Header.Get("ETag"),
+		LastModified: meta.Header.Get("Last-Modified"),
+		ContentType:  meta.Header.Get("Content-Type"),
+	}
+}
+
+// freshUntil computes the end of a response's freshness lifetime from
+// Cache-Control max-age or, failing that, Expires relative to Date.
+// Returns the zero time when the response must be revalidated on every use.
+func freshUntil(h http.Header, storedAt time.Time) time.Time {
+	if hasDirective(h, "no-cache") || hasDirective(h, "no-store") {
+		return time.Time{}
+	}
+	if v, ok := directiveValue(h, "max-age"); ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			return time.Time{}
+		}
+		return storedAt.Add(time.Duration(secs) * time.Second)
+	}
+	if exp := h.Get("Expires"); exp != "" {
+		expires, err := http.ParseTime(exp)
+		if err != nil {
+			return time.Time{}
+		}
+		// Expires is absolute in server time; translate through Date to
+		// tolerate clock skew between server and client.
+		if date, derr := http.ParseTime(h.Get("Date")); derr == nil {
+			return storedAt.Add(expires.Sub(date))
+		}
+		return expires
+	}
+	return time.Time{}
+}
+
+func hasDirective(h http.Header, name string) bool {
+	_, ok := directiveValue(h, name)
+	return ok
+}
+
+// directiveValue looks up a Cache-Control directive. Valueless directives
+// report ok with an empty value.
+func directiveValue(h http.Header, name string) (string, bool) {
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if strings.EqualFold(strings.TrimSpace(k), name) {
+				return strings.Trim(strings.TrimSpace(v), `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// doHTTP performs req and reads the whole body.
+func doHTTP(client *http.Client, req *http.Request) (*HTTPResponse, error) {
+	resp, err := client.Do(req) // #nosec G704 -- URL is user-provided, already validated by path resolver
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	return &HTTPResponse{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames
+// it into place, so concurrent readers never observe a partially-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
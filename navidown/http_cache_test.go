@@ -0,0 +1,410 @@
+package navidown
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestHTTPCache(t *testing.T, offline bool) *HTTPCache {
+	t.Helper()
+	c := NewHTTPCache(HTTPCacheOptions{CacheDir: t.TempDir(), Offline: offline})
+	if c == nil {
+		t.Fatal("NewHTTPCache returned nil")
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestHTTPCache_FreshHitSkipsNetwork(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_, _ = w.Write([]byte("# cached"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(nil, srv.URL)
+		if err != nil {
+			t.Fatalf("Get #%d: %v", i, err)
+		}
+		if string(resp.Body) != "# cached" {
+			t.Errorf("Get #%d body = %q", i, resp.Body)
+		}
+		if wantCached := i > 0; resp.FromCache != wantCached {
+			t.Errorf("Get #%d FromCache = %v, want %v", i, resp.FromCache, wantCached)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hits = %d, want 1", hits)
+	}
+}
+
+func TestHTTPCache_RevalidatesWithETag(t *testing.T) {
+	var conditional int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte("body-v1"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	if _, err := c.Get(nil, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conditional != 1 {
+		t.Errorf("conditional requests = %d, want 1", conditional)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "body-v1" || !resp.FromCache {
+		t.Errorf("revalidated response = %d %q fromCache=%v", resp.StatusCode, resp.Body, resp.FromCache)
+	}
+}
+
+func TestHTTPCache_RevalidatesWithLastModified(t *testing.T) {
+	lastMod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == lastMod {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastMod)
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	if _, err := c.Get(nil, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Get(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.FromCache || string(resp.Body) != "body" {
+		t.Errorf("expected 304 to serve cached body, got fromCache=%v body=%q", resp.FromCache, resp.Body)
+	}
+}
+
+func TestHTTPCache_NoStoreNotWritten(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	if _, err := c.Get(nil, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(srv.URL); ok {
+		t.Error("no-store response should not be cached")
+	}
+}
+
+func TestHTTPCache_PrivateNotWritten(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+		_, _ = w.Write([]byte("mine"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	if _, err := c.Get(nil, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(srv.URL); ok {
+		t.Error("private response should not be cached")
+	}
+}
+
+func TestHTTPCache_CredentialsBypassCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		if r.Header.Get("Authorization") == "" && r.Header.Get("Private-Token") == "" {
+			_, _ = w.Write([]byte("public"))
+			return
+		}
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	for _, name := range []string{"Authorization", "Cookie"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set(name, "x")
+		if _, err := c.Do(nil, req); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := c.Lookup(srv.URL); ok {
+			t.Errorf("response to a request with %s was cached", name)
+		}
+	}
+
+	// a custom header added by a resolver is not recognizable by name
+	creds := StaticCredentials{{Match: srv.URL + "/", Header: http.Header{"Private-Token": {"t"}}}}
+	client, req, err := AuthorizedRequest(nil, creds, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(client, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(srv.URL); ok {
+		t.Error("response to a request with resolver credentials was cached")
+	}
+
+	// a cached public response is not served to a credentialed request
+	if resp, err := c.Get(nil, srv.URL); err != nil || string(resp.Body) != "public" {
+		t.Fatalf("public get: %v", err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Authorization", "Bearer t")
+	if resp, err := c.Do(nil, req); err != nil || resp.FromCache || string(resp.Body) != "secret" {
+		t.Errorf("credentialed request served from cache: %+v, %v", resp, err)
+	}
+}
+
+func TestHTTPCache_Vary(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("lang=" + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	get := func(lang string) *HTTPResponse {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("Accept-Language", lang)
+		resp, err := c.Do(nil, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	get("en")
+	if resp := get("en"); !resp.FromCache {
+		t.Error("same Accept-Language not served from cache")
+	}
+	if resp := get("de"); resp.FromCache || string(resp.Body) != "lang=de" {
+		t.Errorf("other Accept-Language served %q from cache", resp.Body)
+	}
+	if hits != 2 {
+		t.Errorf("server hits = %d, want 2", hits)
+	}
+
+	star := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Vary", "*")
+		_, _ = w.Write([]byte("x"))
+	}))
+	defer star.Close()
+	if _, err := c.Get(nil, star.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Lookup(star.URL); ok {
+		t.Error("Vary: * response should not be cached")
+	}
+}
+
+func TestHTTPCache_Non200NotCached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	resp, err := c.Get(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+	if len(c.Entries()) != 0 {
+		t.Error("404 response should not be cached")
+	}
+}
+
+func TestHTTPCache_OfflineServesStale(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=0")
+		_, _ = w.Write([]byte("offline copy"))
+	}))
+	url := srv.URL
+
+	c := newTestHTTPCache(t, true)
+	if _, err := c.Get(nil, url); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	resp, err := c.Get(nil, url)
+	if err != nil {
+		t.Fatalf("offline Get: %v", err)
+	}
+	if !resp.Stale || !resp.FromCache || string(resp.Body) != "offline copy" {
+		t.Errorf("offline response = stale=%v fromCache=%v body=%q", resp.Stale, resp.FromCache, resp.Body)
+	}
+
+	c.SetOffline(false)
+	if _, err := c.Get(nil, url); err == nil {
+		t.Error("expected network error with offline fallback disabled")
+	}
+}
+
+func TestHTTPCache_OfflineServesStaleOn5xx(t *testing.T) {
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, true)
+	if _, err := c.Get(nil, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	down.Store(true)
+	resp, err := c.Get(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !resp.Stale {
+		t.Errorf("expected stale 200 on 502, got %d stale=%v", resp.StatusCode, resp.Stale)
+	}
+}
+
+func TestHTTPCache_ExpiresHeader(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Date", now.Format(http.TimeFormat))
+	h.Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+
+	storedAt := now.Add(5 * time.Minute) // client clock runs ahead
+	got := freshUntil(h, storedAt)
+	if want := storedAt.Add(time.Hour); !got.Equal(want) {
+		t.Errorf("freshUntil = %v, want %v", got, want)
+	}
+
+	h.Set("Cache-Control", "max-age=60")
+	if got := freshUntil(h, storedAt); !got.Equal(storedAt.Add(time.Minute)) {
+		t.Errorf("max-age should take precedence over Expires, got %v", got)
+	}
+}
+
+func TestHTTPCache_InspectAndEvict(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("ETag", `"e"`)
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return base }
+	if _, err := c.Get(nil, srv.URL+"/old.md"); err != nil {
+		t.Fatal(err)
+	}
+	c.now = func() time.Time { return base.Add(48 * time.Hour) }
+	if _, err := c.Get(nil, srv.URL+"/new.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, ok := c.Lookup(srv.URL + "/new.md")
+	if !ok {
+		t.Fatal("Lookup: entry missing")
+	}
+	if entry.Size != int64(len("/new.md")) || entry.ETag != `"e"` || entry.ContentType != "text/markdown; charset=utf-8" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if len(c.Entries()) != 2 {
+		t.Fatalf("Entries = %d, want 2", len(c.Entries()))
+	}
+
+	if n := c.EvictOlderThan(24 * time.Hour); n != 1 {
+		t.Errorf("EvictOlderThan removed %d, want 1", n)
+	}
+	if _, ok := c.Lookup(srv.URL + "/old.md"); ok {
+		t.Error("old entry should be evicted")
+	}
+
+	if !c.Evict(srv.URL + "/new.md") {
+		t.Error("Evict should report an existing entry")
+	}
+	if c.Evict(srv.URL + "/new.md") {
+		t.Error("Evict of missing entry should return false")
+	}
+
+	if _, err := c.Get(nil, srv.URL+"/a.md"); err != nil {
+		t.Fatal(err)
+	}
+	c.ClearCache()
+	if len(c.Entries()) != 0 {
+		t.Error("ClearCache should remove all entries")
+	}
+}
+
+func TestHTTPCache_NilIsUncached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("plain"))
+	}))
+	defer srv.Close()
+
+	var c *HTTPCache
+	resp, err := c.Get(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "plain" || resp.FromCache {
+		t.Errorf("nil cache response = %q fromCache=%v", resp.Body, resp.FromCache)
+	}
+	if c.Entries() != nil || c.Evict(srv.URL) {
+		t.Error("nil cache should report no entries")
+	}
+}
+
+func TestImageResolver_UsesHTTPCache(t *testing.T) {
+	var hits int32
+	pngBytes := make1x1PNG()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=600")
+		_, _ = w.Write(pngBytes)
+	}))
+	defer srv.Close()
+
+	c := newTestHTTPCache(t, false)
+	for i := 0; i < 2; i++ {
+		r := NewImageResolver(nil)
+		r.SetHTTPCache(c)
+		if _, err := r.Resolve(srv.URL+"/img.png", ""); err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hits = %d, want 1 (second resolver should hit disk cache)", hits)
+	}
+}
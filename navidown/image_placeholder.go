@@ -2,6 +2,7 @@ package navidown
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -21,12 +22,14 @@ type ImagePlaceholder struct {
 	URL     string // Original URL for reference
 }
 
-// imageFieldSep separates URL from alt text within the token.
+// imageFieldSep separates URL, alt text and the optional width within the
+// token.
 // Using a null byte since it can't appear in URLs or markdown text.
 const imageFieldSep = "\x00"
 
 // FormatImageToken creates the deferred placeholder token that glamour emits.
-// Format: \uFFF0IMG:<url>\x00<alt>\uFFF1
+// Format: \uFFF0IMG:<url>\x00<alt>\uFFF1, or \uFFF0IMG:<url>\x00<alt>\x00<width>\uFFF1
+// for an <img> tag with a width attribute.
 func FormatImageToken(url, altText string) string {
 	return ImageTokenStart + "IMG:" + url + imageFieldSep + altText + ImageTokenEnd
 }
@@ -49,9 +52,39 @@ func ParseImageToken(token string) (string, string, bool) {
 	if !found {
 		return inner, "", true
 	}
+	altText, _, _ = strings.Cut(altText, imageFieldSep)
 	return url, altText, true
 }
 
+// ImageTokenWidth returns the width attribute carried by a placeholder token,
+// or "" if it has none.
+func ImageTokenWidth(token string) string {
+	inner := strings.TrimSuffix(token, ImageTokenEnd)
+	if strings.Count(inner, imageFieldSep) < 2 {
+		return ""
+	}
+	return inner[strings.LastIndex(inner, imageFieldSep)+len(imageFieldSep):]
+}
+
+// ImageWidthColumns converts an HTML width attribute, in pixels ("120",
+// "120px") or a percentage ("50%"), to terminal columns, at most maxCols. It
+// returns maxCols if the width cannot be parsed.
+func ImageWidthColumns(width string, maxCols, cellWidthPx int) int {
+	width = strings.TrimSpace(width)
+	if pct, ok := strings.CutSuffix(width, "%"); ok {
+		n, err := strconv.ParseFloat(pct, 64)
+		if err != nil || n <= 0 {
+			return maxCols
+		}
+		return max(min(int(float64(maxCols)*n/100), maxCols), 1)
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(width, "px"))
+	if err != nil || n <= 0 || cellWidthPx <= 0 {
+		return maxCols
+	}
+	return max(min((n+cellWidthPx-1)/cellWidthPx, maxCols), 1)
+}
+
 // ContainsImageToken returns true if the line contains an image placeholder token.
 func ContainsImageToken(line string) bool {
 	return strings.Contains(line, ImageTokenStart+"IMG:")
@@ -53,6 +53,39 @@ func TestParseImageToken_Invalid(t *testing.T) {
 	}
 }
 
+func TestImageTokenWidth(t *testing.T) {
+	token := ImageTokenStart + "IMG:logo.png" + imageFieldSep + "Logo" + imageFieldSep + "50%" + ImageTokenEnd
+	url, alt, ok := ParseImageToken(token)
+	if !ok || url != "logo.png" || alt != "Logo" {
+		t.Fatalf("ParseImageToken = %q, %q, %v", url, alt, ok)
+	}
+	if got := ImageTokenWidth(token); got != "50%" {
+		t.Errorf("ImageTokenWidth = %q, want 50%%", got)
+	}
+	if got := ImageTokenWidth(FormatImageToken("logo.png", "Logo")); got != "" {
+		t.Errorf("ImageTokenWidth without width = %q", got)
+	}
+}
+
+func TestImageWidthColumns(t *testing.T) {
+	tests := []struct {
+		width string
+		want  int
+	}{
+		{"80", 10},
+		{"84px", 11},
+		{"50%", 40},
+		{"2000", 80},
+		{"auto", 80},
+		{"0", 80},
+	}
+	for _, tt := range tests {
+		if got := ImageWidthColumns(tt.width, 80, 8); got != tt.want {
+			t.Errorf("ImageWidthColumns(%q) = %d, want %d", tt.width, got, tt.want)
+		}
+	}
+}
+
 func TestContainsImageToken(t *testing.T) {
 	if ContainsImageToken("no image here") {
 		t.Error("should not detect image token in plain text")
@@ -7,7 +7,6 @@ import (
 	_ "image/gif"  // Register standard image decoders
 	_ "image/jpeg" // Register standard image decoders
 	"image/png"
-	"io"
 	"math"
 	"net/http"
 	"os"
@@ -39,6 +38,11 @@ type ImageResolver struct {
 	svgRasterWidth int
 	svgScaleFactor float64
 	darkMode       bool
+	httpCache      *HTTPCache
+	client         *http.Client
+	credentials    CredentialResolver
+	rewriter       URLRewriter
+	policy         *PathPolicy
 
 	// progressCB, if set, is called after each image resolves during
 	// PreResolve with (done, total). Set once before PreResolve runs.
@@ -78,6 +82,35 @@ func (r *ImageResolver) SetDarkMode(dm bool) {
 	r.darkMode = dm
 }
 
+// SetHTTPCache routes remote image fetches through the given on-disk HTTP
+// cache. Pass nil to fetch without caching.
+func (r *ImageResolver) SetHTTPCache(c *HTTPCache) {
+	r.httpCache = c
+}
+
+// SetHTTPClient sets the client used for remote image fetches; nil means
+// http.DefaultClient.
+func (r *ImageResolver) SetHTTPClient(client *http.Client) {
+	r.client = client
+}
+
+// SetCredentials attaches per-host authentication to remote image fetches.
+func (r *ImageResolver) SetCredentials(c CredentialResolver) {
+	r.credentials = c
+}
+
+// SetURLRewriter maps remote image URLs before fetching, e.g. forge blob
+// URLs to raw-content URLs.
+func (r *ImageResolver) SetURLRewriter(rw URLRewriter) {
+	r.rewriter = rw
+}
+
+// SetPathPolicy sets the policy deciding which local image files may be
+// read; nil means DefaultPathPolicy.
+func (r *ImageResolver) SetPathPolicy(p *PathPolicy) {
+	r.policy = p
+}
+
 // SetProgressCallback registers a per-item progress callback for PreResolve.
 // The callback receives (done, total) after each url resolves, where total is
 // the full url count for the PreResolve call. Set it before calling PreResolve.
@@ -228,7 +261,7 @@ func (r *ImageResolver) fetchImageBytes(url string, sourceFilePath string) ([]by
 		return r.fetchHTTP(url)
 	}
 
-	resolved, err := ResolveMarkdownPath(url, sourceFilePath, r.searchRoots)
+	resolved, err := ResolveMarkdownPathWithPolicy(url, sourceFilePath, r.searchRoots, r.policy)
 	if err != nil {
 		return nil, fmt.Errorf("resolve image path %q: %w", url, err)
 	}
@@ -241,17 +274,22 @@ func (r *ImageResolver) fetchImageBytes(url string, sourceFilePath string) ([]by
 }
 
 func (r *ImageResolver) fetchHTTP(url string) ([]byte, error) {
-	resp, err := http.Get(url) //nolint:gosec // URL is user-provided, already validated by path resolver
+	url = RewriteFetchURL(r.rewriter, url)
+	client, req, err := AuthorizedRequest(r.client, r.credentials, url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image: %w", err)
+	}
+
+	resp, err := r.httpCache.Do(client, req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch image: %w", err)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("fetch image: HTTP %d", resp.StatusCode)
 	}
 
-	return io.ReadAll(resp.Body)
+	return resp.Body, nil
 }
 
 func decodeImageInfo(data []byte) (*ImageInfo, error) {
@@ -0,0 +1,178 @@
+package navidown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+)
+
+// LineRange is an inclusive, 1-based range of source lines, as referenced by
+// URL fragments like #L42 or #L42-L60. The zero value means no range.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// IsZero reports whether r is the empty range.
+func (r LineRange) IsZero() bool { return r.Start <= 0 }
+
+var lineAnchorPattern = regexp.MustCompile(`^L(\d+)(?:-L?(\d+))?$`)
+
+// ParseLineAnchor parses a line fragment (without the leading '#') in the
+// forms used by GitHub, GitLab, and Gitea: "L42", "L42-L60", and "L42-60".
+// A reversed range is normalized.
+func ParseLineAnchor(fragment string) (LineRange, bool) {
+	m := lineAnchorPattern.FindStringSubmatch(strings.TrimPrefix(fragment, "#"))
+	if m == nil {
+		return LineRange{}, false
+	}
+	start, err := strconv.Atoi(m[1])
+	if err != nil || start < 1 {
+		return LineRange{}, false
+	}
+	end := start
+	if m[2] != "" {
+		if end, err = strconv.Atoi(m[2]); err != nil || end < 1 {
+			return LineRange{}, false
+		}
+	}
+	if end < start {
+		start, end = end, start
+	}
+	return LineRange{Start: start, End: end}, true
+}
+
+// lineHighlightReverse marks highlighted source lines with reverse video,
+// where no theme color applies.
+const lineHighlightReverse = "\x1b[7m"
+
+// lineHighlightSGR returns the sequence marking highlighted source lines: the
+// theme's background for highlighted code lines, bright black in the 16-color
+// profile, or reverse video without colors or a theme.
+func (v *MarkdownSession) lineHighlightSGR() string {
+	r, ok := v.renderer.(*ANSIStyleRenderer)
+	if !ok {
+		return lineHighlightReverse
+	}
+	switch r.ColorProfile() {
+	case ProfileANSI:
+		return "\x1b[100m"
+	case ProfileMonochrome:
+		return lineHighlightReverse
+	}
+	if mark := ansi.CodeHighlightBackground(r.glamourStyle.CodeBlock, r.ColorProfile().termenvProfile()); mark != "" {
+		return mark
+	}
+	return lineHighlightReverse
+}
+
+// fencedSourceCode returns the lines of a document that consists of a single
+// fenced code block, such as a source file wrapped for display. Line anchors
+// refer to these lines.
+func fencedSourceCode(markdown string) ([]string, bool) {
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(markdown, "\n"), "\n")
+	if len(lines) < 3 {
+		return nil, false
+	}
+	opener := strings.TrimLeft(lines[0], " ")
+	if opener == "" || (opener[0] != '`' && opener[0] != '~') {
+		return nil, false
+	}
+	fenceChar := opener[0]
+	n := 0
+	for n < len(opener) && opener[n] == fenceChar {
+		n++
+	}
+	if n < 3 {
+		return nil, false
+	}
+	if strings.TrimSpace(lines[len(lines)-1]) != opener[:n] {
+		return nil, false
+	}
+	return lines[1 : len(lines)-1], true
+}
+
+// sourceLineOffset returns the index of the rendered line showing the first
+// line of code, or -1 if the code block cannot be located. Code block lines
+// are truncated rather than wrapped, so source line n is at offset+n-1.
+func sourceLineOffset(code, rendered []string, cleaner LineCleaner) int {
+	for offset := range rendered {
+		if codeMatchesAt(code, rendered, offset, cleaner) {
+			return offset
+		}
+	}
+	return -1
+}
+
+// codeMatchesAt compares rendered lines at offset against the leading code
+// lines, stopping after a few non-blank matches.
+func codeMatchesAt(code, rendered []string, offset int, cleaner LineCleaner) bool {
+	nonBlank := 0
+	for i := 0; i < len(code) && nonBlank < 3; i++ {
+		if offset+i >= len(rendered) {
+			return false
+		}
+		src := strings.TrimSpace(strings.ReplaceAll(code[i], "\t", "    "))
+		if !codeLineMatches(cleaner.Clean(rendered[offset+i]), src) {
+			return false
+		}
+		if src != "" {
+			nonBlank++
+		}
+	}
+	return true
+}
+
+// codeLineMatches reports whether a cleaned rendered line shows src, allowing
+// for code block borders and truncation at the block width.
+func codeLineMatches(rendered, src string) bool {
+	shown := strings.TrimSpace(strings.Trim(strings.TrimSpace(rendered), "│"))
+	if src == "" {
+		return shown == ""
+	}
+	return shown != "" && strings.HasPrefix(src, shown)
+}
+
+// highlightLine paints a rendered line with mark, from lineHighlightSGR,
+// re-applying it after every SGR sequence that resets or replaces the
+// background.
+func highlightLine(line, mark string) string {
+	out := ansiSGRPattern.ReplaceAllStringFunc(line, func(seq string) string {
+		if sgrTouchesBackground(seq) {
+			return seq + mark
+		}
+		return seq
+	})
+	return mark + out + "\x1b[0m"
+}
+
+// sgrTouchesBackground reports whether an SGR sequence resets or sets the
+// background color.
+func sgrTouchesBackground(seq string) bool {
+	params := strings.Split(strings.TrimSuffix(strings.TrimPrefix(seq, "\x1b["), "m"), ";")
+	for i := 0; i < len(params); i++ {
+		p, err := strconv.Atoi(params[i])
+		if err != nil {
+			// empty parameter means 0 (reset)
+			if params[i] == "" {
+				return true
+			}
+			continue
+		}
+		switch {
+		case p == 0, p == 49, p == 48, p >= 40 && p <= 47, p >= 100 && p <= 107:
+			return true
+		case p == 38 || p == 58:
+			// skip extended color arguments so 38;5;48 is not read as 48
+			if i+1 < len(params) && params[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(params) && params[i+1] == "2" {
+				i += 4
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package navidown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseLineAnchor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want LineRange
+		ok   bool
+	}{
+		{"L42", LineRange{42, 42}, true},
+		{"#L42", LineRange{42, 42}, true},
+		{"L42-L60", LineRange{42, 60}, true},
+		{"L42-60", LineRange{42, 60}, true},
+		{"L60-L42", LineRange{42, 60}, true},
+		{"L0", LineRange{}, false},
+		{"installation", LineRange{}, false},
+		{"L12abc", LineRange{}, false},
+		{"", LineRange{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseLineAnchor(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseLineAnchor(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func sourceFixture(lines int) string {
+	var b strings.Builder
+	b.WriteString("```go\n")
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&b, "var line%02d = %d\n", i, i)
+	}
+	b.WriteString("```\n")
+	return b.String()
+}
+
+func TestScrollToSourceLines(t *testing.T) {
+	for _, width := range []int{0, 60} {
+		t.Run(fmt.Sprintf("width=%d", width), func(t *testing.T) {
+			s := New(Options{})
+			s.SetWidth(width)
+			if err := s.SetMarkdownWithSource(sourceFixture(40), "/tmp/x.go", false); err != nil {
+				t.Fatal(err)
+			}
+
+			if !s.ScrollToSourceLines(LineRange{Start: 20, End: 22}, 5, false) {
+				t.Fatal("ScrollToSourceLines failed")
+			}
+			if got := s.LineHighlight(); got != (LineRange{20, 22}) {
+				t.Errorf("LineHighlight = %v", got)
+			}
+
+			lines := s.RenderedLines()
+			top := s.ScrollOffset()
+			if !strings.Contains(stripANSIAndMarkers(lines[top]), "var line20") {
+				t.Errorf("scrolled to %q, want line 20", stripANSIAndMarkers(lines[top]))
+			}
+			mark := s.lineHighlightSGR()
+			for i, line := range lines {
+				highlighted := strings.Contains(line, mark)
+				want := i >= top && i < top+3
+				if highlighted != want {
+					t.Errorf("line %d %q highlighted=%v, want %v", i, stripANSIAndMarkers(line), highlighted, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScrollToSourceLines_SurvivesResizeAndHistory(t *testing.T) {
+	s := New(Options{})
+	s.SetWidth(60)
+	_ = s.SetMarkdownWithSource(sourceFixture(30), "/tmp/x.go", false)
+	if !s.ScrollToSourceLines(LineRange{Start: 3, End: 3}, 5, false) {
+		t.Fatal("ScrollToSourceLines failed")
+	}
+
+	s.SetWidth(50)
+	if countHighlighted(s) != 1 {
+		t.Error("highlight should be re-applied after re-render")
+	}
+
+	_ = s.SetMarkdownWithSource("# Other\n", "/tmp/other.md", true)
+	if !s.LineHighlight().IsZero() || countHighlighted(s) != 0 {
+		t.Error("loading a new document should clear the highlight")
+	}
+
+	s.GoBack()
+	if s.LineHighlight() != (LineRange{3, 3}) {
+		t.Errorf("GoBack should restore highlight, got %v", s.LineHighlight())
+	}
+	s.SetWidth(40)
+	if countHighlighted(s) != 1 {
+		t.Error("restored highlight should survive re-render")
+	}
+}
+
+func TestScrollToSourceLines_Rejects(t *testing.T) {
+	s := New(Options{})
+	_ = s.SetMarkdownWithSource("# Title\n\nparagraph\n", "/tmp/a.md", false)
+	if s.ScrollToSourceLines(LineRange{Start: 1, End: 1}, 5, false) {
+		t.Error("markdown documents are not source views")
+	}
+
+	_ = s.SetMarkdownWithSource(sourceFixture(5), "/tmp/x.go", false)
+	if s.ScrollToSourceLines(LineRange{Start: 9, End: 9}, 5, false) {
+		t.Error("range past end of file should fail")
+	}
+	if !s.ScrollToSourceLines(LineRange{Start: 4, End: 99}, 5, false) || s.LineHighlight().End != 5 {
+		t.Errorf("range end should clamp to file length, got %v", s.LineHighlight())
+	}
+}
+
+func TestSGRTouchesBackground(t *testing.T) {
+	tests := map[string]bool{
+		"\x1b[0m":          true,
+		"\x1b[m":           true,
+		"\x1b[49m":         true,
+		"\x1b[48;5;236m":   true,
+		"\x1b[1;42m":       true,
+		"\x1b[38;5;48m":    false,
+		"\x1b[38;2;1;2;3m": false,
+		"\x1b[39;22;23m":   false,
+	}
+	for seq, want := range tests {
+		if got := sgrTouchesBackground(seq); got != want {
+			t.Errorf("sgrTouchesBackground(%q) = %v, want %v", seq, got, want)
+		}
+	}
+}
+
+func TestContentFetcher_LineAnchor(t *testing.T) {
+	session := New(Options{})
+	cf := NewContentFetcher(recordingProvider{content: sourceFixture(20)}, nil)
+
+	err := cf.OnSelect(session, NavElement{Type: NavElementURL, URL: "https://example.com/x.go#L10-L12"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.SourceFilePath() != "https://example.com/x.go" {
+		t.Errorf("source path should exclude fragment, got %q", session.SourceFilePath())
+	}
+	if session.LineHighlight() != (LineRange{10, 12}) {
+		t.Errorf("LineHighlight = %v", session.LineHighlight())
+	}
+}
+
+func TestContentFetcher_SameDocumentAnchorInViewport(t *testing.T) {
+	session := New(Options{})
+	if err := session.SetMarkdownWithSource("# Top\n\ntext\n\n## Near\n", "/tmp/doc.md", false); err != nil {
+		t.Fatal(err)
+	}
+	cf := NewContentFetcher(recordingProvider{}, nil)
+
+	// the header is already visible, so nothing scrolls and no history is kept
+	if err := cf.OnSelectInViewport(session, NavElement{Type: NavElementURL, URL: "#near"}, 20); err != nil {
+		t.Fatal(err)
+	}
+	if session.ScrollOffset() != 0 || session.CanGoBack() {
+		t.Errorf("offset = %d, CanGoBack = %v; want 0, false", session.ScrollOffset(), session.CanGoBack())
+	}
+}
+
+func countHighlighted(s *MarkdownSession) int {
+	n := 0
+	mark := s.lineHighlightSGR()
+	for _, line := range s.RenderedLines() {
+		if strings.Contains(line, mark) {
+			n++
+		}
+	}
+	return n
+}
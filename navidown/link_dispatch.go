@@ -0,0 +1,329 @@
+package navidown
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// LinkAction says what a LinkDispatcher does with an activated link.
+type LinkAction int
+
+const (
+	LinkLoad         LinkAction = iota // fetch and show in the viewer
+	LinkOpenExternal                   // hand to an external opener (browser, mail client, ...)
+	LinkCallback                       // call the rule's Handler
+	LinkIgnore                         // do nothing
+)
+
+// ErrNoLinkHandler is returned when a LinkCallback rule has no Handler.
+var ErrNoLinkHandler = errors.New("link rule has no handler")
+
+// LinkRule routes links matching all of its non-empty criteria to Action.
+type LinkRule struct {
+	// Schemes matches the link's scheme, e.g. "mailto" or "https". "" matches
+	// local (scheme-less) links.
+	Schemes []string
+	// Extensions matches the target's file extension, e.g. ".pdf".
+	Extensions []string
+	// ContentTypes matches the media type of http(s) targets, e.g.
+	// "text/html" or "image/*". Evaluating it requires LinkDispatcher.Probe;
+	// without one, rules with ContentTypes never match.
+	ContentTypes []string
+
+	Action LinkAction
+	// Opener overrides the dispatcher's opener for LinkOpenExternal.
+	Opener Opener
+	// Handler is called for LinkCallback with the link and its resolved target.
+	Handler func(elem NavElement, target string) error
+}
+
+// DefaultLinkRules opens mailto: and tel: links and http(s) links to
+// non-text documents externally, loads other http(s) and local links in the
+// viewer, and ignores all other schemes. A link in an untrusted document must
+// not reach the system opener with file:, smb: or an application's custom
+// scheme, or start an installer; add rules ahead of these to allow that.
+var DefaultLinkRules = []LinkRule{
+	{Schemes: []string{"mailto", "tel"}, Action: LinkOpenExternal},
+	{
+		Schemes: []string{"http", "https"},
+		Extensions: []string{
+			".pdf", ".zip", ".tar", ".gz", ".tgz", ".7z", ".rar",
+			".mp3", ".mp4", ".mov", ".avi", ".mkv", ".webm", ".wav", ".flac",
+			".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".odt", ".ods", ".odp",
+		},
+		Action: LinkOpenExternal,
+	},
+	{Schemes: []string{"", "http", "https"}, Action: LinkLoad},
+	{Action: LinkIgnore},
+}
+
+// LinkDispatcher decides per link whether to load it in the viewer, open it
+// externally, or call a host callback. Rules are tried in order and the
+// first match wins; a link matching no rule is loaded.
+type LinkDispatcher struct {
+	Rules []LinkRule
+	// Opener opens external links; nil means SystemOpener().
+	Opener Opener
+	// Probe returns the Content-Type of an http(s) URL, e.g. via a HEAD
+	// request. It is only called when a rule needs it.
+	Probe func(rawURL string) (string, error)
+	// SearchRoots and Policy resolve relative links, as for ResolveMarkdownPathWithPolicy.
+	SearchRoots []string
+	Policy      *PathPolicy
+}
+
+// NewLinkDispatcher returns a dispatcher using rules followed by DefaultLinkRules.
+func NewLinkDispatcher(rules ...LinkRule) *LinkDispatcher {
+	return &LinkDispatcher{Rules: append(append([]LinkRule(nil), rules...), DefaultLinkRules...)}
+}
+
+// Dispatch routes elem according to the rules. It returns handled=true when
+// the link was opened externally, passed to a callback, or ignored; when
+// handled is false the caller should load the link in the viewer. Links that
+// cannot be resolved are left to the caller, whose loader reports the error.
+func (d *LinkDispatcher) Dispatch(elem NavElement) (handled bool, err error) {
+	if d == nil || elem.Type != NavElementURL || elem.IsInternalLink() {
+		return false, nil
+	}
+	target, err := d.target(elem)
+	if err != nil {
+		return false, nil
+	}
+
+	rule, ok := d.match(target)
+	if !ok {
+		return false, nil
+	}
+	switch rule.Action {
+	case LinkOpenExternal:
+		return true, d.open(rule.Opener, target)
+	case LinkCallback:
+		if rule.Handler == nil {
+			return true, ErrNoLinkHandler
+		}
+		return true, rule.Handler(elem, target)
+	case LinkIgnore:
+		return true, nil
+	}
+	return false, nil
+}
+
+// OpenExternal opens elem with the dispatcher's opener regardless of rules.
+func (d *LinkDispatcher) OpenExternal(elem NavElement) error {
+	target, err := d.target(elem)
+	if err != nil {
+		return err
+	}
+	return d.open(nil, target)
+}
+
+func (d *LinkDispatcher) open(o Opener, target string) error {
+	if o == nil {
+		o = d.Opener
+	}
+	if o == nil {
+		o = SystemOpener()
+	}
+	return o.Open(target)
+}
+
+// target returns the URL or absolute path a link refers to. Relative links
+// are resolved against the source document; fragments are kept for URLs.
+func (d *LinkDispatcher) target(elem NavElement) (string, error) {
+	if linkScheme(elem.URL) != "" {
+		return elem.URL, nil
+	}
+	linkPath, fragment, _ := strings.Cut(elem.URL, "#")
+	resolved, err := ResolveMarkdownPathWithPolicy(linkPath, elem.SourceFilePath, d.SearchRoots, d.Policy)
+	if err != nil {
+		return "", err
+	}
+	if resolved == "" {
+		return "", ErrFileNotFound
+	}
+	if fragment != "" && isHTTPURL(resolved) {
+		resolved += "#" + fragment
+	}
+	return resolved, nil
+}
+
+// match returns the first rule matching target.
+func (d *LinkDispatcher) match(target string) (LinkRule, bool) {
+	scheme := linkScheme(target)
+	ext := targetExtension(target, scheme)
+
+	probed := false
+	contentType := ""
+	for _, rule := range d.Rules {
+		if len(rule.Schemes) > 0 && !containsFold(rule.Schemes, scheme) {
+			continue
+		}
+		if len(rule.Extensions) > 0 && !containsFold(rule.Extensions, ext) {
+			continue
+		}
+		if len(rule.ContentTypes) > 0 {
+			if !probed {
+				probed = true
+				if d.Probe != nil && isHTTPScheme(scheme) {
+					contentType, _ = d.Probe(target)
+				}
+			}
+			if !matchContentType(rule.ContentTypes, contentType) {
+				continue
+			}
+		}
+		return rule, true
+	}
+	return LinkRule{}, false
+}
+
+// linkScheme returns the lowercased URL scheme of s, or "" for local paths
+// (including Windows drive letters like C:\).
+func linkScheme(s string) string {
+	i := strings.IndexByte(s, ':')
+	if i < 2 {
+		return ""
+	}
+	for j, c := range s[:i] {
+		isAlpha := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isAlpha && (j == 0 || !(c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.')) {
+			return ""
+		}
+	}
+	return strings.ToLower(s[:i])
+}
+
+func targetExtension(target, scheme string) string {
+	if scheme == "" {
+		return strings.ToLower(filepath.Ext(target))
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(path.Ext(u.Path))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchContentType matches a Content-Type header against media type
+// patterns such as "text/html" or "image/*".
+func matchContentType(patterns []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if p == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Opener opens a URL or file path outside the viewer.
+type Opener interface {
+	Open(target string) error
+}
+
+// OpenerFunc adapts a function to the Opener interface.
+type OpenerFunc func(target string) error
+
+func (f OpenerFunc) Open(target string) error { return f(target) }
+
+// CommandOpener runs a command, substituting the target for "{url}" in its
+// arguments (or appending it when no argument mentions {url}). The command
+// is started without a shell and not waited for.
+type CommandOpener struct {
+	Args []string
+}
+
+// ParseCommandOpener splits a template such as "firefox --new-tab {url}" on
+// whitespace. Quoting is not supported.
+func ParseCommandOpener(template string) (CommandOpener, error) {
+	args := strings.Fields(template)
+	if len(args) == 0 {
+		return CommandOpener{}, errors.New("empty opener command")
+	}
+	return CommandOpener{Args: args}, nil
+}
+
+func (c CommandOpener) Open(target string) error {
+	if len(c.Args) == 0 {
+		return errors.New("empty opener command")
+	}
+	// a target starting with '-' would be parsed as an option
+	if strings.HasPrefix(target, "-") {
+		return fmt.Errorf("refusing to open %q", target)
+	}
+	args := make([]string, 0, len(c.Args)+1)
+	substituted := false
+	for _, a := range c.Args[1:] {
+		if strings.Contains(a, "{url}") {
+			a = strings.ReplaceAll(a, "{url}", target)
+			substituted = true
+		}
+		args = append(args, a)
+	}
+	if !substituted {
+		args = append(args, target)
+	}
+	cmd := exec.Command(c.Args[0], args...) // #nosec G204 -- opener command is configured by the user
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("open %q: %w", target, err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
+
+// SystemOpener returns the platform's opener: the commands in $BROWSER
+// (colon-separated, "%s" marks the URL) when it names any, otherwise open on
+// macOS, the URL protocol handler on Windows, and xdg-open elsewhere.
+func SystemOpener() Opener {
+	var openers []CommandOpener
+	for _, entry := range strings.Split(os.Getenv("BROWSER"), string(os.PathListSeparator)) {
+		if o, err := ParseCommandOpener(strings.ReplaceAll(entry, "%s", "{url}")); err == nil {
+			openers = append(openers, o)
+		}
+	}
+	if len(openers) > 0 {
+		return OpenerFunc(func(target string) error {
+			var errs []error
+			for _, o := range openers {
+				err := o.Open(target)
+				if err == nil {
+					return nil
+				}
+				errs = append(errs, err)
+			}
+			return errors.Join(errs...)
+		})
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return CommandOpener{Args: []string{"open"}}
+	case "windows":
+		return CommandOpener{Args: []string{"rundll32", "url.dll,FileProtocolHandler"}}
+	}
+	return CommandOpener{Args: []string{"xdg-open"}}
+}
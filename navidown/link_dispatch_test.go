@@ -0,0 +1,229 @@
+package navidown
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type recordingOpener struct{ opened []string }
+
+func (r *recordingOpener) Open(target string) error {
+	r.opened = append(r.opened, target)
+	return nil
+}
+
+func link(url, source string) NavElement {
+	return NavElement{Type: NavElementURL, URL: url, SourceFilePath: source}
+}
+
+func TestLinkDispatcher_DefaultRules(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "README.md")
+	for _, name := range []string{"guide.md", "manual.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		elem    NavElement
+		handled bool
+		opened  string
+	}{
+		{name: "mailto", elem: link("mailto:dev@example.com", source), handled: true, opened: "mailto:dev@example.com"},
+		{name: "tel", elem: link("tel:+15550100", source), handled: true, opened: "tel:+15550100"},
+		{name: "remote pdf", elem: link("https://example.com/a.pdf?x=1", source), handled: true, opened: "https://example.com/a.pdf?x=1"},
+		{name: "local pdf", elem: link("manual.pdf", source), handled: false},
+		{name: "file scheme", elem: link("file:///etc/passwd", source), handled: true},
+		{name: "smb scheme", elem: link("smb://host/share/setup.exe", source), handled: true},
+		{name: "custom scheme", elem: link("foo:bar", source), handled: true},
+		{name: "app scheme", elem: link("vscode://file/x", source), handled: true},
+		{name: "remote installer", elem: link("https://example.com/setup.exe", source), handled: false},
+		{name: "remote markdown", elem: link("https://example.com/doc.md", source), handled: false},
+		{name: "remote page", elem: link("https://example.com/page", source), handled: false},
+		{name: "local markdown", elem: link("guide.md", source), handled: false},
+		{name: "missing local file", elem: link("missing.pdf", source), handled: false},
+		{name: "internal anchor", elem: link("#usage", source), handled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opener := &recordingOpener{}
+			d := NewLinkDispatcher()
+			d.Opener = opener
+			handled, err := d.Dispatch(tt.elem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if handled != tt.handled {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+			if tt.opened != "" && (len(opener.opened) != 1 || opener.opened[0] != tt.opened) {
+				t.Errorf("opened %v, want %q", opener.opened, tt.opened)
+			}
+			if tt.opened == "" && len(opener.opened) != 0 {
+				t.Errorf("unexpected open of %v", opener.opened)
+			}
+		})
+	}
+}
+
+func TestLinkDispatcher_ContentTypeRule(t *testing.T) {
+	opener := &recordingOpener{}
+	probes := 0
+	d := NewLinkDispatcher(LinkRule{
+		Schemes:      []string{"https"},
+		ContentTypes: []string{"text/html"},
+		Action:       LinkOpenExternal,
+	})
+	d.Opener = opener
+	d.Probe = func(rawURL string) (string, error) {
+		probes++
+		if rawURL == "https://example.com/page" {
+			return "text/html; charset=utf-8", nil
+		}
+		return "text/markdown", nil
+	}
+
+	if handled, _ := d.Dispatch(link("https://example.com/page", "")); !handled {
+		t.Error("HTML page should open externally")
+	}
+	if handled, _ := d.Dispatch(link("https://example.com/raw", "")); handled {
+		t.Error("markdown response should load in the viewer")
+	}
+	if handled, _ := d.Dispatch(link("mailto:a@b.c", "")); !handled {
+		t.Error("mailto should still open externally")
+	}
+	if probes != 2 {
+		t.Errorf("probes = %d, want 2 (only http(s) links are probed)", probes)
+	}
+}
+
+func TestLinkDispatcher_RelativeLinkInRemoteDocument(t *testing.T) {
+	opener := &recordingOpener{}
+	d := NewLinkDispatcher()
+	d.Opener = opener
+	handled, err := d.Dispatch(link("../files/spec.pdf#page=2", "https://example.com/docs/index.md"))
+	if err != nil || !handled {
+		t.Fatalf("handled=%v err=%v", handled, err)
+	}
+	if want := "https://example.com/files/spec.pdf#page=2"; opener.opened[0] != want {
+		t.Errorf("opened %q, want %q", opener.opened[0], want)
+	}
+}
+
+func TestLinkDispatcher_Callback(t *testing.T) {
+	var got string
+	d := NewLinkDispatcher(LinkRule{
+		Schemes: []string{"tiki"},
+		Action:  LinkCallback,
+		Handler: func(elem NavElement, target string) error {
+			got = target
+			return nil
+		},
+	})
+	if handled, err := d.Dispatch(link("tiki:TASK-12", "")); !handled || err != nil {
+		t.Fatalf("handled=%v err=%v", handled, err)
+	}
+	if got != "tiki:TASK-12" {
+		t.Errorf("callback target = %q", got)
+	}
+
+	d = NewLinkDispatcher(LinkRule{Schemes: []string{"tiki"}, Action: LinkCallback})
+	if _, err := d.Dispatch(link("tiki:TASK-12", "")); !errors.Is(err, ErrNoLinkHandler) {
+		t.Errorf("expected ErrNoLinkHandler, got %v", err)
+	}
+}
+
+func TestLinkDispatcher_NilLoadsEverything(t *testing.T) {
+	var d *LinkDispatcher
+	if handled, err := d.Dispatch(link("mailto:a@b.c", "")); handled || err != nil {
+		t.Errorf("nil dispatcher: handled=%v err=%v", handled, err)
+	}
+}
+
+func TestLinkScheme(t *testing.T) {
+	tests := map[string]string{
+		"https://x":       "https",
+		"MAILTO:a@b":      "mailto",
+		"git+ssh://h/r":   "git+ssh",
+		"docs/guide.md":   "",
+		`C:\docs\a.md`:    "",
+		"../a.md":         "",
+		"1abc:not-scheme": "",
+	}
+	for in, want := range tests {
+		if got := linkScheme(in); got != want {
+			t.Errorf("linkScheme(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCommandOpener_Substitution(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := filepath.Join(dir, "opener.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := ParseCommandOpener(script + " --new-tab {url}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Open("https://example.com"); err != nil {
+		t.Fatal(err)
+	}
+	waitForFile(t, out, "--new-tab https://example.com\n")
+
+	if err := o.Open("--evil"); err == nil {
+		t.Error("targets starting with '-' should be refused")
+	}
+}
+
+func TestSystemOpener_EmptyBrowser(t *testing.T) {
+	// $BROWSER without a usable command falls back to the platform opener
+	t.Setenv("BROWSER", string(os.PathListSeparator))
+	if _, ok := SystemOpener().(CommandOpener); !ok {
+		t.Errorf("SystemOpener() = %T, want the platform CommandOpener", SystemOpener())
+	}
+}
+
+func TestContentFetcher_DispatchesExternalLinks(t *testing.T) {
+	opener := &recordingOpener{}
+	d := NewLinkDispatcher()
+	d.Opener = opener
+
+	session := New(Options{})
+	_ = session.SetMarkdownWithSource("# Home\n", "/tmp/home.md", false)
+	cf := NewContentFetcher(recordingProvider{}, nil)
+	cf.SetLinkDispatcher(d)
+
+	if err := cf.OnSelect(session, link("mailto:dev@example.com", "/tmp/home.md")); err != nil {
+		t.Fatal(err)
+	}
+	if len(opener.opened) != 1 || session.Markdown() != "# Home\n" {
+		t.Errorf("mailto should open externally and leave the page, opened=%v", opener.opened)
+	}
+}
+
+// waitForFile polls for an asynchronously written file with the given content.
+func waitForFile(t *testing.T, path, want string) {
+	t.Helper()
+	var got []byte
+	for i := 0; i < 100; i++ {
+		got, _ = os.ReadFile(path)
+		if string(got) == want {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("%s = %q, want %q", path, got, want)
+}
@@ -5,12 +5,16 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"unicode"
 
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
 // hexHashPNG matches exactly a 64-char lowercase hex SHA256 hash with .png extension.
@@ -38,6 +42,35 @@ func generateSlug(text string) string {
 	return result.String()
 }
 
+// slugger assigns heading anchors in document order, numbering repeated
+// slugs like GitHub does ("intro", "intro-1", ...).
+type slugger map[string]int
+
+func (s slugger) slug(text string) string {
+	base := generateSlug(text)
+	count := s[base]
+	s[base]++
+	if count > 0 {
+		return fmt.Sprintf("%s-%d", base, count)
+	}
+	return base
+}
+
+// headingText returns the text of a heading's direct text children, the
+// text its slug is generated from.
+func headingText(n *ast.Heading, source []byte) string {
+	var b strings.Builder
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		switch textNode := child.(type) {
+		case *ast.Text:
+			b.Write(textNode.Segment.Value(source))
+		case *ast.String:
+			b.Write(textNode.Value)
+		}
+	}
+	return b.String()
+}
+
 // MarkdownSession is a UI-agnostic navigable markdown state machine that serves as a model
 // for UI components, remembering original Markdown, rendered text, links and scrolling position
 
@@ -47,6 +80,7 @@ func generateSlug(text string) string {
 // - render markdown via Renderer and keep a cleaner for matching
 // - correlate element positions in rendered output
 // - track selection, scroll offset, and history
+// - track horizontal offsets of content wider than the viewport
 // - expose navigation methods and read accessors
 // - accept UI-driven actions to update scroll/selection/history on interaction
 type MarkdownSession struct {
@@ -55,7 +89,10 @@ type MarkdownSession struct {
 	currentSourceFile string
 	renderedLines     []string
 	cleaner           LineCleaner
+	renderedSourceMap *SourceMap // from the renderer, before image post-processing
+	sourceMap         *SourceMap // for renderedLines
 	elements          []NavElement
+	detailsOpen       map[int]bool // <details> blocks opened or closed by the user
 
 	// navigation
 	selectedIndex int
@@ -71,18 +108,30 @@ type MarkdownSession struct {
 	// rendering
 	currentWidth int // 0 means no wrapping
 
+	// horizontal scrolling of content wider than currentWidth
+	hscroll    HScrollMode
+	hOffset    int // document offset in HScrollDocument mode
+	wideBlocks []wideBlock
+
+	// right-to-left text is left in logical order (see reorderBidi)
+	logicalOrder bool
+
 	// behavior
 	alwaysScrollToAnchor bool
 
+	// source line range highlighted by ScrollToSourceLines
+	lineHighlight LineRange
+
 	// image support
 	imagePostProcessor ImagePostProcessor
 	preImageLines      []string // cached lines before image post-processing (for re-processing on cell size change)
 
-	// mermaid support
-	mermaidRenderer *MermaidRenderer
-
-	// graphviz support
-	graphvizRenderer *GraphvizRenderer
+	// diagram renderers by fence language (mermaid, graphviz, ...)
+	diagrams            *DiagramRegistry
+	diagramFailureMode  DiagramFailureMode
+	diagramSourceOpen   map[string]bool // sources of failed diagrams shown, by diagramKey
+	diagramErrors       []DiagramError  // failures of the current document
+	diagramErrorHandler func(DiagramError)
 }
 
 // Options configures a markdownSession.
@@ -107,6 +156,24 @@ type Options struct {
 	// PNG via the dot CLI and inserted as images before parsing/rendering.
 	// If dot is not found, graphviz support is silently disabled.
 	GraphvizOptions *GraphvizOptions
+	// Diagrams, when non-nil, renders the fenced blocks of the languages
+	// registered in it; the session takes it over and closes it. Mermaid
+	// and graphviz renderers from the options above are registered in it.
+	// Renderers can also be added later through Diagrams.
+	Diagrams *DiagramRegistry
+	// DiagramFailureMode tells what diagram blocks that fail to render
+	// show; by default they stay code blocks.
+	DiagramFailureMode DiagramFailureMode
+	// DiagramErrorHandler, if set, is called for each diagram block that
+	// fails to render, on every render (see SetDiagramErrorHandler).
+	DiagramErrorHandler func(DiagramError)
+	// HorizontalScroll renders tables and code blocks at their natural
+	// width and scrolls them sideways (see SetHorizontalScroll).
+	HorizontalScroll HScrollMode
+	// LogicalOrder leaves right-to-left text (Arabic, Hebrew) in logical
+	// order, for terminals that reorder bidirectional text themselves. By
+	// default such lines are reordered for display.
+	LogicalOrder bool
 }
 
 // New creates a new markdownSession.
@@ -124,53 +191,64 @@ func New(opts Options) *MarkdownSession {
 		hmax = 50
 	}
 
-	var mermaid *MermaidRenderer
-	if opts.MermaidOptions != nil {
-		mermaid = NewMermaidRenderer(*opts.MermaidOptions)
-		// mermaid is nil if mmdc not found — silent degradation
+	diagrams := opts.Diagrams
+	if diagrams == nil {
+		diagrams = NewDiagramRegistry()
 	}
 
-	var graphviz *GraphvizRenderer
-	if opts.GraphvizOptions != nil {
-		graphviz = NewGraphvizRenderer(*opts.GraphvizOptions)
-		// graphviz is nil if dot not found — silent degradation
-	}
-
-	return &MarkdownSession{
+	v := &MarkdownSession{
 		selectedIndex:        -1,
 		scrollOffset:         0,
 		history:              NewNavigationHistory[PageState](hmax),
 		renderer:             renderer,
 		correlator:           correlator,
 		alwaysScrollToAnchor: opts.AlwaysScrollToAnchor,
+		hscroll:              opts.HorizontalScroll,
+		logicalOrder:         opts.LogicalOrder,
 		imagePostProcessor:   opts.ImagePostProcessor,
-		mermaidRenderer:      mermaid,
-		graphvizRenderer:     graphviz,
-	}
+		diagrams:             diagrams,
+		diagramFailureMode:   opts.DiagramFailureMode,
+		diagramErrorHandler:  opts.DiagramErrorHandler,
+	}
+	// the renderers are nil if mmdc or dot is not found — silent degradation
+	v.SetMermaidOptions(opts.MermaidOptions)
+	v.SetGraphvizOptions(opts.GraphvizOptions)
+	return v
 }
 
-// ClearCaches flushes all diagram renderer caches (mermaid and graphviz),
-// both in-memory and on disk. Call before re-rendering to force fresh output.
+// Diagrams returns the registry of diagram renderers, where renderers for
+// further fence languages can be registered.
+func (v *MarkdownSession) Diagrams() *DiagramRegistry {
+	return v.diagrams
+}
+
+// ClearCaches flushes all diagram renderer caches (mermaid, graphviz and any
+// registered renderer implementing DiagramCacher), both in-memory and on
+// disk, and the failures remembered for diagram blocks. Call before
+// re-rendering to force fresh output.
 func (v *MarkdownSession) ClearCaches() {
-	if v.mermaidRenderer != nil {
-		v.mermaidRenderer.ClearCache()
-	}
-	if v.graphvizRenderer != nil {
-		v.graphvizRenderer.ClearCache()
-	}
+	v.diagrams.ClearCaches()
 }
 
 // ClearCachesForDocument evicts only the diagram cache entries used by
-// the currently loaded document. Other documents' cached diagrams are preserved.
+// the currently loaded document, and forgets its failed diagrams so they
+// are tried again. Other documents' cached diagrams are preserved.
 func (v *MarkdownSession) ClearCachesForDocument() {
-	if v.mermaidRenderer != nil {
-		keys := diagramKeysForRenderer(v.elements, v.mermaidRenderer.WorkDir())
-		v.mermaidRenderer.EvictKeys(keys)
-	}
-	if v.graphvizRenderer != nil {
-		keys := diagramKeysForRenderer(v.elements, v.graphvizRenderer.WorkDir())
-		v.graphvizRenderer.EvictKeys(keys)
+	v.diagrams.EvictImages(v.elements)
+	v.diagrams.forgetFailures(v.diagramErrors)
+}
+
+// keepCachedImages tells the cache manager which cached images (diagrams
+// and rasterized SVGs) the document shows, so pruning leaves them for
+// ReprocessImages.
+func (v *MarkdownSession) keepCachedImages() {
+	var paths []string
+	for _, elem := range v.elements {
+		if elem.Type == NavElementImage && hexHashPNG.MatchString(filepath.Base(elem.URL)) {
+			paths = append(paths, elem.URL)
+		}
 	}
+	SharedCacheManager().setInUse(v, paths)
 }
 
 // diagramKeysForRenderer extracts cache keys from image elements whose URL
@@ -198,42 +276,55 @@ func diagramKeysForRenderer(elements []NavElement, workDir string) []string {
 	return keys
 }
 
-// Close releases resources held by the session (e.g., mermaid/graphviz temp files).
+// Close releases resources held by the session (e.g., mermaid/graphviz temp files)
+// and writes its cache hit and miss counts to the shared statistics.
 func (v *MarkdownSession) Close() {
-	if v.mermaidRenderer != nil {
-		v.mermaidRenderer.Close()
-	}
-	if v.graphvizRenderer != nil {
-		v.graphvizRenderer.Close()
-	}
+	v.diagrams.Close()
+	SharedCacheManager().setInUse(v, nil)
+	_ = SharedCacheManager().Flush()
 }
 
 // SetMermaidOptions enables or disables mermaid diagram rendering.
 // Pass nil to disable. If mmdc is not found, mermaid is silently disabled.
 // Closes any existing mermaid renderer before replacing it.
 func (v *MarkdownSession) SetMermaidOptions(opts *MermaidOptions) {
-	if v.mermaidRenderer != nil {
-		v.mermaidRenderer.Close()
-		v.mermaidRenderer = nil
-	}
-	if opts != nil {
-		v.mermaidRenderer = NewMermaidRenderer(*opts)
+	if opts == nil {
+		v.diagrams.Unregister("mermaid")
+		return
 	}
+	v.diagrams.Register("mermaid", NewMermaidRenderer(*opts), mermaidLanguages...)
 }
 
 // SetGraphvizOptions enables or disables graphviz diagram rendering.
 // Pass nil to disable. If dot is not found, graphviz is silently disabled.
 // Closes any existing graphviz renderer before replacing it.
 func (v *MarkdownSession) SetGraphvizOptions(opts *GraphvizOptions) {
-	if v.graphvizRenderer != nil {
-		v.graphvizRenderer.Close()
-		v.graphvizRenderer = nil
-	}
-	if opts != nil {
-		v.graphvizRenderer = NewGraphvizRenderer(*opts)
+	if opts == nil {
+		v.diagrams.Unregister("graphviz")
+		return
 	}
+	v.diagrams.Register("graphviz", NewGraphvizRenderer(*opts), graphvizLanguages...)
+}
+
+// SetDiagramFailureMode sets what diagram blocks that fail to render show,
+// from the next render on.
+func (v *MarkdownSession) SetDiagramFailureMode(mode DiagramFailureMode) {
+	v.diagramFailureMode = mode
+}
+
+// SetDiagramErrorHandler sets a function called for each diagram block that
+// fails to render, such as to log it. Failures are remembered until
+// ClearCaches or ClearCachesForDocument, and each render (including on a
+// change of width) reports them again without running the tool. Pass nil
+// to remove it.
+func (v *MarkdownSession) SetDiagramErrorHandler(h func(DiagramError)) {
+	v.diagramErrorHandler = h
 }
 
+// DiagramErrors returns the diagram blocks of the current document that
+// failed to render, in document order.
+func (v *MarkdownSession) DiagramErrors() []DiagramError { return v.diagramErrors }
+
 // SetImagePostProcessor sets the image post-processor for rendering.
 func (v *MarkdownSession) SetImagePostProcessor(p ImagePostProcessor) {
 	v.imagePostProcessor = p
@@ -267,6 +358,13 @@ func (v *MarkdownSession) CurrentWidth() int { return v.currentWidth }
 // RenderedLines returns all rendered lines.
 func (v *MarkdownSession) RenderedLines() []string { return v.renderedLines }
 
+// SourceMap returns the map from RenderedLines back to the markdown source,
+// or nil if the renderer does not make one. Offsets and lines are those of
+// the markdown as rendered, which differs from Markdown where diagrams were
+// replaced by images or closed <details> blocks were cut; columns are those
+// before right-to-left text is reordered.
+func (v *MarkdownSession) SourceMap() *SourceMap { return v.sourceMap }
+
 // Elements returns all navigable elements.
 func (v *MarkdownSession) Elements() []NavElement { return v.elements }
 
@@ -320,15 +418,7 @@ func (v *MarkdownSession) SetWidth(cols int) bool {
 
 	// Save state for scroll restoration after re-render
 	selectedElem := v.Selected()
-	var anchorElem *NavElement
-	if v.scrollOffset >= 0 && v.scrollOffset < len(v.renderedLines) {
-		anchorElem = v.findElementNearLine(v.scrollOffset)
-		// Don't use anchor element if it had invalid position (from width=0 render).
-		// This prevents jumping to wrong scroll position when width changes from 0.
-		if anchorElem != nil && anchorElem.EndCol <= anchorElem.StartCol {
-			anchorElem = nil
-		}
-	}
+	anchorElem := v.scrollAnchor()
 
 	oldWidth := v.currentWidth
 	v.currentWidth = cols
@@ -345,23 +435,37 @@ func (v *MarkdownSession) SetWidth(cols int) bool {
 }
 
 func (v *MarkdownSession) reRenderWithWidth(cols int) error {
-	processed := v.preprocessForRender(v.markdown)
-	rendered, err := v.rendererForWidth(cols).Render(processed)
+	processed, failures := v.preprocessForRender(v.markdown, v.detailsOpen)
+	rendered, err := v.rendererFor(cols, v.currentSourceFile).Render(processed)
 	if err != nil {
 		return err
 	}
 
+	v.diagramErrors = failures
 	v.renderedLines = rendered.Lines
 	v.setCleaner(rendered.Cleaner)
+	v.renderedSourceMap = rendered.SourceMap
+	v.applyLineHighlight()
 	v.postProcessImages()
 	v.correlatePositions()
+	v.reorderBidi()
+	v.measureWideBlocks()
 	return nil
 }
 
-func (v *MarkdownSession) rendererForWidth(cols int) Renderer {
-	if cols > 0 {
-		if ansiRenderer, ok := v.renderer.(*ANSIStyleRenderer); ok {
-			return ansiRenderer.WithWordWrap(cols)
+// rendererFor adapts the session's renderer to the wrap width and to the
+// document at sourcePath, which hyperlinks are resolved against.
+func (v *MarkdownSession) rendererFor(cols int, sourcePath string) Renderer {
+	switch r := v.renderer.(type) {
+	case *ANSIStyleRenderer:
+		r = r.WithSource(sourcePath).WithWideBlocks(v.hscroll != HScrollOff)
+		if cols > 0 {
+			r = r.WithWordWrap(cols)
+		}
+		return r
+	case *PlainTextRenderer:
+		if cols > 0 {
+			return r.WithWordWrap(cols)
 		}
 	}
 	return v.renderer
@@ -374,6 +478,21 @@ func (v *MarkdownSession) setCleaner(c LineCleaner) {
 	}
 }
 
+// scrollAnchor returns the element the view is scrolled to, which
+// restoreScrollAndSelection scrolls back to after a re-render.
+func (v *MarkdownSession) scrollAnchor() *NavElement {
+	if v.scrollOffset < 0 || v.scrollOffset >= len(v.renderedLines) {
+		return nil
+	}
+	anchorElem := v.findElementNearLine(v.scrollOffset)
+	// Don't use anchor element if it had invalid position (from width=0 render).
+	// This prevents jumping to wrong scroll position when width changes from 0.
+	if anchorElem != nil && anchorElem.EndCol <= anchorElem.StartCol {
+		return nil
+	}
+	return anchorElem
+}
+
 func (v *MarkdownSession) findElementNearLine(lineIdx int) *NavElement {
 	if len(v.elements) == 0 {
 		return nil
@@ -411,7 +530,7 @@ func (v *MarkdownSession) restoreScrollAndSelection(anchorElem, selectedElem *Na
 		}
 	}
 
-	if selectedElem != nil && selectedElem.Type == NavElementURL {
+	if selectedElem != nil && selectedElem.focusable() {
 		for i := range v.elements {
 			if v.elementsMatch(&v.elements[i], selectedElem) {
 				v.selectedIndex = i
@@ -439,9 +558,20 @@ func (v *MarkdownSession) elementsMatch(e1, e2 *NavElement) bool {
 	return e1.URL == e2.URL && e1.Text == e2.Text
 }
 
-func (v *MarkdownSession) preprocessForRender(markdown string) string {
-	result := preprocessMermaid(markdown, v.mermaidRenderer)
-	return preprocessGraphviz(result, v.graphvizRenderer)
+// preprocessForRender prepares markdown for parsing and rendering, and
+// returns the diagram blocks that failed to render, located in markdown.
+func (v *MarkdownSession) preprocessForRender(markdown string, detailsOpen map[int]bool) (string, []DiagramError) {
+	// closed <details> blocks go first, so diagrams inside them are not
+	// rendered
+	result := preprocessDetails(markdown, detailsOpen)
+	processed, failures := v.diagrams.preprocess(result, v.diagramFailureMode, v.diagramSourceOpen)
+	for i := range failures {
+		failures[i].Line = originalLine(result, markdown, failures[i].Line)
+		if v.diagramErrorHandler != nil {
+			v.diagramErrorHandler(failures[i])
+		}
+	}
+	return processed, failures
 }
 
 // SetMarkdown loads markdown. If pushToHistory is true, it stores the current page in back history first.
@@ -452,13 +582,20 @@ func (v *MarkdownSession) SetMarkdown(content string) error {
 // SetMarkdownWithSource loads markdown with source file context.
 // State is only mutated if rendering succeeds, ensuring the viewer remains valid on error.
 func (v *MarkdownSession) SetMarkdownWithSource(content string, sourceFilePath string, pushToHistory bool) error {
-	// preprocess mermaid blocks before parsing/rendering
-	processed := v.preprocessForRender(content)
+	// the open and closed <details> blocks are kept when the same file is
+	// loaded again
+	detailsOpen := v.detailsOpen
+	if sourceFilePath == "" || sourceFilePath != v.currentSourceFile {
+		detailsOpen = nil
+	}
+
+	// preprocess diagram blocks before parsing/rendering
+	processed, failures := v.preprocessForRender(content, detailsOpen)
 
 	// Parse and render BEFORE mutating state to ensure atomicity
 	tmpElements := v.parseMarkdownWithSource([]byte(processed), sourceFilePath)
 
-	rendered, err := v.rendererForWidth(v.currentWidth).Render(processed)
+	rendered, err := v.rendererFor(v.currentWidth, sourceFilePath).Render(processed)
 	if err != nil {
 		return err // Nothing mutated, viewer still valid
 	}
@@ -471,12 +608,18 @@ func (v *MarkdownSession) SetMarkdownWithSource(content string, sourceFilePath s
 	// Mutate state atomically - all operations succeeded
 	v.markdown = content
 	v.currentSourceFile = sourceFilePath
+	v.detailsOpen = detailsOpen
+	v.diagramErrors = failures
 	v.elements = tmpElements
 	v.renderedLines = rendered.Lines
 	v.setCleaner(rendered.Cleaner)
+	v.renderedSourceMap = rendered.SourceMap
+	v.lineHighlight = LineRange{}
 
 	v.postProcessImages()
 	v.correlatePositions()
+	v.reorderBidi()
+	v.resetHorizontalScroll()
 
 	v.selectedIndex = -1
 	v.scrollOffset = 0
@@ -484,12 +627,16 @@ func (v *MarkdownSession) SetMarkdownWithSource(content string, sourceFilePath s
 }
 
 func (v *MarkdownSession) parseMarkdownWithSource(source []byte, sourceFilePath string) []NavElement {
-	md := goldmark.New()
+	// raw HTML is rewritten as by the renderer, so <a> and <img> tags are
+	// navigable too
+	md := goldmark.New(goldmark.WithParserOptions(
+		parser.WithASTTransformers(util.Prioritized(ansi.HTMLTransformer{}, 100)),
+	))
 	reader := text.NewReader(source)
 	doc := md.Parser().Parse(reader)
 
 	var elements []NavElement
-	slugCounts := make(map[string]int)
+	slugs := slugger{}
 
 	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
 		if !entering {
@@ -498,34 +645,35 @@ func (v *MarkdownSession) parseMarkdownWithSource(source []byte, sourceFilePath
 
 		switch n := node.(type) {
 		case *ast.Heading:
-			var headingText strings.Builder
-			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
-				if textNode, ok := child.(*ast.Text); ok {
-					headingText.Write(textNode.Segment.Value(source))
-				}
-			}
-			text := headingText.String()
-			baseSlug := generateSlug(text)
-			count := slugCounts[baseSlug]
-			slugCounts[baseSlug]++
-
-			slug := baseSlug
-			if count > 0 {
-				slug = fmt.Sprintf("%s-%d", baseSlug, count)
-			}
-
+			text := headingText(n, source)
 			elements = append(elements, NavElement{
 				Type:           NavElementHeader,
 				Text:           text,
 				Level:          n.Level,
-				Slug:           slug,
+				Slug:           slugs.slug(text),
 				SourceFilePath: sourceFilePath,
+				sourceOffset:   ansi.SourceOffset(n),
 			})
 		case *ast.Link:
+			if attr, ok := n.AttributeString(ansi.DetailsAttribute); ok {
+				// the summary of a <details> block, as made by the transformer
+				id, _ := attr.([]byte)
+				elements = append(elements, NavElement{
+					Type:           NavElementDetails,
+					Text:           strings.TrimLeft(string(n.Text(source)), "▸▾ "), //nolint: staticcheck
+					URL:            detailsPrefix + string(id),
+					SourceFilePath: sourceFilePath,
+					sourceOffset:   ansi.SourceOffset(n),
+				})
+				return ast.WalkSkipChildren, nil
+			}
 			var linkText strings.Builder
 			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
-				if textNode, ok := child.(*ast.Text); ok {
+				switch textNode := child.(type) {
+				case *ast.Text:
 					linkText.Write(textNode.Segment.Value(source))
+				case *ast.String:
+					linkText.Write(textNode.Value)
 				}
 			}
 			elements = append(elements, NavElement{
@@ -533,6 +681,7 @@ func (v *MarkdownSession) parseMarkdownWithSource(source []byte, sourceFilePath
 				Text:           linkText.String(),
 				URL:            string(n.Destination),
 				SourceFilePath: sourceFilePath,
+				sourceOffset:   ansi.SourceOffset(n),
 			})
 		case *ast.AutoLink:
 			elements = append(elements, NavElement{
@@ -540,6 +689,7 @@ func (v *MarkdownSession) parseMarkdownWithSource(source []byte, sourceFilePath
 				Text:           string(n.URL(source)),
 				URL:            string(n.URL(source)),
 				SourceFilePath: sourceFilePath,
+				sourceOffset:   ansi.SourceOffset(n),
 			})
 		case *ast.Image:
 			altText := string(n.Text(source)) //nolint: staticcheck
@@ -561,6 +711,9 @@ func (v *MarkdownSession) parseMarkdownWithSource(source []byte, sourceFilePath
 // If an ImagePostProcessor is configured, it delegates to that.
 // Otherwise, image tokens are replaced with fallback alt text.
 func (v *MarkdownSession) postProcessImages() {
+	defer func() { v.sourceMap = v.renderedSourceMap.remap(v.preImageLines, v.renderedLines) }()
+	v.keepCachedImages()
+
 	if len(v.renderedLines) == 0 {
 		v.preImageLines = nil
 		return
@@ -607,6 +760,8 @@ func (v *MarkdownSession) ReprocessImages() bool {
 	copy(v.renderedLines, v.preImageLines)
 	v.postProcessImages()
 	v.correlatePositions()
+	v.reorderBidi()
+	v.measureWideBlocks()
 	return true
 }
 
@@ -615,6 +770,12 @@ func (v *MarkdownSession) correlatePositions() {
 		return
 	}
 
+	// the source map places what it can; the correlator the rest
+	mapped := v.correlateFromSourceMap()
+	if mapped != nil && !slices.Contains(mapped, false) {
+		return
+	}
+
 	// Reset marker correlator state if applicable
 	if mc, ok := v.correlator.(*MarkerCorrelator); ok {
 		mc.Reset()
@@ -635,7 +796,7 @@ func (v *MarkdownSession) correlatePositions() {
 	for i := range v.elements {
 		elem := &v.elements[i]
 		lineIdx, startCol, endCol, found := v.correlator.CorrelatePosition(elem, v.renderedLines, v.cleaner)
-		if found {
+		if found && (mapped == nil || !mapped[i]) {
 			correlations[i] = correlation{
 				found:    true,
 				elemIdx:  i,
@@ -698,7 +859,11 @@ func (v *MarkdownSession) saveCurrentState() PageState {
 		RenderedLines:  linesCopy,
 		PreImageLines:  preImageCopy,
 		Cleaner:        v.cleaner,
+		SourceMap:      v.renderedSourceMap,
 		Width:          v.currentWidth,
+		LineHighlight:  v.lineHighlight,
+		DetailsOpen:    v.detailsOpen,
+		DiagramErrors:  v.diagramErrors,
 	}
 }
 
@@ -707,12 +872,17 @@ func (v *MarkdownSession) restoreState(state PageState) {
 	v.currentSourceFile = state.SourceFilePath
 	v.scrollOffset = state.ScrollOffset
 	v.currentWidth = state.Width
+	v.lineHighlight = state.LineHighlight
+	v.detailsOpen = state.DetailsOpen
+	v.diagramErrors = state.DiagramErrors
 
 	v.elements = make([]NavElement, len(state.Elements))
 	copy(v.elements, state.Elements)
+	v.keepCachedImages()
 	v.renderedLines = make([]string, len(state.RenderedLines))
 	copy(v.renderedLines, state.RenderedLines)
 	v.setCleaner(state.Cleaner)
+	v.resetHorizontalScroll()
 
 	if len(state.PreImageLines) > 0 {
 		v.preImageLines = make([]string, len(state.PreImageLines))
@@ -720,6 +890,8 @@ func (v *MarkdownSession) restoreState(state PageState) {
 	} else {
 		v.preImageLines = nil
 	}
+	v.renderedSourceMap = state.SourceMap
+	v.sourceMap = v.renderedSourceMap.remap(v.preImageLines, v.renderedLines)
 
 	v.selectedIndex = state.SelectedIndex
 	if v.selectedIndex < 0 || v.selectedIndex >= len(v.elements) {
@@ -872,6 +1044,7 @@ func (v *MarkdownSession) ensureVisible(viewportHeight int) {
 	if v.scrollOffset < 0 {
 		v.scrollOffset = 0
 	}
+	v.revealColumns(elem)
 }
 
 // MoveToNextLink moves selection to the next link element.
@@ -882,7 +1055,7 @@ func (v *MarkdownSession) MoveToNextLink(viewportHeight int) bool {
 
 	if v.selectedIndex >= 0 {
 		for i := v.selectedIndex + 1; i < len(v.elements); i++ {
-			if v.elements[i].Type == NavElementURL && v.elements[i].EndCol > v.elements[i].StartCol {
+			if v.elements[i].focusable() && v.elements[i].EndCol > v.elements[i].StartCol {
 				v.selectedIndex = i
 				v.ensureVisible(viewportHeight)
 				return true
@@ -892,7 +1065,7 @@ func (v *MarkdownSession) MoveToNextLink(viewportHeight int) bool {
 	}
 
 	for i := 0; i < len(v.elements); i++ {
-		if v.elements[i].Type == NavElementURL &&
+		if v.elements[i].focusable() &&
 			v.elements[i].StartLine >= v.scrollOffset &&
 			v.elements[i].StartLine < v.scrollOffset+viewportHeight &&
 			v.elements[i].EndCol > v.elements[i].StartCol {
@@ -912,7 +1085,7 @@ func (v *MarkdownSession) MoveToPreviousLink(viewportHeight int) bool {
 
 	if v.selectedIndex >= 0 {
 		for i := v.selectedIndex - 1; i >= 0; i-- {
-			if v.elements[i].Type == NavElementURL && v.elements[i].EndCol > v.elements[i].StartCol {
+			if v.elements[i].focusable() && v.elements[i].EndCol > v.elements[i].StartCol {
 				v.selectedIndex = i
 				v.ensureVisible(viewportHeight)
 				return true
@@ -923,7 +1096,7 @@ func (v *MarkdownSession) MoveToPreviousLink(viewportHeight int) bool {
 
 	viewportBottom := v.scrollOffset + viewportHeight - 1
 	for i := len(v.elements) - 1; i >= 0; i-- {
-		if v.elements[i].Type == NavElementURL &&
+		if v.elements[i].focusable() &&
 			v.elements[i].StartLine >= v.scrollOffset &&
 			v.elements[i].StartLine <= viewportBottom &&
 			v.elements[i].EndCol > v.elements[i].StartCol {
@@ -941,7 +1114,7 @@ func (v *MarkdownSession) MoveToFirst(viewportHeight int) bool {
 		return false
 	}
 	for i := 0; i < len(v.elements); i++ {
-		if v.elements[i].Type == NavElementURL && v.elements[i].EndCol > v.elements[i].StartCol {
+		if v.elements[i].focusable() && v.elements[i].EndCol > v.elements[i].StartCol {
 			if v.selectedIndex != i {
 				v.selectedIndex = i
 				v.ensureVisible(viewportHeight)
@@ -959,7 +1132,7 @@ func (v *MarkdownSession) MoveToLast(viewportHeight int) bool {
 		return false
 	}
 	for i := len(v.elements) - 1; i >= 0; i-- {
-		if v.elements[i].Type == NavElementURL && v.elements[i].EndCol > v.elements[i].StartCol {
+		if v.elements[i].focusable() && v.elements[i].EndCol > v.elements[i].StartCol {
 			if v.selectedIndex != i {
 				v.selectedIndex = i
 				v.ensureVisible(viewportHeight)
@@ -1016,3 +1189,70 @@ func (v *MarkdownSession) ScrollToAnchor(slug string, viewportHeight int, pushTo
 
 	return true
 }
+
+// LineHighlight returns the source line range highlighted by
+// ScrollToSourceLines, or the zero range if none.
+func (v *MarkdownSession) LineHighlight() LineRange { return v.lineHighlight }
+
+// ScrollToSourceLines highlights a range of source lines and scrolls to its
+// first line. It applies to documents that are a single fenced code block,
+// such as source files wrapped by a loader, where r refers to lines of the
+// code. The range is clamped to the end of the code.
+// If pushToHistory is true, saves the current position to back history first.
+// Returns false if the document is not a source view or r is out of range.
+func (v *MarkdownSession) ScrollToSourceLines(r LineRange, viewportHeight int, pushToHistory bool) bool {
+	code, ok := fencedSourceCode(v.markdown)
+	if !ok || r.IsZero() || r.Start > len(code) {
+		return false
+	}
+	r.End = min(max(r.End, r.Start), len(code))
+
+	offset := sourceLineOffset(code, v.renderedLines, v.cleaner)
+	if offset < 0 {
+		return false
+	}
+
+	if pushToHistory {
+		v.history.Push(v.saveCurrentState())
+	}
+
+	v.lineHighlight = r
+	if err := v.reRenderWithWidth(v.currentWidth); err != nil {
+		return false
+	}
+
+	v.scrollOffset = offset + r.Start - 1
+	maxOffset := len(v.renderedLines) - viewportHeight
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+	if v.scrollOffset > maxOffset {
+		v.scrollOffset = maxOffset
+	}
+	v.clearSelectionIfOffScreen(viewportHeight)
+	return true
+}
+
+// applyLineHighlight paints the highlighted source lines in freshly rendered
+// output. Highlighting only adds SGR sequences, so correlation is unaffected.
+func (v *MarkdownSession) applyLineHighlight() {
+	if v.lineHighlight.IsZero() {
+		return
+	}
+	code, ok := fencedSourceCode(v.markdown)
+	if !ok {
+		return
+	}
+	offset := sourceLineOffset(code, v.renderedLines, v.cleaner)
+	if offset < 0 {
+		return
+	}
+	mark := v.lineHighlightSGR()
+	for n := v.lineHighlight.Start; n <= v.lineHighlight.End; n++ {
+		idx := offset + n - 1
+		if idx >= len(v.renderedLines) {
+			return
+		}
+		v.renderedLines[idx] = highlightLine(v.renderedLines[idx], mark)
+	}
+}
@@ -413,6 +413,37 @@ func TestViewer_ParsesAutoLink(t *testing.T) {
 	}
 }
 
+func TestViewer_ParsesHTMLLinksAndImages(t *testing.T) {
+	v := New(Options{})
+	_ = v.SetMarkdown(`See <a href="https://example.com">the <b>site</b></a> and <a href="javascript:x()">this</a>.
+
+<div><h2>Raw title</h2><img src="logo.png" alt="Logo"></div>`)
+
+	var link, header, image *NavElement
+	for i, elem := range v.Elements() {
+		switch elem.Type {
+		case NavElementURL:
+			if link != nil {
+				t.Fatalf("unsafe link parsed: %#v", elem)
+			}
+			link = &v.Elements()[i]
+		case NavElementHeader:
+			header = &v.Elements()[i]
+		case NavElementImage:
+			image = &v.Elements()[i]
+		}
+	}
+	if link == nil || link.URL != "https://example.com" || link.StartCol == link.EndCol {
+		t.Fatalf("expected a positioned link for <a>, got %#v", link)
+	}
+	if header == nil || header.Text != "Raw title" || header.Level != 2 {
+		t.Fatalf("expected a header for <h2>, got %#v", header)
+	}
+	if image == nil || image.URL != "logo.png" || image.Text != "Logo" {
+		t.Fatalf("expected an image for <img>, got %#v", image)
+	}
+}
+
 func TestGenerateSlug(t *testing.T) {
 	tests := []struct {
 		input    string
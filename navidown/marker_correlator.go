@@ -58,7 +58,8 @@ func (mc *MarkerCorrelator) CorrelatePosition(elem *NavElement, renderedLines []
 	}
 
 	switch elem.Type {
-	case NavElementURL:
+	case NavElementURL, NavElementDetails:
+		// details summaries are rendered as links
 		lineIdx, startCol, endCol, found := mc.correlateLinkPosition()
 		if found {
 			return lineIdx, startCol, endCol, true
@@ -176,7 +176,8 @@ func findEndOfMarkedContent(runes []rune, startIdx int, endMarker string) int {
 }
 
 // calculateVisualColumn calculates the visual column at a given rune index.
-// This excludes ANSI codes and marker characters from the count.
+// This excludes ANSI codes and marker characters from the count, and counts
+// wide characters as two columns.
 func calculateVisualColumn(runes []rune, targetIdx int, cleaner LineCleaner) int {
 	// Build the string up to targetIdx, clean it, and count visible characters
 	var sb strings.Builder
@@ -190,13 +191,6 @@ func calculateVisualColumn(runes []rune, targetIdx int, cleaner LineCleaner) int
 		cleaned = cleaner.Clean(cleaned)
 	}
 
-	// Remove marker characters and count remaining
-	col := 0
-	for _, r := range cleaned {
-		if !IsMarkerRune(r) {
-			col++
-		}
-	}
-
-	return col
+	// Markers take no cells; wide characters take two
+	return stringColumns(cleaned)
 }
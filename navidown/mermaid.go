@@ -12,7 +12,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
@@ -245,11 +244,8 @@ func configDataWithFontSize(theme, fontSize string) []byte {
 
 // MermaidRenderer renders mermaid source code to PNG files using mmdc.
 type MermaidRenderer struct {
+	diagramCache
 	opts            MermaidOptions
-	cache           sync.Map // cache key hex -> absolute PNG path
-	persistentDir   string   // persistent cache (never deleted by Close)
-	tempDir         string   // temp fallback (deleted by Close); "" if persistent worked
-	workDir         string   // whichever dir is actually used
 	mmdcPath        string
 	configPath      string        // path to config JSON written to workDir
 	configData      []byte        // resolved config content (used in cache key)
@@ -369,10 +365,8 @@ func NewMermaidRenderer(opts MermaidOptions) *MermaidRenderer {
 	}
 
 	return &MermaidRenderer{
+		diagramCache:    diagramCache{persistentDir: persistentDir, tempDir: tempDir, workDir: workDir},
 		opts:            opts,
-		persistentDir:   persistentDir,
-		tempDir:         tempDir,
-		workDir:         workDir,
 		mmdcPath:        mmdcPath,
 		configPath:      configPath,
 		configData:      configData,
@@ -567,17 +561,9 @@ func (r *MermaidRenderer) RenderToFile(source string) (string, error) {
 	key := r.cacheKey(source)
 	outputPath := filepath.Join(r.workDir, key+".png")
 
-	// check in-memory cache
-	if cached, ok := r.cache.Load(key); ok {
-		if path, ok := cached.(string); ok {
-			return path, nil
-		}
-	}
-
-	// check disk cache
-	if _, err := os.Stat(outputPath); err == nil {
-		r.cache.Store(key, outputPath)
-		return outputPath, nil
+	// check in-memory, then disk cache
+	if path, ok := r.lookup(key, outputPath); ok {
+		return path, nil
 	}
 
 	inputPath := filepath.Join(r.workDir, key+".mmd")
@@ -600,7 +586,7 @@ func (r *MermaidRenderer) RenderToFile(source string) (string, error) {
 			return "", err
 		}
 		_ = os.Remove(inputPath)
-		r.cache.Store(key, pngPath)
+		r.store(key, pngPath)
 		return pngPath, nil
 	}
 
@@ -622,13 +608,13 @@ func (r *MermaidRenderer) RenderToFile(source string) (string, error) {
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("mmdc failed: %w\n%s", err, string(out))
+		return "", &DiagramToolError{Tool: "mmdc", Err: err, Output: string(out)}
 	}
 
 	// clean up .mmd input file
 	_ = os.Remove(inputPath)
 
-	r.cache.Store(key, outputPath)
+	r.store(key, outputPath)
 	return outputPath, nil
 }
 
@@ -650,7 +636,7 @@ func (r *MermaidRenderer) renderViaResvg(ctx context.Context, inputPath, outputP
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("mmdc (svg) failed: %w\n%s", err, string(out))
+		return "", &DiagramToolError{Tool: "mmdc (svg)", Err: err, Output: string(out)}
 	}
 
 	svgData, err := os.ReadFile(svgPath)
@@ -804,65 +790,5 @@ func (r *MermaidRenderer) naturalWidth(_ context.Context, inputPath, cfgPath, cs
 	return min(natural, compactCap)
 }
 
-// ClearCache flushes the in-memory cache and removes disk-cached PNGs.
-// Config and CSS files in the work directory are preserved.
-func (r *MermaidRenderer) ClearCache() {
-	r.cache.Range(func(key, _ any) bool { r.cache.Delete(key); return true })
-	entries, _ := os.ReadDir(r.workDir)
-	for _, e := range entries {
-		if strings.HasSuffix(e.Name(), ".png") {
-			_ = os.Remove(filepath.Join(r.workDir, e.Name()))
-		}
-	}
-}
-
-// WorkDir returns the cache working directory.
-func (r *MermaidRenderer) WorkDir() string { return r.workDir }
-
-// EvictKeys removes specific cache entries by key (hex hash, no extension).
-func (r *MermaidRenderer) EvictKeys(keys []string) {
-	for _, key := range keys {
-		r.cache.Delete(key)
-		_ = os.Remove(filepath.Join(r.workDir, key+".png"))
-	}
-}
-
-// Close releases resources. Only removes the temp dir (if used as fallback).
-// Persistent cache directories are preserved for future sessions.
-func (r *MermaidRenderer) Close() {
-	if r.tempDir != "" {
-		_ = os.RemoveAll(r.tempDir)
-	}
-}
-
-// mermaidFenceRe matches the opening of a mermaid fenced code block.
-var mermaidFenceRe = regexp.MustCompile("^(\\s*`{3,})mermaid\\s*$")
-
-// extractMermaidBlocks scans markdown lines for ```mermaid fences and returns
-// the split lines along with the identified blocks (source + positions).
-func extractMermaidBlocks(markdown string) ([]string, []diagramBlock) {
-	return extractDiagramBlocks(markdown, mermaidFenceRe)
-}
-
-// reassembleMermaid rebuilds markdown from lines, substituting rendered blocks.
-// Blocks missing from the rendered map are preserved as original fenced code.
-func reassembleMermaid(lines []string, blocks []diagramBlock, rendered map[int]string) string {
-	return reassembleDiagram(lines, blocks, rendered, "mermaid diagram")
-}
-
-// preprocessMermaid scans raw markdown for ```mermaid blocks and replaces them
-// with ![mermaid diagram](path.png) using the given renderer.
-// Blocks are rendered in parallel. On render error, the original code block is preserved.
-func preprocessMermaid(markdown string, renderer *MermaidRenderer) string {
-	if renderer == nil {
-		return markdown
-	}
-
-	lines, blocks := extractDiagramBlocks(markdown, mermaidFenceRe)
-	if len(blocks) == 0 {
-		return markdown
-	}
-
-	rendered := renderDiagramBlocks(blocks, renderer)
-	return reassembleDiagram(lines, blocks, rendered, "mermaid diagram")
-}
+// mermaidLanguages are the fence languages of mermaid blocks.
+var mermaidLanguages = []string{"mermaid"}
@@ -75,8 +75,8 @@ func TestProfile_ParallelTimeline(t *testing.T) {
 	renderer.rasterizer = nil
 	t.Cleanup(renderer.Close)
 
-	// manually call extractMermaidBlocks + parallel render with timestamps
-	lines, blocks := extractMermaidBlocks(markdown)
+	// manually extract the blocks + parallel render with timestamps
+	lines, blocks := extractDiagramBlocks(markdown, diagramFenceRe(mermaidLanguages...))
 	if len(blocks) != nBlocks {
 		t.Fatalf("expected %d blocks, got %d", nBlocks, len(blocks))
 	}
@@ -105,7 +105,7 @@ func TestProfile_ParallelTimeline(t *testing.T) {
 	}
 	wg.Wait()
 
-	result := reassembleMermaid(lines, blocks, results)
+	result := reassembleDiagram(lines, blocks, mermaidImages(results))
 	imgCount := strings.Count(result, "![mermaid diagram](")
 	if imgCount != nBlocks {
 		t.Fatalf("expected %d images, got %d", nBlocks, imgCount)
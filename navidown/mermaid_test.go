@@ -166,7 +166,7 @@ func TestPreprocessMermaid_DetectsBlocks(t *testing.T) {
 	renderer := newTestMermaidRenderer(t)
 
 	md := "# Title\n\n```mermaid\ngraph TD\n    A-->B\n```\n\nSome text.\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	if strings.Contains(result, "```mermaid") {
 		t.Error("mermaid fence should have been replaced")
@@ -190,7 +190,7 @@ func TestPreprocessMermaid_PreservesNonMermaid(t *testing.T) {
 	renderer := newTestMermaidRenderer(t)
 
 	md := "```go\nfunc main() {}\n```\n\n```python\nprint('hello')\n```\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	if result != md {
 		t.Errorf("non-mermaid blocks should be untouched.\ngot:  %q\nwant: %q", result, md)
@@ -201,7 +201,7 @@ func TestPreprocessMermaid_MixedContent(t *testing.T) {
 	renderer := newTestMermaidRenderer(t)
 
 	md := "# Intro\n\n```go\nfunc main() {}\n```\n\n```mermaid\nsequenceDiagram\n    A->>B: Hello\n```\n\nEnd.\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	// go block preserved
 	if !strings.Contains(result, "```go") {
@@ -229,7 +229,7 @@ func TestPreprocessMermaid_ErrorPreservesBlock(t *testing.T) {
 	t.Cleanup(renderer.Close)
 
 	md := "```mermaid\ngraph TD\n    A-->B\n```\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	if !strings.Contains(result, "```mermaid") {
 		t.Error("on error, original block should be preserved")
@@ -302,7 +302,7 @@ func TestMermaidRenderer_Close_TempDir(t *testing.T) {
 
 func TestPreprocessMermaid_NilRenderer(t *testing.T) {
 	md := "```mermaid\ngraph TD\n```\n"
-	result := preprocessMermaid(md, nil)
+	result := preprocessDiagram(md, "mermaid", nil, mermaidLanguages...)
 	if result != md {
 		t.Error("nil renderer should return markdown unchanged")
 	}
@@ -312,7 +312,7 @@ func TestPreprocessMermaid_MultipleMermaidBlocks(t *testing.T) {
 	renderer := newTestMermaidRenderer(t)
 
 	md := "```mermaid\ngraph TD\n    A-->B\n```\n\nMiddle text.\n\n```mermaid\nsequenceDiagram\n    A->>B: Hi\n```\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	count := strings.Count(result, "![mermaid diagram](")
 	if count != 2 {
@@ -330,7 +330,7 @@ func TestPreprocessMermaid_FourBacktickFence(t *testing.T) {
 	renderer := newTestMermaidRenderer(t)
 
 	md := "````mermaid\ngraph TD\n    A-->B\n````\n"
-	result := preprocessMermaid(md, renderer)
+	result := preprocessDiagram(md, "mermaid", renderer, mermaidLanguages...)
 
 	if strings.Contains(result, "````mermaid") {
 		t.Error("4-backtick mermaid fence should have been replaced")
@@ -347,7 +347,7 @@ func TestMarkdownSession_MermaidRendersAsImage(t *testing.T) {
 		MermaidOptions: &MermaidOptions{MmdcPath: renderer.mmdcPath},
 	})
 	// replace the auto-created mermaid renderer with our test one
-	session.mermaidRenderer = renderer
+	session.Diagrams().Register("mermaid", renderer, mermaidLanguages...)
 
 	md := "# Title\n\n```mermaid\ngraph TD\n    A-->B\n```\n\nSome text.\n"
 	if err := session.SetMarkdown(md); err != nil {
@@ -417,7 +417,7 @@ func TestExtractMermaidBlocks(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, blocks := extractMermaidBlocks(tt.markdown)
+			_, blocks := extractDiagramBlocks(tt.markdown, diagramFenceRe(mermaidLanguages...))
 			if len(blocks) != tt.wantCount {
 				t.Fatalf("got %d blocks, want %d", len(blocks), tt.wantCount)
 			}
@@ -430,13 +430,22 @@ func TestExtractMermaidBlocks(t *testing.T) {
 	}
 }
 
+// mermaidImages substitutes the blocks rendered to the paths in rendered,
+// by block index, as DiagramRegistry.preprocess does.
+func mermaidImages(rendered map[int]string) func(i int) (string, bool) {
+	return func(i int) (string, bool) {
+		path, ok := rendered[i]
+		return "![" + diagramAltText(mermaidLanguages) + "](" + path + ")", ok
+	}
+}
+
 func TestReassembleMermaid(t *testing.T) {
 	t.Run("substitutes rendered blocks", func(t *testing.T) {
 		md := "# Title\n\n```mermaid\ngraph TD\n```\n\nEnd.\n"
-		lines, blocks := extractMermaidBlocks(md)
+		lines, blocks := extractDiagramBlocks(md, diagramFenceRe(mermaidLanguages...))
 		rendered := map[int]string{0: "/tmp/test.png"}
 
-		result := reassembleMermaid(lines, blocks, rendered)
+		result := reassembleDiagram(lines, blocks, mermaidImages(rendered))
 		if !strings.Contains(result, "![mermaid diagram](/tmp/test.png)") {
 			t.Errorf("expected image substitution, got:\n%s", result)
 		}
@@ -450,10 +459,10 @@ func TestReassembleMermaid(t *testing.T) {
 
 	t.Run("preserves block on error", func(t *testing.T) {
 		md := "```mermaid\ngraph TD\n```\n"
-		lines, blocks := extractMermaidBlocks(md)
+		lines, blocks := extractDiagramBlocks(md, diagramFenceRe(mermaidLanguages...))
 		rendered := map[int]string{} // empty = all errors
 
-		result := reassembleMermaid(lines, blocks, rendered)
+		result := reassembleDiagram(lines, blocks, mermaidImages(rendered))
 		if !strings.Contains(result, "```mermaid") {
 			t.Error("original block should be preserved on error")
 		}
@@ -461,10 +470,10 @@ func TestReassembleMermaid(t *testing.T) {
 
 	t.Run("mixed success and error", func(t *testing.T) {
 		md := "```mermaid\ngraph A\n```\n\n```mermaid\ngraph B\n```\n"
-		lines, blocks := extractMermaidBlocks(md)
+		lines, blocks := extractDiagramBlocks(md, diagramFenceRe(mermaidLanguages...))
 		rendered := map[int]string{0: "/tmp/a.png"} // second block failed
 
-		result := reassembleMermaid(lines, blocks, rendered)
+		result := reassembleDiagram(lines, blocks, mermaidImages(rendered))
 		if !strings.Contains(result, "![mermaid diagram](/tmp/a.png)") {
 			t.Error("first block should be rendered")
 		}
@@ -0,0 +1,264 @@
+package navidown
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrPathNotAllowed matches every PathPolicyError. Refusals also match
+// ErrDirectoryTraversal, so existing checks keep working.
+var ErrPathNotAllowed = errors.New("path not allowed by policy")
+
+// PathRefusal says why a PathPolicy refused a path.
+type PathRefusal int
+
+const (
+	RefusedDenied        PathRefusal = iota + 1 // path matches a Deny entry
+	RefusedOutsideRoots                         // path is not under any allowed root
+	RefusedSymlinkEscape                        // path is a symlink leading outside the allowed roots
+)
+
+func (r PathRefusal) String() string {
+	switch r {
+	case RefusedDenied:
+		return "denied"
+	case RefusedOutsideRoots:
+		return "outside allowed roots"
+	case RefusedSymlinkEscape:
+		return "symlink escapes allowed roots"
+	}
+	return "refused"
+}
+
+// PathPolicyError reports a local path refused by a PathPolicy.
+type PathPolicyError struct {
+	Path   string      // the resolved path that was refused
+	Target string      // for symlinks, where the path really points; "" otherwise
+	Reason PathRefusal // why the path was refused
+	Rule   string      // the Deny entry that matched, for RefusedDenied
+}
+
+func (e *PathPolicyError) Error() string {
+	msg := fmt.Sprintf("%s: %s (%s)", ErrPathNotAllowed, e.Path, e.Reason)
+	if e.Target != "" {
+		msg += " -> " + e.Target
+	}
+	if e.Rule != "" {
+		msg += fmt.Sprintf(" by %q", e.Rule)
+	}
+	return msg
+}
+
+// Is makes errors.Is match ErrPathNotAllowed and ErrDirectoryTraversal.
+func (e *PathPolicyError) Is(target error) bool {
+	return target == ErrPathNotAllowed || target == ErrDirectoryTraversal
+}
+
+// PathPolicy decides which local files links and images may resolve to.
+// The zero value allows everything. Files navidown generated itself (diagram
+// and rasterizer output in its cache or temp directories) are always allowed.
+type PathPolicy struct {
+	// AllowedRoots, when non-empty, restricts resolution to files under these
+	// directories.
+	AllowedRoots []string
+
+	// ConfineToSearchRoots additionally treats the search roots passed to the
+	// resolver as allowed roots, so links cannot leave the configured tree.
+	// Note that the linking document's own directory is not implied.
+	ConfineToSearchRoots bool
+
+	// Deny lists paths that are never resolved, even under an allowed root.
+	// Entries containing a path separator (or starting with "~/") are
+	// directories or files refused along with everything below them; other
+	// entries are glob patterns matched against each path element, e.g.
+	// ".ssh" or "*.pem".
+	Deny []string
+
+	// AllowSymlinkEscape disables the check that a path's real location,
+	// after following symlinks, is also allowed.
+	AllowSymlinkEscape bool
+}
+
+// DefaultPathPolicy returns the policy used when none is configured: system
+// directories and common credential files are denied, and everything else is
+// allowed.
+func DefaultPathPolicy() *PathPolicy {
+	return &PathPolicy{
+		Deny: []string{
+			"/etc", "/proc", "/sys",
+			".ssh", ".gnupg", ".aws", ".kube", ".docker", ".netrc", ".env",
+			"*.pem", "*.key", "id_rsa", "id_ecdsa", "id_ed25519",
+		},
+	}
+}
+
+var sharedDefaultPathPolicy = sync.OnceValue(DefaultPathPolicy)
+
+// Check returns a *PathPolicyError if path may not be resolved, or nil.
+// searchRoots are the resolver's search roots, used by ConfineToSearchRoots.
+func (p *PathPolicy) Check(path string, searchRoots []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return &PathPolicyError{Path: path, Reason: RefusedOutsideRoots}
+	}
+	if isNavidownWorkPath(abs) {
+		return nil
+	}
+
+	if rule, ok := p.denied(abs); ok {
+		return &PathPolicyError{Path: abs, Reason: RefusedDenied, Rule: rule}
+	}
+
+	roots, confined := p.roots(searchRoots)
+	if confined && !underAny(abs, roots) {
+		return &PathPolicyError{Path: abs, Reason: RefusedOutsideRoots}
+	}
+
+	if p.AllowSymlinkEscape {
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(abs)
+	if err != nil || target == abs {
+		return nil
+	}
+	if rule, ok := p.denied(target); ok {
+		return &PathPolicyError{Path: abs, Target: target, Reason: RefusedDenied, Rule: rule}
+	}
+	if confined && !underAny(target, realPaths(roots)) {
+		return &PathPolicyError{Path: abs, Target: target, Reason: RefusedSymlinkEscape}
+	}
+	return nil
+}
+
+// roots returns the cleaned absolute allowed roots, including search roots
+// when confined to them. confined is false when any path is allowed.
+func (p *PathPolicy) roots(searchRoots []string) (roots []string, confined bool) {
+	add := func(dir string) {
+		if dir == "" {
+			return
+		}
+		if abs, err := filepath.Abs(expandHome(dir)); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	for _, dir := range p.AllowedRoots {
+		add(dir)
+	}
+	if p.ConfineToSearchRoots {
+		for _, dir := range searchRoots {
+			add(dir)
+		}
+	}
+	return roots, len(p.AllowedRoots) > 0 || p.ConfineToSearchRoots
+}
+
+// denied reports the Deny entry matching abs, if any.
+func (p *PathPolicy) denied(abs string) (string, bool) {
+	elems := strings.Split(filepath.ToSlash(abs), "/")
+	for _, rule := range p.Deny {
+		if rule == "" {
+			continue
+		}
+		if strings.ContainsAny(rule, `/\`) {
+			dir, err := filepath.Abs(expandHome(rule))
+			if err != nil {
+				continue
+			}
+			if underDir(abs, dir) {
+				return rule, true
+			}
+			// also match the real location, e.g. /private/etc for /etc on macOS
+			if target, err := filepath.EvalSymlinks(dir); err == nil && target != dir && underDir(abs, target) {
+				return rule, true
+			}
+			continue
+		}
+		for _, elem := range elems {
+			if ok, _ := filepath.Match(rule, elem); ok {
+				return rule, true
+			}
+		}
+	}
+	return "", false
+}
+
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != filepath.Separator) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + rest
+}
+
+// underDir reports whether path is dir or inside it. Both must be clean and absolute.
+func underDir(path, dir string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+func underAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if underDir(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPaths returns dirs with symlinks resolved, keeping the originals so a
+// root that is itself a symlink still matches.
+func realPaths(dirs []string) []string {
+	out := append([]string(nil), dirs...)
+	for _, dir := range dirs {
+		if target, err := filepath.EvalSymlinks(dir); err == nil && target != dir {
+			out = append(out, target)
+		}
+	}
+	return out
+}
+
+// navidown's own cache and temp directories, registered by resolveCacheDir.
+var (
+	workDirsMu sync.RWMutex
+	workDirs   = map[string]struct{}{}
+)
+
+func registerWorkDir(dir string) {
+	if dir == "" {
+		return
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	workDirsMu.Lock()
+	workDirs[abs] = struct{}{}
+	workDirsMu.Unlock()
+}
+
+// isNavidownWorkPath reports whether abs is inside a directory navidown uses
+// for generated files. Those paths are handed back to the resolver as image
+// URLs and are trusted because navidown wrote them.
+func isNavidownWorkPath(abs string) bool {
+	workDirsMu.RLock()
+	defer workDirsMu.RUnlock()
+	for dir := range workDirs {
+		if underDir(abs, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package navidown
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writePolicyFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("# doc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func refusal(t *testing.T, err error) PathRefusal {
+	t.Helper()
+	var perr *PathPolicyError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *PathPolicyError, got %v", err)
+	}
+	if !errors.Is(err, ErrPathNotAllowed) || !errors.Is(err, ErrDirectoryTraversal) {
+		t.Errorf("policy error should match ErrPathNotAllowed and ErrDirectoryTraversal")
+	}
+	return perr.Reason
+}
+
+func TestPathPolicy_AllowedRoots(t *testing.T) {
+	repo := t.TempDir()
+	outside := t.TempDir()
+	writePolicyFile(t, filepath.Join(repo, "docs", "a.md"))
+	writePolicyFile(t, filepath.Join(outside, "secret.md"))
+	policy := &PathPolicy{AllowedRoots: []string{repo}}
+
+	got, err := ResolveMarkdownPathWithPolicy("a.md", filepath.Join(repo, "docs", "index.md"), nil, policy)
+	if err != nil || got != filepath.Join(repo, "docs", "a.md") {
+		t.Fatalf("link inside root: got %q, %v", got, err)
+	}
+
+	_, err = ResolveMarkdownPathWithPolicy(filepath.Join(outside, "secret.md"), "", nil, policy)
+	if r := refusal(t, err); r != RefusedOutsideRoots {
+		t.Errorf("reason = %v, want %v", r, RefusedOutsideRoots)
+	}
+}
+
+func TestPathPolicy_ConfineToSearchRoots(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := t.TempDir()
+	writePolicyFile(t, filepath.Join(root, "guide.md"))
+	writePolicyFile(t, filepath.Join(elsewhere, "guide.md"))
+	policy := &PathPolicy{ConfineToSearchRoots: true}
+
+	// the same-directory candidate is refused, the search root satisfies the link
+	got, err := ResolveMarkdownPathWithPolicy("guide.md", filepath.Join(elsewhere, "index.md"), []string{root}, policy)
+	if err != nil || got != filepath.Join(root, "guide.md") {
+		t.Fatalf("got %q, %v; want search root file", got, err)
+	}
+
+	_, err = ResolveMarkdownPathWithPolicy("guide.md", filepath.Join(elsewhere, "index.md"), nil, policy)
+	if r := refusal(t, err); r != RefusedOutsideRoots {
+		t.Errorf("reason = %v, want %v", r, RefusedOutsideRoots)
+	}
+}
+
+func TestPathPolicy_SymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	repo := t.TempDir()
+	outside := t.TempDir()
+	writePolicyFile(t, filepath.Join(outside, "private.md"))
+	link := filepath.Join(repo, "shortcut.md")
+	if err := os.Symlink(filepath.Join(outside, "private.md"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &PathPolicy{AllowedRoots: []string{repo}}
+	_, err := ResolveMarkdownPathWithPolicy("shortcut.md", filepath.Join(repo, "README.md"), nil, policy)
+	if r := refusal(t, err); r != RefusedSymlinkEscape {
+		t.Errorf("reason = %v, want %v", r, RefusedSymlinkEscape)
+	}
+
+	policy.AllowSymlinkEscape = true
+	if _, err := ResolveMarkdownPathWithPolicy("shortcut.md", filepath.Join(repo, "README.md"), nil, policy); err != nil {
+		t.Errorf("AllowSymlinkEscape should permit the link, got %v", err)
+	}
+}
+
+func TestPathPolicy_DenyPatterns(t *testing.T) {
+	dir := t.TempDir()
+	policy := &PathPolicy{Deny: []string{"*.pem", filepath.Join(dir, "private")}}
+
+	tests := []struct {
+		path string
+		rule string
+	}{
+		{filepath.Join(dir, "certs", "server.pem"), "*.pem"},
+		{filepath.Join(dir, "private", "notes.md"), filepath.Join(dir, "private")},
+		{filepath.Join(dir, "private"), filepath.Join(dir, "private")},
+		{filepath.Join(dir, "private-notes.md"), ""},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.path, nil)
+		if tt.rule == "" {
+			if err != nil {
+				t.Errorf("Check(%q) = %v, want allowed", tt.path, err)
+			}
+			continue
+		}
+		var perr *PathPolicyError
+		if !errors.As(err, &perr) || perr.Reason != RefusedDenied || perr.Rule != tt.rule {
+			t.Errorf("Check(%q) = %v, want denial by %q", tt.path, err, tt.rule)
+		}
+	}
+}
+
+func TestPathPolicy_DenyHomeRelative(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	policy := &PathPolicy{Deny: []string{"~/private"}}
+	if err := policy.Check(filepath.Join(home, "private", "a.md"), nil); err == nil {
+		t.Error("~/private should expand to the home directory")
+	}
+}
+
+func TestPathPolicy_ZeroValueAllowsEverything(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix-only path semantics")
+	}
+	if err := (&PathPolicy{}).Check("/etc/passwd", nil); err != nil {
+		t.Errorf("zero policy should allow, got %v", err)
+	}
+}
+
+func TestPathPolicyError_Message(t *testing.T) {
+	err := &PathPolicyError{Path: "/etc/passwd", Reason: RefusedDenied, Rule: "/etc"}
+	msg := err.Error()
+	for _, want := range []string{"/etc/passwd", "denied", `"/etc"`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q missing %q", msg, want)
+		}
+	}
+}
+
+func TestImageResolver_PathPolicy(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "img.png")
+	if err := os.WriteFile(img, make1x1PNG(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewImageResolver(nil)
+	r.SetPathPolicy(&PathPolicy{AllowedRoots: []string{t.TempDir()}})
+	_, err := r.Resolve(img, "")
+	if !errors.Is(err, ErrPathNotAllowed) {
+		t.Errorf("expected policy refusal, got %v", err)
+	}
+}
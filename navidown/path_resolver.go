@@ -9,29 +9,48 @@ import (
 )
 
 var (
-	// ErrDirectoryTraversal is returned when a path contains directory traversal attempts.
+	// ErrDirectoryTraversal matches errors for paths refused by the path
+	// policy; use errors.As with *PathPolicyError for the reason.
 	ErrDirectoryTraversal = errors.New("directory traversal not allowed")
 	// ErrFileNotFound is returned when a file doesn't exist after resolution attempts.
 	ErrFileNotFound = errors.New("file not found")
 )
 
-// ResolveMarkdownPath resolves a markdown link URL to an absolute file path.
+// ResolveMarkdownPath resolves a markdown link URL to an absolute file path,
+// checking local paths against DefaultPathPolicy.
+// See ResolveMarkdownPathWithPolicy.
+func ResolveMarkdownPath(linkURL, sourceFilePath string, searchRoots []string) (string, error) {
+	return ResolveMarkdownPathWithPolicy(linkURL, sourceFilePath, searchRoots, nil)
+}
+
+// ResolveMarkdownPathWithPolicy resolves a markdown link URL to an absolute
+// file path. Local candidates are checked against policy; nil means
+// DefaultPathPolicy.
 //
 // Resolution order:
 // 1. If linkURL is HTTP/HTTPS -> return as-is
 // 2. If sourceFilePath is HTTP/HTTPS -> resolve linkURL as URL reference
-// 3. Security check for local directory traversal
-// 4. If local absolute path and exists -> return it
-// 5. If local sourceFilePath provided, try same directory
-// 6. Try any extra local search roots (in order)
-// 7. Return ErrFileNotFound
+// 3. If local absolute path, allowed by policy, and exists -> return it
+// 4. If local sourceFilePath provided, try same directory
+// 5. Try any extra local search roots (in order)
+// 6. Return the first policy refusal (a *PathPolicyError), or ErrFileNotFound
+//
+// Candidates refused by the policy are skipped, so an allowed search root can
+// still satisfy a link whose same-directory candidate is refused.
+//
+// Local directories resolve too, returned with a trailing path separator so
+// the result can serve as a source path: relative links in a directory's
+// README or generated index then resolve inside that directory.
 //
 // Note: in HTTP source mode, resolution is URL-only and does not fall back to
 // local path checks or search roots.
-func ResolveMarkdownPath(linkURL, sourceFilePath string, searchRoots []string) (string, error) {
+func ResolveMarkdownPathWithPolicy(linkURL, sourceFilePath string, searchRoots []string, policy *PathPolicy) (string, error) {
 	if linkURL == "" {
 		return "", nil
 	}
+	if policy == nil {
+		policy = sharedDefaultPathPolicy()
+	}
 
 	if isHTTPURL(linkURL) {
 		return linkURL, nil
@@ -43,97 +62,62 @@ func ResolveMarkdownPath(linkURL, sourceFilePath string, searchRoots []string) (
 		return resolveAgainstHTTPSource(sourceFilePath, linkURL)
 	}
 
-	if containsDirectoryTraversal(linkURL) {
-		return "", ErrDirectoryTraversal
-	}
-
 	if filepath.IsAbs(linkURL) {
+		if err := policy.Check(linkURL, searchRoots); err != nil {
+			return "", err
+		}
 		if fileExists(linkURL) {
 			return linkURL, nil
 		}
+		if dirExists(linkURL) {
+			return DirectorySourcePath(linkURL), nil
+		}
 		return "", ErrFileNotFound
 	}
 
+	var candidates []string
 	if sourceFilePath != "" {
 		sourceDir := filepath.Dir(sourceFilePath)
-		candidate := filepath.Clean(filepath.Join(sourceDir, linkURL))
-		if fileExists(candidate) {
-			return candidate, nil
-		}
+		candidates = append(candidates, filepath.Clean(filepath.Join(sourceDir, linkURL)))
 	}
-
 	for _, root := range searchRoots {
 		if root == "" {
 			continue
 		}
-		candidate := filepath.Clean(filepath.Join(root, linkURL))
-		if fileExists(candidate) {
-			return candidate, nil
-		}
+		candidates = append(candidates, filepath.Clean(filepath.Join(root, linkURL)))
 	}
 
-	return "", ErrFileNotFound
-}
-
-func isHTTPURL(url string) bool {
-	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
-}
-
-func containsDirectoryTraversal(path string) bool {
-	// Security: absolute paths into sensitive system directories are disallowed.
-	// This prevents resolving system files like /etc/passwd via absolute link URLs.
-	if filepath.IsAbs(path) {
-		// navidown writes rasterized Mermaid/SVG output under os.UserCacheDir()/navidown/
-		// and later hands those absolute paths back to the resolver. When tiki runs as
-		// root (typical in Docker), the cache lives at /root/.cache/navidown/, which the
-		// /root block below would otherwise reject. Whitelist the known-safe subtree.
-		if isNavidownCachePath(path) {
-			return false
-		}
-
-		cleaned := filepath.Clean(path)
-		parts := strings.Split(cleaned, string(filepath.Separator))
-		// For absolute paths, parts[0] will be "" (leading slash), so the first
-		// meaningful segment is parts[1] when present.
-		if len(parts) > 1 {
-			// block a small set of sensitive system directories.
-			// note: do not block /var because temp dirs often live under it (e.g. /var/folders on macOS).
-			sensitive := map[string]struct{}{
-				"etc":  {},
-				"sys":  {},
-				"proc": {},
-				"root": {},
-			}
-			if _, ok := sensitive[parts[1]]; ok {
-				return true
+	var refused error
+	allowed := candidates[:0]
+	for _, candidate := range candidates {
+		if err := policy.Check(candidate, searchRoots); err != nil {
+			if refused == nil {
+				refused = err
 			}
+			continue
 		}
+		allowed = append(allowed, candidate)
 	}
 
-	if strings.HasPrefix(path, "../") || path == ".." {
-		cleaned := filepath.Clean(path)
-		parts := strings.Split(cleaned, string(filepath.Separator))
-
-		sensitivePatterns := []string{"etc", "var", "usr", "sys", "proc", "root"}
-		for _, part := range parts {
-			for _, sensitive := range sensitivePatterns {
-				if part == sensitive {
-					return true
-				}
-			}
+	for _, candidate := range allowed {
+		if fileExists(candidate) {
+			return candidate, nil
 		}
 	}
-
-	if !filepath.IsAbs(path) {
-		sensitivePatterns := []string{"etc/", "var/", "usr/", "sys/", "proc/", "root/"}
-		for _, pattern := range sensitivePatterns {
-			if strings.HasPrefix(path, pattern) || strings.Contains(path, "/"+pattern) {
-				return true
-			}
+	for _, candidate := range allowed {
+		if dirExists(candidate) {
+			return DirectorySourcePath(candidate), nil
 		}
 	}
 
-	return false
+	if refused != nil {
+		return "", refused
+	}
+	return "", ErrFileNotFound
+}
+
+func isHTTPURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
 }
 
 func fileExists(path string) bool {
@@ -144,19 +128,20 @@ func fileExists(path string) bool {
 	return !info.IsDir()
 }
 
-// isNavidownCachePath reports whether path is under the navidown cache directory.
-// This whitelist exists so that internally-rasterized Mermaid/SVG outputs can be
-// handed back to the resolver without tripping the sensitive-directory block —
-// their absolute paths (e.g. /root/.cache/navidown/mermaid/<hash>.png in a root
-// container) are known-safe because navidown itself wrote them.
-func isNavidownCachePath(path string) bool {
-	cacheDir, err := os.UserCacheDir()
-	if err != nil || cacheDir == "" {
-		return false
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// DirectorySourcePath returns dir cleaned and with a trailing separator, the
+// form used as the source path of a directory page. filepath.Dir of the
+// result is dir itself, so relative links resolve inside the directory.
+func DirectorySourcePath(dir string) string {
+	cleaned := filepath.Clean(dir)
+	if strings.HasSuffix(cleaned, string(filepath.Separator)) {
+		return cleaned
 	}
-	root := filepath.Join(cacheDir, "navidown") + string(filepath.Separator)
-	cleaned := filepath.Clean(path)
-	return strings.HasPrefix(cleaned+string(filepath.Separator), root)
+	return cleaned + string(filepath.Separator)
 }
 
 // looksLikeHTTPURL is a case-insensitive prefix check for http(s) URLs.
@@ -32,25 +32,54 @@ func TestResolveMarkdownPath_HTTPURLs(t *testing.T) {
 }
 
 func TestResolveMarkdownPath_DirectoryTraversal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix-only path semantics")
+	}
 	tests := []struct {
-		name string
-		url  string
+		name   string
+		url    string
+		source string
 	}{
-		{name: "Parent directory traversal", url: "../../etc/passwd"},
-		{name: "Relative path to etc", url: "etc/passwd"},
-		{name: "Relative path with /etc", url: "docs/../etc/passwd"},
+		{name: "Parent directory traversal", url: "../../etc/passwd", source: "/srv/docs/index.md"},
+		{name: "Traversal through subdirectory", url: "guide/../../../etc/passwd", source: "/srv/docs/index.md"},
+		{name: "Absolute sensitive path", url: "/etc/passwd", source: ""},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := ResolveMarkdownPath(tt.url, "", nil)
+			_, err := ResolveMarkdownPath(tt.url, tt.source, nil)
 			if !errors.Is(err, ErrDirectoryTraversal) {
 				t.Errorf("expected ErrDirectoryTraversal, got %v", err)
 			}
+			var perr *PathPolicyError
+			if !errors.As(err, &perr) || perr.Reason != RefusedDenied || perr.Rule != "/etc" {
+				t.Errorf("expected denial by /etc, got %#v", err)
+			}
 		})
 	}
 }
 
+// Directories that merely share a name with system directories are allowed:
+// the policy judges the resolved location, not path segments.
+func TestResolveMarkdownPath_RepoDirectoryNamedEtc(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "etc", "config.md")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("# Config"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ResolveMarkdownPath("etc/config.md", filepath.Join(dir, "README.md"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != target {
+		t.Errorf("expected %s, got %s", target, got)
+	}
+}
+
 func TestResolveMarkdownPath_AbsolutePath(t *testing.T) {
 	tmpDir := t.TempDir()
 	tmpFile := filepath.Join(tmpDir, "test.md")
@@ -189,18 +218,22 @@ func TestResolveMarkdownPath_MultipleParentSegments(t *testing.T) {
 }
 
 func TestResolveMarkdownPath_DeepTraversalToSensitiveDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix-only path semantics")
+	}
 	tests := []struct {
-		name string
-		url  string
+		name   string
+		url    string
+		source string
 	}{
-		{name: "Deep traversal to etc", url: "../../../etc/passwd"},
-		{name: "Two segments to etc", url: "../../etc/passwd"},
-		{name: "One segment to proc", url: "../proc/cpuinfo"},
+		{name: "Deep traversal to etc", url: "../../../etc/passwd", source: "/a/b/c/doc.md"},
+		{name: "Two segments to etc", url: "../../etc/passwd", source: "/a/b/doc.md"},
+		{name: "One segment to proc", url: "../proc/cpuinfo", source: "/a/doc.md"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := ResolveMarkdownPath(tt.url, "", nil)
+			_, err := ResolveMarkdownPath(tt.url, tt.source, nil)
 			if !errors.Is(err, ErrDirectoryTraversal) {
 				t.Errorf("expected ErrDirectoryTraversal for %q, got %v", tt.url, err)
 			}
@@ -433,7 +466,7 @@ func TestResolveMarkdownPath_HTTPSourceNoLocalFallback(t *testing.T) {
 
 func TestResolveMarkdownPath_LocalNotAffectedByHTTPChanges(t *testing.T) {
 	// local traversal is still blocked
-	_, err := ResolveMarkdownPath("../etc/passwd", "", nil)
+	_, err := ResolveMarkdownPath("../etc/passwd", "/srv/doc.md", nil)
 	if !errors.Is(err, ErrDirectoryTraversal) {
 		t.Errorf("expected ErrDirectoryTraversal for local traversal, got %v", err)
 	}
@@ -458,62 +491,91 @@ func TestResolveMarkdownPath_LocalNotAffectedByHTTPChanges(t *testing.T) {
 	}
 }
 
-func TestContainsDirectoryTraversal_AbsoluteSensitivePaths(t *testing.T) {
+func TestDefaultPathPolicy_AbsolutePaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix-only path semantics")
+	}
 	tests := []struct {
-		name          string
-		path          string
-		want          bool
-		skipOnWindows bool
+		name    string
+		path    string
+		refused bool
 	}{
-		{name: "Sensitive /etc path", path: "/etc/passwd", want: true},
-		{name: "Non-sensitive /usr path", path: "/usr/bin/env", want: false, skipOnWindows: true},
-		{name: "Non-sensitive absolute path", path: "/tmp/test.md", want: false, skipOnWindows: true},
+		{name: "Sensitive /etc path", path: "/etc/passwd", refused: true},
+		{name: "SSH key under home", path: "/home/user/.ssh/id_ed25519", refused: true},
+		{name: "Source tree under /usr", path: "/usr/src/linux/README.md", refused: false},
+		{name: "Repo under /root", path: "/root/project/README.md", refused: false},
+		{name: "Non-sensitive absolute path", path: "/tmp/test.md", refused: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.skipOnWindows && runtime.GOOS == "windows" {
-				t.Skip("Skipping Unix-specific path test on Windows")
-			}
-			if got := containsDirectoryTraversal(tt.path); got != tt.want {
-				t.Errorf("containsDirectoryTraversal(%q) = %v, want %v", tt.path, got, tt.want)
+			err := DefaultPathPolicy().Check(tt.path, nil)
+			if (err != nil) != tt.refused {
+				t.Errorf("Check(%q) = %v, want refused=%v", tt.path, err, tt.refused)
 			}
 		})
 	}
 }
 
-// Regression test: navidown writes rasterized PNGs under os.UserCacheDir()/navidown/
-// and hands those absolute paths back to the resolver. Containers that run as root
-// put the cache under /root/.cache/, which would otherwise collide with the /root
-// sensitive-directory block. The cache subtree must stay allowed.
-func TestContainsDirectoryTraversal_NavidownCacheAllowed(t *testing.T) {
-	if runtime.GOOS == "windows" {
-		t.Skip("Unix-only path semantics")
+// Regression test: navidown writes rasterized PNGs under its cache directory
+// and hands those absolute paths back to the resolver. They must be allowed
+// even by a policy confined elsewhere.
+func TestPathPolicy_NavidownWorkDirTrusted(t *testing.T) {
+	_, _, workDir := resolveCacheDir(filepath.Join(t.TempDir(), "cache"), "mermaid")
+	png := filepath.Join(workDir, "abc123.png")
+	if err := os.WriteFile(png, []byte("PNG"), 0o644); err != nil {
+		t.Fatal(err)
 	}
-	cacheDir, err := os.UserCacheDir()
-	if err != nil || cacheDir == "" {
-		t.Skip("no user cache dir available")
+
+	policy := &PathPolicy{AllowedRoots: []string{t.TempDir()}, Deny: []string{"*.png"}}
+	if err := policy.Check(png, nil); err != nil {
+		t.Errorf("navidown work dir should be trusted, got %v", err)
 	}
-	navCache := filepath.Join(cacheDir, "navidown")
+	if err := policy.Check(workDir+"-evil/x.png", nil); err == nil {
+		t.Error("trust must not extend to adjacent directories with a shared prefix")
+	}
+}
 
-	tests := []struct {
-		name string
-		path string
-		want bool
-	}{
-		{name: "Mermaid cache PNG", path: filepath.Join(navCache, "mermaid", "abc123.png"), want: false},
-		{name: "SVG cache PNG", path: filepath.Join(navCache, "svg", "def456.png"), want: false},
-		{name: "Cache root", path: navCache, want: false},
-		// Ensure the whitelist doesn't extend to adjacent directories with a shared prefix.
-		{name: "Adjacent dir with same-prefix name", path: cacheDir + string(filepath.Separator) + "navidown-evil" + string(filepath.Separator) + "x.png", want: false},
+func TestResolveMarkdownPath_Directory(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "index.md")
+	if err := os.WriteFile(source, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o750); err != nil {
+		t.Fatal(err)
 	}
+	want := filepath.Join(dir, "docs") + string(filepath.Separator)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := containsDirectoryTraversal(tt.path)
-			if got != tt.want {
-				t.Errorf("containsDirectoryTraversal(%q) = %v, want %v", tt.path, got, tt.want)
-			}
-		})
+	got, err := ResolveMarkdownPath("docs", source, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// relative links resolve inside the directory when it is the source
+	if err := os.WriteFile(filepath.Join(dir, "docs", "a.md"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ResolveMarkdownPath("a.md", want, nil)
+	if err != nil || got != filepath.Join(dir, "docs", "a.md") {
+		t.Errorf("link from directory source: %q, %v", got, err)
+	}
+}
+
+func TestResolveMarkdownPath_FilePreferredOverDirectory(t *testing.T) {
+	rootA := t.TempDir()
+	rootB := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(rootA, "guide"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootB, "guide"), []byte("file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ResolveMarkdownPath("guide", "", []string{rootA, rootB})
+	if err != nil || got != filepath.Join(rootB, "guide") {
+		t.Errorf("got %q, %v; want file in second root", got, err)
 	}
 }
@@ -0,0 +1,52 @@
+package navidown
+
+import (
+	"strings"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+)
+
+// PlainTextRenderer renders markdown to plain text without escape sequences,
+// for piping into other tools or screen readers. Structure is kept with the
+// ascii style's text decorations ("# " headings, "• " bullets, "| " quotes).
+type PlainTextRenderer struct {
+	wordWrap int
+}
+
+// NewPlainTextRenderer creates a plain-text renderer without word wrap.
+func NewPlainTextRenderer() *PlainTextRenderer {
+	return &PlainTextRenderer{}
+}
+
+// WithWordWrap returns a new renderer with specified word wrap.
+func (r *PlainTextRenderer) WithWordWrap(cols int) *PlainTextRenderer {
+	return &PlainTextRenderer{wordWrap: cols}
+}
+
+// Render renders markdown to plain lines. The lines keep the invisible
+// navigation markers so a session can locate headings and links; the
+// cleaner (and PlainText) removes them.
+func (r *PlainTextRenderer) Render(markdown string) (RenderResult, error) {
+	res, err := NewANSIRendererWithStyle(styles.AsciiStyle).
+		WithColorProfile(ProfileMonochrome).
+		WithWordWrap(r.wordWrap).
+		Render(markdown)
+	if err != nil {
+		return res, err
+	}
+	for i, line := range res.Lines {
+		res.Lines[i] = strings.TrimRight(ansiSGRPattern.ReplaceAllString(line, ""), " ")
+	}
+	res.Cleaner = LineCleanerFunc(StripMarkers)
+	return res, nil
+}
+
+// PlainText renders markdown to a plain-text document, without markers or
+// the blank lines around the document.
+func (r *PlainTextRenderer) PlainText(markdown string) (string, error) {
+	res, err := r.Render(markdown)
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(StripMarkers(strings.Join(res.Lines, "\n")), "\n") + "\n", nil
+}
@@ -0,0 +1,269 @@
+package navidown
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PlantUMLLanguages are the fence languages of PlantUML blocks, for
+// registering a PlantUMLRenderer in a DiagramRegistry.
+var PlantUMLLanguages = []string{"plantuml", "puml"}
+
+// plantUMLSecurityProfile is the system property and environment variable
+// that select what PlantUML lets diagrams access.
+const plantUMLSecurityProfile = "PLANTUML_SECURITY_PROFILE"
+
+// PlantUMLOptions configures PlantUML diagram rendering.
+type PlantUMLOptions struct {
+	// PlantUMLPath is the plantuml executable or wrapper script; "" looks up
+	// "plantuml" in PATH. Ignored when JarPath is set.
+	PlantUMLPath string
+	// JarPath, if set, runs plantuml.jar with java (JavaPath, "" = lookup
+	// "java" in PATH) instead of a wrapper script.
+	JarPath  string
+	JavaPath string
+	Format   string        // "svg" (rasterized here) or "png"; "" = "svg"
+	Scale    int           // SVG rasterization scale; 0 = 2 (retina)
+	Timeout  time.Duration // render timeout; 0 = 30s
+	CacheDir string        // persistent cache dir; "" = auto (os.UserCacheDir()/navidown/plantuml)
+	// DarkMode recolors SVG output for dark backgrounds: dark text and lines
+	// become light and light gray shape fills dark. PNG output is used as
+	// PlantUML renders it. Set to false for light terminals or when the
+	// source defines its own colors. Default true.
+	DarkMode *bool
+	// Unsafe runs PlantUML with its default security profile, under which
+	// diagrams can !include local files and URLs and read environment
+	// variables. By default it runs with the SANDBOX profile, which blocks
+	// them.
+	Unsafe bool
+}
+
+func (o *PlantUMLOptions) resolvedDarkMode() bool {
+	if o.DarkMode != nil {
+		return *o.DarkMode
+	}
+	return true
+}
+
+func (o *PlantUMLOptions) resolvedFormat() string {
+	if o.Format == "png" {
+		return "png"
+	}
+	return "svg"
+}
+
+func (o *PlantUMLOptions) resolvedScale() int {
+	if o.Scale > 0 {
+		return o.Scale
+	}
+	return 2
+}
+
+func (o *PlantUMLOptions) resolvedTimeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return 30 * time.Second
+}
+
+// PlantUMLRenderer renders PlantUML source code to PNG files with a local
+// plantuml, rasterizing its SVG output with the wasm rasterizer.
+type PlantUMLRenderer struct {
+	diagramCache
+	opts       PlantUMLOptions
+	command    []string      // the plantuml command and its leading arguments
+	format     string        // "svg" or "png"
+	rasterizer SVGRasterizer // nil if wasm failed to init, in which case PNG is rendered
+}
+
+// NewPlantUMLRenderer creates a new renderer. Returns nil if plantuml (or
+// java, for JarPath) is not found.
+func NewPlantUMLRenderer(opts PlantUMLOptions) *PlantUMLRenderer {
+	var command []string
+	if opts.JarPath != "" {
+		javaPath := opts.JavaPath
+		if javaPath == "" {
+			resolved, err := exec.LookPath("java")
+			if err != nil {
+				return nil
+			}
+			javaPath = resolved
+		}
+		command = []string{javaPath, "-Djava.awt.headless=true"}
+		if !opts.Unsafe {
+			command = append(command, "-D"+plantUMLSecurityProfile+"=SANDBOX")
+		}
+		command = append(command, "-jar", opts.JarPath)
+	} else {
+		plantumlPath := opts.PlantUMLPath
+		if plantumlPath == "" {
+			resolved, err := exec.LookPath("plantuml")
+			if err != nil {
+				return nil
+			}
+			plantumlPath = resolved
+		}
+		command = []string{plantumlPath}
+	}
+
+	persistentDir, tempDir, workDir := resolveCacheDir(opts.CacheDir, "plantuml")
+	if workDir == "" {
+		return nil
+	}
+
+	r := &PlantUMLRenderer{
+		diagramCache: diagramCache{persistentDir: persistentDir, tempDir: tempDir, workDir: workDir},
+		opts:         opts,
+		command:      command,
+		format:       opts.resolvedFormat(),
+	}
+	if r.format == "svg" {
+		if rasterizer, err := sharedWasmRasterizer(); err == nil {
+			r.rasterizer = rasterizer
+		} else {
+			r.format = "png"
+		}
+	}
+	return r
+}
+
+func (r *PlantUMLRenderer) cacheKey(source string) string {
+	h := sha256.New()
+	h.Write([]byte(source))
+	h.Write([]byte{0})
+	h.Write([]byte(r.format))
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%d", r.opts.resolvedScale())
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%t", r.opts.resolvedDarkMode())
+	h.Write([]byte{0})
+	_, _ = fmt.Fprintf(h, "%t", r.opts.Unsafe)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// RenderToFile renders PlantUML source to a PNG file and returns its
+// absolute path. Results are cached by content hash (in-memory and on disk).
+func (r *PlantUMLRenderer) RenderToFile(source string) (string, error) {
+	key := r.cacheKey(source)
+	outputPath := filepath.Join(r.workDir, key+".png")
+
+	if path, ok := r.lookup(key, outputPath); ok {
+		return path, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.resolvedTimeout())
+	defer cancel()
+
+	// -pipe reads the diagram from stdin and writes the image to stdout
+	args := slices.Concat(r.command[1:], []string{"-t" + r.format, "-pipe", "-charset", "UTF-8"})
+	cmd := exec.CommandContext(ctx, r.command[0], args...) // #nosec G204 -- command from LookPath or user-provided
+	cmd.Stdin = strings.NewReader(plantUMLDocument(source))
+	if !r.opts.Unsafe {
+		// wrapper scripts pass no java options; PlantUML reads the profile
+		// from the environment too
+		cmd.Env = append(os.Environ(), plantUMLSecurityProfile+"=SANDBOX")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", &DiagramToolError{Tool: "plantuml", Err: err, Output: stderr.String()}
+	}
+
+	data := stdout.Bytes()
+	if r.format == "svg" {
+		var err error
+		if data, err = r.rasterize(data); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(outputPath, data, 0600); err != nil { // #nosec G703 -- outputPath from filepath.Join(workDir, hash+".png")
+		return "", fmt.Errorf("write plantuml png: %w", err)
+	}
+
+	r.store(key, outputPath)
+	return outputPath, nil
+}
+
+// rasterize converts the SVG plantuml wrote to PNG at the configured scale.
+func (r *PlantUMLRenderer) rasterize(svg []byte) ([]byte, error) {
+	if !isSVGData(svg) {
+		return nil, fmt.Errorf("plantuml wrote no svg")
+	}
+	if r.opts.resolvedDarkMode() {
+		svg = plantUMLSVGForDarkMode(svg)
+	}
+	png, err := rasterizeDiagramSVG(r.rasterizer, svg, r.opts.resolvedScale())
+	if err != nil {
+		return nil, fmt.Errorf("rasterize plantuml svg: %w", err)
+	}
+	return png, nil
+}
+
+// plantUMLDocument wraps source in @startuml/@enduml unless it already has
+// its own @start line, as blocks in markdown often leave them out.
+func plantUMLDocument(source string) string {
+	for _, line := range strings.Split(source, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "@start") {
+			return source
+		}
+	}
+	return "@startuml\n" + source + "@enduml\n"
+}
+
+var (
+	// svgTagPattern matches an SVG start tag, capturing its name.
+	svgTagPattern = regexp.MustCompile(`<([a-zA-Z]+)\b[^>]*>`)
+	// svgStyleColorPattern matches fill and stroke colors in style attributes.
+	svgStyleColorPattern = regexp.MustCompile(`(fill|stroke):(#[0-9a-fA-F]{3,6})\b`)
+)
+
+// plantUMLSVGForDarkMode recolors PlantUML's light default style for dark
+// backgrounds. As in preprocessSVGForDarkMode, dark gray text and lines
+// become light; in addition the light gray fill of shapes becomes dark so
+// the text on them stays readable. PlantUML sets colors both as attributes
+// and in style attributes.
+func plantUMLSVGForDarkMode(data []byte) []byte {
+	return svgTagPattern.ReplaceAllFunc(data, func(tag []byte) []byte {
+		isText := string(svgTagPattern.FindSubmatch(tag)[1]) == "text"
+		remap := func(attr, color string) string {
+			if attr == "fill" && !isText {
+				if r, g, b, ok := parseHexColor(color); ok && isLightGray(r, g, b) {
+					return "#333333"
+				}
+			}
+			if remapped, ok := remapDarkColor(color); ok {
+				return remapped
+			}
+			return color
+		}
+		tag = fillStrokePattern.ReplaceAllFunc(tag, func(m []byte) []byte {
+			parts := fillStrokePattern.FindSubmatch(m)
+			return []byte(fmt.Sprintf(`%s="%s"`, parts[1], remap(string(parts[1]), string(parts[2]))))
+		})
+		return svgStyleColorPattern.ReplaceAllFunc(tag, func(m []byte) []byte {
+			parts := svgStyleColorPattern.FindSubmatch(m)
+			return []byte(string(parts[1]) + ":" + remap(string(parts[1]), string(parts[2])))
+		})
+	})
+}
+
+// isLightGray returns true if the color is an achromatic gray light enough
+// to make light text on it unreadable.
+func isLightGray(r, g, b uint8) bool {
+	maxC := max(r, max(g, b))
+	minC := min(r, min(g, b))
+	if int(maxC)-int(minC) >= 30 {
+		return false // has significant hue
+	}
+	avg := (int(r) + int(g) + int(b)) / 3
+	return avg >= 192
+}
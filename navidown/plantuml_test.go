@@ -0,0 +1,185 @@
+package navidown
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testPlantUMLSVG is what the fake plantuml writes: black text on a light
+// gray box, PlantUML's default style.
+const testPlantUMLSVG = `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20" viewBox="0 0 40 20">` +
+	`<rect x="1" y="1" width="38" height="18" fill="#F1F1F1" style="stroke:#181818;stroke-width:0.5;"/>` +
+	`<text x="4" y="14" fill="#000000">A</text></svg>`
+
+// newTestPlantUMLRenderer creates a PlantUMLRenderer whose fake plantuml
+// writes fixture to stdout and appends its arguments to the returned log.
+func newTestPlantUMLRenderer(t *testing.T, fixture []byte, opts PlantUMLOptions) (*PlantUMLRenderer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	fixturePath := filepath.Join(dir, "fixture")
+	if err := os.WriteFile(fixturePath, fixture, 0644); err != nil {
+		t.Fatalf("write fixture: %v", err)
+	}
+	logPath := filepath.Join(dir, "args")
+	opts.PlantUMLPath = writeFakePlantUML(t, dir, fixturePath, logPath)
+	opts.CacheDir = t.TempDir()
+	renderer := NewPlantUMLRenderer(opts)
+	if renderer == nil {
+		t.Fatal("NewPlantUMLRenderer returned nil")
+	}
+	t.Cleanup(renderer.Close)
+	return renderer, logPath
+}
+
+// writeFakePlantUML creates a platform-appropriate fake plantuml that logs
+// its arguments and stdin to logPath and writes fixturePath to stdout.
+func writeFakePlantUML(t *testing.T, dir, fixturePath, logPath string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		p := filepath.Join(dir, "fake-plantuml.bat")
+		script := fmt.Sprintf("@echo off\r\necho %%*>>\"%s\"\r\nmore>>\"%s\"\r\ntype \"%s\"\r\n",
+			filepath.FromSlash(logPath), filepath.FromSlash(logPath), filepath.FromSlash(fixturePath))
+		if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+			t.Fatalf("write fake plantuml.bat: %v", err)
+		}
+		return p
+	}
+	p := filepath.Join(dir, "fake-plantuml")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> \"%s\"\ncat >> \"%s\"\ncat \"%s\"\n", logPath, logPath, fixturePath)
+	if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+		t.Fatalf("write fake plantuml: %v", err)
+	}
+	return p
+}
+
+func TestPlantUMLRenderer_SVG(t *testing.T) {
+	renderer, logPath := newTestPlantUMLRenderer(t, []byte(testPlantUMLSVG), PlantUMLOptions{})
+
+	path, err := renderer.RenderToFile("Alice -> Bob\n")
+	if err != nil {
+		t.Fatalf("RenderToFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, minimalPNG()[:8]) {
+		t.Fatalf("output is not a PNG: %v", err)
+	}
+
+	log, _ := os.ReadFile(logPath)
+	for _, want := range []string{"-tsvg -pipe", "@startuml\nAlice -> Bob\n@enduml"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("plantuml not given %q:\n%s", want, log)
+		}
+	}
+
+	// cached: plantuml is not run again
+	if again, err := renderer.RenderToFile("Alice -> Bob\n"); err != nil || again != path {
+		t.Errorf("second render: %q, %v", again, err)
+	}
+	if again, _ := os.ReadFile(logPath); !bytes.Equal(again, log) {
+		t.Error("plantuml run again for a cached diagram")
+	}
+}
+
+func TestPlantUMLRenderer_PNG(t *testing.T) {
+	renderer, logPath := newTestPlantUMLRenderer(t, minimalPNG(), PlantUMLOptions{Format: "png"})
+	path, err := renderer.RenderToFile("@startuml\nA -> B\n@enduml\n")
+	if err != nil {
+		t.Fatalf("RenderToFile: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, minimalPNG()) {
+		t.Error("PNG output not written as is")
+	}
+	if log, _ := os.ReadFile(logPath); !strings.Contains(string(log), "-tpng") || strings.Count(string(log), "@startuml") != 1 {
+		t.Errorf("unexpected plantuml input:\n%s", log)
+	}
+}
+
+func TestPlantUMLRenderer_Failure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script")
+	}
+	dir := t.TempDir()
+	p := filepath.Join(dir, "bad-plantuml")
+	if err := os.WriteFile(p, []byte("#!/bin/sh\necho 'Syntax Error?' >&2\nexit 200\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	renderer := NewPlantUMLRenderer(PlantUMLOptions{PlantUMLPath: p, CacheDir: t.TempDir()})
+	_, err := renderer.RenderToFile("nonsense\n")
+	if err == nil || !strings.Contains(err.Error(), "Syntax Error?") {
+		t.Errorf("error does not carry stderr: %v", err)
+	}
+}
+
+func TestPlantUMLRenderer_Sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "profile")
+	p := filepath.Join(dir, "env-plantuml")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$PLANTUML_SECURITY_PROFILE\" >> \"%s\"\ncat >/dev/null\n", logPath)
+	if err := os.WriteFile(p, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, unsafe := range []bool{false, true} {
+		renderer := NewPlantUMLRenderer(PlantUMLOptions{PlantUMLPath: p, Format: "png", CacheDir: t.TempDir(), Unsafe: unsafe})
+		_, _ = renderer.RenderToFile("A -> B\n")
+	}
+	if log, _ := os.ReadFile(logPath); string(log) != "SANDBOX\n\n" {
+		t.Errorf("security profiles %q, want SANDBOX then none", log)
+	}
+
+	// plantuml.jar gets it as a system property
+	java := filepath.Join(dir, "java")
+	if err := os.WriteFile(java, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	jar := NewPlantUMLRenderer(PlantUMLOptions{JarPath: "plantuml.jar", JavaPath: java, CacheDir: t.TempDir()})
+	if !slices.Contains(jar.command, "-DPLANTUML_SECURITY_PROFILE=SANDBOX") {
+		t.Errorf("java not sandboxed: %q", jar.command)
+	}
+	unsafe := NewPlantUMLRenderer(PlantUMLOptions{JarPath: "plantuml.jar", JavaPath: java, CacheDir: t.TempDir(), Unsafe: true})
+	if slices.ContainsFunc(unsafe.command, func(arg string) bool { return strings.Contains(arg, "SECURITY_PROFILE") }) {
+		t.Errorf("unsafe java sandboxed: %q", unsafe.command)
+	}
+}
+
+func TestNewPlantUMLRenderer_NotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if r := NewPlantUMLRenderer(PlantUMLOptions{}); r != nil {
+		t.Error("renderer created without plantuml")
+	}
+	if r := NewPlantUMLRenderer(PlantUMLOptions{JarPath: "plantuml.jar"}); r != nil {
+		t.Error("renderer created without java")
+	}
+}
+
+func TestPlantUMLSVGForDarkMode(t *testing.T) {
+	got := string(plantUMLSVGForDarkMode([]byte(testPlantUMLSVG)))
+	for _, want := range []string{`fill="#333333"`, "stroke:#e7e7e7", `<text x="4" y="14" fill="#ffffff">`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestSession_PlantUML(t *testing.T) {
+	renderer, _ := newTestPlantUMLRenderer(t, []byte(testPlantUMLSVG), PlantUMLOptions{})
+	session := New(Options{})
+	session.Diagrams().Register("plantuml", renderer, PlantUMLLanguages...)
+	if err := session.SetMarkdown("```puml\nA -> B\n```\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range session.Elements() {
+		if e.Type == NavElementImage && e.Text == "plantuml diagram" {
+			return
+		}
+	}
+	t.Errorf("no plantuml diagram image: %+v", session.Elements())
+}
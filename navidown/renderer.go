@@ -1,9 +1,9 @@
 package navidown
 
 import (
-	"os"
+	"net/url"
+	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/alecthomas/chroma/v2"
@@ -11,12 +11,17 @@ import (
 	"github.com/boolean-maybe/navidown/internal/glamour"
 	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
 	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+	"github.com/boolean-maybe/navidown/navidown/theme"
 )
 
 // RenderResult is the rendered representation used by the viewer.
 type RenderResult struct {
 	Lines   []string
 	Cleaner LineCleaner
+
+	// SourceMap, if set, locates headings, links and blocks in Lines. The
+	// session positions elements by it rather than by the markers.
+	SourceMap *SourceMap
 }
 
 // Renderer renders markdown into decorated lines along with a cleaner that can
@@ -29,6 +34,10 @@ type Renderer interface {
 type ANSIStyleRenderer struct {
 	glamourStyle ansi.StyleConfig
 	wordWrap     int
+	colorProfile ColorProfile
+	hyperlinks   bool
+	baseURL      string // hyperlink base, from WithSource
+	wideBlocks   bool
 }
 
 func uintPtr(v uint) *uint {
@@ -45,20 +54,29 @@ func NewANSIRenderer() *ANSIStyleRenderer {
 }
 
 // NewANSIRendererWithStyle creates a renderer with the specified style.
-// styleName can be any key from styles.DefaultStyles (e.g. "dark", "light",
-// "dracula", "tokyo-night", "pink") or "auto" to detect from COLORFGBG.
+// styleName can be any of theme.Names() (e.g. "dark", "light", "dracula",
+// "tokyo-night", "pink") or "auto" to detect from COLORFGBG.
 // Unknown names fall back to "dark".
 func NewANSIRendererWithStyle(styleName string) *ANSIStyleRenderer {
-	var style ansi.StyleConfig
-
-	if styleName == "auto" {
-		style = detectStyleFromEnvironment()
-	} else if s, ok := styles.DefaultStyles[styleName]; ok {
-		style = *s
-	} else {
-		style = styles.DarkStyleConfig
+	style, ok := builtinStyle(styleName)
+	if !ok {
+		style, _ = builtinStyle(styles.DarkStyle)
 	}
+	return newANSIRenderer(style)
+}
 
+// builtinStyle returns the named built-in style, or false if there is no
+// such style.
+func builtinStyle(styleName string) (ansi.StyleConfig, bool) {
+	t, err := theme.Builtin(styleName)
+	if err != nil {
+		return ansi.StyleConfig{}, false
+	}
+	return t.StyleConfig(), true
+}
+
+// newANSIRenderer creates a renderer for style, clearing its margins.
+func newANSIRenderer(style ansi.StyleConfig) *ANSIStyleRenderer {
 	// Always clear margins for consistent rendering
 	style.Document.Margin = uintPtr(0)
 	style.CodeBlock.Margin = uintPtr(0)
@@ -68,9 +86,6 @@ func NewANSIRendererWithStyle(styleName string) *ANSIStyleRenderer {
 		style.CodeBlock.Color = stringPtr("#808080")
 	}
 
-	// soften inline code color from bright red to muted steel blue
-	style.Code.Color = stringPtr("109")
-
 	return &ANSIStyleRenderer{
 		glamourStyle: style,
 		wordWrap:     0,
@@ -81,35 +96,7 @@ func NewANSIRendererWithStyle(styleName string) *ANSIStyleRenderer {
 // background, based on the COLORFGBG environment variable.
 // Returns true (dark) when the variable is missing or unparseable.
 func IsDarkBackground() bool {
-	colorfgbg := os.Getenv("COLORFGBG")
-	if colorfgbg == "" {
-		return true
-	}
-
-	parts := strings.Split(colorfgbg, ";")
-	if len(parts) < 2 {
-		return true
-	}
-
-	bgStr := strings.TrimSpace(parts[len(parts)-1])
-	bg, err := strconv.Atoi(bgStr)
-	if err != nil {
-		return true
-	}
-
-	// background >= 8 means light background (colors 8-15 are bright)
-	return bg < 8
-}
-
-// detectStyleFromEnvironment detects terminal theme using COLORFGBG.
-// Format: "foreground;background" (e.g., "15;0")
-// Background >= 8 indicates light background, < 8 indicates dark.
-// Defaults to dark on parse errors or missing variable.
-func detectStyleFromEnvironment() ansi.StyleConfig {
-	if !IsDarkBackground() {
-		return styles.LightStyleConfig
-	}
-	return styles.DarkStyleConfig
+	return theme.IsDarkBackground()
 }
 
 // WithCodeTheme returns a new renderer that uses a named Chroma style for code
@@ -129,10 +116,9 @@ func (r *ANSIStyleRenderer) WithCodeTheme(theme string) *ANSIStyleRenderer {
 		}
 	}
 
-	return &ANSIStyleRenderer{
-		glamourStyle: style,
-		wordWrap:     r.wordWrap,
-	}
+	c := *r
+	c.glamourStyle = style
+	return &c
 }
 
 // WithCodeBackground returns a new renderer with the specified code block
@@ -140,10 +126,9 @@ func (r *ANSIStyleRenderer) WithCodeTheme(theme string) *ANSIStyleRenderer {
 func (r *ANSIStyleRenderer) WithCodeBackground(color string) *ANSIStyleRenderer {
 	style := r.glamourStyle
 	style.CodeBlock.BackgroundColor = &color
-	return &ANSIStyleRenderer{
-		glamourStyle: style,
-		wordWrap:     r.wordWrap,
-	}
+	c := *r
+	c.glamourStyle = style
+	return &c
 }
 
 // WithCodeBorder returns a new renderer with the specified code block
@@ -151,33 +136,109 @@ func (r *ANSIStyleRenderer) WithCodeBackground(color string) *ANSIStyleRenderer
 func (r *ANSIStyleRenderer) WithCodeBorder(color string) *ANSIStyleRenderer {
 	style := r.glamourStyle
 	style.CodeBlock.Color = &color
-	return &ANSIStyleRenderer{
-		glamourStyle: style,
-		wordWrap:     r.wordWrap,
-	}
+	c := *r
+	c.glamourStyle = style
+	return &c
 }
 
 // WithWordWrap returns a new renderer with specified word wrap.
 func (r *ANSIStyleRenderer) WithWordWrap(cols int) *ANSIStyleRenderer {
-	return &ANSIStyleRenderer{
-		glamourStyle: r.glamourStyle,
-		wordWrap:     cols,
+	c := *r
+	c.wordWrap = cols
+	return &c
+}
+
+// WithColorProfile returns a new renderer limited to the colors of profile.
+// ProfileMonochrome drops all colors and keeps emphasis visible through bold,
+// underline, and reverse video.
+func (r *ANSIStyleRenderer) WithColorProfile(p ColorProfile) *ANSIStyleRenderer {
+	c := *r
+	c.colorProfile = p
+	return &c
+}
+
+// ColorProfile returns the renderer's color profile.
+func (r *ANSIStyleRenderer) ColorProfile() ColorProfile {
+	return r.colorProfile
+}
+
+// WithHyperlinks returns a new renderer that wraps link text in OSC 8
+// hyperlink sequences, so terminals that support them can open links even
+// when the URL is not shown. Relative links become absolute through
+// WithSource; ones that cannot be resolved stay plain text.
+func (r *ANSIStyleRenderer) WithHyperlinks(enabled bool) *ANSIStyleRenderer {
+	c := *r
+	c.hyperlinks = enabled
+	return &c
+}
+
+// WithWideBlocks returns a new renderer that keeps tables and code blocks at
+// their natural width instead of fitting them to the word wrap. Use it with
+// a session horizontal scroll mode so the overflow can be scrolled into view.
+func (r *ANSIStyleRenderer) WithWideBlocks(enabled bool) *ANSIStyleRenderer {
+	c := *r
+	c.wideBlocks = enabled
+	return &c
+}
+
+// WithSource returns a new renderer that resolves relative hyperlink targets
+// against the document at path, a local file or directory path or an HTTP
+// URL. Sessions set it for each document they render.
+func (r *ANSIStyleRenderer) WithSource(path string) *ANSIStyleRenderer {
+	c := *r
+	c.baseURL = sourceBaseURL(path)
+	return &c
+}
+
+// sourceBaseURL returns the URL that links in the document at path are
+// relative to: path itself for HTTP sources, otherwise a file:// URL.
+func sourceBaseURL(path string) string {
+	if path == "" || looksLikeHTTPURL(path) {
+		return path
 	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if strings.HasSuffix(path, string(filepath.Separator)) {
+		u.Path += "/"
+	}
+	return u.String()
 }
 
-var ansiSGRPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+var (
+	ansiSGRPattern  = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	ansiOSC8Pattern = regexp.MustCompile(`\x1b\]8;[^;\x07\x1b]*;[^\x07\x1b]*(?:\x07|\x1b\\)`)
+)
 
-// stripANSIAndMarkers removes both ANSI escape sequences and marker characters.
+// stripANSIAndMarkers removes ANSI escape sequences (SGR and OSC 8
+// hyperlinks) and marker characters.
 func stripANSIAndMarkers(s string) string {
+	s = ansiOSC8Pattern.ReplaceAllString(s, "")
 	s = ansiSGRPattern.ReplaceAllString(s, "")
 	return StripMarkers(s)
 }
 
 func (r *ANSIStyleRenderer) Render(markdown string) (RenderResult, error) {
-	tr, err := glamour.NewTermRenderer(
-		glamour.WithStyles(r.glamourStyle),
+	style := r.glamourStyle
+	if r.colorProfile == ProfileMonochrome {
+		style = monochromeStyle(style)
+	}
+	opts := []glamour.TermRendererOption{
+		glamour.WithStyles(style),
 		glamour.WithWordWrap(r.wordWrap),
-	)
+		glamour.WithColorProfile(r.colorProfile.termenvProfile()),
+		glamour.WithChromaFormatter(r.colorProfile.chromaFormatter()),
+		glamour.WithSourceMap(),
+	}
+	if r.hyperlinks {
+		opts = append(opts, glamour.WithHyperlinks(r.baseURL))
+	}
+	if r.wideBlocks {
+		opts = append(opts, glamour.WithWideBlocks())
+	}
+	tr, err := glamour.NewTermRenderer(opts...)
 	if err != nil {
 		return RenderResult{Lines: strings.Split(markdown, "\n"), Cleaner: LineCleanerFunc(func(s string) string { return s })}, err
 	}
@@ -187,9 +248,18 @@ func (r *ANSIStyleRenderer) Render(markdown string) (RenderResult, error) {
 		return RenderResult{Lines: strings.Split(markdown, "\n"), Cleaner: LineCleanerFunc(func(s string) string { return s })}, err
 	}
 
+	// columns are taken before compactSGR moves the byte offsets
 	lines := strings.Split(out, "\n")
+	cleaner := LineCleanerFunc(stripANSIAndMarkers)
+	sourceMap := newSourceMap(markdown, tr.SourceMap(), lines, cleaner)
+
+	if r.colorProfile == ProfileMonochrome {
+		lines = strings.Split(compactSGR(out), "\n")
+	}
+
 	return RenderResult{
-		Lines:   lines,
-		Cleaner: LineCleanerFunc(stripANSIAndMarkers),
+		Lines:     lines,
+		Cleaner:   cleaner,
+		SourceMap: sourceMap,
 	}, nil
 }
@@ -0,0 +1,71 @@
+package navidown
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStripANSIAndMarkers_OSC8(t *testing.T) {
+	in := "see \x1b]8;;https://example.com\x1b\\\x1b[4mlink\x1b[0m\x1b]8;;\x1b\\ and \x1b]8;id=1;https://x.org\x07bell\x1b]8;;\x07"
+	if got := stripANSIAndMarkers(in); got != "see link and bell" {
+		t.Errorf("stripANSIAndMarkers = %q", got)
+	}
+}
+
+func TestSourceBaseURL(t *testing.T) {
+	abs, _ := filepath.Abs("docs")
+	tests := map[string]string{
+		"":                                  "",
+		"https://example.com/a/b.md":        "https://example.com/a/b.md",
+		filepath.Join("docs", "a.md"):       "file://" + filepath.ToSlash(abs) + "/a.md",
+		"docs" + string(filepath.Separator): "file://" + filepath.ToSlash(abs) + "/",
+	}
+	for in, want := range tests {
+		if got := sourceBaseURL(in); got != want {
+			t.Errorf("sourceBaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestANSIStyleRenderer_Hyperlinks(t *testing.T) {
+	md := "Read the [guide](guide.md#setup) or [the site](https://example.com).\n"
+
+	plain, err := NewANSIRenderer().Render(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(plain.Lines, "\n"), "\x1b]8;") {
+		t.Error("hyperlinks should be off by default")
+	}
+
+	s := New(Options{Renderer: NewANSIRenderer().WithHyperlinks(true)})
+	src := filepath.Join(t.TempDir(), "readme.md")
+	if err := s.SetMarkdownWithSource(md, src, false); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(s.RenderedLines(), "\n")
+	wantTarget := "file://" + filepath.ToSlash(filepath.Dir(src)) + "/guide.md#setup"
+	if !strings.Contains(out, "\x1b]8;;"+wantTarget+"\x1b\\") {
+		t.Errorf("relative link not made absolute (want %s): %q", wantTarget, out)
+	}
+	if !strings.Contains(out, "\x1b]8;;https://example.com\x1b\\") {
+		t.Errorf("absolute link missing: %q", out)
+	}
+
+	// correlation sees through the hyperlink sequences
+	links := 0
+	for _, e := range s.Elements() {
+		if e.Type != NavElementURL {
+			continue
+		}
+		links++
+		line := []rune(stripANSIAndMarkers(s.RenderedLines()[e.StartLine]))
+		if got := string(line[e.StartCol:e.EndCol]); got != e.Text {
+			t.Errorf("link %q located at text %q", e.Text, got)
+		}
+	}
+	if links != 2 {
+		t.Errorf("found %d links, want 2", links)
+	}
+}
@@ -78,10 +78,10 @@ func TestDetectStyleFromEnvironment(t *testing.T) {
 				}
 			}
 
-			style := detectStyleFromEnvironment()
+			style := NewANSIRendererWithStyle("auto").glamourStyle
 
 			// Check if we got the right style config
-			isDark := style.Document.Color == styles.DarkStyleConfig.Document.Color
+			isDark := *style.Document.Color == *styles.DarkStyleConfig.Document.Color
 			if isDark != tt.wantDark {
 				t.Errorf("%s: got dark=%v, want dark=%v", tt.description, isDark, tt.wantDark)
 			}
@@ -168,7 +168,7 @@ func TestNewANSIRendererWithStyle_RegisteredStyle(t *testing.T) {
 
 	// dracula should differ from dark in at least the document color
 	dark := NewANSIRendererWithStyle("dark")
-	if renderer.glamourStyle.Document.Color == dark.glamourStyle.Document.Color {
+	if *renderer.glamourStyle.Document.Color == *dark.glamourStyle.Document.Color {
 		t.Error("dracula style should differ from dark style")
 	}
 }
@@ -0,0 +1,207 @@
+package navidown
+
+import (
+	"strings"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+)
+
+// SourceKind tells what kind of markdown node a SourceMapEntry stands for.
+type SourceKind int
+
+const (
+	SourceBlock   SourceKind = iota // a paragraph, list item text, rule or table
+	SourceHeading                   // a heading
+	SourceLink                      // a link, autolink or <details> summary
+	SourceCode                      // a code block, rendered a line per source line
+)
+
+// SourceMapEntry ties a node of the markdown source to where it was
+// rendered. Columns count cells of the cleaned line, as NavElement columns
+// do; a node that wraps ends on a later line.
+type SourceMapEntry struct {
+	Kind       SourceKind
+	Offset     int // byte offset of the node in the source, -1 if unknown
+	SourceLine int // 1-based line of Offset, 0 if unknown
+
+	Line, Col       int // where the node starts in the rendered lines
+	EndLine, EndCol int // where it ends
+}
+
+// SourceMap maps rendered lines back to the markdown source. Renderers that
+// can tell where each node was rendered return one in RenderResult.
+type SourceMap struct {
+	Entries []SourceMapEntry // in document order
+
+	source string // the markdown rendered
+}
+
+// newSourceMap converts the entries of the glamour fork for source, which
+// locate nodes by byte offsets into the rendered lines, to cell columns.
+func newSourceMap(source string, entries []ansi.SourceMapEntry, lines []string, cleaner LineCleaner) *SourceMap {
+	column := func(line, at int) int {
+		if line < 0 || line >= len(lines) {
+			return 0
+		}
+		return stringColumns(cleaner.Clean(lines[line][:min(at, len(lines[line]))]))
+	}
+	m := &SourceMap{Entries: make([]SourceMapEntry, 0, len(entries)), source: source}
+	for _, e := range entries {
+		m.Entries = append(m.Entries, SourceMapEntry{
+			Kind:       SourceKind(e.Kind),
+			Offset:     e.Offset,
+			SourceLine: e.SourceLine,
+			Line:       e.Line,
+			Col:        column(e.Line, e.Byte),
+			EndLine:    e.EndLine,
+			EndCol:     column(e.EndLine, e.EndByte),
+		})
+	}
+	return m
+}
+
+// At returns the innermost entry whose rendered span holds line, such as a
+// paragraph or the code block the line belongs to.
+func (m *SourceMap) At(line int) (SourceMapEntry, bool) {
+	if m == nil {
+		return SourceMapEntry{}, false
+	}
+	// entries are in document order, so the last one to start at or before
+	// line and still span it is the innermost
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		if e := m.Entries[i]; e.Line <= line && line <= e.EndLine {
+			return e, true
+		}
+	}
+	return SourceMapEntry{}, false
+}
+
+// SourceLineAt returns the 1-based source line of the node rendered at
+// line, or 0 if the line belongs to none (such as blank lines between
+// blocks). Within a code block it is the line of code shown.
+func (m *SourceMap) SourceLineAt(line int) int {
+	e, ok := m.At(line)
+	if !ok || e.SourceLine == 0 {
+		return 0
+	}
+	if e.Kind == SourceCode {
+		return e.SourceLine + line - e.Line
+	}
+	return e.SourceLine
+}
+
+// lineOf returns the 1-based line of offset in the rendered markdown.
+func (m *SourceMap) lineOf(offset int) int {
+	if m == nil || offset < 0 || offset > len(m.source) {
+		return 0
+	}
+	return strings.Count(m.source[:offset], "\n") + 1
+}
+
+// Find returns the first entry of the node at offset in the source.
+func (m *SourceMap) Find(offset int) (SourceMapEntry, bool) {
+	if m == nil {
+		return SourceMapEntry{}, false
+	}
+	for _, e := range m.Entries {
+		if e.Offset == offset {
+			return e, true
+		}
+	}
+	return SourceMapEntry{}, false
+}
+
+// remap moves the entries from the lines before image post-processing to
+// the lines after it. Lines without image tokens pass unchanged, and a line
+// with tokens may become several, so lines are matched in order. It returns
+// nil if the lines cannot be matched.
+func (m *SourceMap) remap(before, after []string) *SourceMap {
+	if m == nil || before == nil {
+		return m
+	}
+	index := make([]int, len(before))
+	next := 0
+	for i, line := range before {
+		if ContainsImageToken(line) {
+			index[i] = min(next, max(len(after)-1, 0))
+			continue
+		}
+		k := next
+		for k < len(after) && after[k] != line {
+			k++
+		}
+		if k == len(after) {
+			return nil
+		}
+		index[i], next = k, k+1
+	}
+
+	moved := &SourceMap{Entries: make([]SourceMapEntry, 0, len(m.Entries)), source: m.source}
+	for _, e := range m.Entries {
+		if e.Line >= len(index) || e.EndLine >= len(index) {
+			continue
+		}
+		e.Line, e.EndLine = index[e.Line], max(index[e.EndLine], index[e.Line])
+		moved.Entries = append(moved.Entries, e)
+	}
+	return moved
+}
+
+// sourceMapKind returns the kind of source map entry an element is rendered
+// as, and false for elements the map does not locate.
+func sourceMapKind(elem *NavElement) (SourceKind, bool) {
+	switch elem.Type {
+	case NavElementHeader:
+		return SourceHeading, true
+	case NavElementURL, NavElementDetails:
+		return SourceLink, true
+	}
+	return 0, false
+}
+
+// correlateFromSourceMap positions the elements found in the source map and
+// reports which ones it positioned. Elements sharing a source offset (as
+// links built from one HTML block do) take the entries at that offset in
+// order. An element spanning lines is kept to its first line.
+func (v *MarkdownSession) correlateFromSourceMap() []bool {
+	if v.sourceMap == nil || len(v.sourceMap.Entries) == 0 {
+		return nil
+	}
+	type key struct {
+		kind   SourceKind
+		offset int
+	}
+	byKey := map[key][]SourceMapEntry{}
+	for _, e := range v.sourceMap.Entries {
+		k := key{e.Kind, e.Offset}
+		byKey[k] = append(byKey[k], e)
+	}
+
+	mapped := make([]bool, len(v.elements))
+	for i := range v.elements {
+		elem := &v.elements[i]
+		kind, ok := sourceMapKind(elem)
+		if !ok {
+			continue
+		}
+		k := key{kind, elem.sourceOffset}
+		entries := byKey[k]
+		if len(entries) == 0 {
+			continue
+		}
+		e := entries[0]
+		byKey[k] = entries[1:]
+		if e.Line < 0 || e.Line >= len(v.renderedLines) {
+			continue
+		}
+
+		endCol := e.EndCol
+		if e.EndLine != e.Line {
+			endCol = stringColumns(strings.TrimRight(v.cleaner.Clean(v.renderedLines[e.Line]), " "))
+		}
+		elem.StartLine, elem.EndLine = e.Line, e.Line
+		elem.StartCol, elem.EndCol = e.Col, endCol
+		mapped[i] = true
+	}
+	return mapped
+}
@@ -0,0 +1,112 @@
+package navidown
+
+import (
+	"strings"
+	"testing"
+)
+
+const sourceMapDoc = "# Title\n\nSome text with a [link](https://example.com).\n\n```go\nfmt.Println()\n```\n"
+
+// lineWith returns the index of the first rendered line containing s.
+func lineWith(t *testing.T, v *MarkdownSession, s string) int {
+	t.Helper()
+	for i, line := range v.RenderedLines() {
+		if strings.Contains(stripANSIAndMarkers(line), s) {
+			return i
+		}
+	}
+	t.Fatalf("no line with %q", s)
+	return -1
+}
+
+// elementText returns the cleaned text an element's columns cover.
+func elementText(v *MarkdownSession, e NavElement) string {
+	cells := []rune(stripANSIAndMarkers(v.RenderedLines()[e.StartLine]))
+	if e.EndCol > len(cells) {
+		return ""
+	}
+	return string(cells[e.StartCol:e.EndCol])
+}
+
+func TestSourceMap_Session(t *testing.T) {
+	v := New(Options{})
+	if err := v.SetMarkdown(sourceMapDoc); err != nil {
+		t.Fatal(err)
+	}
+	m := v.SourceMap()
+	if m == nil {
+		t.Fatal("no source map")
+	}
+	for s, want := range map[string]int{"Title": 1, "Some text": 3, "fmt.Println": 6} {
+		if got := m.SourceLineAt(lineWith(t, v, s)); got != want {
+			t.Errorf("source line of %q = %d, want %d", s, got, want)
+		}
+	}
+	if got := m.SourceLineAt(0); got != 0 {
+		t.Errorf("blank line mapped to source line %d", got)
+	}
+
+	e, ok := m.Find(strings.Index(sourceMapDoc, "link]"))
+	if !ok || e.Kind != SourceLink || e.Line != lineWith(t, v, "Some text") {
+		t.Errorf("unexpected link entry %+v", e)
+	}
+}
+
+// noCorrelator finds nothing, so only the source map can place elements.
+type noCorrelator struct{}
+
+func (noCorrelator) CorrelatePosition(*NavElement, []string, LineCleaner) (int, int, int, bool) {
+	return 0, 0, 0, false
+}
+
+func TestCorrelatePositions_UsesSourceMap(t *testing.T) {
+	v := New(Options{Correlator: noCorrelator{}})
+	if err := v.SetMarkdown(sourceMapDoc + "\n<details><summary>More</summary>body</details>\n"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Title": "Title", "link": "link", "More": "▸ More"}
+	for _, e := range v.Elements() {
+		if got := elementText(v, e); got != want[e.Text] {
+			t.Errorf("element %q covers %q, want %q", e.Text, got, want[e.Text])
+		}
+	}
+}
+
+// tallImageProcessor turns each line with an image into three lines.
+type tallImageProcessor struct{}
+
+func (tallImageProcessor) ProcessImageTokens(lines []string, _ string, _ int) []string {
+	var out []string
+	for _, line := range lines {
+		if ContainsImageToken(line) {
+			out = append(out, "[image]", "", "")
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestSourceMap_FollowsImageLines(t *testing.T) {
+	v := New(Options{Correlator: noCorrelator{}, ImagePostProcessor: tallImageProcessor{}})
+	md := "![photo](pic.png)\n\nSee [after](https://example.com).\n"
+	if err := v.SetMarkdown(md); err != nil {
+		t.Fatal(err)
+	}
+	line := lineWith(t, v, "See after")
+	if got := v.SourceMap().SourceLineAt(line); got != 3 {
+		t.Errorf("source line after the image = %d, want 3", got)
+	}
+	for _, e := range v.Elements() {
+		if e.Type == NavElementURL && (e.StartLine != line || elementText(v, e) != "after") {
+			t.Errorf("link placed at line %d covering %q", e.StartLine, elementText(v, e))
+		}
+	}
+
+	// going back restores the map with the page
+	_ = v.SetMarkdownWithSource("# Other\n", "other.md", true)
+	v.GoBack()
+	if got := v.SourceMap().SourceLineAt(line); got != 3 {
+		t.Errorf("source line after going back = %d, want 3", got)
+	}
+}
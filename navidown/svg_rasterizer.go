@@ -92,18 +92,26 @@ func svgCacheKey(svgData []byte, targetWidth int) string {
 func (c *CachingSVGRasterizer) Rasterize(svgData []byte, targetWidth int) ([]byte, error) {
 	key := svgCacheKey(svgData, targetWidth)
 
-	// in-memory cache
+	diskPath := filepath.Join(c.workDir, key+".png")
+
+	// in-memory cache; the file's time still records the use
 	if cached, ok := c.cache.Load(key); ok {
 		data, _ := cached.([]byte)
+		if info, err := os.Stat(diskPath); err == nil {
+			SharedCacheManager().hit(diskPath, info)
+		}
 		return data, nil
 	}
 
 	// disk cache
-	diskPath := filepath.Join(c.workDir, key+".png")
 	if data, err := os.ReadFile(diskPath); err == nil { // #nosec G703 -- diskPath from filepath.Join(workDir, hash+".png")
 		c.cache.Store(key, data)
+		if info, err := os.Stat(diskPath); err == nil {
+			SharedCacheManager().hit(diskPath, info)
+		}
 		return data, nil
 	}
+	SharedCacheManager().miss()
 
 	// run rasterizer
 	pngData, err := c.inner.Rasterize(svgData, targetWidth)
@@ -112,7 +120,9 @@ func (c *CachingSVGRasterizer) Rasterize(svgData []byte, targetWidth int) ([]byt
 	}
 
 	// write to disk (best effort)
-	_ = os.WriteFile(diskPath, pngData, 0600) // #nosec G703 -- diskPath from filepath.Join(workDir, hash+".png")
+	if err := os.WriteFile(diskPath, pngData, 0600); err == nil { // #nosec G703 -- diskPath from filepath.Join(workDir, hash+".png")
+		SharedCacheManager().added()
+	}
 
 	c.cache.Store(key, pngData)
 	return pngData, nil
@@ -0,0 +1,122 @@
+package theme
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+)
+
+// Element names a styled markdown element. The values are the element keys
+// used in theme files.
+type Element string
+
+const (
+	Document              Element = "document"
+	Paragraph             Element = "paragraph"
+	BlockQuote            Element = "block_quote"
+	List                  Element = "list"
+	Heading               Element = "heading" // all headings; H1-H6 override it
+	H1                    Element = "h1"
+	H2                    Element = "h2"
+	H3                    Element = "h3"
+	H4                    Element = "h4"
+	H5                    Element = "h5"
+	H6                    Element = "h6"
+	Text                  Element = "text"
+	Emph                  Element = "emph"
+	Strong                Element = "strong"
+	Strikethrough         Element = "strikethrough"
+	HorizontalRule        Element = "hr"
+	Item                  Element = "item"
+	Enumeration           Element = "enumeration"
+	Task                  Element = "task"
+	Link                  Element = "link"
+	LinkText              Element = "link_text"
+	Image                 Element = "image"
+	ImageText             Element = "image_text"
+	Code                  Element = "code"
+	CodeBlock             Element = "code_block"
+	Table                 Element = "table"
+	DefinitionList        Element = "definition_list"
+	DefinitionTerm        Element = "definition_term"
+	DefinitionDescription Element = "definition_description"
+)
+
+// Style is the style of one element. When overriding, nil and empty fields
+// leave the current value unchanged; set a flag to Bool(false) to turn off a
+// style inherited from an enclosing element (e.g. a bold Heading for H1).
+// Fields that do not apply to an element are ignored.
+type Style struct {
+	Color      *string `json:"color,omitempty"`
+	Background *string `json:"background_color,omitempty"`
+
+	Bold       *bool `json:"bold,omitempty"`
+	Italic     *bool `json:"italic,omitempty"`
+	Underline  *bool `json:"underline,omitempty"`
+	CrossedOut *bool `json:"crossed_out,omitempty"`
+	Faint      *bool `json:"faint,omitempty"`
+	Inverse    *bool `json:"inverse,omitempty"`
+	Upper      *bool `json:"upper,omitempty"`
+
+	Prefix      string `json:"prefix,omitempty"`       // before the element's text
+	Suffix      string `json:"suffix,omitempty"`       // after the element's text
+	BlockPrefix string `json:"block_prefix,omitempty"` // before the block
+	BlockSuffix string `json:"block_suffix,omitempty"` // after the block
+	Format      string `json:"format,omitempty"`       // template, e.g. "Image: {{.text}} →"
+
+	// block elements
+	Indent      *uint   `json:"indent,omitempty"`
+	IndentToken *string `json:"indent_token,omitempty"` // e.g. "│ " for block quotes
+	Margin      *uint   `json:"margin,omitempty"`
+
+	// List
+	LevelIndent uint `json:"level_indent,omitempty"`
+
+	// Table
+	CenterSeparator *string `json:"center_separator,omitempty"`
+	ColumnSeparator *string `json:"column_separator,omitempty"`
+	RowSeparator    *string `json:"row_separator,omitempty"`
+}
+
+// Bool returns a pointer to v, for Style fields.
+func Bool(v bool) *bool { return &v }
+
+// String returns a pointer to v, for Style fields.
+func String(v string) *string { return &v }
+
+// Uint returns a pointer to v, for Style fields.
+func Uint(v uint) *uint { return &v }
+
+// Style returns the current style of elem.
+func (t *Theme) Style(elem Element) (Style, error) {
+	var s Style
+	if !isElement(elem) {
+		return s, fmt.Errorf("%w: unknown element %q", ErrInvalid, elem)
+	}
+	m, err := toMap(t.style)
+	if err != nil {
+		return s, err
+	}
+	if err := remarshal(m[string(elem)], &s); err != nil {
+		return Style{}, err
+	}
+	return s, nil
+}
+
+// With returns a copy of t with the non-empty fields of s applied to elem.
+func (t *Theme) With(elem Element, s Style) (*Theme, error) {
+	if !isElement(elem) {
+		return nil, fmt.Errorf("%w: unknown element %q", ErrInvalid, elem)
+	}
+	overlay, err := toMap(s)
+	if err != nil {
+		return nil, err
+	}
+	return t.merged(map[string]any{string(elem): overlay})
+}
+
+func isElement(elem Element) bool {
+	_, ok := jsonFields(reflect.TypeOf(ansi.StyleConfig{}))[string(elem)]
+	return ok
+}
@@ -0,0 +1,214 @@
+package theme
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+)
+
+// layeredFile is the layered theme format. A file containing none of these
+// keys is read as a complete style config (the format MarshalJSON writes).
+//
+//	inherit = "dracula"        # optional built-in base
+//	[colors]                   # optional palette, applied with WithPalette
+//	blue = "#8be9fd"
+//	[style.heading]            # optional per-element overrides
+//	bold = false
+type layeredFile struct {
+	Inherit string            `json:"inherit,omitempty"`
+	Colors  *Palette          `json:"colors,omitempty"`
+	Style   *ansi.StyleConfig `json:"style,omitempty"`
+}
+
+// Load reads a JSON (.json) or TOML (.toml) theme file. See Parse for the format.
+func Load(path string) (*Theme, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	data, err := os.ReadFile(path) // #nosec G304 -- theme path is chosen by the user
+	if err != nil {
+		return nil, err
+	}
+	t, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads theme data in format "json" or "toml". The data is either a
+// complete style config, or a layered theme with any of these keys, applied
+// in order:
+//
+//   - inherit: name of a built-in theme to start from
+//   - colors: a Palette (fg, bg, muted, yellow, orange, purple, green, blue,
+//     cyan, red, chroma_*) expanded into a full style; unset colors keep
+//     the inherited ones
+//   - style: element keys (see Element) overriding individual elements
+//
+// Unknown keys are reported with the closest valid key.
+func Parse(data []byte, format string) (*Theme, error) {
+	raw, err := decode(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	_, hasInherit := raw["inherit"]
+	_, hasColors := raw["colors"]
+	_, hasStyle := raw["style"]
+	if !hasInherit && !hasColors && !hasStyle {
+		if err := checkKeys(raw, reflect.TypeOf(ansi.StyleConfig{}), ""); err != nil {
+			return nil, err
+		}
+		style, err := fromMap(raw)
+		if err != nil {
+			return nil, err
+		}
+		return &Theme{style: style}, nil
+	}
+	if err := checkKeys(raw, reflect.TypeOf(layeredFile{}), ""); err != nil {
+		return nil, err
+	}
+
+	var layers layeredFile
+	if err := remarshal(raw, &layers); err != nil {
+		return nil, err
+	}
+
+	t := &Theme{}
+	if layers.Inherit != "" {
+		if t, err = Builtin(layers.Inherit); err != nil {
+			return nil, err
+		}
+	}
+	if layers.Colors != nil {
+		if t, err = t.WithPalette(*layers.Colors); err != nil {
+			return nil, err
+		}
+	}
+	if style, ok := raw["style"].(map[string]any); ok {
+		if t, err = t.merged(style); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// decode parses JSON or TOML into a generic map, so keys can be checked
+// before decoding and layers merged key by key.
+func decode(data []byte, format string) (map[string]any, error) {
+	raw := map[string]any{}
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	case "toml":
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q (use json or toml)", ErrInvalid, format)
+	}
+	return raw, nil
+}
+
+// checkKeys reports the first key in raw that has no matching json field in
+// t, suggesting the closest valid key.
+func checkKeys(raw map[string]any, t reflect.Type, prefix string) error {
+	fields := jsonFields(t)
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		ft, ok := fields[key]
+		if !ok {
+			valid := make([]string, 0, len(fields))
+			for name := range fields {
+				valid = append(valid, name)
+			}
+			slices.Sort(valid)
+			if guess := closestKey(key, valid); guess != "" {
+				return fmt.Errorf("%w: unknown key %q, did you mean %q?", ErrInvalid, prefix+key, prefix+guess)
+			}
+			return fmt.Errorf("%w: unknown key %q (valid keys: %s)", ErrInvalid, prefix+key, strings.Join(valid, ", "))
+		}
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		nested, ok := raw[key].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %q must be a table of keys", ErrInvalid, prefix+key)
+		}
+		if err := checkKeys(nested, ft, prefix+key+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFields maps the json names of t's fields, including those of embedded
+// structs, to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			for name, ft := range jsonFields(f.Type) {
+				fields[name] = ft
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// closestKey returns the valid key within a small edit distance of key, if any.
+func closestKey(key string, valid []string) string {
+	best, bestDist := "", 3
+	for _, v := range valid {
+		if d := editDistance(strings.ToLower(key), v); d < bestDist {
+			best, bestDist = v, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
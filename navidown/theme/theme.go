@@ -0,0 +1,217 @@
+// Package theme provides the styles used by navidown's ANSI renderer: the
+// built-in themes, themes derived from a color palette, per-element
+// overrides, and theme files. Pass a Theme to navidown.NewANSIRendererWithTheme.
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/ansi"
+	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+)
+
+// ErrUnknown is returned for built-in theme names that do not exist.
+var ErrUnknown = errors.New("unknown theme")
+
+// ErrInvalid is returned for theme data that cannot be parsed or contains
+// unknown keys.
+var ErrInvalid = errors.New("invalid theme")
+
+// Auto selects the dark or light theme from the terminal background.
+const Auto = styles.AutoStyle
+
+// Theme is a complete set of element styles. Themes are immutable; the With
+// methods return modified copies.
+type Theme struct {
+	style ansi.StyleConfig
+}
+
+// Palette is the set of colors a theme is derived from. Colors are hex
+// ("#8be9fd") or ANSI 256 ("117") values; see FromPalette for where each
+// is used.
+type Palette struct {
+	Fg    string `json:"fg,omitempty"`    // document text, code text, punctuation
+	Bg    string `json:"bg,omitempty"`    // code block background
+	Muted string `json:"muted,omitempty"` // horizontal rule, code comments
+
+	Yellow string `json:"yellow,omitempty"` // block quotes, emphasis, strings in code
+	Orange string `json:"orange,omitempty"` // strong text, code block border
+	Purple string `json:"purple,omitempty"` // headings, constants in code
+	Green  string `json:"green,omitempty"`  // inline code, functions in code
+	Blue   string `json:"blue,omitempty"`   // links, list numbers, types in code
+	Cyan   string `json:"cyan,omitempty"`   // link text, images
+	Red    string `json:"red,omitempty"`    // deletions and errors in code
+
+	// syntax highlighting colors
+	ChromaKeyword      string `json:"chroma_keyword,omitempty"`       // keywords and operators
+	ChromaNumber       string `json:"chroma_number,omitempty"`        // numbers (empty = uncolored)
+	ChromaStringEscape string `json:"chroma_string_escape,omitempty"` // string escapes
+	ChromaPreproc      string `json:"chroma_preproc,omitempty"`       // preprocessor directives
+	ChromaTag          string `json:"chroma_tag,omitempty"`           // markup tags
+	ChromaAttribute    string `json:"chroma_attribute,omitempty"`     // attributes (empty = Green)
+	ChromaDecorator    string `json:"chroma_decorator,omitempty"`     // decorators (empty = Green)
+}
+
+// Names returns the built-in theme names accepted by Builtin, including Auto.
+func Names() []string {
+	names := []string{Auto}
+	for name := range styles.DefaultStyles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Builtin returns the named built-in theme. Auto picks "dark" or "light"
+// using IsDarkBackground.
+func Builtin(name string) (*Theme, error) {
+	if name == Auto {
+		name = styles.DarkStyle
+		if !IsDarkBackground() {
+			name = styles.LightStyle
+		}
+	}
+	s, ok := styles.DefaultStyles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (built-in themes: %s)", ErrUnknown, name, strings.Join(Names(), ", "))
+	}
+	style := *s
+
+	// soften inline code color from bright red to muted steel blue
+	style.Code.Color = String("109")
+	return &Theme{style: style}, nil
+}
+
+// FromPalette derives a theme from a palette, with bold headings, underlined
+// links, italic block quotes, and "#" heading prefixes.
+func FromPalette(p Palette) *Theme {
+	return &Theme{style: styles.BuildStyleConfig(styles.ThemeColors(p))}
+}
+
+// WithPalette returns a copy of t recolored from p, as FromPalette would
+// color it. Colors left empty in p keep their current values.
+func (t *Theme) WithPalette(p Palette) (*Theme, error) {
+	palette, err := toMap(styles.BuildStyleConfig(styles.ThemeColors(p)))
+	if err != nil {
+		return nil, err
+	}
+	// an unset palette color must not blank out the current one
+	pruneEmptyColors(palette)
+	return t.merged(palette)
+}
+
+// MarshalJSON encodes the theme as a complete style config, the format read
+// by Parse and Load.
+func (t *Theme) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.style)
+}
+
+// StyleConfig returns the theme's element styles. The copy shares pointer
+// fields with the theme; replace them rather than writing through them.
+func (t *Theme) StyleConfig() ansi.StyleConfig {
+	return t.style
+}
+
+// merged returns a copy of t with overlay merged key by key.
+func (t *Theme) merged(overlay map[string]any) (*Theme, error) {
+	m, err := toMap(t.style)
+	if err != nil {
+		return nil, err
+	}
+	mergeMaps(m, overlay)
+	style, err := fromMap(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Theme{style: style}, nil
+}
+
+// IsDarkBackground returns true if the terminal appears to have a dark
+// background, based on the COLORFGBG environment variable.
+// Returns true (dark) when the variable is missing or unparseable.
+func IsDarkBackground() bool {
+	colorfgbg := os.Getenv("COLORFGBG")
+	if colorfgbg == "" {
+		return true
+	}
+
+	parts := strings.Split(colorfgbg, ";")
+	if len(parts) < 2 {
+		return true
+	}
+
+	bgStr := strings.TrimSpace(parts[len(parts)-1])
+	bg, err := strconv.Atoi(bgStr)
+	if err != nil {
+		return true
+	}
+
+	// background >= 8 means light background (colors 8-15 are bright)
+	return bg < 8
+}
+
+// toMap converts v to a generic map through JSON, so styles can be merged
+// key by key.
+func toMap(v any) (map[string]any, error) {
+	m := map[string]any{}
+	if err := remarshal(v, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func fromMap(m map[string]any) (ansi.StyleConfig, error) {
+	var style ansi.StyleConfig
+	if err := remarshal(m, &style); err != nil {
+		return ansi.StyleConfig{}, err
+	}
+	return style, nil
+}
+
+// remarshal converts v to out through JSON.
+func remarshal(v, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return nil
+}
+
+// mergeMaps overlays src onto dst, merging nested tables.
+func mergeMaps(dst, src map[string]any) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if existing, ok := dst[k].(map[string]any); ok {
+				mergeMaps(existing, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// pruneEmptyColors removes empty color values and the tables left empty.
+func pruneEmptyColors(m map[string]any) {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			if v == "" && (k == "color" || k == "background_color") {
+				delete(m, k)
+			}
+		case map[string]any:
+			pruneEmptyColors(v)
+			if len(v) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package theme
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	names := Names()
+	for _, want := range []string{Auto, "dark", "light", "dracula", "nord"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("Names() missing %q: %v", want, names)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("Names() not sorted: %v", names)
+	}
+	for _, name := range names {
+		if _, err := Builtin(name); err != nil {
+			t.Errorf("Builtin(%q): %v", name, err)
+		}
+	}
+}
+
+func TestBuiltin_Unknown(t *testing.T) {
+	_, err := Builtin("no-such-theme")
+	if !errors.Is(err, ErrUnknown) {
+		t.Fatalf("error = %v, want ErrUnknown", err)
+	}
+}
+
+func TestBuiltin_Auto(t *testing.T) {
+	t.Setenv("COLORFGBG", "0;15")
+	auto, err := Builtin(Auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	light, _ := Builtin("light")
+	if got, want := *styleOf(t, auto, Document).Color, *styleOf(t, light, Document).Color; got != want {
+		t.Errorf("auto on a light background: document color %q, want light's %q", got, want)
+	}
+}
+
+func TestBuiltin_StyleConfigIsACopy(t *testing.T) {
+	dark, _ := Builtin("dark")
+	style := dark.StyleConfig()
+	if got := *style.Code.Color; got != "109" {
+		t.Errorf("inline code color %q, want 109", got)
+	}
+	style.Code.Color = nil
+	if dark.StyleConfig().Code.Color == nil {
+		t.Error("changing a StyleConfig copy changed the theme")
+	}
+}
+
+func TestFromPalette(t *testing.T) {
+	th := FromPalette(Palette{Fg: "#eeeeee", Blue: "#0000ff", Purple: "#aa00aa"})
+
+	if c := styleOf(t, th, Link).Color; c == nil || *c != "#0000ff" {
+		t.Errorf("link color = %v, want #0000ff", c)
+	}
+	heading := styleOf(t, th, Heading)
+	if heading.Color == nil || *heading.Color != "#aa00aa" {
+		t.Errorf("heading color = %v, want #aa00aa", heading.Color)
+	}
+	if heading.Bold == nil || !*heading.Bold {
+		t.Error("palette themes should have bold headings")
+	}
+	if got := styleOf(t, th, H2).Prefix; got != "## " {
+		t.Errorf("h2 prefix = %q, want %q", got, "## ")
+	}
+}
+
+func TestWith(t *testing.T) {
+	base, err := Builtin("dracula")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := base
+	for elem, s := range map[Element]Style{
+		Heading:    {Color: String("#123456")},
+		H1:         {Bold: Bool(false), Prefix: "» "},
+		Table:      {ColumnSeparator: String("|")},
+		BlockQuote: {IndentToken: String("> ")},
+	} {
+		if th, err = th.With(elem, s); err != nil {
+			t.Fatalf("With(%s): %v", elem, err)
+		}
+	}
+
+	heading := styleOf(t, th, Heading)
+	if *heading.Color != "#123456" {
+		t.Errorf("heading color = %q, want #123456", *heading.Color)
+	}
+	// fields not overridden are kept
+	if heading.Bold == nil || !*heading.Bold {
+		t.Error("heading bold should be kept from dracula")
+	}
+	h1 := styleOf(t, th, H1)
+	if h1.Bold == nil || *h1.Bold || h1.Prefix != "» " {
+		t.Errorf("h1 = %+v, want explicit non-bold with prefix", h1)
+	}
+	if sep := styleOf(t, th, Table).ColumnSeparator; sep == nil || *sep != "|" {
+		t.Errorf("table column separator = %v, want |", sep)
+	}
+	if tok := styleOf(t, th, BlockQuote).IndentToken; tok == nil || *tok != "> " {
+		t.Errorf("block quote indent token = %v, want \"> \"", tok)
+	}
+
+	// the original is unchanged
+	if *styleOf(t, base, Heading).Color == "#123456" {
+		t.Error("With mutated the original theme")
+	}
+	// unknown elements are reported
+	if _, err := th.With("nope", Style{Color: String("1")}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("With(unknown) error = %v, want ErrInvalid", err)
+	}
+	if _, err := th.Style("nope"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Style(unknown) error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestWithPalette_KeepsUnsetColors(t *testing.T) {
+	base, err := Builtin("nord")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, err := base.WithPalette(Palette{Blue: "#0000ff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *styleOf(t, th, Link).Color; got != "#0000ff" {
+		t.Errorf("link color = %q, want #0000ff", got)
+	}
+	if got, want := *styleOf(t, th, Heading).Color, *styleOf(t, base, Heading).Color; got != want {
+		t.Errorf("heading color = %q, want nord's %q", got, want)
+	}
+}
+
+func TestMarshalJSON_RoundTrip(t *testing.T) {
+	th, err := FromPalette(Palette{Purple: "#aa00aa"}).With(Link, Style{Underline: Bool(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := th.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data, "json")
+	if err != nil {
+		t.Fatalf("Parse(MarshalJSON()): %v", err)
+	}
+	if got := *styleOf(t, parsed, Heading).Color; got != "#aa00aa" {
+		t.Errorf("heading color = %q, want #aa00aa", got)
+	}
+	if u := styleOf(t, parsed, Link).Underline; u == nil || *u {
+		t.Errorf("link underline = %v, want explicit false", u)
+	}
+}
+
+// styleOf returns th's style for elem, failing the test on error.
+func styleOf(t *testing.T, th *Theme, elem Element) Style {
+	t.Helper()
+	s, err := th.Style(elem)
+	if err != nil {
+		t.Fatalf("Style(%s): %v", elem, err)
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package navidown
+
+import (
+	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+	"github.com/boolean-maybe/navidown/navidown/theme"
+)
+
+// ErrInvalidTheme is returned for theme files that cannot be parsed or
+// contain unknown keys.
+var ErrInvalidTheme = theme.ErrInvalid
+
+// ErrUnknownTheme is returned when a theme inherits from a built-in style
+// that does not exist.
+var ErrUnknownTheme = theme.ErrUnknown
+
+// NewANSIRendererWithTheme creates a renderer from a theme built with the
+// theme package. A nil theme means the dark style.
+func NewANSIRendererWithTheme(t *theme.Theme) *ANSIStyleRenderer {
+	if t == nil {
+		return NewANSIRendererWithStyle(styles.DarkStyle)
+	}
+	return newANSIRenderer(t.StyleConfig())
+}
+
+// NewANSIRendererFromThemeFile creates a renderer from a JSON (.json) or TOML
+// (.toml) theme file. See theme.Parse for the format.
+func NewANSIRendererFromThemeFile(path string) (*ANSIStyleRenderer, error) {
+	t, err := theme.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewANSIRendererWithTheme(t), nil
+}
+
+// NewANSIRendererFromTheme creates a renderer from theme data in format
+// "json" or "toml": a complete style config, or inherit/colors/style layers
+// over a built-in theme. See theme.Parse for the format.
+func NewANSIRendererFromTheme(data []byte, format string) (*ANSIStyleRenderer, error) {
+	t, err := theme.Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return NewANSIRendererWithTheme(t), nil
+}
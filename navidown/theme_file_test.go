@@ -0,0 +1,163 @@
+package navidown
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boolean-maybe/navidown/internal/glamour/styles"
+	"github.com/boolean-maybe/navidown/navidown/theme"
+)
+
+func TestNewANSIRendererFromTheme_FullStyleConfig(t *testing.T) {
+	data := []byte(`{"heading": {"color": "#ff0000", "bold": true}, "code_block": {"margin": 4}}`)
+	r, err := NewANSIRendererFromTheme(data, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := r.glamourStyle.Heading.Color; got == nil || *got != "#ff0000" {
+		t.Errorf("Heading.Color = %v, want #ff0000", got)
+	}
+	if m := r.glamourStyle.CodeBlock.Margin; m == nil || *m != 0 {
+		t.Errorf("CodeBlock.Margin should be cleared to 0, got %v", m)
+	}
+	if r.glamourStyle.Link.Color != nil {
+		t.Error("full style config should not inherit link color")
+	}
+}
+
+func TestNewANSIRendererFromTheme_Inherit(t *testing.T) {
+	data := []byte(`
+inherit = "dracula"
+
+[style.heading]
+color = "#123456"
+`)
+	r, err := NewANSIRendererFromTheme(data, "toml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := *r.glamourStyle.Heading.Color; got != "#123456" {
+		t.Errorf("Heading.Color = %q, want #123456", got)
+	}
+	// other heading fields and other elements come from dracula
+	if r.glamourStyle.Heading.Bold == nil || !*r.glamourStyle.Heading.Bold {
+		t.Error("Heading.Bold should be inherited from dracula")
+	}
+	want := *NewANSIRendererWithStyle("dracula").glamourStyle.Link.Color
+	if got := *r.glamourStyle.Link.Color; got != want {
+		t.Errorf("Link.Color = %q, want inherited %q", got, want)
+	}
+}
+
+func TestNewANSIRendererFromTheme_Palette(t *testing.T) {
+	data := []byte(`{"colors": {"fg": "#eeeeee", "blue": "#0000ff", "purple": "#aa00aa"}}`)
+	r, err := NewANSIRendererFromTheme(data, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := styles.BuildStyleConfig(styles.ThemeColors{Blue: "#0000ff", Purple: "#aa00aa"})
+	if got := *r.glamourStyle.Link.Color; got != *want.Link.Color {
+		t.Errorf("Link.Color = %q, want %q", got, *want.Link.Color)
+	}
+	if got := *r.glamourStyle.Heading.Color; got != "#aa00aa" {
+		t.Errorf("Heading.Color = %q, want #aa00aa", got)
+	}
+	// unset palette colors are left out rather than set to ""
+	if c := r.glamourStyle.Strong.Color; c != nil {
+		t.Errorf("Strong.Color = %q, want unset", *c)
+	}
+}
+
+func TestNewANSIRendererFromTheme_PaletteOverInherit(t *testing.T) {
+	data := []byte(`{"inherit": "nord", "colors": {"blue": "#0000ff"}}`)
+	r, err := NewANSIRendererFromTheme(data, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := *r.glamourStyle.Link.Color; got != "#0000ff" {
+		t.Errorf("Link.Color = %q, want #0000ff", got)
+	}
+	if got, want := *r.glamourStyle.Heading.Color, *styles.NordStyleConfig.Heading.Color; got != want {
+		t.Errorf("Heading.Color = %q, want nord's %q", got, want)
+	}
+}
+
+func TestNewANSIRendererFromTheme_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		format  string
+		want    error
+		message string
+	}{
+		{"typo suggests key", `{"heading": {"colour": "1"}}`, "json", ErrInvalidTheme, `did you mean "heading.color"`},
+		{"unknown key lists valid keys", `{"colors": {"zzzzzz": "1"}}`, "json", ErrInvalidTheme, "valid keys: bg, blue,"},
+		{"nested chroma key", `{"style": {"code_block": {"chroma": {"keywrd": {}}}}}`, "json", ErrInvalidTheme, `"style.code_block.chroma.keyword"`},
+		{"wrong value type", `{"colors": {"blue": 5}}`, "json", ErrInvalidTheme, "cannot unmarshal"},
+		{"table expected", `{"style": {"heading": "red"}}`, "json", ErrInvalidTheme, "must be a table"},
+		{"unknown base", `inherit = "nope"`, "toml", ErrUnknownTheme, "dracula"},
+		{"syntax error", `{"heading": `, "json", ErrInvalidTheme, ""},
+		{"unsupported format", `{}`, "yaml", ErrInvalidTheme, "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewANSIRendererFromTheme([]byte(tt.data), tt.format)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error %q should contain %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestNewANSIRendererFromThemeFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "theme.toml")
+	if err := os.WriteFile(path, []byte("inherit = \"light\"\n[colors]\nred = \"#ff0000\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewANSIRendererFromThemeFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := r.Render("# Title\n"); err != nil {
+		t.Errorf("render failed: %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"inherti": "dark"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewANSIRendererFromThemeFile(bad)
+	if !errors.Is(err, ErrInvalidTheme) || !strings.Contains(err.Error(), bad) {
+		t.Errorf("error = %v, want ErrInvalidTheme naming the file", err)
+	}
+}
+
+func TestNewANSIRendererWithTheme(t *testing.T) {
+	th, err := theme.FromPalette(theme.Palette{Purple: "#aa00aa"}).
+		With(theme.Link, theme.Style{Color: theme.String("#00ff00")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewANSIRendererWithTheme(th)
+	if got := *r.glamourStyle.Link.Color; got != "#00ff00" {
+		t.Errorf("Link.Color = %q, want #00ff00", got)
+	}
+	if got := *r.glamourStyle.Heading.Color; got != "#aa00aa" {
+		t.Errorf("Heading.Color = %q, want #aa00aa", got)
+	}
+	if m := r.glamourStyle.Document.Margin; m == nil || *m != 0 {
+		t.Errorf("Document.Margin should be cleared to 0, got %v", m)
+	}
+
+	// nil falls back to dark
+	if got, want := *NewANSIRendererWithTheme(nil).glamourStyle.Document.Color, *styles.DarkStyleConfig.Document.Color; got != want {
+		t.Errorf("nil theme Document.Color = %q, want dark's %q", got, want)
+	}
+}
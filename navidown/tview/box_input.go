@@ -29,6 +29,16 @@ func (v *BoxViewer) InputHandler() func(event *tcell.EventKey, setFocus func(p t
 			}
 		}
 
+		// shift+Left / shift+Right (or h / l) scroll wide content sideways.
+		if v.core.HorizontalScroll() != nav.HScrollOff {
+			if delta := hScrollDelta(event); delta != 0 {
+				if scrollHorizontally(v.core, height, delta) {
+					v.fireStateChanged()
+				}
+				return
+			}
+		}
+
 		switch key {
 		case tcell.KeyLeft:
 			// plain Left arrow = go back (fallback for terminals with broken Alt-key support)
@@ -77,6 +87,12 @@ func (v *BoxViewer) InputHandler() func(event *tcell.EventKey, setFocus func(p t
 				v.fireStateChanged()
 			}
 		case tcell.KeyEnter:
+			// a details summary opens or closes its block
+			if v.core.ToggleSelectedDetails() {
+				v.refreshDisplayCache()
+				v.fireStateChanged()
+				return
+			}
 			if v.onSelect != nil {
 				if sel := v.core.Selected(); sel != nil {
 					// ensure we pass a stable copy to callback.
@@ -88,6 +104,29 @@ func (v *BoxViewer) InputHandler() func(event *tcell.EventKey, setFocus func(p t
 	})
 }
 
+// hScrollStep is the number of columns one key press scrolls sideways.
+const hScrollStep = 4
+
+// hScrollDelta returns the horizontal scroll for a key: -hScrollStep for
+// shift+Left or 'h', hScrollStep for shift+Right or 'l', else 0.
+func hScrollDelta(event *tcell.EventKey) int {
+	shift := event.Modifiers() == tcell.ModShift
+	switch {
+	case event.Key() == tcell.KeyLeft && shift, event.Key() == tcell.KeyRune && event.Rune() == 'h':
+		return -hScrollStep
+	case event.Key() == tcell.KeyRight && shift, event.Key() == tcell.KeyRune && event.Rune() == 'l':
+		return hScrollStep
+	}
+	return 0
+}
+
+func scrollHorizontally(core *nav.MarkdownSession, viewportHeight, delta int) bool {
+	if delta < 0 {
+		return core.ScrollLeft(viewportHeight, -delta)
+	}
+	return core.ScrollRight(viewportHeight, delta)
+}
+
 // setCorrelator delegates to the core viewer.
 func (v *BoxViewer) SetCorrelator(c nav.PositionCorrelator) {
 	v.core.SetCorrelator(c)
@@ -121,6 +121,39 @@ func (v *BoxViewer) SetMarkdownWithSource(content string, sourceFilePath string,
 	return v
 }
 
+// FollowLink loads the link elem through cf, as ContentFetcher.OnSelect
+// does, scrolling anchors within the visible height, and triggers UI redraw.
+func (v *BoxViewer) FollowLink(cf *nav.ContentFetcher, elem nav.NavElement) error {
+	v.ensureWidthConfigured()
+	_, _, _, height := v.GetInnerRect()
+	err := cf.OnSelectInViewport(v.core, elem, height)
+	v.refreshDisplayCache()
+	v.fireStateChanged()
+	return err
+}
+
+// Reload replaces the document with a new version of the same file, such as
+// after editing it, keeping the reading position. Returns the render error, if any.
+func (v *BoxViewer) Reload(content string) error {
+	v.ensureWidthConfigured()
+	err := v.core.Reload(content)
+	v.refreshDisplayCache()
+	v.fireStateChanged()
+	return err
+}
+
+// ScrollToSourceLines highlights a source line range (e.g. from a #L42-L60
+// fragment) in a source-file view and scrolls to it.
+func (v *BoxViewer) ScrollToSourceLines(r nav.LineRange, pushToHistory bool) bool {
+	_, _, _, height := v.GetInnerRect()
+	if v.core.ScrollToSourceLines(r, height, pushToHistory) {
+		v.refreshDisplayCache()
+		v.fireStateChanged()
+		return true
+	}
+	return false
+}
+
 // draw renders the component.
 func (v *BoxViewer) Draw(screen tcell.Screen) {
 	v.DrawForSubclass(screen, v)
@@ -178,7 +211,7 @@ func (v *BoxViewer) Draw(screen tcell.Screen) {
 		if lineIdx == selectedLine {
 			hs, he = highlightStart, highlightEnd
 		}
-		v.drawLine(screen, x, y+row, width, line, hs, he, v.backgroundColor)
+		v.drawLine(screen, x, y+row, width, v.core.LineOffset(lineIdx), line, hs, he, v.backgroundColor)
 	}
 
 }
@@ -233,23 +266,25 @@ func (v *BoxViewer) fireStateChanged() {
 	}
 }
 
-func (v *BoxViewer) drawLine(screen tcell.Screen, x, y, width int, line string, highlightStart, highlightEnd int, fillBg tcell.Color) {
+// drawLine draws line scrolled offset columns to the left. Highlight columns
+// are relative to the start of the line.
+func (v *BoxViewer) drawLine(screen tcell.Screen, x, y, width, offset int, line string, highlightStart, highlightEnd int, fillBg tcell.Color) {
 	isHighlightLine := highlightStart >= 0 && highlightEnd > highlightStart
 
 	col := 0
 	currentFg := tcell.ColorDefault
 	currentBg := tcell.ColorDefault
-	currentBold := false
+	var currentAttrs tcell.AttrMask
+	currentURL := ""
 
 	runes := []rune(line)
-	for i := 0; i < len(runes) && col < width; {
+	for i := 0; i < len(runes) && col < offset+width; {
 		// parse tview tag.
 		if runes[i] == '[' {
 			tagEnd := findTagEnd(runes, i)
 			if tagEnd > i {
 				tag := string(runes[i+1 : tagEnd])
-				fg, bg, bold := parseTag(tag, currentFg, currentBg, currentBold, fillBg)
-				currentFg, currentBg, currentBold = fg, bg, bold
+				currentFg, currentBg, currentAttrs, currentURL = parseTag(tag, currentFg, currentBg, currentAttrs, currentURL, fillBg)
 				i = tagEnd + 1
 				continue
 			}
@@ -261,10 +296,7 @@ func (v *BoxViewer) drawLine(screen tcell.Screen, x, y, width int, line string,
 			bg = fillBg
 		}
 
-		style := tcell.StyleDefault.Foreground(currentFg).Background(bg)
-		if currentBold {
-			style = style.Bold(true)
-		}
+		style := tcell.StyleDefault.Foreground(currentFg).Background(bg).Attributes(currentAttrs).Url(currentURL)
 		if isHighlightLine && col >= highlightStart && col < highlightEnd {
 			style = style.Reverse(true)
 		}
@@ -280,8 +312,17 @@ func (v *BoxViewer) drawLine(screen tcell.Screen, x, y, width int, line string,
 			i++
 		}
 
-		screen.SetContent(x+col, y, baseRune, combining, style)
-		col++
+		w := nav.RuneColumns(baseRune)
+		switch {
+		case w == 0:
+			continue
+		case col >= offset && col+w <= offset+width:
+			screen.SetContent(x+col-offset, y, baseRune, combining, style)
+		case col < offset && col+w > offset:
+			// a wide character cut by the left edge
+			screen.SetContent(x, y, ' ', nil, style)
+		}
+		col += w
 	}
 }
 
@@ -333,9 +374,23 @@ func findTagEnd(runes []rune, start int) int {
 	return start
 }
 
-func parseTag(tag string, currentFg, currentBg tcell.Color, currentBold bool, fillBg tcell.Color) (tcell.Color, tcell.Color, bool) {
-	parts := strings.Split(tag, ":")
-	fg, bg, bold := currentFg, currentBg, currentBold
+// tagAttrs maps tview attribute flags to tcell attributes. Lowercase flags
+// turn an attribute on, uppercase flags turn it off, and "-" clears all.
+var tagAttrs = map[byte]tcell.AttrMask{
+	'b': tcell.AttrBold,
+	'd': tcell.AttrDim,
+	'i': tcell.AttrItalic,
+	'l': tcell.AttrBlink,
+	'r': tcell.AttrReverse,
+	's': tcell.AttrStrikeThrough,
+	'u': tcell.AttrUnderline,
+}
+
+// parseTag applies a tview style tag ("fg:bg:attrs:url") to the current
+// style. A URL of "-" ends the current hyperlink.
+func parseTag(tag string, currentFg, currentBg tcell.Color, currentAttrs tcell.AttrMask, currentURL string, fillBg tcell.Color) (tcell.Color, tcell.Color, tcell.AttrMask, string) {
+	parts := strings.SplitN(tag, ":", 4)
+	fg, bg, attrs, url := currentFg, currentBg, currentAttrs, currentURL
 
 	if len(parts) >= 1 {
 		if parts[0] == "-" {
@@ -359,14 +414,28 @@ func parseTag(tag string, currentFg, currentBg tcell.Color, currentBold bool, fi
 	}
 
 	if len(parts) >= 3 {
-		if strings.Contains(parts[2], "b") {
-			bold = true
-		} else if parts[2] == "-" {
-			bold = false
+		if parts[2] == "-" {
+			attrs = 0
+		}
+		for i := 0; i < len(parts[2]); i++ {
+			flag := parts[2][i]
+			if a, ok := tagAttrs[flag]; ok {
+				attrs |= a
+			} else if a, ok := tagAttrs[flag+'a'-'A']; ok {
+				attrs &^= a
+			}
+		}
+	}
+
+	if len(parts) == 4 {
+		if parts[3] == "-" {
+			url = ""
+		} else if parts[3] != "" {
+			url = parts[3]
 		}
 	}
 
-	return fg, bg, bold
+	return fg, bg, attrs, url
 }
 
 func parseColor(s string, fallback tcell.Color) tcell.Color {
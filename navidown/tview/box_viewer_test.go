@@ -0,0 +1,98 @@
+package tview
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseTag_Attributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		tag   string
+		attrs tcell.AttrMask
+		want  tcell.AttrMask
+	}{
+		{"bold", "-:-:b", 0, tcell.AttrBold},
+		{"several", "-:-:iur", 0, tcell.AttrItalic | tcell.AttrUnderline | tcell.AttrReverse},
+		{"uppercase clears", "-:-:bI", tcell.AttrBold | tcell.AttrItalic, tcell.AttrBold},
+		{"dash clears all", "-:-:-", tcell.AttrBold | tcell.AttrUnderline, 0},
+		{"colors only keep attributes", "red:-", tcell.AttrBold, tcell.AttrBold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, got, _ := parseTag(tt.tag, tcell.ColorDefault, tcell.ColorDefault, tt.attrs, "", tcell.ColorDefault)
+			if got != tt.want {
+				t.Errorf("parseTag(%q) attrs = %v, want %v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTag_URL(t *testing.T) {
+	_, _, attrs, url := parseTag(":::https://example.com/a", tcell.ColorDefault, tcell.ColorDefault, tcell.AttrBold, "", tcell.ColorDefault)
+	if url != "https://example.com/a" || attrs != tcell.AttrBold {
+		t.Errorf("url tag: url %q, attrs %v", url, attrs)
+	}
+	if _, _, _, url = parseTag("-:-:-", tcell.ColorDefault, tcell.ColorDefault, 0, "https://x", tcell.ColorDefault); url != "https://x" {
+		t.Errorf("tags without a URL field should keep the link, got %q", url)
+	}
+	if _, _, _, url = parseTag(":::-", tcell.ColorDefault, tcell.ColorDefault, 0, "https://x", tcell.ColorDefault); url != "" {
+		t.Errorf("\":::-\" should end the link, got %q", url)
+	}
+}
+
+func TestDrawLine_Offset(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(10, 1)
+
+	v := NewBox()
+	// scrolled 3 columns; the highlight is at columns 4-6 of the line
+	v.drawLine(screen, 0, 0, 5, 3, "[red]abc[blue]defghij", 4, 6, tcell.ColorDefault)
+
+	want := "defgh"
+	for i, r := range want {
+		got, _, style, _ := screen.GetContent(i, 0)
+		if got != r {
+			t.Fatalf("cell %d = %q, want %q", i, got, r)
+		}
+		fg, _, attrs := style.Decompose()
+		if fg != tcell.ColorBlue {
+			t.Errorf("cell %d fg = %v, want blue", i, fg)
+		}
+		if highlighted := attrs&tcell.AttrReverse != 0; highlighted != (i == 1 || i == 2) {
+			t.Errorf("cell %d highlighted = %v", i, highlighted)
+		}
+	}
+	if got, _, _, _ := screen.GetContent(5, 0); got != ' ' {
+		t.Errorf("drew past the width: %q", got)
+	}
+}
+
+func TestDrawLine_WideCharacters(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Fini()
+	screen.SetSize(10, 1)
+
+	v := NewBox()
+	// the highlight covers the two columns of 本
+	v.drawLine(screen, 0, 0, 10, 0, "日本語x", 2, 4, tcell.ColorDefault)
+
+	for col, want := range map[int]rune{0: '日', 2: '本', 4: '語', 6: 'x'} {
+		got, _, style, _ := screen.GetContent(col, 0)
+		if got != want {
+			t.Errorf("cell %d = %q, want %q", col, got, want)
+		}
+		_, _, attrs := style.Decompose()
+		if highlighted := attrs&tcell.AttrReverse != 0; highlighted != (want == '本') {
+			t.Errorf("cell %d highlighted = %v", col, highlighted)
+		}
+	}
+}
@@ -118,6 +118,13 @@ func (m *ImageManager) UpdateCellSize(screen tcell.Screen) bool {
 	return true
 }
 
+// CellWidth returns the pixel width of a terminal cell.
+func (m *ImageManager) CellWidth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cellWidth
+}
+
 // Supported returns whether the terminal supports Kitty graphics protocol.
 // Returns false if detection hasn't run or the terminal doesn't support it.
 func (m *ImageManager) Supported() bool {
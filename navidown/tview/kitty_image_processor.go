@@ -111,8 +111,13 @@ func (p *KittyImageProcessor) processLine(line string, sourceFilePath string, ma
 			continue
 		}
 
-		// Try to resolve and generate placeholders
-		placeholder, err := p.manager.ResolveAndAllocate(url, sourceFilePath, maxCols)
+		// Try to resolve and generate placeholders; <img width> narrows the
+		// image
+		cols := maxCols
+		if width := nav.ImageTokenWidth(token); width != "" {
+			cols = nav.ImageWidthColumns(width, maxCols, p.manager.CellWidth())
+		}
+		placeholder, err := p.manager.ResolveAndAllocate(url, sourceFilePath, cols)
 		if err != nil {
 			// Fallback: show alt text
 			if alt != "" {
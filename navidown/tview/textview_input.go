@@ -33,6 +33,20 @@ func (v *TextViewViewer) InputHandler() func(event *tcell.EventKey, setFocus fun
 			}
 		}
 
+		// shift+Left / shift+Right (or h / l) scroll wide content sideways;
+		// the session tracks the offsets, so the TextView column stays at 0.
+		hscroll := v.core.HorizontalScroll() != nav.HScrollOff
+		if hscroll {
+			if delta := hScrollDelta(event); delta != 0 {
+				if scrollHorizontally(v.core, height, delta) {
+					v.updateTextViewContent(true)
+					v.ScrollTo(v.core.ScrollOffset(), 0)
+					v.fireStateChanged()
+				}
+				return
+			}
+		}
+
 		switch key {
 		case tcell.KeyLeft:
 			// plain Left arrow = go back (fallback for terminals with broken Alt-key support)
@@ -56,19 +70,26 @@ func (v *TextViewViewer) InputHandler() func(event *tcell.EventKey, setFocus fun
 			}
 		case tcell.KeyTab:
 			if v.core.MoveToNextLink(height) {
-				v.updateTextViewContent(false)
+				v.updateTextViewContent(hscroll)
 				v.ScrollTo(v.core.ScrollOffset(), 0)
 				v.fireStateChanged()
 				return
 			}
 		case tcell.KeyBacktab:
 			if v.core.MoveToPreviousLink(height) {
-				v.updateTextViewContent(false)
+				v.updateTextViewContent(hscroll)
 				v.ScrollTo(v.core.ScrollOffset(), 0)
 				v.fireStateChanged()
 				return
 			}
 		case tcell.KeyEnter:
+			// a details summary opens or closes its block
+			if v.core.ToggleSelectedDetails() {
+				v.refreshDisplayCache()
+				v.ScrollTo(v.core.ScrollOffset(), 0)
+				v.fireStateChanged()
+				return
+			}
 			if v.onSelect != nil {
 				if sel := v.core.Selected(); sel != nil {
 					// ensure we pass a stable copy to callback.
@@ -79,6 +100,9 @@ func (v *TextViewViewer) InputHandler() func(event *tcell.EventKey, setFocus fun
 		}
 
 		base(event, setFocus)
+		if row, col := v.GetScrollOffset(); hscroll && col != 0 {
+			v.ScrollTo(row, 0)
+		}
 
 		if v.syncCoreScrollFromTextView(height) {
 			v.fireStateChanged()
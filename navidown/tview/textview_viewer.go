@@ -212,6 +212,30 @@ func (v *TextViewViewer) SetMarkdownWithSource(content string, sourceFilePath st
 	return v
 }
 
+// FollowLink loads the link elem through cf, as ContentFetcher.OnSelect
+// does, scrolling anchors within the visible height, and triggers UI redraw.
+func (v *TextViewViewer) FollowLink(cf *nav.ContentFetcher, elem nav.NavElement) error {
+	v.ensureWidthConfigured()
+	_, _, _, height := v.GetInnerRect()
+	err := cf.OnSelectInViewport(v.core, elem, height)
+	v.refreshDisplayCache()
+	v.ScrollTo(v.core.ScrollOffset(), 0)
+	v.fireStateChanged()
+	return err
+}
+
+// Reload replaces the document with a new version of the same file, such as
+// after editing it, keeping the reading position, and triggers UI redraw.
+// Returns the render error, if any.
+func (v *TextViewViewer) Reload(content string) error {
+	v.ensureWidthConfigured()
+	err := v.core.Reload(content)
+	v.refreshDisplayCache()
+	v.ScrollTo(v.core.ScrollOffset(), 0)
+	v.fireStateChanged()
+	return err
+}
+
 // ScrollToAnchor scrolls to a header by slug and triggers UI redraw.
 func (v *TextViewViewer) ScrollToAnchor(slug string, pushToHistory bool) bool {
 	_, _, _, height := v.GetInnerRect()
@@ -223,6 +247,19 @@ func (v *TextViewViewer) ScrollToAnchor(slug string, pushToHistory bool) bool {
 	return false
 }
 
+// ScrollToSourceLines highlights a source line range (e.g. from a #L42-L60
+// fragment) in a source-file view, scrolls to it, and triggers UI redraw.
+func (v *TextViewViewer) ScrollToSourceLines(r nav.LineRange, pushToHistory bool) bool {
+	_, _, _, height := v.GetInnerRect()
+	if v.core.ScrollToSourceLines(r, height, pushToHistory) {
+		v.refreshDisplayCache()
+		v.ScrollTo(v.core.ScrollOffset(), 0)
+		v.fireStateChanged()
+		return true
+	}
+	return false
+}
+
 func (v *TextViewViewer) refreshDisplayCache() {
 	lines := v.core.RenderedLines()
 	if len(lines) == 0 {
@@ -264,8 +301,10 @@ func (v *TextViewViewer) updateTextViewContent(force bool) {
 
 	var builder strings.Builder
 	for i, line := range v.displayLines {
+		offset := v.core.LineOffset(i)
+		line = cropLine(line, offset)
 		if current.ok && i == current.line && current.end > current.start {
-			line = insertRegionTags(line, current.start, current.end, selectedRegionID)
+			line = insertRegionTags(line, max(current.start-offset, 0), current.end-offset, selectedRegionID)
 		}
 		builder.WriteString(line)
 		if i < len(v.displayLines)-1 {
@@ -317,18 +356,18 @@ func insertRegionTags(line string, startCol, endCol int, regionID string) string
 			}
 		}
 
-		if !insertedStart && col == startCol {
+		if !insertedStart && col >= startCol {
 			builder.WriteString(startTag)
 			insertedStart = true
 		}
-		if !insertedEnd && col == endCol {
+		if !insertedEnd && col >= endCol {
 			builder.WriteString(endTag)
 			insertedEnd = true
 		}
 
 		builder.WriteRune(runes[i])
+		col += nav.RuneColumns(runes[i])
 		i++
-		col++
 	}
 
 	if !insertedStart {
@@ -339,3 +378,40 @@ func insertRegionTags(line string, startCol, endCol int, regionID string) string
 	}
 	return builder.String()
 }
+
+// cropLine drops the first offset columns of a tagged line, keeping the tags
+// so colors carry over into the visible part.
+func cropLine(line string, offset int) string {
+	if offset <= 0 {
+		return line
+	}
+
+	runes := []rune(line)
+	var builder strings.Builder
+	col := 0
+	for i := 0; i < len(runes); {
+		if runes[i] == '[' {
+			tagEnd := findTagEnd(runes, i)
+			if tagEnd > i {
+				builder.WriteString(string(runes[i : tagEnd+1]))
+				i = tagEnd + 1
+				continue
+			}
+		}
+		if col >= offset {
+			builder.WriteString(string(runes[i:]))
+			break
+		}
+		w := nav.RuneColumns(runes[i])
+		i++
+		for i < len(runes) && isCombining(runes[i]) {
+			i++
+		}
+		col += w
+		if col > offset {
+			// a wide character cut by the left edge
+			builder.WriteString(strings.Repeat(" ", col-offset))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,50 @@
+package tview
+
+import (
+	"strings"
+	"testing"
+
+	nav "github.com/boolean-maybe/navidown/navidown"
+)
+
+func TestCropLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		offset int
+		want   string
+	}{
+		{"[red]abc[blue]def", 0, "[red]abc[blue]def"},
+		{"[red]abc[blue]def", 2, "[red]c[blue]def"},
+		{"[red]abc[blue]def", 3, "[red][blue]def"},
+		{"[red]abc", 5, "[red]"},
+		// wide characters take two columns; one cut by the edge leaves a space
+		{"日本語", 2, "本語"},
+		{"日本語", 3, " 語"},
+	}
+	for _, tt := range tests {
+		if got := cropLine(tt.line, tt.offset); got != tt.want {
+			t.Errorf("cropLine(%q, %d) = %q, want %q", tt.line, tt.offset, got, tt.want)
+		}
+	}
+}
+
+type stubProvider string
+
+func (p stubProvider) FetchContent(nav.NavElement) (string, error) { return string(p), nil }
+
+func TestTextViewViewer_FollowLink(t *testing.T) {
+	v := NewTextView()
+	v.SetRect(0, 0, 40, 10)
+	v.SetMarkdownWithSource("# Home\n\n[next](next.md)\n", "/tmp/home.md", false)
+
+	cf := nav.NewContentFetcher(stubProvider("# Next\n"), nil)
+	if err := v.FollowLink(cf, nav.NavElement{Type: nav.NavElementURL, URL: "next.md", SourceFilePath: "/tmp/home.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := v.GetText(true); !strings.Contains(got, "Next") {
+		t.Errorf("text = %q, want the linked document", got)
+	}
+	if !v.Core().CanGoBack() {
+		t.Error("following a link should push history")
+	}
+}
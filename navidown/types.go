@@ -7,9 +7,12 @@ const (
 	NavElementHeader NavElementType = iota
 	NavElementURL
 	NavElementImage
+	// NavElementDetails is the summary line of a <details> block, which
+	// opens and closes the block (see MarkdownSession.ToggleSelectedDetails).
+	NavElementDetails
 )
 
-// NavElement represents a navigable item (header or URL).
+// NavElement represents a navigable item (header, URL or details summary).
 //
 // Positions are in rendered output coordinates:
 // - StartLine/EndLine are 0-indexed line numbers
@@ -17,7 +20,7 @@ const (
 type NavElement struct {
 	Type           NavElementType
 	Text           string // visible text (header text or link text)
-	URL            string // for links, the URL; empty for headers
+	URL            string // for links, the URL; for details summaries, "details:<n>" naming the block; empty for headers
 	Level          int    // for headers: 1-6; for links: 0
 	Slug           string // URL-safe anchor ID for headers (e.g., "my-header")
 	SourceFilePath string // path to the markdown file containing this element
@@ -26,6 +29,14 @@ type NavElement struct {
 	EndLine   int
 	StartCol  int
 	EndCol    int
+
+	sourceOffset int // where the element's node starts in the source, see ansi.SourceOffset
+}
+
+// focusable reports whether the element can be selected: a link or a
+// details summary.
+func (e *NavElement) focusable() bool {
+	return e.Type == NavElementURL || e.Type == NavElementDetails
 }
 
 // IsInternalLink returns true if this element is a link to an anchor within the same document.
@@ -55,5 +66,9 @@ type PageState struct {
 	RenderedLines  []string
 	PreImageLines  []string // cached lines before image post-processing
 	Cleaner        LineCleaner
-	Width          int // Rendering width at capture time
+	SourceMap      *SourceMap     // source map of PreImageLines, or RenderedLines without images
+	Width          int            // Rendering width at capture time
+	LineHighlight  LineRange      // highlighted source lines, if any
+	DetailsOpen    map[int]bool   // <details> blocks opened or closed by the user
+	DiagramErrors  []DiagramError // diagram blocks that failed to render
 }
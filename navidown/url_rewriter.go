@@ -0,0 +1,254 @@
+package navidown
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// URLRewriter maps a link URL to the URL that is actually fetched, and to
+// the URL that should act as the fetched document's source path. Relative
+// links inside the document resolve against sourceURL, so a rewriter can
+// fetch from a raw-content host while keeping links pointing at the original
+// site (where they are rewritten again on the next fetch).
+// ok is false when the URL is not handled and should be fetched unchanged.
+type URLRewriter interface {
+	Rewrite(rawURL string) (fetchURL, sourceURL string, ok bool)
+}
+
+// URLRewriterFunc adapts a function to the URLRewriter interface.
+type URLRewriterFunc func(rawURL string) (fetchURL, sourceURL string, ok bool)
+
+func (f URLRewriterFunc) Rewrite(rawURL string) (string, string, bool) { return f(rawURL) }
+
+// RewriteFetchURL applies r to rawURL and returns the URL to fetch.
+// A nil rewriter returns rawURL unchanged.
+func RewriteFetchURL(r URLRewriter, rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+	if fetchURL, _, ok := r.Rewrite(rawURL); ok {
+		return fetchURL
+	}
+	return rawURL
+}
+
+// RewriteSourceURL applies r to rawURL and returns the source path to use for
+// the fetched document. A nil rewriter returns rawURL unchanged.
+func RewriteSourceURL(r URLRewriter, rawURL string) string {
+	if r == nil {
+		return rawURL
+	}
+	if _, sourceURL, ok := r.Rewrite(rawURL); ok {
+		return sourceURL
+	}
+	return rawURL
+}
+
+// ForgeKind identifies a code-forge URL scheme.
+type ForgeKind int
+
+const (
+	ForgeGitHub ForgeKind = iota // github.com and GitHub Enterprise
+	ForgeGitLab                  // gitlab.com and self-managed GitLab
+	ForgeGitea                   // Gitea, Forgejo, Codeberg
+)
+
+// ForgeRule tells a ForgeRewriter how to handle one forge host.
+type ForgeRule struct {
+	Kind ForgeKind
+	Host string // web host, e.g. "github.com" or "git.example.com:8443"
+	// RawBase overrides where raw files are fetched from, e.g.
+	// "https://raw.githubusercontent.com". "" uses the forge's own raw
+	// endpoint on Host (github.com defaults to raw.githubusercontent.com).
+	RawBase string
+}
+
+// DefaultForgeRules covers the public forges.
+var DefaultForgeRules = []ForgeRule{
+	{Kind: ForgeGitHub, Host: "github.com", RawBase: "https://raw.githubusercontent.com"},
+	{Kind: ForgeGitLab, Host: "gitlab.com"},
+	{Kind: ForgeGitea, Host: "gitea.com"},
+	{Kind: ForgeGitea, Host: "codeberg.org"},
+}
+
+// ForgeRewriter rewrites forge web URLs to raw-content URLs:
+//
+//   - blob URLs map to the raw file
+//   - tree URLs (and bare GitHub repo URLs) map to the directory's README
+//
+// The source URL is the blob URL of the file actually shown, so relative
+// links and images resolve against the right directory.
+type ForgeRewriter struct {
+	Rules []ForgeRule
+	// ReadmeName is the file shown for tree URLs; "" = "README.md".
+	ReadmeName string
+}
+
+// NewForgeRewriter returns a rewriter for DefaultForgeRules plus any extra
+// rules for self-hosted instances. Extra rules take precedence.
+func NewForgeRewriter(extra ...ForgeRule) *ForgeRewriter {
+	rules := append(append([]ForgeRule(nil), extra...), DefaultForgeRules...)
+	return &ForgeRewriter{Rules: rules}
+}
+
+func (f *ForgeRewriter) readme() string {
+	if f.ReadmeName != "" {
+		return f.ReadmeName
+	}
+	return "README.md"
+}
+
+// Rewrite implements URLRewriter.
+func (f *ForgeRewriter) Rewrite(rawURL string) (fetchURL, sourceURL string, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isHTTPScheme(u.Scheme) {
+		return "", "", false
+	}
+	for _, rule := range f.Rules {
+		if !strings.EqualFold(u.Host, rule.Host) {
+			continue
+		}
+		segs := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+		var target forgeTarget
+		switch rule.Kind {
+		case ForgeGitHub:
+			target, ok = parseGitHubPath(segs)
+		case ForgeGitLab:
+			target, ok = parseGitLabPath(segs)
+		case ForgeGitea:
+			target, ok = parseGiteaPath(segs)
+		}
+		if !ok {
+			return "", "", false
+		}
+		if target.dir {
+			target.file = path.Join(target.file, f.readme())
+		}
+		origin := u.Scheme + "://" + u.Host
+		return target.rawURL(rule, origin), target.blobURL(rule.Kind, origin), true
+	}
+	return "", "", false
+}
+
+// forgeTarget is a parsed forge path: repo prefix, ref, and file path.
+// For tree URLs dir is true and file names the directory.
+type forgeTarget struct {
+	repo    string // "owner/repo" or "group/sub/project"
+	refKind string // Gitea only: "branch", "tag", "commit", or ""
+	ref     string
+	file    string
+	dir     bool
+}
+
+func (t forgeTarget) rawURL(rule ForgeRule, origin string) string {
+	base := strings.TrimRight(rule.RawBase, "/")
+	switch rule.Kind {
+	case ForgeGitLab:
+		if base == "" {
+			base = origin
+		}
+		return base + "/" + t.repo + "/-/raw/" + t.ref + "/" + t.file
+	case ForgeGitea:
+		if base == "" {
+			base = origin
+		}
+		return base + "/" + t.repo + "/raw/" + joinNonEmpty(t.refKind, t.ref) + "/" + t.file
+	default:
+		if base != "" {
+			return base + "/" + t.repo + "/" + t.ref + "/" + t.file
+		}
+		return origin + "/" + t.repo + "/raw/" + t.ref + "/" + t.file
+	}
+}
+
+func (t forgeTarget) blobURL(kind ForgeKind, origin string) string {
+	switch kind {
+	case ForgeGitLab:
+		return origin + "/" + t.repo + "/-/blob/" + t.ref + "/" + t.file
+	case ForgeGitea:
+		return origin + "/" + t.repo + "/src/" + joinNonEmpty(t.refKind, t.ref) + "/" + t.file
+	default:
+		return origin + "/" + t.repo + "/blob/" + t.ref + "/" + t.file
+	}
+}
+
+func joinNonEmpty(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "/" + b
+}
+
+// parseGitHubPath handles owner/repo, owner/repo/blob/ref/path, and
+// owner/repo/tree/ref[/dir]. Refs containing slashes are not supported.
+func parseGitHubPath(segs []string) (forgeTarget, bool) {
+	if len(segs) < 2 || segs[0] == "" || segs[1] == "" {
+		return forgeTarget{}, false
+	}
+	t := forgeTarget{repo: segs[0] + "/" + segs[1]}
+	switch {
+	case len(segs) == 2:
+		t.ref, t.dir = "HEAD", true
+	case len(segs) >= 5 && segs[2] == "blob":
+		t.ref, t.file = segs[3], strings.Join(segs[4:], "/")
+	case len(segs) >= 4 && segs[2] == "tree":
+		t.ref, t.file, t.dir = segs[3], strings.Join(segs[4:], "/"), true
+	default:
+		return forgeTarget{}, false
+	}
+	return t, true
+}
+
+// parseGitLabPath handles group[/sub]/project/-/blob/ref/path and
+// .../-/tree/ref[/dir].
+func parseGitLabPath(segs []string) (forgeTarget, bool) {
+	sep := -1
+	for i, s := range segs {
+		if s == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 2 || len(segs) < sep+3 {
+		return forgeTarget{}, false
+	}
+	t := forgeTarget{repo: strings.Join(segs[:sep], "/"), ref: segs[sep+2]}
+	rest := strings.Join(segs[sep+3:], "/")
+	switch segs[sep+1] {
+	case "blob":
+		if rest == "" {
+			return forgeTarget{}, false
+		}
+		t.file = rest
+	case "tree":
+		t.file, t.dir = rest, true
+	default:
+		return forgeTarget{}, false
+	}
+	return t, true
+}
+
+// parseGiteaPath handles owner/repo/src/{branch,tag,commit}/ref[/path] and
+// the legacy owner/repo/src/ref[/path]. Gitea uses the same URL for files and
+// directories, so a final segment without an extension is treated as a
+// directory.
+func parseGiteaPath(segs []string) (forgeTarget, bool) {
+	if len(segs) < 4 || segs[2] != "src" {
+		return forgeTarget{}, false
+	}
+	t := forgeTarget{repo: segs[0] + "/" + segs[1]}
+	rest := segs[3:]
+	switch rest[0] {
+	case "branch", "tag", "commit":
+		if len(rest) < 2 {
+			return forgeTarget{}, false
+		}
+		t.refKind, t.ref, rest = rest[0], rest[1], rest[2:]
+	default:
+		t.ref, rest = rest[0], rest[1:]
+	}
+	t.file = strings.Join(rest, "/")
+	t.dir = len(rest) == 0 || path.Ext(rest[len(rest)-1]) == ""
+	return t, true
+}
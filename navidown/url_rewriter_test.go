@@ -0,0 +1,166 @@
+package navidown
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestForgeRewriter_Rewrite(t *testing.T) {
+	rw := NewForgeRewriter(
+		ForgeRule{Kind: ForgeGitHub, Host: "ghe.corp.example"},
+		ForgeRule{Kind: ForgeGitLab, Host: "git.corp.example"},
+	)
+
+	tests := []struct {
+		name       string
+		in         string
+		wantFetch  string
+		wantSource string
+	}{
+		{
+			name:       "github blob",
+			in:         "https://github.com/org/repo/blob/main/docs/x.md",
+			wantFetch:  "https://raw.githubusercontent.com/org/repo/main/docs/x.md",
+			wantSource: "https://github.com/org/repo/blob/main/docs/x.md",
+		},
+		{
+			name:       "github tree",
+			in:         "https://github.com/org/repo/tree/v1.2/docs",
+			wantFetch:  "https://raw.githubusercontent.com/org/repo/v1.2/docs/README.md",
+			wantSource: "https://github.com/org/repo/blob/v1.2/docs/README.md",
+		},
+		{
+			name:       "github tree root",
+			in:         "https://github.com/org/repo/tree/main",
+			wantFetch:  "https://raw.githubusercontent.com/org/repo/main/README.md",
+			wantSource: "https://github.com/org/repo/blob/main/README.md",
+		},
+		{
+			name:       "github repo landing page",
+			in:         "https://github.com/org/repo",
+			wantFetch:  "https://raw.githubusercontent.com/org/repo/HEAD/README.md",
+			wantSource: "https://github.com/org/repo/blob/HEAD/README.md",
+		},
+		{
+			name:       "github image blob",
+			in:         "https://github.com/org/repo/blob/main/img/logo.png",
+			wantFetch:  "https://raw.githubusercontent.com/org/repo/main/img/logo.png",
+			wantSource: "https://github.com/org/repo/blob/main/img/logo.png",
+		},
+		{
+			name:       "github enterprise",
+			in:         "https://ghe.corp.example/team/svc/blob/main/README.md",
+			wantFetch:  "https://ghe.corp.example/team/svc/raw/main/README.md",
+			wantSource: "https://ghe.corp.example/team/svc/blob/main/README.md",
+		},
+		{
+			name:       "gitlab nested group blob",
+			in:         "https://gitlab.com/group/sub/project/-/blob/main/docs/a.md",
+			wantFetch:  "https://gitlab.com/group/sub/project/-/raw/main/docs/a.md",
+			wantSource: "https://gitlab.com/group/sub/project/-/blob/main/docs/a.md",
+		},
+		{
+			name:       "self-managed gitlab tree",
+			in:         "https://git.corp.example/team/project/-/tree/dev/docs",
+			wantFetch:  "https://git.corp.example/team/project/-/raw/dev/docs/README.md",
+			wantSource: "https://git.corp.example/team/project/-/blob/dev/docs/README.md",
+		},
+		{
+			name:       "codeberg file",
+			in:         "https://codeberg.org/org/repo/src/branch/main/docs/a.md",
+			wantFetch:  "https://codeberg.org/org/repo/raw/branch/main/docs/a.md",
+			wantSource: "https://codeberg.org/org/repo/src/branch/main/docs/a.md",
+		},
+		{
+			name:       "codeberg directory",
+			in:         "https://codeberg.org/org/repo/src/tag/v1/docs",
+			wantFetch:  "https://codeberg.org/org/repo/raw/tag/v1/docs/README.md",
+			wantSource: "https://codeberg.org/org/repo/src/tag/v1/docs/README.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetch, source, ok := rw.Rewrite(tt.in)
+			if !ok {
+				t.Fatalf("Rewrite(%q) not handled", tt.in)
+			}
+			if fetch != tt.wantFetch {
+				t.Errorf("fetch = %q, want %q", fetch, tt.wantFetch)
+			}
+			if source != tt.wantSource {
+				t.Errorf("source = %q, want %q", source, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestForgeRewriter_Unhandled(t *testing.T) {
+	rw := NewForgeRewriter()
+	for _, in := range []string{
+		"https://example.com/org/repo/blob/main/x.md",
+		"https://raw.githubusercontent.com/org/repo/main/x.md",
+		"https://github.com/org/repo/issues/12",
+		"https://github.com/org",
+		"https://gitlab.com/group/project",
+		"/local/path.md",
+		"mailto:someone@example.com",
+	} {
+		if _, _, ok := rw.Rewrite(in); ok {
+			t.Errorf("Rewrite(%q) should not be handled", in)
+		}
+	}
+}
+
+func TestForgeRewriter_RelativeLinksResolveAgainstBlobURL(t *testing.T) {
+	rw := NewForgeRewriter()
+	_, source, _ := rw.Rewrite("https://github.com/org/repo/tree/main/docs")
+
+	link, err := ResolveMarkdownPath("guide/setup.md", source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "https://github.com/org/repo/blob/main/docs/guide/setup.md" {
+		t.Errorf("relative link resolved to %q", link)
+	}
+	if got := RewriteFetchURL(rw, link); got != "https://raw.githubusercontent.com/org/repo/main/docs/guide/setup.md" {
+		t.Errorf("fetch URL for resolved link = %q", got)
+	}
+
+	img, _ := ResolveMarkdownPath("../img/a.png", source, nil)
+	if got := RewriteFetchURL(rw, img); got != "https://raw.githubusercontent.com/org/repo/main/img/a.png" {
+		t.Errorf("fetch URL for relative image = %q", got)
+	}
+}
+
+func TestRewriteHelpers_NilRewriter(t *testing.T) {
+	u := "https://github.com/org/repo/blob/main/x.md"
+	if RewriteFetchURL(nil, u) != u || RewriteSourceURL(nil, u) != u {
+		t.Error("nil rewriter should return the URL unchanged")
+	}
+}
+
+type recordingProvider struct {
+	content string
+}
+
+func (p recordingProvider) FetchContent(NavElement) (string, error) {
+	if p.content == "" {
+		return "", errors.New("no content")
+	}
+	return p.content, nil
+}
+
+func TestContentFetcher_SourcePathUsesRewriter(t *testing.T) {
+	session := New(Options{})
+	cf := NewContentFetcher(recordingProvider{content: "# Docs\n"}, nil)
+	cf.SetURLRewriter(NewForgeRewriter())
+
+	err := cf.OnSelect(session, NavElement{Type: NavElementURL, URL: "https://github.com/org/repo/tree/main/docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := session.SourceFilePath(); got != "https://github.com/org/repo/blob/main/docs/README.md" {
+		t.Errorf("SourceFilePath = %q", got)
+	}
+}
@@ -7,17 +7,35 @@ import (
 	"strings"
 )
 
-// Package-level compiled regex for ANSI SGR sequences (avoids recompilation per call).
-var ansiSGRPattern = regexp.MustCompile(`\x1b\[([0-9;]*)m`)
+// Package-level compiled regex for ANSI SGR sequences and OSC 8 hyperlinks
+// (avoids recompilation per call). Group 1 holds SGR parameters, group 2 a
+// hyperlink target (empty when a hyperlink ends).
+var ansiSGRPattern = regexp.MustCompile(`\x1b\[([0-9;]*)m|\x1b\]8;[^;\x07\x1b]*;([^\x07\x1b]*)(?:\x07|\x1b\\)`)
+
+// tviewURLEscaper percent-encodes the brackets that would end a tview URL tag.
+var tviewURLEscaper = strings.NewReplacer("[", "%5B", "]", "%5D")
 
 // tviewTagEscapePattern matches bracket patterns that tview would interpret as
 // style tags (e.g. [link], [red], [#ff0000]) and escapes them by inserting []
 // before the closing ]. This mirrors tview.Escape but avoids importing tview.
 var tviewTagEscapePattern = regexp.MustCompile(`(\[[a-zA-Z0-9_,;: \-\."#]+\[*)\]`)
 
+// ColorLevel is the range of colors the converted output may use. The
+// levels are in the order of navidown.ColorProfile, so ColorLevel(profile)
+// converts one to the other.
+type ColorLevel int
+
+const (
+	ColorTrue ColorLevel = iota // 24-bit colors
+	Color256                    // the xterm 256-color palette
+	Color16                     // the 16 basic terminal colors
+	ColorNone                   // no colors, only text attributes
+)
+
 // AnsiConverter converts ANSI escape sequences to tview color tags.
 type AnsiConverter struct {
 	enabled bool
+	level   ColorLevel
 }
 
 // NewAnsiConverter creates a new ANSI converter.
@@ -28,8 +46,23 @@ func NewAnsiConverter(enabled bool) *AnsiConverter {
 	}
 }
 
+// SetColorLevel limits the converted output to level. With ColorNone
+// colors are dropped and only text attributes (bold, italic, underline,
+// reverse, ...) are kept.
+func (c *AnsiConverter) SetColorLevel(level ColorLevel) {
+	c.level = level
+}
+
+// sgrState is the styling in effect after a run of SGR sequences.
+type sgrState struct {
+	fg, bg                                          string
+	bold, dim, italic, underline, reverse, crossOut bool
+}
+
 // Convert translates ANSI escape sequences to tview color tags.
-// Properly handles foreground, background, and bold attributes.
+// Handles foreground and background colors (16, 256, and 24-bit) and the
+// bold, dim, italic, underline, reverse, and strikethrough attributes.
+// OSC 8 hyperlinks become tview URL tags ("[:::url]" ... "[:::-]").
 func (c *AnsiConverter) Convert(text string) string {
 	if !c.enabled {
 		return text
@@ -38,8 +71,7 @@ func (c *AnsiConverter) Convert(text string) string {
 	result := strings.Builder{}
 	lastIndex := 0
 
-	var fgColor, bgColor string
-	bold := false
+	var state sgrState
 
 	matches := ansiSGRPattern.FindAllStringSubmatchIndex(text, -1)
 	for _, match := range matches {
@@ -47,14 +79,26 @@ func (c *AnsiConverter) Convert(text string) string {
 		// doesn't misinterpret e.g. [link] as a color/style tag
 		result.WriteString(escapeTviewTags(text[lastIndex:match[0]]))
 
+		if match[2] < 0 {
+			// OSC 8 hyperlink
+			if target := text[match[4]:match[5]]; target != "" {
+				result.WriteString("[:::" + tviewURLEscaper.Replace(target) + "]")
+			} else {
+				result.WriteString("[:::-]")
+			}
+			lastIndex = match[1]
+			continue
+		}
+
 		params := text[match[2]:match[3]]
 
-		newFg, newBg, newBold := parseSGR(params, fgColor, bgColor, bold)
-		if newFg != fgColor || newBg != bgColor || newBold != bold {
-			fgColor = newFg
-			bgColor = newBg
-			bold = newBold
-			result.WriteString(formatTviewTag(fgColor, bgColor, bold))
+		next := parseSGR(params, state, c.level)
+		if c.level == ColorNone {
+			next.fg, next.bg = "", ""
+		}
+		if next != state {
+			result.WriteString(formatTviewTag(state, next))
+			state = next
 		}
 
 		lastIndex = match[1]
@@ -70,11 +114,14 @@ func escapeTviewTags(s string) string {
 	return tviewTagEscapePattern.ReplaceAllString(s, "$1[]")
 }
 
-func parseSGR(params string, currentFg, currentBg string, currentBold bool) (fg, bg string, bold bool) {
-	fg = currentFg
-	bg = currentBg
-	bold = currentBold
+// basicColorNames are the tview names of the 16 basic terminal colors, which
+// tcell draws with the terminal's own palette.
+var basicColorNames = [16]string{
+	"black", "maroon", "green", "olive", "navy", "purple", "teal", "silver",
+	"gray", "red", "lime", "yellow", "blue", "fuchsia", "aqua", "white",
+}
 
+func parseSGR(params string, st sgrState, level ColorLevel) sgrState {
 	// Per ANSI SGR, an empty parameter list (ESC[m) is equivalent to "0" (reset).
 	if params == "" {
 		params = "0"
@@ -88,52 +135,90 @@ func parseSGR(params string, currentFg, currentBg string, currentBold bool) (fg,
 			continue
 		}
 
-		switch code {
-		case 0:
-			fg = ""
-			bg = ""
-			bold = false
-		case 1:
-			bold = true
-		case 22:
-			bold = false
-		case 38:
-			if i+2 < len(parts) && parts[i+1] == "5" {
-				if colorCode, err := strconv.Atoi(parts[i+2]); err == nil {
-					fg = Ansi256ToHex(colorCode)
-					i += 2
-				}
-			} else if i+4 < len(parts) && parts[i+1] == "2" {
-				r, _ := strconv.Atoi(parts[i+2])
-				g, _ := strconv.Atoi(parts[i+3])
-				b, _ := strconv.Atoi(parts[i+4])
-				fg = fmt.Sprintf("#%02x%02x%02x", r, g, b)
-				i += 4
+		switch {
+		case code == 0:
+			st = sgrState{}
+		case code == 1:
+			st.bold = true
+		case code == 2:
+			st.dim = true
+		case code == 3:
+			st.italic = true
+		case code == 4:
+			st.underline = true
+		case code == 7:
+			st.reverse = true
+		case code == 9:
+			st.crossOut = true
+		case code == 22:
+			st.bold, st.dim = false, false
+		case code == 23:
+			st.italic = false
+		case code == 24:
+			st.underline = false
+		case code == 27:
+			st.reverse = false
+		case code == 29:
+			st.crossOut = false
+		case code >= 30 && code <= 37:
+			st.fg = basicColorNames[code-30]
+		case code >= 90 && code <= 97:
+			st.fg = basicColorNames[code-90+8]
+		case code >= 40 && code <= 47:
+			st.bg = basicColorNames[code-40]
+		case code >= 100 && code <= 107:
+			st.bg = basicColorNames[code-100+8]
+		case code == 38 || code == 48:
+			color, n := parseExtendedColor(parts[i+1:], level)
+			if n == 0 {
+				continue
 			}
-		case 48:
-			if i+2 < len(parts) && parts[i+1] == "5" {
-				if colorCode, err := strconv.Atoi(parts[i+2]); err == nil {
-					bg = Ansi256ToHex(colorCode)
-					i += 2
-				}
-			} else if i+4 < len(parts) && parts[i+1] == "2" {
-				r, _ := strconv.Atoi(parts[i+2])
-				g, _ := strconv.Atoi(parts[i+3])
-				b, _ := strconv.Atoi(parts[i+4])
-				bg = fmt.Sprintf("#%02x%02x%02x", r, g, b)
-				i += 4
+			if code == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
 			}
-		case 39:
-			fg = ""
-		case 49:
-			bg = ""
+			i += n
+		case code == 39:
+			st.fg = ""
+		case code == 49:
+			st.bg = ""
 		}
 	}
 
-	return fg, bg, bold
+	return st
 }
 
-func formatTviewTag(fg, bg string, bold bool) string {
+// parseExtendedColor parses the arguments of a 38 or 48 SGR code ("5;n" or
+// "2;r;g;b") and returns the color and the number of arguments consumed.
+// Output downsampled to 256 or 16 colors uses the first 16 palette entries
+// for the basic colors, so they keep the terminal's own palette; in true
+// color they are the fixed xterm values.
+func parseExtendedColor(args []string, level ColorLevel) (string, int) {
+	if len(args) >= 2 && args[0] == "5" {
+		colorCode, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", 0
+		}
+		if colorCode >= 0 && colorCode < 16 && (level == Color256 || level == Color16) {
+			return basicColorNames[colorCode], 2
+		}
+		return Ansi256ToHex(colorCode), 2
+	}
+	if len(args) >= 4 && args[0] == "2" {
+		r, _ := strconv.Atoi(args[1])
+		g, _ := strconv.Atoi(args[2])
+		b, _ := strconv.Atoi(args[3])
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b), 4
+	}
+	return "", 0
+}
+
+// formatTviewTag returns the tag switching from prev to st. tview attribute
+// flags are additive, so attributes turned off are cleared with their
+// uppercase flag; "-" clears them all.
+func formatTviewTag(prev, st sgrState) string {
+	fg, bg := st.fg, st.bg
 	if fg == "" {
 		fg = "-"
 	}
@@ -141,9 +226,25 @@ func formatTviewTag(fg, bg string, bold bool) string {
 		bg = "-"
 	}
 
-	attr := "-"
-	if bold {
-		attr = "b"
+	attr := ""
+	anyOn := false
+	for _, a := range []struct {
+		was, on bool
+		flag    string
+	}{
+		{prev.bold, st.bold, "b"}, {prev.dim, st.dim, "d"}, {prev.italic, st.italic, "i"},
+		{prev.underline, st.underline, "u"}, {prev.reverse, st.reverse, "r"}, {prev.crossOut, st.crossOut, "s"},
+	} {
+		switch {
+		case a.on:
+			attr += a.flag
+			anyOn = true
+		case a.was:
+			attr += strings.ToUpper(a.flag)
+		}
+	}
+	if !anyOn {
+		attr = "-"
 	}
 
 	return fmt.Sprintf("[%s:%s:%s]", fg, bg, attr)
@@ -174,6 +174,100 @@ func TestAnsiConverter_CombinedSequence(t *testing.T) {
 	}
 }
 
+func TestAnsiConverter_BasicColors(t *testing.T) {
+	c := NewAnsiConverter(true)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"foreground 31", "\x1b[31mred", "[maroon:-:-]red"},
+		{"bright foreground 92", "\x1b[92mgreen", "[lime:-:-]green"},
+		{"background 44", "\x1b[44mblue", "[-:navy:-]blue"},
+		{"bright background 107", "\x1b[107mwhite", "[-:white:-]white"},
+		{"256-color below 16 is the xterm color", "\x1b[38;5;3mx", "[#808000:-:-]x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Convert(tt.input)
+			if got != tt.want {
+				t.Errorf("got:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnsiConverter_Attributes(t *testing.T) {
+	c := NewAnsiConverter(true)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"italic and underline", "\x1b[3;4mx", "[-:-:iu]x"},
+		{"reverse", "\x1b[7mx\x1b[27my", "[-:-:r]x[-:-:-]y"},
+		{"turning one attribute off clears it", "\x1b[1;3mx\x1b[23my", "[-:-:bi]x[-:-:bI]y"},
+		{"strikethrough and dim", "\x1b[2;9mx", "[-:-:ds]x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Convert(tt.input)
+			if got != tt.want {
+				t.Errorf("got:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnsiConverter_DownsampledUsesPalette(t *testing.T) {
+	for _, level := range []ColorLevel{Color256, Color16} {
+		c := NewAnsiConverter(true)
+		c.SetColorLevel(level)
+		if got, want := c.Convert("\x1b[38;5;3;48;5;196mx"), "[olive:#ff0000:-]x"; got != want {
+			t.Errorf("level %d: got %q, want %q", level, got, want)
+		}
+	}
+}
+
+func TestAnsiConverter_Monochrome(t *testing.T) {
+	c := NewAnsiConverter(true)
+	c.SetColorLevel(ColorNone)
+
+	input := "\x1b[38;5;196;48;2;0;0;255mplain\x1b[1;4;31mlink\x1b[0m"
+	want := "plain[-:-:bu]link[-:-:-]"
+	got := c.Convert(input)
+	if got != want {
+		t.Errorf("got:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestAnsiConverter_Hyperlinks(t *testing.T) {
+	c := NewAnsiConverter(true)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"ST terminated", "a \x1b]8;;https://x.org/p\x1b\\\x1b[4mlink\x1b[0m\x1b]8;;\x1b\\ b", "a [:::https://x.org/p][-:-:u]link[-:-:-][:::-] b"},
+		{"BEL terminated with id", "\x1b]8;id=7;file:///tmp/a.md\x07x\x1b]8;;\x07", "[:::file:///tmp/a.md]x[:::-]"},
+		{"brackets escaped", "\x1b]8;;https://x.org/a[1]\x1b\\x", "[:::https://x.org/a%5B1%5D]x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Convert(tt.input)
+			if got != tt.want {
+				t.Errorf("got:  %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAnsi256ToRGB(t *testing.T) {
 	tests := []struct {
 		code    int
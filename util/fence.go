@@ -0,0 +1,16 @@
+package util
+
+// LongestRun returns the length of the longest run of ch in s, so a markdown
+// fence around s can be made longer than any fence inside it.
+func LongestRun(s string, ch byte) int {
+	longest, cur := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == ch {
+			cur++
+			longest = max(longest, cur)
+		} else {
+			cur = 0
+		}
+	}
+	return longest
+}
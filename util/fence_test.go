@@ -0,0 +1,20 @@
+package util
+
+import "testing"
+
+func TestLongestRun(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"no ticks", 0},
+		{"a `b` c", 1},
+		{"```go\n`x`\n````", 4},
+	}
+	for _, tt := range tests {
+		if got := LongestRun(tt.s, '`'); got != tt.want {
+			t.Errorf("LongestRun(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}